@@ -0,0 +1,235 @@
+// Package balancer implements client-side load balancing over the instances
+// discovered in the cloud registry.
+//
+// Example:
+//
+//	lb, err := balancer.New(ctx, registry, &cloudregistry.ServicePrefix{Name: "billing"},
+//		balancer.WithPicker(balancer.NewLeastOutstanding()))
+//	if err != nil {
+//		return err
+//	}
+//	defer lb.Close()
+//
+//	svc, done, err := lb.Pick(ctx, "")
+//	if err != nil {
+//		return err
+//	}
+//	err = callService(svc.Hostname, svc.Port)
+//	done(err)
+package balancer
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+const (
+	defaultRefreshInterval = 10 * time.Second
+	defaultTTL             = 30 * time.Second
+)
+
+// ErrNoInstances is returned when there are no instances to pick from.
+var ErrNoInstances = errors.New("no service instances available")
+
+// Option is a configuration option for the Balancer.
+type Option func(b *Balancer)
+
+// WithPicker sets the instance picker, round-robin is used by default.
+func WithPicker(picker Picker) Option {
+	return func(b *Balancer) {
+		b.picker = picker
+	}
+}
+
+// WithRefreshInterval sets the interval of the instance list refresh
+// used if the registry does not support service watching or while the closed watch is restored.
+func WithRefreshInterval(interval time.Duration) Option {
+	return func(b *Balancer) {
+		b.refreshInterval = interval
+	}
+}
+
+// WithTTL sets the TTL passed to the registry Discover method.
+func WithTTL(ttl time.Duration) Option {
+	return func(b *Balancer) {
+		b.ttl = ttl
+	}
+}
+
+// Balancer keeps the set of service instances fresh and picks the instance for each request.
+type Balancer struct {
+	registry        cloudregistry.Registry
+	prefix          *cloudregistry.ServicePrefix
+	picker          Picker
+	refreshInterval time.Duration
+	ttl             time.Duration
+
+	mx        sync.RWMutex
+	instances []*cloudregistry.ServiceInfo
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New creates a new balancer for the service prefix and loads the initial set of instances.
+// The set is updated from the registry watch if it implements cloudregistry.ServiceWatcher,
+// otherwise it is refreshed periodically with Discover.
+func New(ctx context.Context, registry cloudregistry.Registry, prefix *cloudregistry.ServicePrefix, options ...Option) (*Balancer, error) {
+	b := &Balancer{
+		registry:        registry,
+		prefix:          prefix,
+		refreshInterval: defaultRefreshInterval,
+		ttl:             defaultTTL,
+	}
+	for _, option := range options {
+		option(b)
+	}
+	if b.picker == nil {
+		b.picker = NewRoundRobin()
+	}
+
+	bgCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	b.cancel = cancel
+
+	events, err := cloudregistry.WatchServices(bgCtx, registry, prefix)
+	switch {
+	case err == nil:
+		instances := map[string]*cloudregistry.ServiceInfo{}
+		// Wait for the initial snapshot so the balancer is ready to pick
+		select {
+		case <-ctx.Done():
+			cancel()
+			return nil, ctx.Err()
+		case event, ok := <-events:
+			if ok {
+				b.apply(instances, event)
+			}
+		}
+		b.wg.Add(1)
+		go b.watch(bgCtx, events, instances)
+	case errors.Is(err, cloudregistry.ErrWatchNotSupported):
+		if err := b.Refresh(ctx); err != nil {
+			cancel()
+			return nil, err
+		}
+		b.wg.Add(1)
+		go b.poll(bgCtx)
+	default:
+		cancel()
+		return nil, err
+	}
+	return b, nil
+}
+
+// Pick selects the service instance for the request key.
+// The returned DoneFunc must be called with the result of the request.
+func (b *Balancer) Pick(ctx context.Context, key string) (*cloudregistry.ServiceInfo, DoneFunc, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	svc, done := b.picker.Pick(key)
+	if svc == nil {
+		return nil, nil, ErrNoInstances
+	}
+	return svc, done, nil
+}
+
+// Instances returns the current set of the service instances.
+func (b *Balancer) Instances() []*cloudregistry.ServiceInfo {
+	b.mx.RLock()
+	defer b.mx.RUnlock()
+	return b.instances
+}
+
+// Refresh reloads the set of instances from the registry.
+func (b *Balancer) Refresh(ctx context.Context) error {
+	services, err := b.registry.Discover(ctx, b.prefix, b.ttl)
+	if err != nil && !errors.Is(err, cloudregistry.ErrNotFound) {
+		return err
+	}
+	b.update(services)
+	return nil
+}
+
+// Close stops the instance set updates.
+func (b *Balancer) Close() error {
+	b.cancel()
+	b.wg.Wait()
+	return nil
+}
+
+func (b *Balancer) update(instances []*cloudregistry.ServiceInfo) {
+	b.mx.Lock()
+	b.instances = instances
+	b.mx.Unlock()
+	b.picker.Update(instances)
+}
+
+// watch applies the watch events until the balancer is closed.
+// If the watch ends earlier, the instances are refreshed with Discover until it is subscribed again.
+func (b *Balancer) watch(ctx context.Context, events <-chan cloudregistry.ServiceEvent, instances map[string]*cloudregistry.ServiceInfo) {
+	defer b.wg.Done()
+	for events != nil {
+		for event := range events {
+			b.apply(instances, event)
+		}
+		events = b.resubscribe(ctx)
+	}
+}
+
+// resubscribe polls the instances every refresh interval until the watch is subscribed again,
+// nil is returned when the context is done. The new watch starts with the snapshot.
+func (b *Balancer) resubscribe(ctx context.Context) <-chan cloudregistry.ServiceEvent {
+	for {
+		// Keep the last known instances on temporary errors
+		_ = b.Refresh(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(b.refreshInterval):
+		}
+		if events, err := cloudregistry.WatchServices(ctx, b.registry, b.prefix); err == nil {
+			return events
+		}
+	}
+}
+
+func (b *Balancer) apply(instances map[string]*cloudregistry.ServiceInfo, event cloudregistry.ServiceEvent) {
+	switch event.Type {
+	case cloudregistry.ServiceSnapshot:
+		clear(instances)
+		for _, svc := range event.Services {
+			instances[svc.InstanceID] = svc
+		}
+	case cloudregistry.ServiceAdded, cloudregistry.ServiceUpdated:
+		instances[event.Service.InstanceID] = event.Service
+	case cloudregistry.ServiceRemoved:
+		delete(instances, event.Service.InstanceID)
+	}
+	list := make([]*cloudregistry.ServiceInfo, 0, len(instances))
+	for _, svc := range instances {
+		list = append(list, svc)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].InstanceID < list[j].InstanceID })
+	b.update(list)
+}
+
+func (b *Balancer) poll(ctx context.Context) {
+	defer b.wg.Done()
+	ticker := time.NewTicker(b.refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Keep the last known instances on temporary errors
+			_ = b.Refresh(ctx)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package balancer
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/demdxx/cloudregistry"
+	"github.com/demdxx/cloudregistry/dummy"
+	"github.com/demdxx/cloudregistry/memory"
+	"github.com/demdxx/cloudregistry/registrytest"
+)
+
+// pollRegistry is a registry without watch support returning the configured instances.
+type pollRegistry struct {
+	cloudregistry.Registry
+
+	mx        sync.Mutex
+	instances []*cloudregistry.ServiceInfo
+}
+
+func (r *pollRegistry) set(instances []*cloudregistry.ServiceInfo) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.instances = instances
+}
+
+func (r *pollRegistry) Discover(ctx context.Context, prefix *cloudregistry.ServicePrefix, TTL time.Duration) ([]*cloudregistry.ServiceInfo, error) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if len(r.instances) == 0 {
+		return nil, cloudregistry.ErrNotFound
+	}
+	return r.instances, nil
+}
+
+func registerInstances(t *testing.T, registry cloudregistry.Registry, instances ...*cloudregistry.ServiceInfo) {
+	t.Helper()
+	for _, svc := range instances {
+		err := registry.Register(context.Background(), &cloudregistry.Service{
+			Name: svc.Name, InstanceID: svc.InstanceID, Hostname: svc.Hostname, Port: svc.Port,
+		})
+		if err != nil {
+			t.Fatalf("Register() error = %v", err)
+		}
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition was not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestBalancer_Poll(t *testing.T) {
+	ctx := context.Background()
+	registry := &pollRegistry{Registry: &dummy.Registry{}}
+
+	lb, err := New(ctx, registry, &cloudregistry.ServicePrefix{Name: "test"},
+		WithRefreshInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer func() { _ = lb.Close() }()
+
+	if _, _, err := lb.Pick(ctx, ""); err != ErrNoInstances {
+		t.Errorf("Pick() error = %v, want %v", err, ErrNoInstances)
+	}
+
+	registry.set(testInstances(2))
+	waitFor(t, func() bool { return len(lb.Instances()) == 2 })
+
+	svc, done, err := lb.Pick(ctx, "")
+	if err != nil || svc == nil {
+		t.Fatalf("Pick() = %v, %v", svc, err)
+	}
+	done(nil)
+}
+
+func TestBalancer_Watch(t *testing.T) {
+	ctx := context.Background()
+	instances := testInstances(3)
+	registry := memory.NewRegistry()
+	registerInstances(t, registry, instances[:2]...)
+
+	lb, err := New(ctx, registry, &cloudregistry.ServicePrefix{Name: "test"},
+		WithPicker(NewConsistentHash(10)))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer func() { _ = lb.Close() }()
+
+	if got := len(lb.Instances()); got != 2 {
+		t.Fatalf("Instances() after snapshot = %d, want 2", got)
+	}
+
+	registerInstances(t, registry, instances[2])
+	_ = registry.Deregister(ctx, &cloudregistry.ServiceID{Name: "test", InstanceID: instances[0].InstanceID})
+	waitFor(t, func() bool {
+		list := lb.Instances()
+		return len(list) == 2 && list[0].InstanceID == "instance-1" && list[1].InstanceID == "instance-2"
+	})
+
+	for i := 0; i < 10; i++ {
+		svc, _, err := lb.Pick(ctx, "key")
+		if err != nil {
+			t.Fatalf("Pick() error = %v", err)
+		}
+		if svc.InstanceID == "instance-0" {
+			t.Errorf("Pick() returned removed instance")
+		}
+	}
+}
+
+func TestBalancer_WatchClosed(t *testing.T) {
+	instances := testInstances(3)
+	registry := memory.NewRegistry()
+	registerInstances(t, registry, instances[0])
+	closer := registrytest.NewWatchCloser(registry, 1)
+	lb, err := New(context.Background(), closer, &cloudregistry.ServicePrefix{Name: "test"},
+		WithRefreshInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer func() { _ = lb.Close() }()
+
+	// The closed watch is resubscribed and the new one delivers the changes
+	waitFor(t, func() bool { return closer.Watches() == 2 })
+	registerInstances(t, registry, instances[1:]...)
+	waitFor(t, func() bool { return len(lb.Instances()) == 3 })
+}
+
+func TestBalancer_PickCanceled(t *testing.T) {
+	registry := &pollRegistry{Registry: &dummy.Registry{}}
+	registry.set(testInstances(1))
+	lb, err := New(context.Background(), registry, &cloudregistry.ServicePrefix{Name: "test"})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer func() { _ = lb.Close() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := lb.Pick(ctx, ""); err != context.Canceled {
+		t.Errorf("Pick() error = %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,291 @@
+package balancer
+
+import (
+	"hash/crc32"
+	"math/rand/v2"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/demdxx/gocast/v2"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+// DoneFunc reports the result of the request sent to the picked instance.
+type DoneFunc func(err error)
+
+func noopDone(error) {}
+
+// Picker selects an instance for the request.
+// Implementations must be safe for concurrent use.
+type Picker interface {
+	// Update replaces the set of instances available for picking.
+	Update(instances []*cloudregistry.ServiceInfo)
+	// Pick selects the instance for the request key, returns nil if there are no instances.
+	Pick(key string) (*cloudregistry.ServiceInfo, DoneFunc)
+}
+
+// instanceList is the base of the pickers which keep a plain list of instances.
+type instanceList struct {
+	mx        sync.RWMutex
+	instances []*cloudregistry.ServiceInfo
+}
+
+func (l *instanceList) Update(instances []*cloudregistry.ServiceInfo) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	l.instances = instances
+}
+
+func (l *instanceList) list() []*cloudregistry.ServiceInfo {
+	l.mx.RLock()
+	defer l.mx.RUnlock()
+	return l.instances
+}
+
+// RoundRobin picks instances one by one in order.
+type RoundRobin struct {
+	instanceList
+	next atomic.Uint64
+}
+
+// NewRoundRobin creates a new round-robin picker.
+func NewRoundRobin() *RoundRobin {
+	return &RoundRobin{}
+}
+
+// Pick selects the next instance.
+func (p *RoundRobin) Pick(_ string) (*cloudregistry.ServiceInfo, DoneFunc) {
+	instances := p.list()
+	if len(instances) == 0 {
+		return nil, noopDone
+	}
+	idx := p.next.Add(1) - 1
+	return instances[idx%uint64(len(instances))], noopDone
+}
+
+// Random picks a random instance.
+type Random struct {
+	instanceList
+}
+
+// NewRandom creates a new random picker.
+func NewRandom() *Random {
+	return &Random{}
+}
+
+// Pick selects a random instance.
+func (p *Random) Pick(_ string) (*cloudregistry.ServiceInfo, DoneFunc) {
+	instances := p.list()
+	if len(instances) == 0 {
+		return nil, noopDone
+	}
+	return instances[rand.IntN(len(instances))], noopDone
+}
+
+// Weighted picks a random instance with probability proportional to the weight
+// stored in the instance Meta by the given key.
+// Instances without the weight or with an invalid one get the default weight,
+// instances with zero or negative weight are never picked.
+type Weighted struct {
+	metaKey       string
+	defaultWeight float64
+
+	mx        sync.RWMutex
+	instances []*cloudregistry.ServiceInfo
+	cumulated []float64
+}
+
+// NewWeighted creates a new weighted picker which reads weights from Meta[metaKey].
+func NewWeighted(metaKey string, defaultWeight float64) *Weighted {
+	return &Weighted{metaKey: metaKey, defaultWeight: defaultWeight}
+}
+
+// Update replaces the set of instances and recalculates the weights.
+func (p *Weighted) Update(instances []*cloudregistry.ServiceInfo) {
+	var (
+		total     float64
+		list      = make([]*cloudregistry.ServiceInfo, 0, len(instances))
+		cumulated = make([]float64, 0, len(instances))
+	)
+	for _, svc := range instances {
+		weight := p.weight(svc)
+		if weight <= 0 {
+			continue
+		}
+		total += weight
+		list = append(list, svc)
+		cumulated = append(cumulated, total)
+	}
+
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	p.instances = list
+	p.cumulated = cumulated
+}
+
+// Pick selects a random instance according to the weights.
+func (p *Weighted) Pick(_ string) (*cloudregistry.ServiceInfo, DoneFunc) {
+	p.mx.RLock()
+	defer p.mx.RUnlock()
+	if len(p.instances) == 0 {
+		return nil, noopDone
+	}
+	point := rand.Float64() * p.cumulated[len(p.cumulated)-1]
+	idx := sort.SearchFloat64s(p.cumulated, point)
+	if idx < len(p.cumulated) && p.cumulated[idx] == point {
+		idx++
+	}
+	if idx >= len(p.instances) {
+		idx = len(p.instances) - 1
+	}
+	return p.instances[idx], noopDone
+}
+
+func (p *Weighted) weight(svc *cloudregistry.ServiceInfo) float64 {
+	val, ok := svc.Meta[p.metaKey]
+	if !ok || val == "" {
+		return p.defaultWeight
+	}
+	weight, err := gocast.TryNumber[float64](val)
+	if err != nil {
+		return p.defaultWeight
+	}
+	return weight
+}
+
+// LeastOutstanding picks the instance with the least number of requests in flight.
+// The request is considered finished when the returned DoneFunc is called.
+type LeastOutstanding struct {
+	mx        sync.RWMutex
+	instances []*cloudregistry.ServiceInfo
+	inflight  map[string]*atomic.Int64
+	next      atomic.Uint64
+}
+
+// NewLeastOutstanding creates a new least-outstanding-requests picker.
+func NewLeastOutstanding() *LeastOutstanding {
+	return &LeastOutstanding{inflight: map[string]*atomic.Int64{}}
+}
+
+// Update replaces the set of instances keeping the counters of the known ones.
+func (p *LeastOutstanding) Update(instances []*cloudregistry.ServiceInfo) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	inflight := make(map[string]*atomic.Int64, len(instances))
+	for _, svc := range instances {
+		if counter, ok := p.inflight[svc.InstanceID]; ok {
+			inflight[svc.InstanceID] = counter
+		} else {
+			inflight[svc.InstanceID] = &atomic.Int64{}
+		}
+	}
+	p.instances = instances
+	p.inflight = inflight
+}
+
+// Pick selects the least loaded instance, ties are resolved in round-robin order.
+func (p *LeastOutstanding) Pick(_ string) (*cloudregistry.ServiceInfo, DoneFunc) {
+	p.mx.RLock()
+	defer p.mx.RUnlock()
+	if len(p.instances) == 0 {
+		return nil, noopDone
+	}
+	var (
+		start   = int(p.next.Add(1) % uint64(len(p.instances)))
+		picked  *cloudregistry.ServiceInfo
+		counter *atomic.Int64
+	)
+	for i := range p.instances {
+		svc := p.instances[(start+i)%len(p.instances)]
+		cnt := p.inflight[svc.InstanceID]
+		if counter == nil || cnt.Load() < counter.Load() {
+			picked, counter = svc, cnt
+		}
+	}
+	counter.Add(1)
+	var once sync.Once
+	return picked, func(error) { once.Do(func() { counter.Add(-1) }) }
+}
+
+// Outstanding returns the number of requests in flight for the instance.
+func (p *LeastOutstanding) Outstanding(instanceID string) int64 {
+	p.mx.RLock()
+	defer p.mx.RUnlock()
+	if counter, ok := p.inflight[instanceID]; ok {
+		return counter.Load()
+	}
+	return 0
+}
+
+// ConsistentHash picks the instance by the request key using a hash ring,
+// so the same key goes to the same instance while the set of instances is stable.
+// Requests with an empty key are distributed randomly.
+type ConsistentHash struct {
+	replicas int
+
+	mx     sync.RWMutex
+	hashes []uint32
+	ring   map[uint32]*cloudregistry.ServiceInfo
+	random Random
+}
+
+// NewConsistentHash creates a new consistent hashing picker with the number of virtual nodes per instance.
+func NewConsistentHash(replicas int) *ConsistentHash {
+	if replicas <= 0 {
+		replicas = 100
+	}
+	return &ConsistentHash{replicas: replicas}
+}
+
+// Update rebuilds the hash ring.
+func (p *ConsistentHash) Update(instances []*cloudregistry.ServiceInfo) {
+	hashes := make([]uint32, 0, len(instances)*p.replicas)
+	ring := make(map[uint32]*cloudregistry.ServiceInfo, len(instances)*p.replicas)
+	for _, svc := range instances {
+		for i := 0; i < p.replicas; i++ {
+			hash := crc32.ChecksumIEEE([]byte(strconv.Itoa(i) + svc.InstanceID))
+			if _, ok := ring[hash]; ok {
+				continue
+			}
+			ring[hash] = svc
+			hashes = append(hashes, hash)
+		}
+	}
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i] < hashes[j] })
+
+	p.random.Update(instances)
+
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	p.hashes = hashes
+	p.ring = ring
+}
+
+// Pick selects the instance owning the key on the hash ring.
+func (p *ConsistentHash) Pick(key string) (*cloudregistry.ServiceInfo, DoneFunc) {
+	if key == "" {
+		return p.random.Pick(key)
+	}
+	p.mx.RLock()
+	defer p.mx.RUnlock()
+	if len(p.hashes) == 0 {
+		return nil, noopDone
+	}
+	hash := crc32.ChecksumIEEE([]byte(key))
+	idx := sort.Search(len(p.hashes), func(i int) bool { return p.hashes[i] >= hash })
+	if idx == len(p.hashes) {
+		idx = 0
+	}
+	return p.ring[p.hashes[idx]], noopDone
+}
+
+var (
+	_ Picker = (*RoundRobin)(nil)
+	_ Picker = (*Random)(nil)
+	_ Picker = (*Weighted)(nil)
+	_ Picker = (*LeastOutstanding)(nil)
+	_ Picker = (*ConsistentHash)(nil)
+)
@@ -0,0 +1,142 @@
+package balancer
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+func testInstances(n int) []*cloudregistry.ServiceInfo {
+	instances := make([]*cloudregistry.ServiceInfo, 0, n)
+	for i := 0; i < n; i++ {
+		instances = append(instances, &cloudregistry.ServiceInfo{
+			Name:       "test",
+			InstanceID: fmt.Sprintf("instance-%d", i),
+			Hostname:   fmt.Sprintf("host-%d", i),
+			Port:       8080,
+		})
+	}
+	return instances
+}
+
+func TestPickers_Empty(t *testing.T) {
+	pickers := map[string]Picker{
+		"round-robin":       NewRoundRobin(),
+		"random":            NewRandom(),
+		"weighted":          NewWeighted("weight", 1),
+		"least-outstanding": NewLeastOutstanding(),
+		"consistent-hash":   NewConsistentHash(10),
+	}
+	for name, picker := range pickers {
+		t.Run(name, func(t *testing.T) {
+			if svc, done := picker.Pick("key"); svc != nil || done == nil {
+				t.Errorf("Pick() on empty picker = %v, want nil instance and non-nil done", svc)
+			}
+			picker.Update(testInstances(3))
+			if svc, _ := picker.Pick("key"); svc == nil {
+				t.Errorf("Pick() after Update() should return an instance")
+			}
+		})
+	}
+}
+
+func TestRoundRobin_Pick(t *testing.T) {
+	picker := NewRoundRobin()
+	picker.Update(testInstances(3))
+
+	for i := 0; i < 6; i++ {
+		svc, _ := picker.Pick("")
+		if want := fmt.Sprintf("instance-%d", i%3); svc.InstanceID != want {
+			t.Errorf("RoundRobin.Pick() #%d = %s, want %s", i, svc.InstanceID, want)
+		}
+	}
+}
+
+func TestWeighted_Pick(t *testing.T) {
+	instances := testInstances(3)
+	instances[0].Meta = map[string]string{"weight": "0"}
+	instances[1].Meta = map[string]string{"weight": "3"}
+	instances[2].Meta = map[string]string{"weight": "invalid"} // default weight 1
+
+	picker := NewWeighted("weight", 1)
+	picker.Update(instances)
+
+	counts := map[string]int{}
+	for i := 0; i < 4000; i++ {
+		svc, _ := picker.Pick("")
+		counts[svc.InstanceID]++
+	}
+	if counts["instance-0"] != 0 {
+		t.Errorf("Weighted.Pick() picked zero weight instance %d times", counts["instance-0"])
+	}
+	if counts["instance-1"] < 2*counts["instance-2"] {
+		t.Errorf("Weighted.Pick() distribution %v does not follow the weights 3:1", counts)
+	}
+}
+
+func TestLeastOutstanding_Pick(t *testing.T) {
+	picker := NewLeastOutstanding()
+	picker.Update(testInstances(2))
+
+	first, done1 := picker.Pick("")
+	second, done2 := picker.Pick("")
+	if first.InstanceID == second.InstanceID {
+		t.Fatalf("LeastOutstanding.Pick() should pick the idle instance, got %s twice", first.InstanceID)
+	}
+
+	done1(nil)
+	done1(nil) // repeated calls must not decrement twice
+	if got := picker.Outstanding(first.InstanceID); got != 0 {
+		t.Errorf("LeastOutstanding.Outstanding(%s) = %d, want 0", first.InstanceID, got)
+	}
+	if got := picker.Outstanding(second.InstanceID); got != 1 {
+		t.Errorf("LeastOutstanding.Outstanding(%s) = %d, want 1", second.InstanceID, got)
+	}
+
+	for i := 0; i < 3; i++ {
+		if svc, done := picker.Pick(""); svc.InstanceID != first.InstanceID {
+			t.Errorf("LeastOutstanding.Pick() = %s, want the idle %s", svc.InstanceID, first.InstanceID)
+		} else {
+			done(nil)
+		}
+	}
+	done2(nil)
+
+	// Counters survive the instance set update
+	_, done := picker.Pick("")
+	picker.Update(testInstances(3))
+	var total int64
+	for _, svc := range testInstances(3) {
+		total += picker.Outstanding(svc.InstanceID)
+	}
+	if total != 1 {
+		t.Errorf("LeastOutstanding keeps %d requests after Update(), want 1", total)
+	}
+	done(nil)
+}
+
+func TestConsistentHash_Pick(t *testing.T) {
+	picker := NewConsistentHash(50)
+	picker.Update(testInstances(5))
+
+	assigned := map[string]string{}
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("user-%d", i)
+		svc, _ := picker.Pick(key)
+		again, _ := picker.Pick(key)
+		if svc.InstanceID != again.InstanceID {
+			t.Fatalf("ConsistentHash.Pick(%s) is not stable: %s != %s", key, svc.InstanceID, again.InstanceID)
+		}
+		assigned[key] = svc.InstanceID
+	}
+
+	// Removing one instance must only move the keys it owned
+	picker.Update(testInstances(4))
+	for key, instanceID := range assigned {
+		svc, _ := picker.Pick(key)
+		if instanceID != "instance-4" && svc.InstanceID != instanceID {
+			t.Errorf("ConsistentHash.Pick(%s) moved from %s to %s", key, instanceID, svc.InstanceID)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package cloudregistry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/demdxx/gocast/v2"
+)
+
+// ErrInvalidBindTarget is returned when Bind target is not a pointer to a struct.
+var ErrInvalidBindTarget = errors.New("bind target must be a pointer to a struct")
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+type bindField struct {
+	key      string
+	index    []int
+	typ      reflect.Type
+	defValue *string
+}
+
+// Binding holds the struct bound to the registry values.
+// Every update creates a new copy of the struct, so Value always returns a consistent snapshot.
+type Binding[T any] struct {
+	mx     sync.Mutex
+	val    atomic.Pointer[T]
+	prefix string
+	fields []bindField
+	// updated is the set of the keys delivered by the subscription while the initial values are loaded,
+	// they are newer than the loaded ones. It is nil after Bind returns.
+	updated map[string]bool
+}
+
+// Bind maps the struct fields onto the registry keys under the prefix and keeps them updated.
+//
+// The fields are mapped by the `registry:"key"` tag, the `default:"value"` tag is used if the key
+// is missing in the registry or deleted, otherwise the field keeps the value from cfg. Nested structs
+// are mapped to the sub-prefixes "key/". Fields without the tag or with `registry:"-"` are not bound.
+//
+//	type Config struct {
+//		Timeout time.Duration `registry:"timeout" default:"5s"`
+//		DB      struct {
+//			Host string `registry:"host" default:"localhost"`
+//			Port int    `registry:"port" default:"5432"`
+//		} `registry:"db"`
+//	}
+//
+// The initial values are loaded with Value and written to cfg, the updates are delivered
+// by the prefix subscription until the context is done and visible only with Binding.Value.
+func Bind[T any](ctx context.Context, client ValueClient, prefix string, cfg *T) (*Binding[T], error) {
+	if cfg == nil || reflect.TypeOf(cfg).Elem().Kind() != reflect.Struct {
+		return nil, ErrInvalidBindTarget
+	}
+	values := client
+	if prefix != "" {
+		values = client.Values(ctx, prefix)
+	}
+
+	binding := &Binding[T]{
+		prefix:  prefix,
+		fields:  bindFields(reflect.TypeOf(cfg).Elem(), "", nil),
+		updated: map[string]bool{},
+	}
+	snapshot := *cfg
+	binding.val.Store(&snapshot)
+	if len(binding.fields) == 0 {
+		return binding, nil
+	}
+
+	// The subscriptions of some backends deliver only the changes, so the initial values are loaded
+	// after subscribing, the changes delivered in the meantime are not overwritten by the loading
+	if err := client.SubscribeValueWithPrefix(ctx, prefix, binding); err != nil {
+		return nil, err
+	}
+	loaded := make([]any, len(binding.fields))
+	for i, field := range binding.fields {
+		value, err := values.Value(ctx, field.key)
+		switch {
+		case errors.Is(err, ErrNotFound):
+			if field.defValue == nil {
+				continue
+			}
+			loaded[i] = *field.defValue
+		case err != nil:
+			return nil, fmt.Errorf("bind %s: %w", field.key, err)
+		default:
+			loaded[i] = value
+		}
+	}
+
+	binding.mx.Lock()
+	defer binding.mx.Unlock()
+	next := *binding.val.Load()
+	target := reflect.ValueOf(&next).Elem()
+	for i, field := range binding.fields {
+		if loaded[i] == nil || binding.updated[field.key] {
+			continue
+		}
+		if err := field.set(target, loaded[i]); err != nil {
+			return nil, err
+		}
+	}
+	binding.val.Store(&next)
+	binding.updated = nil
+	*cfg = next
+	return binding, nil
+}
+
+// Value returns the current snapshot of the struct.
+func (b *Binding[T]) Value() T {
+	return *b.val.Load()
+}
+
+// SetValue updates the field bound to the key, unknown keys are ignored.
+// The key is relative to the client passed to Bind, so it starts with the bound prefix.
+func (b *Binding[T]) SetValue(key string, value any) error {
+	field := b.lookup(key)
+	if field == nil {
+		return nil
+	}
+	if err := checkEncrypted(key, value); err != nil {
+		return err
+	}
+
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	next := *b.val.Load()
+	if err := field.set(reflect.ValueOf(&next).Elem(), value); err != nil {
+		return err
+	}
+	if b.updated != nil {
+		b.updated[field.key] = true
+	}
+	b.val.Store(&next)
+	return nil
+}
+
+// lookup returns the field bound to the key with the prefix trimmed.
+func (b *Binding[T]) lookup(key string) *bindField {
+	name, ok := strings.CutPrefix(key, b.prefix)
+	if !ok {
+		return nil
+	}
+	for i := range b.fields {
+		if b.fields[i].key == name {
+			return &b.fields[i]
+		}
+	}
+	return nil
+}
+
+// set converts the value to the field type, the deleted value resets the field to the default.
+func (f *bindField) set(target reflect.Value, value any) error {
+	if value == nil && f.defValue != nil {
+		value = *f.defValue
+	}
+	converted, err := castBindValue(value, f.typ)
+	if err != nil {
+		return fmt.Errorf("bind %s: %w", f.key, err)
+	}
+	rv := reflect.ValueOf(converted)
+	switch {
+	case !rv.IsValid():
+		rv = reflect.Zero(f.typ)
+	case rv.Type() != f.typ:
+		if !rv.CanConvert(f.typ) {
+			return fmt.Errorf("bind %s: can't convert %s to %s", f.key, rv.Type(), f.typ)
+		}
+		rv = rv.Convert(f.typ)
+	}
+	target.FieldByIndex(f.index).Set(rv)
+	return nil
+}
+
+// bindFields collects the tagged fields of the struct, nested structs are flattened.
+func bindFields(typ reflect.Type, keyPrefix string, index []int) []bindField {
+	var fields []bindField
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name := field.Tag.Get("registry")
+		if name == "" || name == "-" || !field.IsExported() {
+			continue
+		}
+		fieldIndex := append(append([]int{}, index...), i)
+		if field.Type.Kind() == reflect.Struct && field.Type != timeType {
+			fields = append(fields, bindFields(field.Type, keyPrefix+name+"/", fieldIndex)...)
+			continue
+		}
+		bf := bindField{key: keyPrefix + name, index: fieldIndex, typ: field.Type}
+		if def, ok := field.Tag.Lookup("default"); ok {
+			bf.defValue = &def
+		}
+		fields = append(fields, bf)
+	}
+	return fields
+}
+
+func castBindValue(value any, typ reflect.Type) (any, error) {
+	if typ == durationType {
+		// Durations mean the same as in SyncDurationValue, "5s" or the number of seconds
+		return parseDuration(value)
+	}
+	if s, ok := value.(string); ok {
+		switch typ.Kind() {
+		case reflect.Slice, reflect.Map, reflect.Struct:
+			// Composite values are stored as JSON
+			target := reflect.New(typ)
+			if err := json.Unmarshal([]byte(s), target.Interface()); err == nil {
+				return target.Elem().Interface(), nil
+			}
+		}
+	}
+	return gocast.TryToType(value, typ)
+}
+
+var _ Valuer[struct{}] = (*Binding[struct{}])(nil)
@@ -0,0 +1,195 @@
+package cloudregistry
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mapValues is the ValueClient keeping values in the map.
+type mapValues struct {
+	mx     sync.Mutex
+	prefix string
+	values map[string]string
+	subs   map[string]ValueSetter
+}
+
+func newMapValues(values map[string]string) *mapValues {
+	return &mapValues{values: values, subs: map[string]ValueSetter{}}
+}
+
+func (v *mapValues) Values(ctx context.Context, prefix ...string) ValueClient {
+	return &mapValues{prefix: v.prefix + strings.Join(prefix, ""), values: v.values, subs: v.subs}
+}
+
+func (v *mapValues) Value(ctx context.Context, name string) (string, error) {
+	v.mx.Lock()
+	defer v.mx.Unlock()
+	value, ok := v.values[v.prefix+name]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
+
+func (v *mapValues) SetValue(ctx context.Context, name, value string) error {
+	v.mx.Lock()
+	v.values[v.prefix+name] = value
+	var subs []ValueSetter
+	for prefix, sub := range v.subs {
+		if strings.HasPrefix(v.prefix+name, prefix) {
+			subs = append(subs, sub)
+		}
+	}
+	v.mx.Unlock()
+	for _, sub := range subs {
+		_ = sub.SetValue(v.prefix+name, value)
+	}
+	return nil
+}
+
+func (v *mapValues) SubscribeValue(ctx context.Context, name string, val ValueSetter) error {
+	return v.SubscribeValueWithPrefix(ctx, name, val)
+}
+
+func (v *mapValues) SubscribeValueWithPrefix(ctx context.Context, prefix string, val ValueSetter) error {
+	v.mx.Lock()
+	defer v.mx.Unlock()
+	// The keys are delivered relative to the client prefix like the backends do
+	clientPrefix := v.prefix
+	v.subs[v.prefix+prefix] = ValueSetterFunc(func(key string, value any) error {
+		return val.SetValue(strings.TrimPrefix(key, clientPrefix), value)
+	})
+	return nil
+}
+
+type bindConfig struct {
+	Name    string        `registry:"name"`
+	Timeout time.Duration `registry:"timeout" default:"5s"`
+	Tags    []string      `registry:"tags"`
+	Ignored string
+	DB      struct {
+		Host string `registry:"host" default:"localhost"`
+		Port int    `registry:"port" default:"5432"`
+	} `registry:"db"`
+	Port int `registry:"port"`
+}
+
+func TestBind(t *testing.T) {
+	ctx := context.Background()
+	values := newMapValues(map[string]string{
+		"app/name":    "billing",
+		"app/tags":    `["a","b"]`,
+		"app/db/port": "6432",
+		"app/port":    "8080",
+	})
+
+	cfg := bindConfig{Ignored: "keep", Name: "default"}
+	binding, err := Bind(ctx, values, "app/", &cfg)
+	if err != nil {
+		t.Fatalf("Bind() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{name: "loaded", got: cfg.Name, want: "billing"},
+		{name: "default duration", got: cfg.Timeout, want: 5 * time.Second},
+		{name: "json slice", got: strings.Join(cfg.Tags, ","), want: "a,b"},
+		{name: "not bound", got: cfg.Ignored, want: "keep"},
+		{name: "nested default", got: cfg.DB.Host, want: "localhost"},
+		{name: "nested loaded", got: cfg.DB.Port, want: 6432},
+		{name: "same name as nested", got: cfg.Port, want: 8080},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+	if binding.Value().Name != "billing" {
+		t.Errorf("Value().Name = %s, want billing", binding.Value().Name)
+	}
+
+	snapshot := binding.Value()
+	_ = values.SetValue(ctx, "app/db/port", "7432")
+	_ = values.SetValue(ctx, "app/timeout", "1m")
+	_ = values.SetValue(ctx, "app/unknown", "x")
+	_ = values.SetValue(ctx, "app/extra/port", "1")
+
+	updated := binding.Value()
+	if updated.DB.Port != 7432 || updated.Port != 8080 {
+		t.Errorf("updated ports = %d/%d, want 7432/8080", updated.DB.Port, updated.Port)
+	}
+	if updated.Timeout != time.Minute {
+		t.Errorf("updated Timeout = %v, want 1m", updated.Timeout)
+	}
+	if snapshot.DB.Port != 6432 {
+		t.Error("the previous snapshot must not be changed by the update")
+	}
+
+	if err := binding.SetValue("app/port", "not a number"); err == nil {
+		t.Error("SetValue() with invalid value expected error")
+	}
+	if binding.Value().Port != 8080 {
+		t.Error("invalid value must not change the snapshot")
+	}
+	if err := binding.SetValue("port", "1"); err != nil || binding.Value().Port != 8080 {
+		t.Error("the key out of the bound prefix must be ignored")
+	}
+
+	_ = binding.SetValue("app/timeout", float64(30))
+	if got := binding.Value().Timeout; got != 30*time.Second {
+		t.Errorf("numeric Timeout = %v, want 30s as SyncDurationValue reads it", got)
+	}
+	_ = binding.SetValue("app/timeout", nil)
+	_ = binding.SetValue("app/db/host", nil)
+	if got := binding.Value(); got.Timeout != 5*time.Second || got.DB.Host != "localhost" {
+		t.Errorf("deleted values = %v/%s, want the defaults", got.Timeout, got.DB.Host)
+	}
+}
+
+// racingValues changes the value after it is read, before Bind applies it.
+type racingValues struct {
+	*mapValues
+	key, value string
+}
+
+func (v *racingValues) Value(ctx context.Context, name string) (string, error) {
+	value, err := v.mapValues.Value(ctx, name)
+	if name == v.key {
+		_ = v.mapValues.SetValue(ctx, name, v.value)
+	}
+	return value, err
+}
+
+func TestBind_UpdateWhileLoading(t *testing.T) {
+	ctx := context.Background()
+	values := &racingValues{mapValues: newMapValues(map[string]string{"name": "old"}), key: "name", value: "new"}
+	var cfg bindConfig
+	binding, err := Bind(ctx, values, "", &cfg)
+	if err != nil {
+		t.Fatalf("Bind() error = %v", err)
+	}
+	if cfg.Name != "new" || binding.Value().Name != "new" {
+		t.Errorf("Name = %q/%q, want the value changed while loading", cfg.Name, binding.Value().Name)
+	}
+}
+
+func TestBind_Errors(t *testing.T) {
+	ctx := context.Background()
+	var notStruct int
+	if _, err := Bind(ctx, newMapValues(map[string]string{}), "", &notStruct); !errors.Is(err, ErrInvalidBindTarget) {
+		t.Errorf("Bind(int) error = %v, want %v", err, ErrInvalidBindTarget)
+	}
+
+	var cfg bindConfig
+	values := newMapValues(map[string]string{"db/port": "x"})
+	if _, err := Bind(ctx, values, "", &cfg); err == nil || !strings.Contains(err.Error(), "db/port") {
+		t.Errorf("Bind() with invalid value error = %v, want error for db/port", err)
+	}
+}
@@ -0,0 +1,185 @@
+// Command cloudregistry operates the services and values of any registry supported by the library.
+//
+// The registry is selected by the connection URI, like etcd://localhost:2379, consul://localhost:8500
+// or zk://localhost:2181, passed with -registry or the CLOUDREGISTRY_URI environment variable.
+//
+// Usage:
+//
+//	cloudregistry [-registry URI] [-timeout 10s] <command> [flags] [args]
+//
+// Run cloudregistry help for the list of the commands. The exit code is 1 for the failed commands
+// and 2 for the invalid usage.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/demdxx/cloudregistry"
+	_ "github.com/demdxx/cloudregistry/consul"
+	_ "github.com/demdxx/cloudregistry/etcd"
+	_ "github.com/demdxx/cloudregistry/zookeeper"
+)
+
+// errUsage is returned for the invalid arguments, the usage of the command is printed.
+// The flag parsing errors wrap it, they are printed by the flag package.
+var errUsage = errors.New("invalid usage")
+
+// openRegistry opens the registry connection, it is replaced in tests.
+var openRegistry = cloudregistry.Open
+
+// env is the environment of the command.
+type env struct {
+	registry cloudregistry.Registry
+	stdout   io.Writer
+	stderr   io.Writer
+	// timeout limits the commands which do not run until interrupted.
+	timeout time.Duration
+}
+
+// withTimeout returns the context of the single request.
+func (e *env) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if e.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, e.timeout)
+}
+
+type command struct {
+	name  string
+	args  string
+	help  string
+	setup func(fs *flag.FlagSet) func(ctx context.Context, e *env, args []string) error
+}
+
+var commands = []*command{
+	{name: "register", args: "-name NAME -host HOST -port PORT [flags]", help: "register the service instance and keep it registered until interrupted", setup: registerCommand},
+	{name: "deregister", args: "-name NAME -id ID", help: "deregister the service instance", setup: deregisterCommand},
+	{name: "discover", args: "[flags] NAME", help: "list the service instances", setup: discoverCommand},
+	{name: "health", args: "-name NAME -id ID [-status passing] [-output TEXT]", help: "report the health status of the service instance", setup: healthCommand},
+	{name: "services", args: "[-namespace NS] [NAME...]", help: "summarize the instances and their health by service, all services without names", setup: servicesCommand},
+	{name: "get", args: "KEY", help: "print the value", setup: getCommand},
+	{name: "set", args: "KEY VALUE", help: "set the value", setup: setCommand},
+	{name: "ls", args: "[-o table|json] [PREFIX]", help: "list the values with the prefix", setup: lsCommand},
+	{name: "watch", args: "[-prefix] KEY", help: "print the changes of the key or the keys with the prefix until interrupted", setup: watchCommand},
+}
+
+func main() {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	cancel()
+	code := exitCode(err)
+	if code == 1 {
+		fmt.Fprintln(os.Stderr, "cloudregistry:", err)
+	}
+	os.Exit(code)
+}
+
+// exitCode returns 2 for the usage errors, their usage is already printed, and 1 for the runtime errors.
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, errUsage), errors.Is(err, flag.ErrHelp):
+		return 2
+	}
+	return 1
+}
+
+// run parses the global flags and runs the command.
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("cloudregistry", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	uri := fs.String("registry", os.Getenv("CLOUDREGISTRY_URI"), "registry connection URI, like etcd://localhost:2379 (CLOUDREGISTRY_URI)")
+	timeout := fs.Duration("timeout", 10*time.Second, "timeout of the registry requests")
+	fs.Usage = func() { usage(fs) }
+	if err := fs.Parse(args); err != nil {
+		return usageError(err)
+	}
+	if fs.NArg() == 0 || fs.Arg(0) == "help" {
+		fs.Usage()
+		return errUsage
+	}
+
+	cmd := lookupCommand(fs.Arg(0))
+	if cmd == nil {
+		fmt.Fprintf(stderr, "unknown command %q\n\n", fs.Arg(0))
+		fs.Usage()
+		return errUsage
+	}
+	cmdFlags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	cmdFlags.SetOutput(stderr)
+	cmdFlags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: cloudregistry %s %s\n\n%s.\n\n", cmd.name, cmd.args, capitalize(cmd.help))
+		cmdFlags.PrintDefaults()
+	}
+	exec := cmd.setup(cmdFlags)
+	if err := cmdFlags.Parse(fs.Args()[1:]); err != nil {
+		return usageError(err)
+	}
+
+	if *uri == "" {
+		fmt.Fprintln(stderr, "the registry URI is required, set -registry or CLOUDREGISTRY_URI")
+		return errUsage
+	}
+	openCtx, cancel := context.WithTimeout(ctx, *timeout)
+	registry, err := openRegistry(openCtx, *uri)
+	cancel()
+	if err != nil {
+		return err
+	}
+	defer registry.Close()
+
+	err = exec(ctx, &env{registry: registry, stdout: stdout, stderr: stderr, timeout: *timeout}, cmdFlags.Args())
+	if errors.Is(err, errUsage) {
+		cmdFlags.Usage()
+	}
+	return err
+}
+
+// usageError marks the flag parsing error as the usage error, flag.ErrHelp is kept as is.
+func usageError(err error) error {
+	if errors.Is(err, flag.ErrHelp) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", errUsage, err)
+}
+
+func lookupCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+func usage(fs *flag.FlagSet) {
+	out := fs.Output()
+	fmt.Fprintf(out, "Usage: cloudregistry [flags] <command> [command flags] [args]\n\n")
+	fmt.Fprintf(out, "Registries: %s\n\n", strings.Join(cloudregistry.Drivers(), ", "))
+	fmt.Fprintln(out, "Commands:")
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %s\t%s\n", cmd.name, cmd.help)
+	}
+	_ = w.Flush()
+	fmt.Fprintln(out, "\nFlags:")
+	fs.PrintDefaults()
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/demdxx/cloudregistry"
+	"github.com/demdxx/cloudregistry/memory"
+)
+
+// sharedRegistry keeps the memory registry open between the commands.
+type sharedRegistry struct {
+	*memory.Registry
+}
+
+func (r sharedRegistry) Close() error { return nil }
+
+// syncBuffer is the output of the commands running in the background.
+type syncBuffer struct {
+	mx  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	return b.buf.String()
+}
+
+func useRegistry(t *testing.T) *memory.Registry {
+	t.Helper()
+	registry := memory.NewRegistry()
+	prev := openRegistry
+	openRegistry = func(ctx context.Context, uri string) (cloudregistry.Registry, error) {
+		return sharedRegistry{registry}, nil
+	}
+	t.Cleanup(func() {
+		openRegistry = prev
+		_ = registry.Close()
+	})
+	return registry
+}
+
+func runCommand(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	var stdout bytes.Buffer
+	err := run(context.Background(), append([]string{"-registry", "memory://"}, args...), &stdout, io.Discard)
+	return stdout.String(), err
+}
+
+func TestValues(t *testing.T) {
+	useRegistry(t)
+
+	if _, err := runCommand(t, "set", "app/db/host", "localhost"); err != nil {
+		t.Fatalf("set error = %v", err)
+	}
+	_, _ = runCommand(t, "set", "app/db/port", "5432")
+
+	if out, err := runCommand(t, "get", "app/db/host"); err != nil || out != "localhost\n" {
+		t.Errorf("get = %q, %v, want localhost", out, err)
+	}
+	if _, err := runCommand(t, "get", "app/missing"); !errors.Is(err, cloudregistry.ErrNotFound) {
+		t.Errorf("get of missing key error = %v, want ErrNotFound", err)
+	}
+
+	out, err := runCommand(t, "ls", "app/db/")
+	if err != nil {
+		t.Fatalf("ls error = %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(out), "\n"); len(lines) != 3 ||
+		!strings.HasPrefix(lines[1], "app/db/host") || !strings.HasPrefix(lines[2], "app/db/port") {
+		t.Errorf("ls output:\n%s", out)
+	}
+
+	out, _ = runCommand(t, "ls", "-o", "json", "app/db/p")
+	var values []keyValue
+	if err := json.Unmarshal([]byte(out), &values); err != nil || len(values) != 1 || values[0].Value != "5432" {
+		t.Errorf("ls -o json = %s (%v)", out, err)
+	}
+}
+
+func TestServices(t *testing.T) {
+	useRegistry(t)
+
+	out, err := runCommand(t, "register", "-detach", "-name", "api", "-id", "api-1",
+		"-host", "10.0.0.1", "-port", "8080", "-tag", "v2", "-meta", "zone=a")
+	if err != nil || out != "api-1\n" {
+		t.Fatalf("register = %q, %v", out, err)
+	}
+	_, _ = runCommand(t, "register", "-detach", "-name", "api", "-id", "api-2", "-host", "10.0.0.2")
+	if _, err := runCommand(t, "health", "-name", "api", "-id", "api-2", "-status", "warning", "-output", "slow"); err != nil {
+		t.Fatalf("health error = %v", err)
+	}
+
+	out, err = runCommand(t, "discover", "-tag", "v2", "api")
+	if err != nil {
+		t.Fatalf("discover error = %v", err)
+	}
+	if !strings.Contains(out, "api-1") || !strings.Contains(out, "10.0.0.1:8080") ||
+		!strings.Contains(out, "zone=a") || strings.Contains(out, "api-2") {
+		t.Errorf("discover output:\n%s", out)
+	}
+
+	out, _ = runCommand(t, "discover", "-o", "json", "-passing", "api")
+	var services []*cloudregistry.ServiceInfo
+	if err := json.Unmarshal([]byte(out), &services); err != nil || len(services) != 1 || services[0].InstanceID != "api-1" {
+		t.Errorf("discover -o json -passing = %s (%v)", out, err)
+	}
+
+	_, _ = runCommand(t, "register", "-detach", "-name", "api", "-id", "api-3", "-host", "10.0.0.3")
+	_, _ = runCommand(t, "health", "-name", "api", "-id", "api-3", "-status", "unknown")
+	tests := []struct {
+		args []string
+		want []serviceSummary
+	}{
+		{
+			args: []string{"api", "web"},
+			want: []serviceSummary{{Name: "api", Instances: 3, Passing: 1, Warning: 1, Unknown: 1}, {Name: "web"}},
+		},
+		{want: []serviceSummary{{Name: "api", Instances: 3, Passing: 1, Warning: 1, Unknown: 1}}},
+	}
+	for _, tt := range tests {
+		out, _ = runCommand(t, append([]string{"services", "-o", "json"}, tt.args...)...)
+		var summaries []serviceSummary
+		if err := json.Unmarshal([]byte(out), &summaries); err != nil {
+			t.Fatalf("services %v output %s: %v", tt.args, out, err)
+		}
+		if !slices.Equal(summaries, tt.want) {
+			t.Errorf("services %v = %+v, want %+v", tt.args, summaries, tt.want)
+		}
+	}
+
+	if _, err := runCommand(t, "deregister", "-name", "api", "-id", "api-1"); err != nil {
+		t.Fatalf("deregister error = %v", err)
+	}
+	if out, _ := runCommand(t, "discover", "-o", "json", "-tag", "v2", "api"); strings.TrimSpace(out) != "[]" {
+		t.Errorf("discover after deregister = %s, want []", out)
+	}
+}
+
+func TestServices_ListNotSupported(t *testing.T) {
+	registry := useRegistry(t)
+	openRegistry = func(ctx context.Context, uri string) (cloudregistry.Registry, error) {
+		// The plain Registry hides the ServiceLister of the memory registry
+		return struct{ cloudregistry.Registry }{sharedRegistry{registry}}, nil
+	}
+	if _, err := runCommand(t, "services"); !errors.Is(err, cloudregistry.ErrServiceListNotSupported) {
+		t.Errorf("services error = %v, want %v", err, cloudregistry.ErrServiceListNotSupported)
+	}
+	if _, err := runCommand(t, "services", "api"); err != nil {
+		t.Errorf("services with names error = %v", err)
+	}
+}
+
+func TestRegister_Lifecycle(t *testing.T) {
+	registry := useRegistry(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	var stdout syncBuffer
+	done := make(chan error, 1)
+	go func() {
+		done <- run(ctx, []string{"-registry", "memory://", "register", "-name", "api", "-id", "api-1", "-ttl", "1m"}, &stdout, io.Discard)
+	}()
+
+	waitFor(t, func() bool {
+		_, err := registry.Discover(context.Background(), &cloudregistry.ServicePrefix{Name: "api"}, 0)
+		return err == nil
+	})
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("register error = %v", err)
+	}
+	if _, err := registry.Discover(context.Background(), &cloudregistry.ServicePrefix{Name: "api"}, 0); !errors.Is(err, cloudregistry.ErrNotFound) {
+		t.Errorf("service should be deregistered on interrupt, Discover() error = %v", err)
+	}
+	if out := stdout.String(); !strings.Contains(out, "api-1 registered") {
+		t.Errorf("register output:\n%s", out)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	registry := useRegistry(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	_ = registry.SetValue(ctx, "app/a", "1")
+
+	var stdout syncBuffer
+	done := make(chan error, 1)
+	go func() {
+		done <- run(ctx, []string{"-registry", "memory://", "watch", "-prefix", "app/"}, &stdout, io.Discard)
+	}()
+	waitFor(t, func() bool { return strings.Contains(stdout.String(), "app/a = 1") })
+
+	_ = registry.SetValue(ctx, "app/b", `{"x":1}`)
+	_ = registry.SetValue(ctx, "other", "2")
+	waitFor(t, func() bool { return strings.Contains(stdout.String(), `app/b = {"x":1}`) })
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("watch error = %v", err)
+	}
+	if strings.Contains(stdout.String(), "other") {
+		t.Errorf("watch printed the key out of the prefix:\n%s", stdout.String())
+	}
+}
+
+func TestUsage(t *testing.T) {
+	useRegistry(t)
+	tests := [][]string{
+		{},
+		{"unknown"},
+		{"get"},
+		{"set", "key"},
+		{"deregister", "-name", "api"},
+		{"discover", "-o", "yaml", "api"},
+		{"register", "-meta", "zone", "-name", "api"},
+	}
+	for _, args := range tests {
+		if _, err := runCommand(t, args...); err == nil {
+			t.Errorf("run(%q) should fail", args)
+		}
+	}
+	t.Setenv("CLOUDREGISTRY_URI", "")
+	if err := run(context.Background(), []string{"get", "key"}, io.Discard, io.Discard); !errors.Is(err, errUsage) {
+		t.Errorf("run without registry URI error = %v, want errUsage", err)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	useRegistry(t)
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{args: []string{"set", "key", "value"}, want: 0},
+		{args: []string{"get", "missing"}, want: 1},
+		{args: []string{"get"}, want: 2},
+		{args: []string{"unknown"}, want: 2},
+		{args: []string{"discover", "-o", "yaml", "api"}, want: 2},
+		{args: []string{"-unknown-flag", "get", "key"}, want: 2},
+		{args: []string{"get", "-h"}, want: 2},
+	}
+	for _, tt := range tests {
+		if _, err := runCommand(t, tt.args...); exitCode(err) != tt.want {
+			t.Errorf("run(%q) exit code = %d (%v), want %d", tt.args, exitCode(err), err, tt.want)
+		}
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition is not met in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
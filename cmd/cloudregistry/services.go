@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+// listFlag collects the values of the repeated flag.
+type listFlag []string
+
+func (f *listFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *listFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// metaFlag collects the key=value pairs of the repeated flag.
+type metaFlag map[string]string
+
+func (f metaFlag) String() string {
+	pairs := make([]string, 0, len(f))
+	for key, value := range f {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (f metaFlag) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("invalid metadata %q, want key=value", value)
+	}
+	f[key] = val
+	return nil
+}
+
+// outputFlag is the output format of the listing commands.
+type outputFlag string
+
+func (f *outputFlag) String() string { return string(*f) }
+
+func (f *outputFlag) Set(value string) error {
+	if value != "table" && value != "json" {
+		return fmt.Errorf("unsupported output format %q, want table or json", value)
+	}
+	*f = outputFlag(value)
+	return nil
+}
+
+// serviceFlags binds the flags identifying the service instance.
+func serviceFlags(fs *flag.FlagSet) *cloudregistry.ServiceID {
+	id := &cloudregistry.ServiceID{}
+	fs.StringVar(&id.Name, "name", "", "service name")
+	fs.StringVar(&id.Namespace, "namespace", "", "service namespace")
+	fs.StringVar(&id.Partition, "partition", "", "service partition")
+	fs.StringVar(&id.InstanceID, "id", "", "service instance ID")
+	return id
+}
+
+func registerCommand(fs *flag.FlagSet) func(ctx context.Context, e *env, args []string) error {
+	id := serviceFlags(fs)
+	host := fs.String("host", "", "instance hostname or address")
+	port := fs.Int("port", 0, "instance port")
+	ttl := fs.Duration("ttl", 30*time.Second, "health check TTL, the heartbeats are sent every third of it")
+	checkURL := fs.String("check-url", "", "HTTP health check URL, polled by the registries supporting it")
+	detach := fs.Bool("detach", false, "register once and exit, the instance expires with its TTL or session")
+	tags := &listFlag{}
+	fs.Var(tags, "tag", "instance tag, repeatable")
+	meta := metaFlag{}
+	fs.Var(meta, "meta", "instance metadata key=value, repeatable")
+
+	return func(ctx context.Context, e *env, args []string) error {
+		if id.Name == "" || len(args) > 0 {
+			return errUsage
+		}
+		if id.InstanceID == "" {
+			id.InstanceID = cloudregistry.GenerateInstanceID(id.Name)
+		}
+		service := &cloudregistry.Service{
+			Name:       id.Name,
+			Namespace:  id.Namespace,
+			Partition:  id.Partition,
+			InstanceID: id.InstanceID,
+			Hostname:   *host,
+			Port:       *port,
+			Tags:       *tags,
+			Meta:       meta,
+			Check:      cloudregistry.Check{ID: id.InstanceID, TTL: *ttl},
+		}
+		service.Check.HTTP.URL = *checkURL
+
+		if *detach {
+			reqCtx, cancel := e.withTimeout(ctx)
+			defer cancel()
+			if err := e.registry.Register(reqCtx, service); err != nil {
+				return err
+			}
+			fmt.Fprintln(e.stdout, service.InstanceID)
+			return nil
+		}
+
+		lifecycle := cloudregistry.NewLifecycle(e.registry, service,
+			cloudregistry.WithLifecycleHandler(func(state cloudregistry.LifecycleState, err error) {
+				if err != nil {
+					fmt.Fprintf(e.stderr, "%s %s: %v\n", service.InstanceID, state, err)
+				} else {
+					fmt.Fprintf(e.stdout, "%s %s\n", service.InstanceID, state)
+				}
+			}))
+		// The lifecycle is stopped explicitly to report the deregistration error
+		if err := lifecycle.Start(context.WithoutCancel(ctx)); err != nil {
+			return err
+		}
+		<-ctx.Done()
+		stopCtx, cancel := e.withTimeout(context.Background())
+		defer cancel()
+		return lifecycle.Stop(stopCtx)
+	}
+}
+
+func deregisterCommand(fs *flag.FlagSet) func(ctx context.Context, e *env, args []string) error {
+	id := serviceFlags(fs)
+	return func(ctx context.Context, e *env, args []string) error {
+		if id.Name == "" || id.InstanceID == "" || len(args) > 0 {
+			return errUsage
+		}
+		ctx, cancel := e.withTimeout(ctx)
+		defer cancel()
+		return e.registry.Deregister(ctx, id)
+	}
+}
+
+func discoverCommand(fs *flag.FlagSet) func(ctx context.Context, e *env, args []string) error {
+	namespace := fs.String("namespace", "", "service namespace")
+	partition := fs.String("partition", "", "service partition")
+	passing := fs.Bool("passing", false, "skip the instances with the warning and critical health status")
+	limit := fs.Int("limit", 0, "maximal number of the instances")
+	output := outputFlag("table")
+	fs.Var(&output, "o", "output format: table or json")
+	tags := &listFlag{}
+	fs.Var(tags, "tag", "required instance tag, repeatable")
+	meta := metaFlag{}
+	fs.Var(meta, "meta", "required instance metadata key=value, repeatable")
+
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) != 1 {
+			return errUsage
+		}
+		query := &cloudregistry.DiscoverQuery{Tags: *tags, PassingOnly: *passing, Limit: *limit}
+		if len(meta) > 0 {
+			query.Meta = meta
+		}
+		prefix := &cloudregistry.ServicePrefix{Name: args[0], Namespace: *namespace, Partition: *partition}
+
+		ctx, cancel := e.withTimeout(ctx)
+		defer cancel()
+		services, err := cloudregistry.DiscoverWithQuery(ctx, e.registry, prefix, 0, query)
+		if err != nil && !errors.Is(err, cloudregistry.ErrNotFound) {
+			return err
+		}
+		if output == "json" {
+			if services == nil {
+				services = []*cloudregistry.ServiceInfo{}
+			}
+			return writeJSON(e.stdout, services)
+		}
+		w := tabwriter.NewWriter(e.stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "INSTANCE\tADDRESS\tHEALTH\tTAGS\tMETA")
+		for _, svc := range services {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", svc.InstanceID,
+				address(svc.Hostname, svc.Port), svc.Health.Status,
+				strings.Join(svc.Tags, ","), metaFlag(svc.Meta))
+		}
+		return w.Flush()
+	}
+}
+
+func healthCommand(fs *flag.FlagSet) func(ctx context.Context, e *env, args []string) error {
+	id := serviceFlags(fs)
+	status := fs.String("status", "passing", "health status: passing, warning or critical")
+	output := fs.String("output", "", "description of the status")
+	return func(ctx context.Context, e *env, args []string) error {
+		if id.Name == "" || id.InstanceID == "" || len(args) > 0 {
+			return errUsage
+		}
+		health, err := cloudregistry.ParseHealthStatus(*status)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := e.withTimeout(ctx)
+		defer cancel()
+		return cloudregistry.ReportHealth(ctx, e.registry, id, health, *output)
+	}
+}
+
+// serviceSummary is the number of the instances of the service by health status.
+type serviceSummary struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	Instances int    `json:"instances"`
+	Passing   int    `json:"passing"`
+	Warning   int    `json:"warning"`
+	Critical  int    `json:"critical"`
+	Unknown   int    `json:"unknown"`
+}
+
+// servicesCommand summarizes the named services, without the names it lists all services
+// of the registries implementing ServiceLister.
+func servicesCommand(fs *flag.FlagSet) func(ctx context.Context, e *env, args []string) error {
+	namespace := fs.String("namespace", "", "services namespace")
+	output := outputFlag("table")
+	fs.Var(&output, "o", "output format: table or json")
+	return func(ctx context.Context, e *env, args []string) error {
+		ctx, cancel := e.withTimeout(ctx)
+		defer cancel()
+
+		prefixes := make([]*cloudregistry.ServicePrefix, 0, len(args))
+		for _, name := range args {
+			prefixes = append(prefixes, &cloudregistry.ServicePrefix{Name: name, Namespace: *namespace})
+		}
+		if len(args) == 0 {
+			var err error
+			if prefixes, err = cloudregistry.ListServices(ctx, e.registry, *namespace); err != nil {
+				if errors.Is(err, cloudregistry.ErrServiceListNotSupported) {
+					return fmt.Errorf("%w by the registry, pass the service names", err)
+				}
+				return err
+			}
+		}
+
+		summaries := make([]*serviceSummary, 0, len(prefixes))
+		for _, prefix := range prefixes {
+			services, err := e.registry.Discover(ctx, prefix, 0)
+			if err != nil && !errors.Is(err, cloudregistry.ErrNotFound) {
+				return fmt.Errorf("discover %s: %w", prefix.Name, err)
+			}
+			summary := &serviceSummary{Name: prefix.Name, Namespace: prefix.Namespace, Instances: len(services)}
+			for _, svc := range services {
+				switch svc.Health.Status {
+				case cloudregistry.HealthPassing:
+					summary.Passing++
+				case cloudregistry.HealthWarning:
+					summary.Warning++
+				case cloudregistry.HealthCritical:
+					summary.Critical++
+				default:
+					summary.Unknown++
+				}
+			}
+			summaries = append(summaries, summary)
+		}
+
+		if output == "json" {
+			return writeJSON(e.stdout, summaries)
+		}
+		w := tabwriter.NewWriter(e.stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SERVICE\tINSTANCES\tPASSING\tWARNING\tCRITICAL\tUNKNOWN")
+		for _, s := range summaries {
+			name := s.Name
+			if s.Namespace != "" {
+				name = s.Namespace + "/" + s.Name
+			}
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\n", name, s.Instances, s.Passing, s.Warning, s.Critical, s.Unknown)
+		}
+		return w.Flush()
+	}
+}
+
+func address(host string, port int) string {
+	if port == 0 {
+		return host
+	}
+	return host + ":" + strconv.Itoa(port)
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+func getCommand(fs *flag.FlagSet) func(ctx context.Context, e *env, args []string) error {
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) != 1 {
+			return errUsage
+		}
+		ctx, cancel := e.withTimeout(ctx)
+		defer cancel()
+		value, err := e.registry.Value(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(e.stdout, value)
+		return nil
+	}
+}
+
+func setCommand(fs *flag.FlagSet) func(ctx context.Context, e *env, args []string) error {
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) != 2 {
+			return errUsage
+		}
+		ctx, cancel := e.withTimeout(ctx)
+		defer cancel()
+		return e.registry.SetValue(ctx, args[0], args[1])
+	}
+}
+
+// keyValue is the JSON output of the listed value.
+type keyValue struct {
+	Key     string `json:"key"`
+	Value   string `json:"value"`
+	Version uint64 `json:"version"`
+}
+
+func lsCommand(fs *flag.FlagSet) func(ctx context.Context, e *env, args []string) error {
+	output := outputFlag("table")
+	fs.Var(&output, "o", "output format: table or json")
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) > 1 {
+			return errUsage
+		}
+		prefix := ""
+		if len(args) == 1 {
+			prefix = args[0]
+		}
+		ctx, cancel := e.withTimeout(ctx)
+		defer cancel()
+		values, err := cloudregistry.ListValues(ctx, e.registry, prefix)
+		if err != nil {
+			return err
+		}
+		if output == "json" {
+			list := make([]keyValue, 0, len(values))
+			for _, kv := range values {
+				list = append(list, keyValue{Key: kv.Key, Value: kv.Value, Version: kv.Version})
+			}
+			return writeJSON(e.stdout, list)
+		}
+		w := tabwriter.NewWriter(e.stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "KEY\tVERSION\tVALUE")
+		for _, kv := range values {
+			fmt.Fprintf(w, "%s\t%d\t%s\n", kv.Key, kv.Version, kv.Value)
+		}
+		return w.Flush()
+	}
+}
+
+// watchCommand prints the raw values, the subscriptions of some backends deliver only the changes.
+func watchCommand(fs *flag.FlagSet) func(ctx context.Context, e *env, args []string) error {
+	prefix := fs.Bool("prefix", false, "watch all keys with the prefix")
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) != 1 {
+			return errUsage
+		}
+		var mx sync.Mutex
+		printer := cloudregistry.ValueSetterFunc(func(key string, value any) error {
+			mx.Lock()
+			defer mx.Unlock()
+			_, err := fmt.Fprintf(e.stdout, "%s %s = %v\n", time.Now().Format(time.RFC3339), key, value)
+			return err
+		})
+
+		values := cloudregistry.WithCodec(e.registry, cloudregistry.StringCodec)
+		var err error
+		if *prefix {
+			err = values.SubscribeValueWithPrefix(ctx, args[0], printer)
+		} else {
+			err = values.SubscribeValue(ctx, args[0], printer)
+		}
+		if err != nil {
+			return err
+		}
+		<-ctx.Done()
+		return nil
+	}
+}
@@ -0,0 +1,264 @@
+package cloudregistry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/demdxx/gocast/v2"
+)
+
+// Codec encodes and decodes the registry values.
+// Decoded values are delivered to the ValueSetter of the subscriptions.
+type Codec interface {
+	// Name returns the codec name, like "json".
+	Name() string
+	// Encode encodes the value to the raw registry value.
+	Encode(v any) ([]byte, error)
+	// Decode decodes the raw registry value.
+	Decode(data []byte) (any, error)
+}
+
+// CodecClient is implemented by the value clients with the configurable codec.
+type CodecClient interface {
+	// Codec returns the codec of the client.
+	Codec() Codec
+	// WithCodec returns the value client with the same prefix using the codec.
+	WithCodec(codec Codec) ValueClient
+}
+
+var (
+	// DefaultCodec decodes JSON values and falls back to the raw string,
+	// strings are encoded as is and other values as JSON. All backends use it by default.
+	DefaultCodec Codec = autoCodec{}
+	// JSONCodec encodes and decodes the values as JSON, invalid JSON is a decoding error.
+	JSONCodec Codec = jsonCodec{}
+	// StringCodec delivers the values as strings.
+	StringCodec Codec = stringCodec{}
+	// BytesCodec delivers the values as raw bytes.
+	BytesCodec Codec = bytesCodec{}
+)
+
+var (
+	codecsMx sync.RWMutex
+	codecs   = map[string]Codec{}
+)
+
+func init() {
+	for _, codec := range []Codec{DefaultCodec, JSONCodec, StringCodec, BytesCodec} {
+		RegisterCodec(codec)
+	}
+}
+
+// RegisterCodec makes a codec available by its name with LookupCodec.
+// If RegisterCodec is called twice with the same name or if codec is nil, it panics.
+func RegisterCodec(codec Codec) {
+	if codec == nil {
+		panic("cloudregistry: RegisterCodec codec is nil")
+	}
+	name := strings.ToLower(codec.Name())
+
+	codecsMx.Lock()
+	defer codecsMx.Unlock()
+
+	if _, dup := codecs[name]; dup {
+		panic("cloudregistry: RegisterCodec called twice for codec " + name)
+	}
+	codecs[name] = codec
+}
+
+// LookupCodec returns the codec registered with the name.
+func LookupCodec(name string) (Codec, bool) {
+	codecsMx.RLock()
+	defer codecsMx.RUnlock()
+	codec, ok := codecs[strings.ToLower(name)]
+	return codec, ok
+}
+
+// Codecs returns a sorted list of the registered codec names.
+func Codecs() []string {
+	codecsMx.RLock()
+	defer codecsMx.RUnlock()
+	names := make([]string, 0, len(codecs))
+	for name := range codecs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// CodecOf returns the codec of the value client, DefaultCodec if the client has no codec.
+func CodecOf(client ValueClient) Codec {
+	if cc, ok := client.(CodecClient); ok {
+		return cc.Codec()
+	}
+	return DefaultCodec
+}
+
+// WithCodec returns the value client using the codec for the subscriptions and typed values.
+// Clients without the codec support are wrapped, the raw values of the notified keys are read
+// and decoded with the codec.
+func WithCodec(client ValueClient, codec Codec) ValueClient {
+	if cc, ok := client.(CodecClient); ok {
+		return cc.WithCodec(codec)
+	}
+	return &codecValueClient{ValueClient: client, codec: codec}
+}
+
+// TypedValue returns the value decoded by the client codec and cast to the type.
+func TypedValue[T any](ctx context.Context, client ValueClient, name string) (T, error) {
+	var zero T
+	value, err := client.Value(ctx, name)
+	if err != nil {
+		return zero, err
+	}
+	if err := checkEncrypted(name, value); err != nil {
+		return zero, err
+	}
+	decoded, err := CodecOf(client).Decode([]byte(value))
+	if err != nil {
+		return zero, err
+	}
+	return gocast.TryCast[T](decoded)
+}
+
+// SetTypedValue sets the value encoded by the client codec.
+func SetTypedValue[T any](ctx context.Context, client ValueClient, name string, value T) error {
+	data, err := CodecOf(client).Encode(value)
+	if err != nil {
+		return err
+	}
+	return client.SetValue(ctx, name, string(data))
+}
+
+// DecodeValueSetter wraps the setter to decode the raw values with the codec,
+// the values which can't be decoded are skipped. It is intended for the backend implementations.
+// The nil data is the deleted key, it is delivered as the nil value, the empty values must be non-nil.
+func DecodeValueSetter(codec Codec, val ValueSetter) func(key string, data []byte) error {
+	return func(key string, data []byte) error {
+		if data == nil {
+			return val.SetValue(key, nil)
+		}
+		value, err := codec.Decode(data)
+		if err != nil {
+			return err
+		}
+		return val.SetValue(key, value)
+	}
+}
+
+type autoCodec struct{}
+
+func (autoCodec) Name() string { return "auto" }
+
+func (autoCodec) Encode(v any) ([]byte, error) {
+	switch val := v.(type) {
+	case string:
+		return []byte(val), nil
+	case []byte:
+		return val, nil
+	}
+	return json.Marshal(v)
+}
+
+func (autoCodec) Decode(data []byte) (any, error) {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return string(data), nil
+	}
+	return value, nil
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return "json" }
+
+func (jsonCodec) Encode(v any) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonCodec) Decode(data []byte) (any, error) {
+	var value any
+	err := json.Unmarshal(data, &value)
+	return value, err
+}
+
+type stringCodec struct{}
+
+func (stringCodec) Name() string { return "string" }
+
+func (stringCodec) Encode(v any) ([]byte, error) {
+	s, err := gocast.TryStr(v)
+	if err != nil {
+		return nil, fmt.Errorf("string codec: %w", err)
+	}
+	return []byte(s), nil
+}
+
+func (stringCodec) Decode(data []byte) (any, error) { return string(data), nil }
+
+type bytesCodec struct{}
+
+func (bytesCodec) Name() string { return "bytes" }
+
+func (bytesCodec) Encode(v any) ([]byte, error) {
+	switch val := v.(type) {
+	case []byte:
+		return val, nil
+	case string:
+		return []byte(val), nil
+	}
+	return nil, fmt.Errorf("bytes codec: unsupported value type %T", v)
+}
+
+func (bytesCodec) Decode(data []byte) (any, error) { return slices.Clone(data), nil }
+
+// codecValueClient adds the codec to the value clients without the codec support.
+type codecValueClient struct {
+	ValueClient
+	codec Codec
+}
+
+func (c *codecValueClient) Codec() Codec { return c.codec }
+
+func (c *codecValueClient) WithCodec(codec Codec) ValueClient {
+	return &codecValueClient{ValueClient: c.ValueClient, codec: codec}
+}
+
+func (c *codecValueClient) Values(ctx context.Context, prefix ...string) ValueClient {
+	return &codecValueClient{ValueClient: c.ValueClient.Values(ctx, prefix...), codec: c.codec}
+}
+
+func (c *codecValueClient) SubscribeValue(ctx context.Context, name string, val ValueSetter) error {
+	return c.ValueClient.SubscribeValue(ctx, name, c.recode(ctx, val))
+}
+
+func (c *codecValueClient) SubscribeValueWithPrefix(ctx context.Context, prefix string, val ValueSetter) error {
+	return c.ValueClient.SubscribeValueWithPrefix(ctx, prefix, c.recode(ctx, val))
+}
+
+// recode decodes the raw value of the notified key with the codec. The value decoded by the client
+// can't be encoded back to the same raw value, so the current raw value is read by the key.
+func (c *codecValueClient) recode(ctx context.Context, val ValueSetter) ValueSetter {
+	decode := DecodeValueSetter(c.codec, val)
+	return ValueSetterFunc(func(key string, value any) error {
+		if value == nil {
+			return val.SetValue(key, nil)
+		}
+		raw, err := c.ValueClient.Value(ctx, key)
+		if errors.Is(err, ErrNotFound) {
+			return val.SetValue(key, nil)
+		}
+		if err != nil {
+			return err
+		}
+		return decode(key, []byte(raw))
+	})
+}
+
+var (
+	_ CodecClient = (*codecValueClient)(nil)
+	_ ValueClient = (*codecValueClient)(nil)
+)
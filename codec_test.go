@@ -0,0 +1,132 @@
+package cloudregistry
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestCodecs(t *testing.T) {
+	tests := []struct {
+		codec   Codec
+		data    string
+		want    any
+		wantErr bool
+	}{
+		{codec: DefaultCodec, data: `{"a":1}`, want: map[string]any{"a": float64(1)}},
+		{codec: DefaultCodec, data: "10", want: float64(10)},
+		{codec: DefaultCodec, data: "text", want: "text"},
+		{codec: JSONCodec, data: "[1,2]", want: []any{float64(1), float64(2)}},
+		{codec: JSONCodec, data: "text", wantErr: true},
+		{codec: StringCodec, data: "10", want: "10"},
+		{codec: BytesCodec, data: "10", want: []byte("10")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.codec.Name()+"/"+tt.data, func(t *testing.T) {
+			got, err := tt.codec.Decode([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Decode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Decode() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCodecs_Encode(t *testing.T) {
+	tests := []struct {
+		codec   Codec
+		value   any
+		want    string
+		wantErr bool
+	}{
+		{codec: DefaultCodec, value: "text", want: "text"},
+		{codec: DefaultCodec, value: 10, want: "10"},
+		{codec: DefaultCodec, value: []string{"a"}, want: `["a"]`},
+		{codec: JSONCodec, value: "text", want: `"text"`},
+		{codec: StringCodec, value: 10, want: "10"},
+		{codec: BytesCodec, value: []byte("raw"), want: "raw"},
+		{codec: BytesCodec, value: 10, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := tt.codec.Encode(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s.Encode(%v) error = %v, wantErr %v", tt.codec.Name(), tt.value, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !bytes.Equal(got, []byte(tt.want)) {
+			t.Errorf("%s.Encode(%v) = %s, want %s", tt.codec.Name(), tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestLookupCodec(t *testing.T) {
+	for _, name := range []string{"auto", "json", "string", "bytes"} {
+		if !slices.Contains(Codecs(), name) {
+			t.Errorf("Codecs() = %v, should contain %s", Codecs(), name)
+		}
+	}
+	if codec, ok := LookupCodec("JSON"); !ok || codec != JSONCodec {
+		t.Errorf("LookupCodec(JSON) = %v, %v", codec, ok)
+	}
+	if _, ok := LookupCodec("unknown"); ok {
+		t.Error("LookupCodec(unknown) should not find the codec")
+	}
+}
+
+func TestTypedValue(t *testing.T) {
+	ctx := context.Background()
+	values := newMapValues(map[string]string{})
+
+	if err := SetTypedValue(ctx, values, "list", []int{1, 2}); err != nil {
+		t.Fatalf("SetTypedValue() error = %v", err)
+	}
+	if raw, _ := values.Value(ctx, "list"); raw != "[1,2]" {
+		t.Errorf("raw value = %s, want [1,2]", raw)
+	}
+	list, err := TypedValue[[]int](ctx, values, "list")
+	if err != nil || !slices.Equal(list, []int{1, 2}) {
+		t.Errorf("TypedValue() = %v, %v", list, err)
+	}
+
+	// The client without the codec support is wrapped
+	strValues := WithCodec(values, StringCodec)
+	if CodecOf(strValues) != StringCodec {
+		t.Errorf("CodecOf() = %v, want %v", CodecOf(strValues), StringCodec)
+	}
+	if err := SetTypedValue(ctx, strValues, "num", 10); err != nil {
+		t.Fatalf("SetTypedValue() error = %v", err)
+	}
+
+	var received []any
+	err = strValues.SubscribeValue(ctx, "num", ValueSetterFunc(func(key string, value any) error {
+		received = append(received, value)
+		return nil
+	}))
+	if err != nil {
+		t.Fatalf("SubscribeValue() error = %v", err)
+	}
+	// mapValues delivers the raw strings, the client decoding the JSON string delivers it without the quotes
+	values.values["num"] = `"20"`
+	_ = values.subs["num"].SetValue("num", "20")
+	if want := []any{`"20"`}; !reflect.DeepEqual(received, want) {
+		t.Errorf("received values = %#v, want %#v", received, want)
+	}
+
+	received = nil
+	jsonValues := WithCodec(values, JSONCodec)
+	_ = jsonValues.SubscribeValue(ctx, "text", ValueSetterFunc(func(key string, value any) error {
+		received = append(received, value)
+		return nil
+	}))
+	values.values["text"] = `"value"`
+	_ = values.subs["text"].SetValue("text", "value")
+	delete(values.values, "text")
+	_ = values.subs["text"].SetValue("text", "value")
+	if want := []any{"value", nil}; !reflect.DeepEqual(received, want) {
+		t.Errorf("received values = %#v, want %#v", received, want)
+	}
+}
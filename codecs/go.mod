@@ -0,0 +1,15 @@
+module github.com/demdxx/cloudregistry/codecs
+
+go 1.23.0
+
+toolchain go1.24.4
+
+replace github.com/demdxx/cloudregistry => ../
+
+require (
+	github.com/BurntSushi/toml v1.4.0
+	github.com/demdxx/cloudregistry v0.0.0-00010101000000-000000000000
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require github.com/demdxx/gocast/v2 v2.10.1 // indirect
@@ -0,0 +1,34 @@
+// Package toml provides the TOML codec of the registry values.
+//
+// Importing the package registers the codec with the "toml" name:
+//
+//	import "github.com/demdxx/cloudregistry/codecs/toml"
+//
+//	values := cloudregistry.WithCodec(registry.Values(ctx, "app/"), toml.Codec)
+package toml
+
+import (
+	tomlv1 "github.com/BurntSushi/toml"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+// Codec encodes and decodes the values as TOML documents.
+// TOML documents are tables, so the values are decoded to map[string]any.
+var Codec cloudregistry.Codec = codec{}
+
+func init() {
+	cloudregistry.RegisterCodec(Codec)
+}
+
+type codec struct{}
+
+func (codec) Name() string { return "toml" }
+
+func (codec) Encode(v any) ([]byte, error) { return tomlv1.Marshal(v) }
+
+func (codec) Decode(data []byte) (any, error) {
+	var value map[string]any
+	err := tomlv1.Unmarshal(data, &value)
+	return value, err
+}
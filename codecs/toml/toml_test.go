@@ -0,0 +1,35 @@
+package toml
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+func TestCodec(t *testing.T) {
+	if codec, ok := cloudregistry.LookupCodec("toml"); !ok || codec != Codec {
+		t.Errorf("LookupCodec(toml) = %v, %v", codec, ok)
+	}
+
+	value := map[string]any{
+		"host": "localhost",
+		"port": int64(8080),
+		"db":   map[string]any{"timeout": 1.5, "tags": []any{"a", "b"}},
+	}
+	data, err := Codec.Encode(value)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	got, err := Codec.Decode(data)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, value) {
+		t.Errorf("Decode(Encode()) = %#v, want %#v", got, value)
+	}
+
+	if _, err := Codec.Decode([]byte("key = ")); err == nil {
+		t.Error("Decode() of invalid TOML expected error")
+	}
+}
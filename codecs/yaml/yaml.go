@@ -0,0 +1,33 @@
+// Package yaml provides the YAML codec of the registry values.
+//
+// Importing the package registers the codec with the "yaml" name:
+//
+//	import "github.com/demdxx/cloudregistry/codecs/yaml"
+//
+//	values := cloudregistry.WithCodec(registry.Values(ctx, "app/"), yaml.Codec)
+package yaml
+
+import (
+	yamlv3 "gopkg.in/yaml.v3"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+// Codec encodes and decodes the values as YAML documents.
+var Codec cloudregistry.Codec = codec{}
+
+func init() {
+	cloudregistry.RegisterCodec(Codec)
+}
+
+type codec struct{}
+
+func (codec) Name() string { return "yaml" }
+
+func (codec) Encode(v any) ([]byte, error) { return yamlv3.Marshal(v) }
+
+func (codec) Decode(data []byte) (any, error) {
+	var value any
+	err := yamlv3.Unmarshal(data, &value)
+	return value, err
+}
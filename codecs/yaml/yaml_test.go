@@ -0,0 +1,31 @@
+package yaml
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+func TestCodec(t *testing.T) {
+	if codec, ok := cloudregistry.LookupCodec("yaml"); !ok || codec != Codec {
+		t.Errorf("LookupCodec(yaml) = %v, %v", codec, ok)
+	}
+
+	value := map[string]any{"host": "localhost", "port": 8080, "tags": []any{"a", "b"}}
+	data, err := Codec.Encode(value)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	got, err := Codec.Decode(data)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, value) {
+		t.Errorf("Decode(Encode()) = %#v, want %#v", got, value)
+	}
+
+	if _, err := Codec.Decode([]byte("key: [unclosed")); err == nil {
+		t.Error("Decode() of invalid YAML expected error")
+	}
+}
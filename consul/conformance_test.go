@@ -0,0 +1,29 @@
+package consul
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/demdxx/cloudregistry"
+	"github.com/demdxx/cloudregistry/registrytest"
+)
+
+// TestConformance runs the conformance suite against the server from CLOUDREGISTRY_TEST_CONSUL_URI,
+// for example consul://localhost:8500
+func TestConformance(t *testing.T) {
+	uri := os.Getenv("CLOUDREGISTRY_TEST_CONSUL_URI")
+	if uri == "" {
+		t.Skip("CLOUDREGISTRY_TEST_CONSUL_URI is not set")
+	}
+	// Consul keeps the critical instances in the catalog until DeregisterCriticalServiceAfter,
+	// which is at least one minute, so the expiration is not checked
+	registrytest.Run(t, func(t *testing.T) (cloudregistry.Registry, func(time.Duration)) {
+		registry, err := cloudregistry.Open(context.Background(), uri)
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		return registry, nil
+	}, registrytest.WithSkip("TTLExpiry"))
+}
@@ -0,0 +1,22 @@
+package consul
+
+import (
+	"context"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+func init() {
+	cloudregistry.RegisterDriver("consul", open)
+	cloudregistry.RegisterDriver("consuls", open)
+	cloudregistry.RegisterDriver("consul+http", open)
+	cloudregistry.RegisterDriver("consul+https", open)
+}
+
+func open(ctx context.Context, uri string) (cloudregistry.Registry, error) {
+	registry, err := Connect(ctx, WithURI(uri))
+	if err != nil {
+		return nil, err
+	}
+	return registry, nil
+}
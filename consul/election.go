@@ -0,0 +1,117 @@
+package consul
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+// NewElection creates the leader election candidate based on the Consul session
+// and the KV acquire of the election key, the leader value is stored in the key.
+func (r *Registry) NewElection(ctx context.Context, prefix *cloudregistry.ServicePrefix) (cloudregistry.Election, error) {
+	return &election{client: r.client, key: cloudregistry.ElectionKey(prefix)}, nil
+}
+
+type election struct {
+	client *api.Client
+	key    string
+	lock   *api.Lock
+}
+
+// Campaign waits until the candidate acquires the election key, the elected candidate updates the leader value.
+func (e *election) Campaign(ctx context.Context, value string) error {
+	if e.lock != nil {
+		pair := &api.KVPair{Key: e.key, Value: []byte(value), Flags: api.LockFlagValue}
+		_, err := e.client.KV().Put(pair, (&api.WriteOptions{}).WithContext(ctx))
+		return wrapError(err)
+	}
+	lock, err := e.client.LockOpts(&api.LockOptions{
+		Key:         e.key,
+		Value:       []byte(value),
+		SessionName: "cloudregistry election " + e.key,
+		SessionTTL:  lockSessionTTL,
+	})
+	if err != nil {
+		return err
+	}
+	lost, err := lock.Lock(ctx.Done())
+	if err != nil {
+		return wrapError(err)
+	}
+	if lost == nil {
+		return ctx.Err()
+	}
+	e.lock = lock
+	return nil
+}
+
+// Resign releases the election key and destroys the session.
+func (e *election) Resign(ctx context.Context) error {
+	if e.lock == nil {
+		return cloudregistry.ErrNotLeader
+	}
+	err := e.lock.Unlock()
+	e.lock = nil
+	return wrapError(err)
+}
+
+// Leader returns the value of the election key if it is held by a session.
+func (e *election) Leader(ctx context.Context) (string, error) {
+	pair, _, err := e.client.KV().Get(e.key, (&api.QueryOptions{}).WithContext(ctx))
+	if err != nil {
+		return "", wrapError(err)
+	}
+	if value, ok := leaderValue(pair); ok {
+		return value, nil
+	}
+	return "", cloudregistry.ErrNotFound
+}
+
+// Observe watches the election key with the blocking queries.
+func (e *election) Observe(ctx context.Context) <-chan string {
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		var (
+			leader    string
+			first     = true
+			waitIndex uint64
+		)
+		for {
+			opts := (&api.QueryOptions{WaitIndex: waitIndex}).WithContext(ctx)
+			pair, meta, err := e.client.KV().Get(e.key, opts)
+			if err != nil {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(watchRetryDelay):
+					continue
+				}
+			}
+			waitIndex = meta.LastIndex
+			value, _ := leaderValue(pair)
+			if !first && value == leader {
+				continue
+			}
+			leader, first = value, false
+			select {
+			case ch <- value:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+func leaderValue(pair *api.KVPair) (string, bool) {
+	if pair == nil || pair.Session == "" {
+		return "", false
+	}
+	return string(pair.Value), true
+}
+
+var _ cloudregistry.Elector = (*Registry)(nil)
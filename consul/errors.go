@@ -0,0 +1,48 @@
+package consul
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/hashicorp/consul/api"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+// wrapError maps the Consul HTTP and network errors onto the registry error kinds, the original error stays wrapped.
+func wrapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var statusErr api.StatusError
+	if errors.As(err, &statusErr) {
+		switch code := statusErr.Code; {
+		case code == http.StatusNotFound:
+			return cloudregistry.NewError(cloudregistry.ErrNotFound, err)
+		case code == http.StatusUnauthorized || code == http.StatusForbidden:
+			return cloudregistry.NewError(cloudregistry.ErrPermissionDenied, err)
+		case code == http.StatusConflict:
+			return cloudregistry.NewError(cloudregistry.ErrConflict, err)
+		case code == http.StatusRequestTimeout || code == http.StatusGatewayTimeout:
+			return cloudregistry.NewError(cloudregistry.ErrTimeout, err)
+		case code == http.StatusTooManyRequests || code >= http.StatusInternalServerError:
+			return cloudregistry.NewError(cloudregistry.ErrUnavailable, err)
+		}
+		return err
+	}
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return cloudregistry.NewError(cloudregistry.ErrTimeout, err)
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return cloudregistry.NewError(cloudregistry.ErrTimeout, err)
+	case errors.Is(err, context.Canceled):
+		return err
+	case netErr != nil || api.IsRetryableError(err):
+		return cloudregistry.NewError(cloudregistry.ErrUnavailable, err)
+	}
+	return err
+}
@@ -0,0 +1,49 @@
+package consul
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+func TestWrapError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{name: "not found", err: api.StatusError{Code: http.StatusNotFound}, want: cloudregistry.ErrNotFound},
+		{name: "forbidden", err: api.StatusError{Code: http.StatusForbidden, Body: "ACL not found"}, want: cloudregistry.ErrPermissionDenied},
+		{name: "conflict", err: api.StatusError{Code: http.StatusConflict}, want: cloudregistry.ErrConflict},
+		{name: "too many requests", err: api.StatusError{Code: http.StatusTooManyRequests}, want: cloudregistry.ErrUnavailable},
+		{name: "server error", err: fmt.Errorf("put: %w", api.StatusError{Code: http.StatusInternalServerError}), want: cloudregistry.ErrUnavailable},
+		{name: "gateway timeout", err: api.StatusError{Code: http.StatusGatewayTimeout}, want: cloudregistry.ErrTimeout},
+		{name: "deadline", err: context.DeadlineExceeded, want: cloudregistry.ErrTimeout},
+		{name: "connection refused", err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, want: cloudregistry.ErrUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := wrapError(tt.err)
+			if !errors.Is(got, tt.want) {
+				t.Errorf("wrapError() = %v, want %v", got, tt.want)
+			}
+			if !errors.Is(got, tt.err) {
+				t.Errorf("wrapError() = %v does not wrap %v", got, tt.err)
+			}
+		})
+	}
+
+	if err := wrapError(nil); err != nil {
+		t.Errorf("wrapError(nil) = %v", err)
+	}
+	if err := wrapError(api.StatusError{Code: http.StatusBadRequest}); errors.Is(err, cloudregistry.ErrUnavailable) {
+		t.Errorf("wrapError() of bad request = %v, want unmapped", err)
+	}
+}
@@ -0,0 +1,76 @@
+package consul
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/consul/api"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+// ReportHealth sets the status and output of the TTL checks of the instance.
+// The other checks are run by Consul, so the instances without TTL checks can't report the status.
+func (r *Registry) ReportHealth(ctx context.Context, id *cloudregistry.ServiceID, status cloudregistry.HealthStatus, output string) error {
+	agent := r.client.Agent()
+	checks, err := agent.ChecksWithFilter(fmt.Sprintf("ServiceID == %q and Type == \"ttl\"", id.InstanceID))
+	if err != nil {
+		return wrapError(err)
+	}
+	if len(checks) == 0 {
+		services, err := agent.ServicesWithFilter(fmt.Sprintf("ID == %q", id.InstanceID))
+		if err != nil {
+			return wrapError(err)
+		}
+		if len(services) == 0 {
+			return cloudregistry.ErrNotFound
+		}
+		return cloudregistry.ErrHealthReporterNotSupported
+	}
+	for _, check := range checks {
+		if err := agent.UpdateTTL(check.CheckID, output, checkStatus(status)); err != nil {
+			return wrapError(err)
+		}
+	}
+	return nil
+}
+
+// checksHealth aggregates the checks of the instance, the output is taken from the worst check.
+// Consul does not return the check times, so LastCheck is zero.
+func checksHealth(checks api.HealthChecks) cloudregistry.Health {
+	if len(checks) == 0 {
+		return cloudregistry.Health{Status: cloudregistry.HealthUnknown}
+	}
+	health := cloudregistry.Health{Status: healthStatus(checks.AggregatedStatus())}
+	for _, check := range checks {
+		if healthStatus(check.Status) == health.Status {
+			health.Output = check.Output
+			break
+		}
+	}
+	return health
+}
+
+// healthStatus converts the Consul check status, the maintenance mode is critical.
+func healthStatus(status string) cloudregistry.HealthStatus {
+	switch status {
+	case api.HealthPassing:
+		return cloudregistry.HealthPassing
+	case api.HealthWarning:
+		return cloudregistry.HealthWarning
+	case api.HealthCritical, api.HealthMaint:
+		return cloudregistry.HealthCritical
+	}
+	return cloudregistry.HealthUnknown
+}
+
+// checkStatus converts the status for the TTL check update, the unknown status is critical.
+func checkStatus(status cloudregistry.HealthStatus) string {
+	switch status {
+	case cloudregistry.HealthPassing:
+		return api.HealthPassing
+	case cloudregistry.HealthWarning:
+		return api.HealthWarning
+	}
+	return api.HealthCritical
+}
@@ -0,0 +1,68 @@
+package consul
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+func TestChecksHealth(t *testing.T) {
+	tests := []struct {
+		name   string
+		checks api.HealthChecks
+		want   cloudregistry.Health
+	}{
+		{name: "no checks", want: cloudregistry.Health{Status: cloudregistry.HealthUnknown}},
+		{
+			name: "passing",
+			checks: api.HealthChecks{
+				{Status: api.HealthPassing, Output: "ok"},
+				{Status: api.HealthPassing, Output: "fine"},
+			},
+			want: cloudregistry.Health{Status: cloudregistry.HealthPassing, Output: "ok"},
+		},
+		{
+			name: "warning",
+			checks: api.HealthChecks{
+				{Status: api.HealthPassing, Output: "ok"},
+				{Status: api.HealthWarning, Output: "slow disk"},
+			},
+			want: cloudregistry.Health{Status: cloudregistry.HealthWarning, Output: "slow disk"},
+		},
+		{
+			name: "critical",
+			checks: api.HealthChecks{
+				{Status: api.HealthWarning, Output: "slow disk"},
+				{Status: api.HealthCritical, Output: "TTL expired"},
+			},
+			want: cloudregistry.Health{Status: cloudregistry.HealthCritical, Output: "TTL expired"},
+		},
+		{
+			name:   "maintenance",
+			checks: api.HealthChecks{{CheckID: api.ServiceMaintPrefix + "api", Status: api.HealthCritical, Output: "upgrade"}},
+			want:   cloudregistry.Health{Status: cloudregistry.HealthCritical, Output: "upgrade"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checksHealth(tt.checks); got != tt.want {
+				t.Errorf("checksHealth() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckStatus(t *testing.T) {
+	for status, want := range map[cloudregistry.HealthStatus]string{
+		cloudregistry.HealthPassing:  api.HealthPassing,
+		cloudregistry.HealthWarning:  api.HealthWarning,
+		cloudregistry.HealthCritical: api.HealthCritical,
+		cloudregistry.HealthUnknown:  api.HealthCritical,
+	} {
+		if got := checkStatus(status); got != want {
+			t.Errorf("checkStatus(%s) = %s, want %s", status, got, want)
+		}
+	}
+}
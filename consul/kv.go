@@ -0,0 +1,53 @@
+package consul
+
+import (
+	"context"
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+// DeleteValue deletes the value from the Consul key-value store.
+func (r *Registry) DeleteValue(ctx context.Context, name string) error {
+	_, err := r.client.KV().Delete(r.prefix+name, (&api.WriteOptions{}).WithContext(ctx))
+	return wrapError(err)
+}
+
+// DeletePrefix deletes all values with the prefix from the Consul key-value store.
+func (r *Registry) DeletePrefix(ctx context.Context, prefix string) error {
+	_, err := r.client.KV().DeleteTree(r.prefix+prefix, (&api.WriteOptions{}).WithContext(ctx))
+	return wrapError(err)
+}
+
+// ListValues returns the values with the prefix, the version is the ModifyIndex.
+func (r *Registry) ListValues(ctx context.Context, prefix string) ([]*cloudregistry.KeyValue, error) {
+	pairs, _, err := r.client.KV().List(r.prefix+prefix, (&api.QueryOptions{}).WithContext(ctx))
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	values := make([]*cloudregistry.KeyValue, 0, len(pairs))
+	for _, pair := range pairs {
+		values = append(values, &cloudregistry.KeyValue{
+			Key:     strings.TrimPrefix(pair.Key, r.prefix),
+			Value:   string(pair.Value),
+			Version: pair.ModifyIndex,
+		})
+	}
+	return values, nil
+}
+
+// CompareAndSwap sets the value with the check-and-set operation on the ModifyIndex,
+// the zero version requires the key to be absent.
+func (r *Registry) CompareAndSwap(ctx context.Context, name string, version uint64, value string) (bool, error) {
+	pair := &api.KVPair{
+		Key:         r.prefix + name,
+		Value:       []byte(value),
+		ModifyIndex: version,
+	}
+	ok, _, err := r.client.KV().CAS(pair, (&api.WriteOptions{}).WithContext(ctx))
+	return ok, wrapError(err)
+}
+
+var _ cloudregistry.ValueStore = (*Registry)(nil)
@@ -0,0 +1,90 @@
+package consul
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+const (
+	// lockSessionTTL is the TTL of the lock session renewed while the lock is held.
+	lockSessionTTL = "15s"
+	// lockTryWaitTime limits the blocking query of TryLock.
+	lockTryWaitTime = time.Millisecond
+)
+
+// NewMutex creates the distributed mutex based on the Consul session and the KV acquire.
+// The session is created on every acquisition and destroyed on Unlock.
+func (r *Registry) NewMutex(ctx context.Context, name string) (cloudregistry.Mutex, error) {
+	lost := make(chan struct{})
+	close(lost)
+	return &mutex{client: r.client, key: r.prefix + "locks/" + name, lost: lost}, nil
+}
+
+type mutex struct {
+	client *api.Client
+	key    string
+	lock   *api.Lock
+	lost   <-chan struct{}
+}
+
+// Lock acquires the lock, waiting until it is released or the context is done.
+func (m *mutex) Lock(ctx context.Context) error {
+	return m.acquire(ctx, false)
+}
+
+// TryLock acquires the lock if it is free, otherwise returns ErrLocked.
+func (m *mutex) TryLock(ctx context.Context) error {
+	return m.acquire(ctx, true)
+}
+
+func (m *mutex) acquire(ctx context.Context, try bool) error {
+	if m.lock != nil {
+		return cloudregistry.ErrLocked
+	}
+	opts := &api.LockOptions{
+		Key:         m.key,
+		SessionName: "cloudregistry lock " + m.key,
+		SessionTTL:  lockSessionTTL,
+		LockTryOnce: try,
+	}
+	if try {
+		opts.LockWaitTime = lockTryWaitTime
+	}
+	lock, err := m.client.LockOpts(opts)
+	if err != nil {
+		return err
+	}
+	lost, err := lock.Lock(ctx.Done())
+	if err != nil {
+		return wrapError(err)
+	}
+	if lost == nil {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return cloudregistry.ErrLocked
+	}
+	m.lock, m.lost = lock, lost
+	return nil
+}
+
+// Unlock releases the lock and destroys its session.
+func (m *mutex) Unlock(ctx context.Context) error {
+	if m.lock == nil {
+		return cloudregistry.ErrNotLocked
+	}
+	err := m.lock.Unlock()
+	m.lock = nil
+	return wrapError(err)
+}
+
+// Lost returns the channel closed when the lock is released or its session is invalidated.
+func (m *mutex) Lost() <-chan struct{} {
+	return m.lost
+}
+
+var _ cloudregistry.Locker = (*Registry)(nil)
@@ -0,0 +1,39 @@
+package consul
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+// selectorRegexp matches the metadata keys which can be used as the filter selectors.
+var selectorRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// queryFilter converts the query to the Consul filter expression of the health endpoint.
+// The values of the metadata keys which are not valid selectors are checked on the client side,
+// only their existence is pushed down.
+func queryFilter(query *cloudregistry.DiscoverQuery) string {
+	if query == nil {
+		return ""
+	}
+	var exprs []string
+	for _, tag := range query.Tags {
+		exprs = append(exprs, fmt.Sprintf("%s in Service.Tags", strconv.Quote(tag)))
+	}
+	for _, key := range slices.Sorted(maps.Keys(query.Meta)) {
+		if selectorRegexp.MatchString(key) {
+			exprs = append(exprs, fmt.Sprintf("Service.Meta.%s == %s", key, strconv.Quote(query.Meta[key])))
+		} else {
+			exprs = append(exprs, fmt.Sprintf("%s in Service.Meta", strconv.Quote(key)))
+		}
+	}
+	for _, key := range query.MetaKeys {
+		exprs = append(exprs, fmt.Sprintf("%s in Service.Meta", strconv.Quote(key)))
+	}
+	return strings.Join(exprs, " and ")
+}
@@ -0,0 +1,39 @@
+package consul
+
+import (
+	"testing"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+func TestQueryFilter(t *testing.T) {
+	tests := []struct {
+		name  string
+		query *cloudregistry.DiscoverQuery
+		want  string
+	}{
+		{name: "nil", query: nil, want: ""},
+		{name: "empty", query: cloudregistry.NewDiscoverQuery(cloudregistry.WithLimit(3)), want: ""},
+		{
+			name:  "tags",
+			query: cloudregistry.NewDiscoverQuery(cloudregistry.WithTags("v1", "blue")),
+			want:  `"v1" in Service.Tags and "blue" in Service.Tags`,
+		},
+		{
+			name: "meta",
+			query: cloudregistry.NewDiscoverQuery(
+				cloudregistry.WithMeta("zone", "eu-1"),
+				cloudregistry.WithMeta("app-version", "2"),
+				cloudregistry.WithMetaKeys("canary"),
+			),
+			want: `"app-version" in Service.Meta and Service.Meta.zone == "eu-1" and "canary" in Service.Meta`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := queryFilter(tt.query); got != tt.want {
+				t.Errorf("queryFilter() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
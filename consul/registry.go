@@ -2,8 +2,10 @@ package consul
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,22 +18,24 @@ const defaultWaitTime = 10 * time.Second
 
 // valueWatcherWrapper wraps a ValueSetter with watch parameters.
 type valueWatcherWrapper struct {
-	value     cloudregistry.ValueSetter
+	value     func(key string, data []byte) error
 	key       string
 	waitIndex uint64
 	isPrefix  bool
+	// keys are the existing watched keys, the removed ones are delivered as the nil values
+	keys map[string]bool
 }
 
 // Registry is the Consul registry implementation.
 type Registry struct {
-	watcherWg   sync.WaitGroup
-	watcherOnce sync.Once
-	done        chan struct{}
-
-	client   *api.Client
-	prefix   string
-	watchers chan *valueWatcherWrapper
-	parent   *Registry
+	watcherWg sync.WaitGroup
+	closeOnce sync.Once
+	done      chan struct{}
+
+	client *api.Client
+	prefix string
+	codec  cloudregistry.Codec
+	parent *Registry
 }
 
 // Connect connects to the Consul cloud registry.
@@ -52,9 +56,9 @@ func Connect(ctx context.Context, options ...Option) (*Registry, error) {
 // NewRegistry creates a new Consul registry.
 func NewRegistry(client *api.Client) *Registry {
 	return &Registry{
-		client:   client,
-		done:     make(chan struct{}, 1),
-		watchers: make(chan *valueWatcherWrapper, 10),
+		client: client,
+		done:   make(chan struct{}),
+		codec:  cloudregistry.DefaultCodec,
 	}
 }
 
@@ -69,85 +73,120 @@ func (r *Registry) Register(ctx context.Context, service *cloudregistry.Service)
 		Port:      service.Port,
 		Tags:      service.Tags,
 		Meta:      service.Meta,
-		Check: &api.AgentServiceCheck{
-			CheckID:                        service.Check.ID,
-			TTL:                            fmt.Sprintf("%ds", int(service.Check.TTL.Seconds())),
-			DeregisterCriticalServiceAfter: fmt.Sprintf("%ds", int(service.Check.TTL.Seconds()*3)),
-			HTTP:                           service.Check.HTTP.URL,
-			Method:                         service.Check.HTTP.Method,
-			Header:                         service.Check.HTTP.Headers,
-		},
-	}
-	return r.client.Agent().ServiceRegister(reg)
+		Check:     serviceCheck(&service.Check),
+	}
+	return wrapError(r.client.Agent().ServiceRegister(reg))
+}
+
+// serviceCheck converts the check definition, the HTTP check is polled with the TTL interval.
+// Consul rejects checks without TTL or HTTP endpoint, so the service is registered without a check.
+func serviceCheck(check *cloudregistry.Check) *api.AgentServiceCheck {
+	ttl := fmt.Sprintf("%ds", int(check.TTL.Seconds()))
+	conf := &api.AgentServiceCheck{CheckID: check.ID}
+	switch {
+	case check.HTTP.URL != "":
+		conf.HTTP = check.HTTP.URL
+		conf.Method = check.HTTP.Method
+		conf.Header = check.HTTP.Headers
+		conf.Interval = ttl
+	case check.TTL > 0:
+		conf.TTL = ttl
+	default:
+		return nil
+	}
+	if check.TTL > 0 {
+		conf.DeregisterCriticalServiceAfter = fmt.Sprintf("%ds", int(check.TTL.Seconds()*3))
+	}
+	return conf
 }
 
 // Deregister deregisters a service from the Consul cloud registry.
 func (r *Registry) Deregister(ctx context.Context, id *cloudregistry.ServiceID) error {
-	return r.client.Agent().ServiceDeregister(id.InstanceID)
+	return wrapError(r.client.Agent().ServiceDeregister(id.InstanceID))
 }
 
 // Discover discovers a service in the Consul cloud registry.
+// The instances are read from the health endpoint, so their Health reflects the state of the checks,
+// the instances with failing checks are returned too.
 func (r *Registry) Discover(ctx context.Context, prefix *cloudregistry.ServicePrefix, TTL time.Duration) ([]*cloudregistry.ServiceInfo, error) {
-	services, _, err := r.client.Catalog().Service(prefix.Name, "", nil)
+	services, _, err := r.healthServices(ctx, prefix, nil, 0)
 	if err != nil {
-		return nil, err
+		return nil, wrapError(err)
 	}
-
 	if len(services) == 0 {
 		return nil, cloudregistry.ErrNotFound
 	}
+	return services, nil
+}
 
-	serviceInfos := make([]*cloudregistry.ServiceInfo, 0, len(services))
-	for _, svc := range services {
-		if prefix.Namespace != "" && prefix.Namespace != svc.Namespace {
-			continue
-		}
-		if prefix.Partition != "" && prefix.Partition != svc.Partition {
-			continue
-		}
-		info := &cloudregistry.ServiceInfo{
-			Name:       svc.ServiceName,
-			Namespace:  svc.Namespace,
-			Partition:  svc.Partition,
-			InstanceID: svc.ServiceID,
-			Hostname:   svc.ServiceAddress,
-			Port:       svc.ServicePort,
-			Tags:       svc.ServiceTags,
-			Meta:       svc.ServiceMeta,
-			LastUpdate: time.Now(), // Consul does not provide last update time directly
-			// Populate Public and Private hosts if applicable
-			// This example assumes single host; adjust as needed
-			Public: []cloudregistry.Host{
-				{
-					Hostname: svc.ServiceAddress,
-					Ports: cloudregistry.Ports{
-						"http": fmt.Sprintf("%d", svc.ServicePort),
-					},
-				},
-			},
-			RawInfo: svc, // Store the raw service info for future use
-		}
-		serviceInfos = append(serviceInfos, info)
+// DiscoverWithQuery discovers the services from the health endpoint, the tags and metadata
+// are pushed down as the Consul filter expression and PassingOnly skips the instances with failing checks.
+func (r *Registry) DiscoverWithQuery(ctx context.Context, prefix *cloudregistry.ServicePrefix, TTL time.Duration, query *cloudregistry.DiscoverQuery) ([]*cloudregistry.ServiceInfo, error) {
+	if query == nil {
+		return r.Discover(ctx, prefix, TTL)
 	}
+	services, _, err := r.healthServices(ctx, prefix, query, 0)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	// The limit and the metadata keys not supported by the filter selectors are applied here
+	return cloudregistry.FilterServices(services, query)
+}
 
-	return serviceInfos, nil
+// ListServices returns the services of the Consul catalog in the namespace,
+// the namespaces are supported by Consul Enterprise only.
+func (r *Registry) ListServices(ctx context.Context, namespace string) ([]*cloudregistry.ServicePrefix, error) {
+	opts := &api.QueryOptions{Namespace: namespace}
+	services, _, err := r.client.Catalog().Services(opts.WithContext(ctx))
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	prefixes := make([]*cloudregistry.ServicePrefix, 0, len(services))
+	for _, name := range slices.Sorted(maps.Keys(services)) {
+		prefixes = append(prefixes, &cloudregistry.ServicePrefix{Name: name, Namespace: namespace})
+	}
+	return prefixes, nil
 }
 
 // HealthCheck performs a health check for a service in the Consul cloud registry.
+// TTL checks of the instance are marked as passing, so the call works as a heartbeat,
+// other checks are managed by Consul and ErrNotReady is returned if any of them is critical.
 func (r *Registry) HealthCheck(ctx context.Context, id *cloudregistry.ServiceID, TTL time.Duration) error {
-	// In Consul, health is managed via TTL checks. Here, we can verify the service exists.
-	services, _, err := r.client.Catalog().Service(id.Name, "", nil)
+	agent := r.client.Agent()
+	checks, err := agent.ChecksWithFilter(fmt.Sprintf("ServiceID == %q", id.InstanceID))
 	if err != nil {
-		return err
+		return wrapError(err)
 	}
 
-	for _, svc := range services {
-		if svc.ServiceID == id.InstanceID {
-			return nil
+	if len(checks) == 0 {
+		// The service can be registered without checks
+		services, err := agent.ServicesWithFilter(fmt.Sprintf("ID == %q", id.InstanceID))
+		if err != nil {
+			return wrapError(err)
+		}
+		if len(services) == 0 {
+			return cloudregistry.ErrNotFound
 		}
+		return nil
 	}
 
-	return cloudregistry.ErrNotReady
+	for _, check := range checks {
+		if check.Type == "ttl" {
+			// Keep the warning reported by ReportHealth, the heartbeat only confirms the instance is alive
+			status, output := api.HealthPassing, ""
+			if check.Status == api.HealthWarning {
+				status, output = check.Status, check.Output
+			}
+			if err := agent.UpdateTTL(check.CheckID, output, status); err != nil {
+				return wrapError(err)
+			}
+			continue
+		}
+		if check.Status == api.HealthCritical {
+			return cloudregistry.ErrNotReady
+		}
+	}
+	return nil
 }
 
 // Values returns a ValueClient to interact with the Consul key-value store.
@@ -157,11 +196,27 @@ func (r *Registry) Values(ctx context.Context, prefix ...string) cloudregistry.V
 		newPrefix += prefix[0]
 	}
 	return &Registry{
-		done:     r.done,
-		client:   r.client,
-		prefix:   newPrefix,
-		watchers: r.watchers,
-		parent:   r,
+		done:   r.done,
+		client: r.client,
+		prefix: newPrefix,
+		codec:  r.codec,
+		parent: r,
+	}
+}
+
+// Codec returns the codec decoding the subscription values.
+func (r *Registry) Codec() cloudregistry.Codec {
+	return r.codec
+}
+
+// WithCodec returns a ValueClient with the same prefix using the codec.
+func (r *Registry) WithCodec(codec cloudregistry.Codec) cloudregistry.ValueClient {
+	return &Registry{
+		done:   r.done,
+		client: r.client,
+		prefix: r.prefix,
+		codec:  codec,
+		parent: r,
 	}
 }
 
@@ -170,7 +225,7 @@ func (r *Registry) Value(ctx context.Context, name string) (string, error) {
 	kv := r.client.KV()
 	pair, _, err := kv.Get(r.prefix+name, nil)
 	if err != nil {
-		return "", err
+		return "", wrapError(err)
 	}
 	if pair == nil {
 		return "", cloudregistry.ErrNotFound
@@ -186,78 +241,75 @@ func (r *Registry) SetValue(ctx context.Context, name, value string) error {
 		Value: []byte(value),
 	}
 	_, err := kv.Put(p, nil)
-	return err
+	return wrapError(err)
 }
 
 // SubscribeValue subscribes to a value in the Consul key-value store.
 func (r *Registry) SubscribeValue(ctx context.Context, name string, val cloudregistry.ValueSetter) error {
-	return r.subscriveValue(ctx, r.prefix+name, false, val)
+	return r.subscriveValue(ctx, r.prefix+name, false, r.valueSetter(val))
 }
 
 // SubscribeValueWithPrefix subscribes to values with a specific prefix in the Consul key-value store.
 func (r *Registry) SubscribeValueWithPrefix(ctx context.Context, prefix string, val cloudregistry.ValueSetter) error {
-	return r.subscriveValue(ctx, r.prefix+prefix, true, val)
+	return r.subscriveValue(ctx, r.prefix+prefix, true, r.valueSetter(val))
+}
+
+// valueSetter decodes the watched values, the keys are trimmed to be relative to the client prefix.
+func (r *Registry) valueSetter(val cloudregistry.ValueSetter) func(key string, data []byte) error {
+	setValue := cloudregistry.DecodeValueSetter(r.codec, val)
+	return func(key string, data []byte) error {
+		return setValue(strings.TrimPrefix(key, r.prefix), data)
+	}
 }
 
 // subscriveValue is a common internal method for handling subscriptions.
 // keyOrPrefix: the key or prefix to watch
 // isPrefix: true if watching a prefix, false if watching a single key
 // val: the ValueSetter to invoke on updates
-func (r *Registry) subscriveValue(ctx context.Context, keyOrPrefix string, isPrefix bool, val cloudregistry.ValueSetter) error {
+func (r *Registry) subscriveValue(ctx context.Context, keyOrPrefix string, isPrefix bool, val func(key string, data []byte) error) error {
 	if r.parent != nil {
 		return r.parent.subscriveValue(ctx, keyOrPrefix, isPrefix, val)
 	}
 
-	r.watcherOnce.Do(func() {
-		r.watcherWg.Add(1)
-		go r.valueWatcher(ctx)
-	})
-
 	wrapper := &valueWatcherWrapper{
 		value:    val,
 		key:      keyOrPrefix,
 		isPrefix: isPrefix,
 	}
 
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	case <-r.done:
-		return nil
-	case r.watchers <- wrapper:
-		return nil
-	}
+	// Every subscription has its own blocking query loop, so a quiet key never delays the others
+	r.watcherWg.Add(1)
+	go r.valueWatcher(ctx, wrapper)
+	return nil
 }
 
-// valueWatcher handles all subscription updates.
-func (r *Registry) valueWatcher(ctx context.Context) {
+// valueWatcher handles the subscription updates until the context is done or the registry is closed.
+func (r *Registry) valueWatcher(ctx context.Context, wrapper *valueWatcherWrapper) {
 	defer r.watcherWg.Done()
-	kv := r.client.KV()
-	for {
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
 		select {
 		case <-ctx.Done():
-			return
 		case <-r.done:
-			return
-		case wr := <-r.watchers:
-			if wr == nil {
-				continue
-			}
-			// TODO: Handle errors
-			if wr.isPrefix {
-				_ = r.handlePrefixWatch(ctx, kv, wr)
-			} else {
-				_ = r.handleKeyWatch(ctx, kv, wr)
-			}
+			cancel()
+		}
+	}()
+
+	kv := r.client.KV()
+	for ctx.Err() == nil {
+		var err error
+		if wrapper.isPrefix {
+			err = r.handlePrefixWatch(ctx, kv, wrapper)
+		} else {
+			err = r.handleKeyWatch(ctx, kv, wrapper)
+		}
+		if err != nil {
 			select {
 			case <-ctx.Done():
-				return
-			case <-r.done:
-				return
-			default:
-				r.watchers <- wr
+			case <-time.After(watchRetryDelay):
 			}
-			// time.Sleep(100 * time.Millisecond)
 		}
 	}
 }
@@ -271,7 +323,7 @@ func (r *Registry) handleKeyWatch(ctx context.Context, kv *api.KV, wrapper *valu
 		WaitIndex:  wrapper.waitIndex,
 	}
 	pair, meta, err := kv.Get(wrapper.key, opts.WithContext(ctx))
-	if err != nil || pair == nil {
+	if err != nil {
 		if api.IsRetryableError(err) {
 			wrapper.waitIndex = 0
 		}
@@ -284,14 +336,17 @@ func (r *Registry) handleKeyWatch(ctx context.Context, kv *api.KV, wrapper *valu
 		return nil
 	}
 
-	var value any
-	if err := json.Unmarshal(pair.Value, &value); err != nil {
-		value = string(pair.Value)
-	}
-	if err := wrapper.value.SetValue(pair.Key, value); err != nil {
-		return err
+	// The missing key is still watched with the returned index
+	if pair == nil {
+		if wrapper.keys[wrapper.key] {
+			delete(wrapper.keys, wrapper.key)
+			return wrapper.value(wrapper.key, nil)
+		}
+		return nil
 	}
-	return nil
+
+	wrapper.keys = map[string]bool{pair.Key: true}
+	return wrapper.value(pair.Key, pairValue(pair))
 }
 
 // handlePrefixWatch handles subscription to a key prefix.
@@ -316,28 +371,48 @@ func (r *Registry) handlePrefixWatch(ctx context.Context, kv *api.KV, wrapper *v
 		return nil
 	}
 
+	keys := make(map[string]bool, len(pairs))
 	for _, pair := range pairs {
-		var value any
-		if err := json.Unmarshal(pair.Value, &value); err != nil {
-			value = string(pair.Value)
-		}
-		if err := wrapper.value.SetValue(pair.Key, value); err != nil {
-			return err
+		keys[pair.Key] = true
+		// The values which can't be decoded are skipped
+		_ = wrapper.value(pair.Key, pairValue(pair))
+	}
+	for _, key := range slices.Sorted(maps.Keys(wrapper.keys)) {
+		if !keys[key] {
+			_ = wrapper.value(key, nil)
 		}
 	}
+	wrapper.keys = keys
 	return nil
 }
 
+// pairValue returns the non-nil value of the pair, the nil value is the deleted key.
+func pairValue(pair *api.KVPair) []byte {
+	if pair.Value == nil {
+		return []byte{}
+	}
+	return pair.Value
+}
+
 // Close closes the Consul client and waits for all watchers to finish.
 func (r *Registry) Close() error {
-	// Signal the watcher to stop
-	r.done <- struct{}{}
-
-	// Close the watchers channel to signal watchers to stop
-	close(r.watchers)
-
-	// Wait for all watcher goroutines to finish
-	r.watcherWg.Wait()
+	if r.parent != nil {
+		return r.parent.Close()
+	}
+	r.closeOnce.Do(func() {
+		// Signal the watchers to stop
+		close(r.done)
 
+		// Wait for all watcher goroutines to finish
+		r.watcherWg.Wait()
+	})
 	return nil
 }
+
+var (
+	_ cloudregistry.Registry        = (*Registry)(nil)
+	_ cloudregistry.CodecClient     = (*Registry)(nil)
+	_ cloudregistry.QueryDiscoverer = (*Registry)(nil)
+	_ cloudregistry.HealthReporter  = (*Registry)(nil)
+	_ cloudregistry.ServiceLister   = (*Registry)(nil)
+)
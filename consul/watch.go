@@ -0,0 +1,133 @@
+package consul
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+const watchRetryDelay = time.Second
+
+// WatchServices watches the service instances using Consul blocking queries on the health endpoint.
+// The first event is a snapshot of the current instances, next events reflect the changes.
+func (r *Registry) WatchServices(ctx context.Context, prefix *cloudregistry.ServicePrefix) (<-chan cloudregistry.ServiceEvent, error) {
+	services, waitIndex, err := r.healthServices(ctx, prefix, nil, 0)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	events := make(chan cloudregistry.ServiceEvent, 1)
+	go func() {
+		defer close(events)
+
+		if !sendServiceEvent(ctx, events, cloudregistry.ServiceEvent{
+			Type: cloudregistry.ServiceSnapshot, Services: services,
+		}) {
+			return
+		}
+
+		for {
+			next, lastIndex, err := r.healthServices(ctx, prefix, nil, waitIndex)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				if api.IsRetryableError(err) {
+					waitIndex = 0
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(watchRetryDelay):
+				}
+				continue
+			}
+			if lastIndex == waitIndex {
+				continue
+			}
+			// Reset the index if it goes backwards as recommended by Consul
+			if lastIndex < waitIndex {
+				lastIndex = 0
+			}
+			waitIndex = lastIndex
+
+			for _, event := range cloudregistry.DiffServices(services, next) {
+				if !sendServiceEvent(ctx, events, event) {
+					return
+				}
+			}
+			services = next
+		}
+	}()
+	return events, nil
+}
+
+// healthServices returns the service instances matching the query from the health endpoint,
+// blocking until the index changes if waitIndex is not zero.
+func (r *Registry) healthServices(ctx context.Context, prefix *cloudregistry.ServicePrefix, query *cloudregistry.DiscoverQuery, waitIndex uint64) ([]*cloudregistry.ServiceInfo, uint64, error) {
+	opts := &api.QueryOptions{
+		WaitTime:  defaultWaitTime,
+		WaitIndex: waitIndex,
+		Filter:    queryFilter(query),
+	}
+	passingOnly := query != nil && query.PassingOnly
+	entries, meta, err := r.client.Health().Service(prefix.Name, "", passingOnly, opts.WithContext(ctx))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	services := make([]*cloudregistry.ServiceInfo, 0, len(entries))
+	for _, entry := range entries {
+		svc := entry.Service
+		if svc == nil {
+			continue
+		}
+		if prefix.Namespace != "" && prefix.Namespace != svc.Namespace {
+			continue
+		}
+		if prefix.Partition != "" && prefix.Partition != svc.Partition {
+			continue
+		}
+		address := svc.Address
+		if address == "" && entry.Node != nil {
+			address = entry.Node.Address
+		}
+		services = append(services, &cloudregistry.ServiceInfo{
+			Name:       svc.Service,
+			Namespace:  svc.Namespace,
+			Partition:  svc.Partition,
+			InstanceID: svc.ID,
+			Hostname:   address,
+			Port:       svc.Port,
+			Tags:       svc.Tags,
+			Meta:       svc.Meta,
+			Health:     checksHealth(entry.Checks),
+			LastUpdate: time.Now(),
+			Public: []cloudregistry.Host{
+				{
+					Hostname: address,
+					Ports: cloudregistry.Ports{
+						"http": fmt.Sprintf("%d", svc.Port),
+					},
+				},
+			},
+			RawInfo: entry,
+		})
+	}
+	return services, meta.LastIndex, nil
+}
+
+func sendServiceEvent(ctx context.Context, events chan<- cloudregistry.ServiceEvent, event cloudregistry.ServiceEvent) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case events <- event:
+		return true
+	}
+}
+
+var _ cloudregistry.ServiceWatcher = (*Registry)(nil)
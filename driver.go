@@ -0,0 +1,96 @@
+package cloudregistry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrUnsupportedScheme is returned when no driver is registered for the URI scheme.
+var ErrUnsupportedScheme = errors.New("unsupported registry scheme")
+
+// DriverFactory opens a registry connection from the connection URI.
+type DriverFactory func(ctx context.Context, uri string) (Registry, error)
+
+var (
+	driversMx sync.RWMutex
+	drivers   = map[string]DriverFactory{}
+)
+
+// UnsupportedSchemeError is returned by Open when no driver is registered for the scheme.
+type UnsupportedSchemeError struct {
+	Scheme string
+}
+
+// Error returns the error message.
+func (e *UnsupportedSchemeError) Error() string {
+	return fmt.Sprintf("%s: %q", ErrUnsupportedScheme.Error(), e.Scheme)
+}
+
+// Is reports whether the target error is ErrUnsupportedScheme.
+func (e *UnsupportedSchemeError) Is(target error) bool {
+	return target == ErrUnsupportedScheme
+}
+
+// RegisterDriver makes a registry driver available by the provided URI scheme.
+// Backend packages call it from init, so importing the package is enough to use it with Open.
+// If RegisterDriver is called twice with the same scheme or if factory is nil, it panics.
+func RegisterDriver(scheme string, factory DriverFactory) {
+	scheme = strings.ToLower(scheme)
+	if scheme == "" {
+		panic("cloudregistry: RegisterDriver scheme is empty")
+	}
+	if factory == nil {
+		panic("cloudregistry: RegisterDriver factory is nil")
+	}
+
+	driversMx.Lock()
+	defer driversMx.Unlock()
+
+	if _, dup := drivers[scheme]; dup {
+		panic("cloudregistry: RegisterDriver called twice for scheme " + scheme)
+	}
+	drivers[scheme] = factory
+}
+
+// Drivers returns a sorted list of the registered URI schemes.
+func Drivers() []string {
+	driversMx.RLock()
+	defer driversMx.RUnlock()
+
+	schemes := make([]string, 0, len(drivers))
+	for scheme := range drivers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// Open opens a registry connection using the driver registered for the URI scheme.
+//
+// Example:
+//
+//	import _ "github.com/demdxx/cloudregistry/etcd"
+//
+//	registry, err := cloudregistry.Open(ctx, "etcd://localhost:2379")
+func Open(ctx context.Context, uri string) (Registry, error) {
+	urlObj, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid registry URI: %w", err)
+	}
+
+	scheme := strings.ToLower(urlObj.Scheme)
+
+	driversMx.RLock()
+	factory, ok := drivers[scheme]
+	driversMx.RUnlock()
+
+	if !ok {
+		return nil, &UnsupportedSchemeError{Scheme: scheme}
+	}
+	return factory(ctx, uri)
+}
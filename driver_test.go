@@ -0,0 +1,80 @@
+package cloudregistry
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestRegisterDriver(t *testing.T) {
+	var openedURI string
+	RegisterDriver("test-driver", func(ctx context.Context, uri string) (Registry, error) {
+		openedURI = uri
+		return nil, nil
+	})
+
+	if !slices.Contains(Drivers(), "test-driver") {
+		t.Fatalf("Drivers() = %v, should contain test-driver", Drivers())
+	}
+
+	if _, err := Open(context.Background(), "TEST-DRIVER://localhost:1234/path"); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if openedURI != "TEST-DRIVER://localhost:1234/path" {
+		t.Errorf("Open() passed URI = %v, want original URI", openedURI)
+	}
+}
+
+func TestRegisterDriver_Panics(t *testing.T) {
+	factory := func(ctx context.Context, uri string) (Registry, error) { return nil, nil }
+	RegisterDriver("test-dup", factory)
+
+	tests := []struct {
+		name    string
+		scheme  string
+		factory DriverFactory
+	}{
+		{name: "duplicate scheme", scheme: "test-dup", factory: factory},
+		{name: "duplicate scheme different case", scheme: "Test-Dup", factory: factory},
+		{name: "empty scheme", scheme: "", factory: factory},
+		{name: "nil factory", scheme: "test-nil", factory: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("RegisterDriver() should panic")
+				}
+			}()
+			RegisterDriver(tt.scheme, tt.factory)
+		})
+	}
+}
+
+func TestOpen_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		uri         string
+		unsupported bool
+	}{
+		{name: "unknown scheme", uri: "unknown://localhost", unsupported: true},
+		{name: "no scheme", uri: "localhost:2379", unsupported: true},
+		{name: "invalid uri", uri: "://bad uri", unsupported: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Open(context.Background(), tt.uri)
+			if err == nil {
+				t.Fatalf("Open() expected error")
+			}
+			if got := errors.Is(err, ErrUnsupportedScheme); got != tt.unsupported {
+				t.Errorf("errors.Is(err, ErrUnsupportedScheme) = %v, want %v (err: %v)", got, tt.unsupported, err)
+			}
+			var schemeErr *UnsupportedSchemeError
+			if tt.unsupported && !errors.As(err, &schemeErr) {
+				t.Errorf("Open() error = %T, want *UnsupportedSchemeError", err)
+			}
+		})
+	}
+}
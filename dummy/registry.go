@@ -55,9 +55,23 @@ func (r *Registry) SubscribeValueWithPrefix(ctx context.Context, prefix string,
 	return nil
 }
 
+// WatchServices returns a channel with an empty snapshot which is closed when the context is done.
+func (r *Registry) WatchServices(ctx context.Context, prefix *cloudregistry.ServicePrefix) (<-chan cloudregistry.ServiceEvent, error) {
+	events := make(chan cloudregistry.ServiceEvent, 1)
+	events <- cloudregistry.ServiceEvent{Type: cloudregistry.ServiceSnapshot}
+	go func() {
+		<-ctx.Done()
+		close(events)
+	}()
+	return events, nil
+}
+
 // Close closes the cloud registry.
 func (r *Registry) Close() error {
 	return nil
 }
 
-var _ cloudregistry.Registry = (*Registry)(nil)
+var (
+	_ cloudregistry.Registry       = (*Registry)(nil)
+	_ cloudregistry.ServiceWatcher = (*Registry)(nil)
+)
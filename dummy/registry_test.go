@@ -170,3 +170,22 @@ func TestRegistry_Interface(t *testing.T) {
 		t.Errorf("Registry as cloudregistry.Registry.Close() error = %v", err)
 	}
 }
+
+func TestRegistry_WatchServices(t *testing.T) {
+	registry := &Registry{}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	events, err := cloudregistry.WatchServices(ctx, registry, &cloudregistry.ServicePrefix{Name: "test"})
+	if err != nil {
+		t.Fatalf("Registry.WatchServices() error = %v, want nil", err)
+	}
+
+	if ev := <-events; ev.Type != cloudregistry.ServiceSnapshot || len(ev.Services) != 0 {
+		t.Errorf("Registry.WatchServices() first event = %v, want empty snapshot", ev)
+	}
+
+	cancel()
+	if _, ok := <-events; ok {
+		t.Error("Registry.WatchServices() channel should be closed after context cancel")
+	}
+}
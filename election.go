@@ -0,0 +1,50 @@
+package cloudregistry
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrElectorNotSupported is returned when the registry does not support the leader election.
+	ErrElectorNotSupported = errors.New("leader election is not supported")
+	// ErrNotLeader is returned by Resign when the candidate is not campaigning.
+	ErrNotLeader = errors.New("candidate is not the leader")
+)
+
+// Election is the leader election of the service instances.
+// The Election is a single candidate, every instance creates its own one.
+type Election interface {
+	// Campaign waits until the candidate is elected or the context is done,
+	// the value identifies the leader, usually it is the instance ID.
+	Campaign(ctx context.Context, value string) error
+	// Resign gives up the leadership or the candidacy.
+	Resign(ctx context.Context) error
+	// Leader returns the value of the current leader, ErrNotFound if there is no leader.
+	Leader(ctx context.Context) (string, error)
+	// Observe returns the channel receiving the current leader value and every its change,
+	// the empty value means there is no leader. The channel is closed when the context is done.
+	Observe(ctx context.Context) <-chan string
+}
+
+// Elector is an optional interface implemented by registries providing the leader election.
+// The candidates with the same service prefix take part in the same election.
+type Elector interface {
+	NewElection(ctx context.Context, prefix *ServicePrefix) (Election, error)
+}
+
+// NewElection creates a leader election candidate if the registry implements Elector.
+func NewElection(ctx context.Context, registry Registry, prefix *ServicePrefix) (Election, error) {
+	if elector, ok := registry.(Elector); ok {
+		return elector.NewElection(ctx, prefix)
+	}
+	return nil, ErrElectorNotSupported
+}
+
+// ElectionKey returns the backend key of the election for the service prefix,
+// like "elections/namespace/name/partition/leader". The last segment keeps the elections
+// of the service partitions out of the key range of the whole service election.
+func ElectionKey(prefix *ServicePrefix) string {
+	return "elections/" + strings.TrimPrefix(prefix.String(), "services/") + "leader"
+}
@@ -0,0 +1,30 @@
+package cloudregistry
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestNewElection_NotSupported(t *testing.T) {
+	_, err := NewElection(context.Background(), &lifecycleRegistry{}, &ServicePrefix{Name: "service"})
+	if !errors.Is(err, ErrElectorNotSupported) {
+		t.Errorf("NewElection() error = %v, want %v", err, ErrElectorNotSupported)
+	}
+}
+
+func TestElectionKey(t *testing.T) {
+	tests := []struct {
+		prefix ServicePrefix
+		want   string
+	}{
+		{prefix: ServicePrefix{Name: "api"}, want: "elections/api/leader"},
+		{prefix: ServicePrefix{Name: "api", Namespace: "prod"}, want: "elections/prod/api/leader"},
+		{prefix: ServicePrefix{Name: "api", Namespace: "prod", Partition: "eu"}, want: "elections/prod/api/eu/leader"},
+	}
+	for _, tt := range tests {
+		if got := ElectionKey(&tt.prefix); got != tt.want {
+			t.Errorf("ElectionKey(%+v) = %q, want %q", tt.prefix, got, tt.want)
+		}
+	}
+}
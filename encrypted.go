@@ -0,0 +1,56 @@
+package cloudregistry
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// EncryptedValuePrefix marks the raw registry values encrypted by the encrypted package.
+const EncryptedValuePrefix = "encrypted:"
+
+// ErrEncryptedValue is returned by the plain readers of the encrypted values,
+// such values must be read through the client decrypting them.
+var ErrEncryptedValue = errors.New("value is encrypted")
+
+// envelopeAlphabet is the alphabet of the unpadded URL-safe base64 parts of the encrypted envelope.
+const envelopeAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+
+// IsEncryptedValue reports whether the raw or decoded value is encrypted. The value has to match
+// the whole envelope encrypted:v<version>:<key id>:<encrypted data key>:<encrypted value>,
+// so the plain values which only start with the prefix are not treated as encrypted.
+func IsEncryptedValue(value any) bool {
+	switch val := value.(type) {
+	case string:
+		return isEnvelope(val)
+	case []byte:
+		return isEnvelope(string(val))
+	}
+	return false
+}
+
+func isEnvelope(value string) bool {
+	rest, ok := strings.CutPrefix(value, EncryptedValuePrefix)
+	if !ok {
+		return false
+	}
+	parts := strings.Split(rest, ":")
+	if len(parts) != 4 || len(parts[0]) < 2 || parts[0][0] != 'v' ||
+		strings.Trim(parts[0][1:], "0123456789") != "" || parts[1] == "" {
+		return false
+	}
+	for _, part := range parts[2:] {
+		if part == "" || strings.Trim(part, envelopeAlphabet) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// checkEncrypted returns ErrEncryptedValue for the encrypted values.
+func checkEncrypted(key string, value any) error {
+	if IsEncryptedValue(value) {
+		return fmt.Errorf("%w: %s", ErrEncryptedValue, key)
+	}
+	return nil
+}
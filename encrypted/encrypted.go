@@ -0,0 +1,358 @@
+// Package encrypted implements the ValueClient decorator encrypting the values stored in the registry.
+//
+// Every value is encrypted with its own AES-256-GCM data key, the data key is encrypted
+// by the KeyProvider master key and stored with the value together with the master key ID:
+//
+//	encrypted:v1:<key id>:<encrypted data key>:<encrypted value>
+//
+// The value is authenticated with its key relative to the client passed to New, so the ciphertext
+// copied to another key can't be decrypted. The prefixes of the nested clients are concatenated
+// to the key, so they should end with the separator like "secrets/".
+//
+// The values are decrypted by Value, ListValues and the subscriptions, so the typed values,
+// value holders and Bind work on top of the client as usual. Plain readers of the encrypted keys
+// fail with cloudregistry.ErrEncryptedValue instead of using the ciphertext.
+//
+// Example:
+//
+//	keys, err := encrypted.NewKeyring("2024-01", masterKey)
+//	if err != nil {
+//		return err
+//	}
+//	secrets := encrypted.New(registry.Values(ctx, "billing/secrets/"), keys)
+//	err = secrets.SetValue(ctx, "db/password", password)
+package encrypted
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+// version is the format version of the encrypted values.
+const version = "v1"
+
+var (
+	// ErrInvalidCiphertext is returned when the encrypted value is malformed or can't be authenticated.
+	ErrInvalidCiphertext = errors.New("invalid encrypted value")
+	// ErrNotEncrypted is returned when the value read through the client is not encrypted.
+	ErrNotEncrypted = errors.New("value is not encrypted")
+)
+
+var encoding = base64.RawURLEncoding
+
+// Option is a configuration option of the Client.
+type Option func(conf *config)
+
+// WithPlaintext allows reading the values which are not encrypted yet,
+// so the existing keys can be migrated with Reencrypt.
+func WithPlaintext() Option {
+	return func(conf *config) {
+		conf.plaintext = true
+	}
+}
+
+// WithErrorHandler sets the handler of the subscription values which can't be decrypted,
+// such values are not delivered to the subscribers.
+func WithErrorHandler(handler func(key string, err error)) Option {
+	return func(conf *config) {
+		conf.errHandler = handler
+	}
+}
+
+type config struct {
+	keys       KeyProvider
+	plaintext  bool
+	errHandler func(key string, err error)
+}
+
+// Client is the ValueClient encrypting the values of the wrapped client.
+type Client struct {
+	client cloudregistry.ValueClient
+	// prefix is the prefix of the nested client relative to the one passed to New,
+	// the values are authenticated with the key including it.
+	prefix string
+	codec  cloudregistry.Codec
+	conf   *config
+}
+
+// New wraps the value client to encrypt the values with the data keys protected by the key provider.
+// The subscription values are decoded with the codec of the wrapped client.
+func New(client cloudregistry.ValueClient, keys KeyProvider, options ...Option) *Client {
+	conf := &config{keys: keys}
+	for _, option := range options {
+		option(conf)
+	}
+	return &Client{client: client, codec: cloudregistry.CodecOf(client), conf: conf}
+}
+
+// Values returns the encrypting ValueClient with the prefix.
+func (c *Client) Values(ctx context.Context, prefix ...string) cloudregistry.ValueClient {
+	return &Client{
+		client: c.client.Values(ctx, prefix...),
+		prefix: c.prefix + strings.Join(prefix, ""),
+		codec:  c.codec,
+		conf:   c.conf,
+	}
+}
+
+// Codec returns the codec decoding the decrypted subscription values.
+func (c *Client) Codec() cloudregistry.Codec {
+	return c.codec
+}
+
+// WithCodec returns the encrypting ValueClient with the same prefix using the codec.
+func (c *Client) WithCodec(codec cloudregistry.Codec) cloudregistry.ValueClient {
+	return &Client{client: c.client, prefix: c.prefix, codec: codec, conf: c.conf}
+}
+
+// Value returns the decrypted value.
+func (c *Client) Value(ctx context.Context, name string) (string, error) {
+	raw, err := c.client.Value(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	value, _, err := c.decrypt(ctx, name, raw)
+	if err != nil {
+		return "", fmt.Errorf("decrypt %s: %w", name, err)
+	}
+	return value, nil
+}
+
+// SetValue encrypts the value with the current master key and sets it.
+func (c *Client) SetValue(ctx context.Context, name, value string) error {
+	raw, err := c.encrypt(ctx, name, value)
+	if err != nil {
+		return fmt.Errorf("encrypt %s: %w", name, err)
+	}
+	return c.client.SetValue(ctx, name, raw)
+}
+
+// SubscribeValue subscribes to the value, the subscriber receives the decrypted and decoded value.
+func (c *Client) SubscribeValue(ctx context.Context, name string, val cloudregistry.ValueSetter) error {
+	return cloudregistry.WithCodec(c.client, cloudregistry.StringCodec).SubscribeValue(ctx, name, c.setter(ctx, val))
+}
+
+// SubscribeValueWithPrefix subscribes to the values with the prefix,
+// the subscriber receives the decrypted and decoded values.
+func (c *Client) SubscribeValueWithPrefix(ctx context.Context, prefix string, val cloudregistry.ValueSetter) error {
+	return cloudregistry.WithCodec(c.client, cloudregistry.StringCodec).SubscribeValueWithPrefix(ctx, prefix, c.setter(ctx, val))
+}
+
+// setter decrypts the raw values delivered by the wrapped client with the string codec.
+func (c *Client) setter(ctx context.Context, val cloudregistry.ValueSetter) cloudregistry.ValueSetter {
+	return cloudregistry.ValueSetterFunc(func(key string, value any) error {
+		if value == nil {
+			return val.SetValue(key, nil)
+		}
+		raw, ok := value.(string)
+		if !ok {
+			return c.reportError(key, fmt.Errorf("%w: unexpected value type %T", ErrInvalidCiphertext, value))
+		}
+		plain, _, err := c.decrypt(ctx, key, raw)
+		if err != nil {
+			return c.reportError(key, err)
+		}
+		decoded, err := c.codec.Decode([]byte(plain))
+		if err != nil {
+			return c.reportError(key, err)
+		}
+		return val.SetValue(key, decoded)
+	})
+}
+
+func (c *Client) reportError(key string, err error) error {
+	if c.conf.errHandler != nil {
+		c.conf.errHandler(key, err)
+	}
+	return err
+}
+
+// DeleteValue deletes the value if the wrapped client implements ValueStore.
+func (c *Client) DeleteValue(ctx context.Context, name string) error {
+	return cloudregistry.DeleteValue(ctx, c.client, name)
+}
+
+// DeletePrefix deletes the values with the prefix if the wrapped client implements ValueStore.
+func (c *Client) DeletePrefix(ctx context.Context, prefix string) error {
+	return cloudregistry.DeletePrefix(ctx, c.client, prefix)
+}
+
+// ListValues returns the decrypted values with the prefix if the wrapped client implements ValueStore.
+func (c *Client) ListValues(ctx context.Context, prefix string) ([]*cloudregistry.KeyValue, error) {
+	values, err := cloudregistry.ListValues(ctx, c.client, prefix)
+	if err != nil {
+		return nil, err
+	}
+	decrypted := make([]*cloudregistry.KeyValue, 0, len(values))
+	for _, kv := range values {
+		value, _, err := c.decrypt(ctx, kv.Key, kv.Value)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt %s: %w", kv.Key, err)
+		}
+		decrypted = append(decrypted, &cloudregistry.KeyValue{Key: kv.Key, Value: value, Version: kv.Version})
+	}
+	return decrypted, nil
+}
+
+// CompareAndSwap encrypts the value and sets it if its version matches and the wrapped client implements ValueStore.
+func (c *Client) CompareAndSwap(ctx context.Context, name string, version uint64, value string) (bool, error) {
+	raw, err := c.encrypt(ctx, name, value)
+	if err != nil {
+		return false, fmt.Errorf("encrypt %s: %w", name, err)
+	}
+	return cloudregistry.CompareAndSwap(ctx, c.client, name, version, raw)
+}
+
+// Reencrypt encrypts the values with the prefix by the current master key and returns the number
+// of the updated values. It completes the key rotation, the values encrypted with the current key are skipped.
+// The plaintext values are encrypted too if the client is created WithPlaintext.
+// The values are swapped by version, so the concurrently changed values are left as they are.
+func (c *Client) Reencrypt(ctx context.Context, prefix string) (int, error) {
+	values, err := cloudregistry.ListValues(ctx, c.client, prefix)
+	if err != nil {
+		return 0, err
+	}
+	// The current master key ID is learned by wrapping the unused key once,
+	// so only the values encrypted with the previous keys are unwrapped and encrypted again
+	currentID, _, err := c.conf.keys.WrapKey(ctx, make([]byte, 32))
+	if err != nil {
+		return 0, err
+	}
+	updated := 0
+	for _, kv := range values {
+		if cloudregistry.IsEncryptedValue(kv.Value) {
+			env, err := parseEnvelope(kv.Value)
+			if err != nil {
+				return updated, fmt.Errorf("decrypt %s: %w", kv.Key, err)
+			}
+			if env.keyID == currentID {
+				continue
+			}
+		}
+		value, _, err := c.decrypt(ctx, kv.Key, kv.Value)
+		if err != nil {
+			return updated, fmt.Errorf("decrypt %s: %w", kv.Key, err)
+		}
+		raw, err := c.encrypt(ctx, kv.Key, value)
+		if err != nil {
+			return updated, fmt.Errorf("encrypt %s: %w", kv.Key, err)
+		}
+		swapped, err := cloudregistry.CompareAndSwap(ctx, c.client, kv.Key, kv.Version, raw)
+		if err != nil {
+			return updated, err
+		}
+		if swapped {
+			updated++
+		}
+	}
+	return updated, nil
+}
+
+// encrypt encrypts the value of the key with the new data key.
+func (c *Client) encrypt(ctx context.Context, name, value string) (string, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	keyID, wrapped, err := c.conf.keys.WrapKey(ctx, dataKey)
+	if err != nil {
+		return "", err
+	}
+	if keyID == "" || strings.Contains(keyID, ":") {
+		return "", fmt.Errorf("invalid encryption key ID %q", keyID)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	header := cloudregistry.EncryptedValuePrefix + version + ":" + keyID + ":" + encoding.EncodeToString(wrapped)
+	sealed, err := seal(aead, []byte(value), c.additionalData(header, name))
+	if err != nil {
+		return "", err
+	}
+	return header + ":" + encoding.EncodeToString(sealed), nil
+}
+
+// decrypt decrypts the raw value of the key and returns the master key ID, which is empty for the plaintext values.
+func (c *Client) decrypt(ctx context.Context, name, raw string) (string, string, error) {
+	if !cloudregistry.IsEncryptedValue(raw) {
+		if c.conf.plaintext {
+			return raw, "", nil
+		}
+		return "", "", ErrNotEncrypted
+	}
+	env, err := parseEnvelope(raw)
+	if err != nil {
+		return "", "", err
+	}
+	dataKey, err := c.conf.keys.UnwrapKey(ctx, env.keyID, env.wrapped)
+	if err != nil {
+		return "", "", err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %w", ErrInvalidCiphertext, err)
+	}
+	value, err := open(aead, env.sealed, c.additionalData(env.header, name))
+	if err != nil {
+		return "", "", err
+	}
+	return string(value), env.keyID, nil
+}
+
+// additionalData returns the data authenticated with the value: the header, so the data key
+// can't be replaced, and the key relative to the client passed to New, so the value can't be moved.
+func (c *Client) additionalData(header, name string) []byte {
+	return []byte(header + ":" + c.prefix + name)
+}
+
+// envelope is the parsed encrypted value.
+type envelope struct {
+	header  string
+	keyID   string
+	wrapped []byte
+	sealed  []byte
+}
+
+func parseEnvelope(raw string) (*envelope, error) {
+	sep := strings.LastIndexByte(raw, ':')
+	header, data := raw[:sep], raw[sep+1:]
+	parts := strings.Split(strings.TrimPrefix(header, cloudregistry.EncryptedValuePrefix), ":")
+	if len(parts) != 3 {
+		return nil, ErrInvalidCiphertext
+	}
+	if parts[0] != version {
+		return nil, fmt.Errorf("%w: unsupported version %q", ErrInvalidCiphertext, parts[0])
+	}
+	wrapped, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCiphertext, err)
+	}
+	sealed, err := encoding.DecodeString(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCiphertext, err)
+	}
+	return &envelope{header: header, keyID: parts[1], wrapped: wrapped, sealed: sealed}, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+var (
+	_ cloudregistry.ValueClient = (*Client)(nil)
+	_ cloudregistry.CodecClient = (*Client)(nil)
+	_ cloudregistry.ValueStore  = (*Client)(nil)
+)
@@ -0,0 +1,193 @@
+package encrypted
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/demdxx/cloudregistry"
+	"github.com/demdxx/cloudregistry/memory"
+)
+
+func newKeyring(t *testing.T, id string) *Keyring {
+	t.Helper()
+	keys, err := NewKeyring(id, bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatalf("NewKeyring() error = %v", err)
+	}
+	return keys
+}
+
+func TestKeyring(t *testing.T) {
+	if _, err := NewKeyring("a:b", make([]byte, 32)); err == nil {
+		t.Error("NewKeyring() should reject the key ID with the separator")
+	}
+	if _, err := NewKeyring("k1", make([]byte, 10)); err == nil {
+		t.Error("NewKeyring() should reject the invalid key size")
+	}
+
+	ctx := context.Background()
+	keys := newKeyring(t, "k1")
+	if err := keys.AddKey("k1", bytes.Repeat([]byte{2}, 32)); err == nil {
+		t.Error("AddKey() should reject the different key with the same ID")
+	}
+	id, wrapped, err := keys.WrapKey(ctx, []byte("data key"))
+	if err != nil || id != "k1" {
+		t.Fatalf("WrapKey() = %q, %v", id, err)
+	}
+	if _, err := keys.UnwrapKey(ctx, "k2", wrapped); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("UnwrapKey() of unknown key error = %v, want ErrUnknownKey", err)
+	}
+	if err := keys.Rotate("k2", bytes.Repeat([]byte{2}, 16)); err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+	if key, err := keys.UnwrapKey(ctx, "k1", wrapped); err != nil || string(key) != "data key" {
+		t.Errorf("UnwrapKey() after rotation = %q, %v", key, err)
+	}
+}
+
+func TestClient_Values(t *testing.T) {
+	ctx := context.Background()
+	registry := memory.NewRegistry()
+	raw := registry.Values(ctx, "app/")
+	secrets := New(raw, newKeyring(t, "k1")).Values(ctx, "secrets/")
+
+	if err := secrets.SetValue(ctx, "password", "s3cret"); err != nil {
+		t.Fatalf("SetValue() error = %v", err)
+	}
+	stored, _ := raw.Value(ctx, "secrets/password")
+	if !strings.HasPrefix(stored, "encrypted:v1:k1:") || strings.Contains(stored, "s3cret") {
+		t.Errorf("stored value = %q, want the encrypted value", stored)
+	}
+	if value, err := secrets.Value(ctx, "password"); err != nil || value != "s3cret" {
+		t.Errorf("Value() = %q, %v, want s3cret", value, err)
+	}
+	if _, err := cloudregistry.TypedValue[string](ctx, raw, "secrets/password"); !errors.Is(err, cloudregistry.ErrEncryptedValue) {
+		t.Errorf("plain TypedValue() error = %v, want ErrEncryptedValue", err)
+	}
+
+	_ = raw.SetValue(ctx, "secrets/plain", "text")
+	if _, err := secrets.Value(ctx, "plain"); !errors.Is(err, ErrNotEncrypted) {
+		t.Errorf("Value() of plaintext error = %v, want ErrNotEncrypted", err)
+	}
+	_ = raw.SetValue(ctx, "secrets/broken", stored[:len(stored)-4]+"AAAA")
+	if _, err := secrets.Value(ctx, "broken"); !errors.Is(err, ErrInvalidCiphertext) {
+		t.Errorf("Value() of tampered value error = %v, want ErrInvalidCiphertext", err)
+	}
+	if _, err := New(raw, newKeyring(t, "other")).Value(ctx, "secrets/password"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Value() with other keyring error = %v, want ErrUnknownKey", err)
+	}
+	if value, err := New(raw, newKeyring(t, "k1")).Value(ctx, "secrets/password"); err != nil || value != "s3cret" {
+		t.Errorf("Value() of the parent client = %q, %v, want s3cret", value, err)
+	}
+	_ = raw.SetValue(ctx, "secrets/copy", stored)
+	if _, err := secrets.Value(ctx, "copy"); !errors.Is(err, ErrInvalidCiphertext) {
+		t.Errorf("Value() of the value copied from another key error = %v, want ErrInvalidCiphertext", err)
+	}
+}
+
+func TestClient_Subscribe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	registry := memory.NewRegistry()
+	secrets := New(registry.Values(ctx, "app/"), newKeyring(t, "k1"))
+	_ = cloudregistry.SetTypedValue(ctx, secrets, "limits", map[string]any{"rps": 10})
+
+	var (
+		mx       sync.Mutex
+		received = map[string]any{}
+		failed   []string
+	)
+	secrets = New(registry.Values(ctx, "app/"), secrets.conf.keys, WithErrorHandler(func(key string, err error) {
+		mx.Lock()
+		defer mx.Unlock()
+		failed = append(failed, key)
+	}))
+	err := secrets.SubscribeValueWithPrefix(ctx, "", cloudregistry.ValueSetterFunc(func(key string, value any) error {
+		mx.Lock()
+		defer mx.Unlock()
+		received[key] = value
+		return nil
+	}))
+	if err != nil {
+		t.Fatalf("SubscribeValueWithPrefix() error = %v", err)
+	}
+	port := cloudregistry.NewSyncInt64Value(0)
+	if err := secrets.SubscribeValue(ctx, "port", port); err != nil {
+		t.Fatalf("SubscribeValue() error = %v", err)
+	}
+
+	_ = secrets.SetValue(ctx, "port", "8080")
+	_ = registry.SetValue(ctx, "app/plain", "text")
+
+	mx.Lock()
+	defer mx.Unlock()
+	if limits, ok := received["limits"].(map[string]any); !ok || limits["rps"] != float64(10) {
+		t.Errorf("received limits = %#v, want the decoded map", received["limits"])
+	}
+	if received["port"] != float64(8080) {
+		t.Errorf("received port = %#v, want 8080", received["port"])
+	}
+	if port.Value() != 8080 {
+		t.Errorf("value holder = %d, want 8080", port.Value())
+	}
+	if _, ok := received["plain"]; ok || len(failed) != 1 || failed[0] != "plain" {
+		t.Errorf("plaintext value should be reported, received %v, failed %v", received["plain"], failed)
+	}
+}
+
+// countingKeys counts the data keys wrapped by the keyring.
+type countingKeys struct {
+	*Keyring
+	wrapped int
+}
+
+func (k *countingKeys) WrapKey(ctx context.Context, dataKey []byte) (string, []byte, error) {
+	k.wrapped++
+	return k.Keyring.WrapKey(ctx, dataKey)
+}
+
+func TestClient_Reencrypt(t *testing.T) {
+	ctx := context.Background()
+	registry := memory.NewRegistry()
+	raw := registry.Values(ctx, "app/")
+	keys := newKeyring(t, "k1")
+	counter := &countingKeys{Keyring: keys}
+	secrets := New(raw, counter, WithPlaintext())
+
+	_ = secrets.SetValue(ctx, "old", "one")
+	_ = raw.SetValue(ctx, "plain", "two")
+	if err := keys.Rotate("k2", bytes.Repeat([]byte{2}, 32)); err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+	_ = secrets.SetValue(ctx, "new", "three")
+
+	counter.wrapped = 0
+	updated, err := secrets.Reencrypt(ctx, "")
+	if err != nil || updated != 2 {
+		t.Fatalf("Reencrypt() = %d, %v, want 2 updated", updated, err)
+	}
+	if counter.wrapped != 3 {
+		t.Errorf("Reencrypt() wrapped %d data keys, want 3 for the current key ID and the stale values", counter.wrapped)
+	}
+	values, err := secrets.ListValues(ctx, "")
+	if err != nil {
+		t.Fatalf("ListValues() error = %v", err)
+	}
+	want := map[string]string{"new": "three", "old": "one", "plain": "two"}
+	for _, kv := range values {
+		if want[kv.Key] != kv.Value {
+			t.Errorf("ListValues() %s = %q, want %q", kv.Key, kv.Value, want[kv.Key])
+		}
+		if stored, _ := raw.Value(ctx, kv.Key); !strings.HasPrefix(stored, "encrypted:v1:k2:") {
+			t.Errorf("%s is stored as %q, want the current key", kv.Key, stored)
+		}
+	}
+	if len(values) != len(want) {
+		t.Errorf("ListValues() returned %d values, want %d", len(values), len(want))
+	}
+}
@@ -0,0 +1,129 @@
+package encrypted
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ErrUnknownKey is returned when the master key of the encrypted value is not known to the key provider.
+var ErrUnknownKey = errors.New("unknown encryption key")
+
+// KeyProvider encrypts the data keys of the values with the master keys, like the KMS services do.
+// The master key ID is stored with every value, so the values encrypted with the previous master keys
+// can be decrypted after the rotation.
+type KeyProvider interface {
+	// WrapKey encrypts the data key with the current master key and returns the master key ID.
+	WrapKey(ctx context.Context, dataKey []byte) (keyID string, wrapped []byte, err error)
+	// UnwrapKey decrypts the data key with the master key, ErrUnknownKey is returned for unknown IDs.
+	UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// Keyring is the KeyProvider with the local AES master keys.
+// The current key encrypts the new data keys, all keys decrypt them.
+type Keyring struct {
+	mx      sync.RWMutex
+	current string
+	keys    map[string]*keyringKey
+}
+
+type keyringKey struct {
+	key  []byte
+	aead cipher.AEAD
+}
+
+// NewKeyring creates the keyring with the current master key, the key must be 16, 24 or 32 bytes long.
+func NewKeyring(id string, key []byte) (*Keyring, error) {
+	k := &Keyring{keys: map[string]*keyringKey{}}
+	if err := k.Rotate(id, key); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// AddKey adds the master key decrypting the values, like the previous keys after the rotation.
+func (k *Keyring) AddKey(id string, key []byte) error {
+	if id == "" || strings.Contains(id, ":") {
+		return fmt.Errorf("invalid encryption key ID %q", id)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+
+	k.mx.Lock()
+	defer k.mx.Unlock()
+	if prev, ok := k.keys[id]; ok && !bytes.Equal(prev.key, key) {
+		return fmt.Errorf("encryption key %q already exists", id)
+	}
+	k.keys[id] = &keyringKey{key: bytes.Clone(key), aead: aead}
+	return nil
+}
+
+// Rotate adds the master key and makes it current, the previous keys still decrypt the values.
+func (k *Keyring) Rotate(id string, key []byte) error {
+	if err := k.AddKey(id, key); err != nil {
+		return err
+	}
+	k.mx.Lock()
+	k.current = id
+	k.mx.Unlock()
+	return nil
+}
+
+// WrapKey encrypts the data key with the current master key, the key ID is authenticated with it.
+func (k *Keyring) WrapKey(ctx context.Context, dataKey []byte) (string, []byte, error) {
+	k.mx.RLock()
+	id, key := k.current, k.keys[k.current]
+	k.mx.RUnlock()
+	if key == nil {
+		return "", nil, fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+	wrapped, err := seal(key.aead, dataKey, []byte(id))
+	return id, wrapped, err
+}
+
+// UnwrapKey decrypts the data key with the master key.
+func (k *Keyring) UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	k.mx.RLock()
+	key, ok := k.keys[keyID]
+	k.mx.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, keyID)
+	}
+	return open(key.aead, wrapped, []byte(keyID))
+}
+
+// seal encrypts the data with the random nonce prepended to the result.
+func seal(aead cipher.AEAD, data, additional []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(data)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, data, additional), nil
+}
+
+// open decrypts the data sealed with the nonce.
+func open(aead cipher.AEAD, data, additional []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, ErrInvalidCiphertext
+	}
+	nonce, sealed := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, sealed, additional)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCiphertext, err)
+	}
+	return plain, nil
+}
+
+var _ KeyProvider = (*Keyring)(nil)
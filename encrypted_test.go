@@ -0,0 +1,64 @@
+package cloudregistry
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestIsEncryptedValue(t *testing.T) {
+	tests := []struct {
+		value any
+		want  bool
+	}{
+		{"encrypted:v1:key:d3JhcHBlZA:c2VhbGVk", true},
+		{[]byte("encrypted:v1:key:d3JhcHBlZA:c2VhbGVk"), true},
+		{"encrypted:v2:key:d3JhcHBlZA:c2VhbGVk", true},
+		{"encrypted: yes, at rest", false},
+		{"encrypted:v1:key:data", false},
+		{"encrypted:vx:key:d3JhcHBlZA:c2VhbGVk", false},
+		{"encrypted:v1::d3JhcHBlZA:c2VhbGVk", false},
+		{"encrypted:v1:key:d3JhcHBlZA:", false},
+		{"encrypted:v1:key:d3Jh cHBlZA:c2VhbGVk", false},
+		{"encrypted:v1:key:d3JhcHBlZA:c2VhbGVk:extra", false},
+		{"plain", false},
+		{"", false},
+		{float64(1), false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := IsEncryptedValue(tt.value); got != tt.want {
+			t.Errorf("IsEncryptedValue(%#v) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestEncryptedValue_PlainReaders(t *testing.T) {
+	const secret = EncryptedValuePrefix + "v1:key:d3JhcHBlZA:c2VhbGVk"
+
+	str := NewSyncStringValue("old")
+	var reported error
+	str.OnError(func(key string, err error) { reported = err })
+	if err := str.SetValue("db/password", secret); !errors.Is(err, ErrEncryptedValue) {
+		t.Errorf("SyncStringValue.SetValue() error = %v, want ErrEncryptedValue", err)
+	}
+	if !errors.Is(reported, ErrEncryptedValue) {
+		t.Errorf("reported error = %v, want ErrEncryptedValue", reported)
+	}
+	if str.Value() != "old" {
+		t.Errorf("Value() = %q, the encrypted value should keep the previous one", str.Value())
+	}
+	if err := str.SetValue("status", "encrypted: yes"); err != nil || str.Value() != "encrypted: yes" {
+		t.Errorf("SetValue() of the plain value with the prefix = %q, %v", str.Value(), err)
+	}
+
+	m := NewSyncMapValue[string]("db/")
+	if err := m.SetValue("db/password", secret); !errors.Is(err, ErrEncryptedValue) {
+		t.Errorf("SyncMapValue.SetValue() error = %v, want ErrEncryptedValue", err)
+	}
+
+	client := newMapValues(map[string]string{"password": secret})
+	if _, err := TypedValue[string](context.Background(), client, "password"); !errors.Is(err, ErrEncryptedValue) {
+		t.Errorf("TypedValue() error = %v, want ErrEncryptedValue", err)
+	}
+}
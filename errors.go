@@ -0,0 +1,47 @@
+package cloudregistry
+
+import "errors"
+
+// The error kinds shared by all backends. The backend errors are mapped onto them with NewError,
+// so the callers can check the kind with errors.Is and still reach the backend error.
+var (
+	// ErrAlreadyExists is returned when the created entry already exists.
+	ErrAlreadyExists = errors.New("already exists")
+	// ErrConflict is returned when the entry was changed concurrently or its state does not allow the operation.
+	ErrConflict = errors.New("conflict")
+	// ErrUnavailable is returned when the registry server can't be reached or is temporarily unavailable.
+	ErrUnavailable = errors.New("registry is unavailable")
+	// ErrPermissionDenied is returned when the client is not authenticated or not authorized for the operation.
+	ErrPermissionDenied = errors.New("permission denied")
+	// ErrTimeout is returned when the operation deadline is exceeded.
+	ErrTimeout = errors.New("operation timed out")
+	// ErrClosed is returned when the registry is closed.
+	ErrClosed = errors.New("registry is closed")
+)
+
+// Error is the error of the kind wrapping the backend error.
+type Error struct {
+	// Kind is one of the registry errors like ErrNotFound or ErrUnavailable.
+	Kind error
+	// Err is the original backend error.
+	Err error
+}
+
+// NewError wraps the backend error with the kind, nil error stays nil
+// and the errors which already match the kind are returned as is.
+func NewError(kind, err error) error {
+	if err == nil || errors.Is(err, kind) {
+		return err
+	}
+	return &Error{Kind: kind, Err: err}
+}
+
+// Error returns the message of the kind with the backend error.
+func (e *Error) Error() string {
+	return e.Kind.Error() + ": " + e.Err.Error()
+}
+
+// Unwrap returns both the kind and the backend error for errors.Is and errors.As.
+func (e *Error) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
@@ -0,0 +1,37 @@
+package cloudregistry
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type backendError struct{ code int }
+
+func (e *backendError) Error() string { return fmt.Sprintf("backend error %d", e.code) }
+
+func TestNewError(t *testing.T) {
+	cause := &backendError{code: 404}
+	err := NewError(ErrNotFound, fmt.Errorf("get key: %w", cause))
+
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("errors.Is(%v, ErrNotFound) = false", err)
+	}
+	if errors.Is(err, ErrUnavailable) {
+		t.Errorf("errors.Is(%v, ErrUnavailable) = true", err)
+	}
+	var target *backendError
+	if !errors.As(err, &target) || target.code != 404 {
+		t.Errorf("errors.As() does not reach the backend error: %v", err)
+	}
+	if got, want := err.Error(), "no service addresses found: get key: backend error 404"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+
+	if NewError(ErrTimeout, nil) != nil {
+		t.Error("NewError() of nil error is not nil")
+	}
+	if got := NewError(ErrClosed, ErrClosed); got != ErrClosed {
+		t.Errorf("NewError() of the same kind = %v, want the error as is", got)
+	}
+}
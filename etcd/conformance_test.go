@@ -0,0 +1,27 @@
+package etcd
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/demdxx/cloudregistry"
+	"github.com/demdxx/cloudregistry/registrytest"
+)
+
+// TestConformance runs the conformance suite against the server from CLOUDREGISTRY_TEST_ETCD_URI,
+// for example etcd://localhost:2379
+func TestConformance(t *testing.T) {
+	uri := os.Getenv("CLOUDREGISTRY_TEST_ETCD_URI")
+	if uri == "" {
+		t.Skip("CLOUDREGISTRY_TEST_ETCD_URI is not set")
+	}
+	registrytest.Run(t, func(t *testing.T) (cloudregistry.Registry, func(time.Duration)) {
+		registry, err := cloudregistry.Open(context.Background(), uri)
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		return registry, nil
+	})
+}
@@ -0,0 +1,20 @@
+package etcd
+
+import (
+	"context"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+func init() {
+	cloudregistry.RegisterDriver("etcd", open)
+	cloudregistry.RegisterDriver("etcds", open)
+}
+
+func open(ctx context.Context, uri string) (cloudregistry.Registry, error) {
+	registry, err := Connect(ctx, WithURI(uri))
+	if err != nil {
+		return nil, err
+	}
+	return registry, nil
+}
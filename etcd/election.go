@@ -0,0 +1,147 @@
+package etcd
+
+import (
+	"context"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+// NewElection creates the leader election candidate based on the etcd concurrency.Election,
+// every campaign has its own session which is closed on Resign.
+func (r *Registry) NewElection(ctx context.Context, prefix *cloudregistry.ServicePrefix) (cloudregistry.Election, error) {
+	return &election{registry: r, key: cloudregistry.ElectionKey(prefix)}, nil
+}
+
+type election struct {
+	registry *Registry
+	key      string
+	session  *concurrency.Session
+	election *concurrency.Election
+}
+
+// Campaign waits until the candidate is elected, the elected candidate updates the leader value.
+func (e *election) Campaign(ctx context.Context, value string) error {
+	if e.election != nil {
+		return e.registry.wrapError(e.election.Proclaim(ctx, value))
+	}
+	session, err := concurrency.NewSession(e.registry.cli, concurrency.WithTTL(lockSessionTTL))
+	if err != nil {
+		return e.registry.wrapError(err)
+	}
+	el := concurrency.NewElection(session, e.key)
+	if err := el.Campaign(ctx, value); err != nil {
+		_ = session.Close()
+		return e.registry.wrapError(err)
+	}
+	e.session, e.election = session, el
+	return nil
+}
+
+// Resign gives up the leadership and closes the session.
+func (e *election) Resign(ctx context.Context) error {
+	if e.election == nil {
+		return cloudregistry.ErrNotLeader
+	}
+	err := e.election.Resign(ctx)
+	if cerr := e.session.Close(); err == nil {
+		err = cerr
+	}
+	e.session, e.election = nil, nil
+	return e.registry.wrapError(err)
+}
+
+// Leader returns the value of the oldest candidate.
+func (e *election) Leader(ctx context.Context) (string, error) {
+	resp, err := e.leader(ctx)
+	if err != nil {
+		return "", e.registry.wrapError(err)
+	}
+	if len(resp.Kvs) == 0 {
+		return "", cloudregistry.ErrNotFound
+	}
+	return string(resp.Kvs[0].Value), nil
+}
+
+func (e *election) leader(ctx context.Context) (*clientv3.GetResponse, error) {
+	return e.registry.cli.Get(ctx, e.key+"/", clientv3.WithFirstCreate()...)
+}
+
+// Observe watches the candidate keys and sends the leader value after every change of the leader.
+// The failed watch is restarted with backoff from the current leader, the channel is closed only
+// when the context is done or the registry is closed.
+func (e *election) Observe(ctx context.Context) <-chan string {
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		var (
+			leader string
+			first  = true
+		)
+		send := func(resp *clientv3.GetResponse) bool {
+			value := ""
+			if len(resp.Kvs) > 0 {
+				value = string(resp.Kvs[0].Value)
+			}
+			if !first && value == leader {
+				return true
+			}
+			leader, first = value, false
+			select {
+			case ch <- value:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		delay := watchRetryMinDelay
+		for {
+			// The leader is read again after every interruption, so the changes in between are not lost
+			resp, err := e.leader(ctx)
+			if err != nil {
+				if !e.registry.waitRetry(ctx, &delay) {
+					return
+				}
+				continue
+			}
+			if !send(resp) {
+				return
+			}
+
+			compacted := false
+			wctx, cancel := context.WithCancel(ctx)
+			watchCh := e.registry.cli.Watch(wctx, e.key+"/", clientv3.WithPrefix(),
+				clientv3.WithRev(resp.Header.Revision+1))
+			for wresp := range watchCh {
+				if wresp.CompactRevision != 0 {
+					compacted = true
+					break
+				}
+				if wresp.Err() != nil {
+					break
+				}
+				delay = watchRetryMinDelay
+				if resp, err = e.leader(ctx); err != nil {
+					break
+				}
+				if !send(resp) {
+					cancel()
+					return
+				}
+			}
+			cancel()
+			if e.registry.watchDone(ctx) {
+				return
+			}
+			if !compacted && !e.registry.waitRetry(ctx, &delay) {
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+var _ cloudregistry.Elector = (*Registry)(nil)
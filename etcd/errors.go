@@ -0,0 +1,71 @@
+package etcd
+
+import (
+	"context"
+	"errors"
+
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+// errorKinds are the etcd errors whose gRPC codes don't match the error kind.
+var errorKinds = []struct{ err, kind error }{
+	{err: rpctypes.ErrKeyNotFound, kind: cloudregistry.ErrNotFound},
+	{err: rpctypes.ErrCompacted, kind: cloudregistry.ErrConflict},
+	{err: rpctypes.ErrFutureRev, kind: cloudregistry.ErrConflict},
+	{err: rpctypes.ErrAuthFailed, kind: cloudregistry.ErrPermissionDenied},
+	{err: rpctypes.ErrInvalidAuthToken, kind: cloudregistry.ErrPermissionDenied},
+	{err: rpctypes.ErrUserEmpty, kind: cloudregistry.ErrPermissionDenied},
+	{err: rpctypes.ErrTimeout, kind: cloudregistry.ErrTimeout},
+	{err: rpctypes.ErrTimeoutDueToLeaderFail, kind: cloudregistry.ErrTimeout},
+}
+
+// wrapError maps the etcd and gRPC errors onto the registry error kinds, the original error stays wrapped.
+func (r *Registry) wrapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	select {
+	case <-r.done:
+		return cloudregistry.NewError(cloudregistry.ErrClosed, err)
+	default:
+	}
+	for _, it := range errorKinds {
+		if errors.Is(err, it.err) {
+			return cloudregistry.NewError(it.kind, err)
+		}
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return cloudregistry.NewError(cloudregistry.ErrTimeout, err)
+	case errors.Is(err, clientv3.ErrNoAvailableEndpoints):
+		return cloudregistry.NewError(cloudregistry.ErrUnavailable, err)
+	}
+
+	code := codes.Unknown
+	var etcdErr rpctypes.EtcdError
+	if errors.As(err, &etcdErr) {
+		code = etcdErr.Code()
+	} else if st, ok := status.FromError(err); ok {
+		code = st.Code()
+	}
+	switch code {
+	case codes.NotFound:
+		return cloudregistry.NewError(cloudregistry.ErrNotFound, err)
+	case codes.AlreadyExists:
+		return cloudregistry.NewError(cloudregistry.ErrAlreadyExists, err)
+	case codes.FailedPrecondition, codes.Aborted:
+		return cloudregistry.NewError(cloudregistry.ErrConflict, err)
+	case codes.Unavailable, codes.ResourceExhausted:
+		return cloudregistry.NewError(cloudregistry.ErrUnavailable, err)
+	case codes.PermissionDenied, codes.Unauthenticated:
+		return cloudregistry.NewError(cloudregistry.ErrPermissionDenied, err)
+	case codes.DeadlineExceeded:
+		return cloudregistry.NewError(cloudregistry.ErrTimeout, err)
+	}
+	return err
+}
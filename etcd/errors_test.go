@@ -0,0 +1,59 @@
+package etcd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+func TestRegistry_wrapError(t *testing.T) {
+	registry := &Registry{done: make(chan struct{})}
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{name: "nil", err: nil, want: nil},
+		{name: "deadline", err: context.DeadlineExceeded, want: cloudregistry.ErrTimeout},
+		{name: "no endpoints", err: clientv3.ErrNoAvailableEndpoints, want: cloudregistry.ErrUnavailable},
+		{name: "lease not found", err: rpctypes.ErrLeaseNotFound, want: cloudregistry.ErrNotFound},
+		{name: "permission denied", err: rpctypes.ErrPermissionDenied, want: cloudregistry.ErrPermissionDenied},
+		{name: "auth failed", err: rpctypes.ErrAuthFailed, want: cloudregistry.ErrPermissionDenied},
+		{name: "no leader", err: rpctypes.ErrNoLeader, want: cloudregistry.ErrUnavailable},
+		{name: "key not found", err: rpctypes.ErrKeyNotFound, want: cloudregistry.ErrNotFound},
+		{name: "server timeout", err: rpctypes.ErrTimeout, want: cloudregistry.ErrTimeout},
+		{name: "compacted", err: rpctypes.ErrCompacted, want: cloudregistry.ErrConflict},
+		{name: "grpc status", err: status.Error(codes.Unavailable, "connection refused"), want: cloudregistry.ErrUnavailable},
+		{name: "wrapped", err: fmt.Errorf("get: %w", rpctypes.ErrGRPCPermissionDenied), want: cloudregistry.ErrPermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := registry.wrapError(tt.err)
+			if tt.want == nil {
+				if got != nil {
+					t.Errorf("wrapError() = %v, want nil", got)
+				}
+				return
+			}
+			if !errors.Is(got, tt.want) {
+				t.Errorf("wrapError() = %v, want %v", got, tt.want)
+			}
+			if !errors.Is(got, tt.err) {
+				t.Errorf("wrapError() = %v does not wrap %v", got, tt.err)
+			}
+		})
+	}
+
+	close(registry.done)
+	if err := registry.wrapError(context.Canceled); !errors.Is(err, cloudregistry.ErrClosed) {
+		t.Errorf("wrapError() after Close() = %v, want %v", err, cloudregistry.ErrClosed)
+	}
+}
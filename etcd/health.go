@@ -0,0 +1,138 @@
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+// ReportHealth stores the health status in the service key and refreshes its lease.
+// The key is updated only if it was not changed concurrently, so the status is not lost.
+func (r *Registry) ReportHealth(ctx context.Context, id *cloudregistry.ServiceID, status cloudregistry.HealthStatus, output string) error {
+	key := serviceKey(id)
+	resp, err := r.cli.Get(ctx, key)
+	if err != nil {
+		return r.wrapError(err)
+	}
+	if len(resp.Kvs) == 0 {
+		return cloudregistry.ErrNotFound
+	}
+	kv := resp.Kvs[0]
+
+	var service cloudregistry.ServiceInfo
+	if err := json.Unmarshal(kv.Value, &service); err != nil {
+		return err
+	}
+	service.Health = cloudregistry.Health{Status: status, Output: output, LastCheck: time.Now()}
+	data, err := json.Marshal(&service)
+	if err != nil {
+		return err
+	}
+
+	txn, err := r.cli.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", kv.ModRevision)).
+		Then(clientv3.OpPut(key, string(data), clientv3.WithIgnoreLease())).
+		Commit()
+	if err != nil {
+		return r.wrapError(err)
+	}
+	if !txn.Succeeded {
+		return cloudregistry.ErrConflict
+	}
+	return r.keepAliveOnce(ctx, clientv3.LeaseID(kv.Lease))
+}
+
+// leaseHealthTTL is how long the remaining TTL of the lease is reused by Discover,
+// the etcd leases count the TTL in seconds.
+const leaseHealthTTL = time.Second
+
+// leaseState is the TimeToLive answer of the lease.
+type leaseState struct {
+	granted, ttl int64
+	fetched      time.Time
+}
+
+// leaseCache keeps the TimeToLive answers shared by the Discover calls,
+// so each distinct lease is requested at most once per leaseHealthTTL.
+type leaseCache struct {
+	mx     sync.Mutex
+	leases map[clientv3.LeaseID]leaseState
+}
+
+func newLeaseCache() *leaseCache {
+	return &leaseCache{leases: map[clientv3.LeaseID]leaseState{}}
+}
+
+// get returns the fresh state of the lease and drops the stale ones.
+func (c *leaseCache) get(leaseID clientv3.LeaseID, now time.Time) (leaseState, bool) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	for id, state := range c.leases {
+		if now.Sub(state.fetched) >= leaseHealthTTL {
+			delete(c.leases, id)
+		}
+	}
+	state, ok := c.leases[leaseID]
+	return state, ok
+}
+
+func (c *leaseCache) set(leaseID clientv3.LeaseID, state leaseState) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.leases[leaseID] = state
+}
+
+// leaseTTL returns the state of the lease from the cache or requests it once.
+func (r *Registry) leaseTTL(ctx context.Context, leaseID clientv3.LeaseID, now time.Time) (leaseState, error) {
+	if state, ok := r.leases.get(leaseID, now); ok {
+		return state, nil
+	}
+	resp, err := r.cli.TimeToLive(ctx, leaseID)
+	if err != nil {
+		return leaseState{}, err
+	}
+	state := leaseState{granted: resp.GrantedTTL, ttl: resp.TTL, fetched: now}
+	r.leases.set(leaseID, state)
+	return state, nil
+}
+
+// applyLeaseHealth sets the last check time of the instances from the remaining TTL of their leases,
+// the instances with the expired leases are critical. The lease errors leave the stored health as is.
+// The instances sharing the lease and the repeated calls reuse its TimeToLive answer.
+func (r *Registry) applyLeaseHealth(ctx context.Context, kvs []*mvccpb.KeyValue, services []*cloudregistry.ServiceInfo) {
+	leases := make(map[string]clientv3.LeaseID, len(kvs))
+	for _, kv := range kvs {
+		leases[string(kv.Key)] = clientv3.LeaseID(kv.Lease)
+	}
+	now := time.Now()
+	for _, service := range services {
+		leaseID := leases[serviceKey(&cloudregistry.ServiceID{
+			Name:       service.Name,
+			Namespace:  service.Namespace,
+			Partition:  service.Partition,
+			InstanceID: service.InstanceID,
+		})]
+		if leaseID == 0 {
+			continue
+		}
+		state, err := r.leaseTTL(ctx, leaseID, now)
+		if err != nil {
+			continue
+		}
+		if state.ttl <= 0 {
+			service.Health = cloudregistry.Health{Status: cloudregistry.HealthCritical, Output: "lease expired", LastCheck: service.Health.LastCheck}
+			continue
+		}
+		// The lease is refreshed to the granted TTL, so the elapsed part is the time since the last refresh
+		refreshed := state.fetched.Add(-time.Duration(state.granted-state.ttl) * time.Second)
+		if refreshed.After(service.Health.LastCheck) {
+			service.Health.LastCheck = refreshed
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package etcd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLeaseCache(t *testing.T) {
+	cache := newLeaseCache()
+	now := time.Now()
+	cache.set(1, leaseState{granted: 30, ttl: 20, fetched: now})
+	cache.set(2, leaseState{granted: 30, ttl: 30, fetched: now.Add(-leaseHealthTTL)})
+
+	if state, ok := cache.get(1, now.Add(leaseHealthTTL/2)); !ok || state.ttl != 20 {
+		t.Errorf("get(1) = %+v, %t, want the cached state", state, ok)
+	}
+	if _, ok := cache.get(2, now); ok {
+		t.Error("get(2) should miss the stale state")
+	}
+	if _, ok := cache.get(1, now.Add(leaseHealthTTL)); ok {
+		t.Error("get(1) should miss the state after leaseHealthTTL")
+	}
+	if len(cache.leases) != 0 {
+		t.Errorf("cache keeps %d stale leases, want 0", len(cache.leases))
+	}
+}
@@ -0,0 +1,57 @@
+package etcd
+
+import (
+	"context"
+	"strings"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+// DeleteValue deletes the value from the cloud registry.
+func (r *Registry) DeleteValue(ctx context.Context, name string) error {
+	_, err := r.cli.Delete(ctx, r.prefix+name)
+	return r.wrapError(err)
+}
+
+// DeletePrefix deletes all values with the prefix from the cloud registry.
+func (r *Registry) DeletePrefix(ctx context.Context, prefix string) error {
+	_, err := r.cli.Delete(ctx, r.prefix+prefix, clientv3.WithPrefix())
+	return r.wrapError(err)
+}
+
+// ListValues returns the values with the prefix, the version is the modification revision.
+func (r *Registry) ListValues(ctx context.Context, prefix string) ([]*cloudregistry.KeyValue, error) {
+	resp, err := r.cli.Get(ctx, r.prefix+prefix, clientv3.WithPrefix(),
+		clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend))
+	if err != nil {
+		return nil, r.wrapError(err)
+	}
+	values := make([]*cloudregistry.KeyValue, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		values = append(values, &cloudregistry.KeyValue{
+			Key:     strings.TrimPrefix(string(kv.Key), r.prefix),
+			Value:   string(kv.Value),
+			Version: uint64(kv.ModRevision),
+		})
+	}
+	return values, nil
+}
+
+// CompareAndSwap sets the value in a transaction comparing the modification revision,
+// the zero version requires the key to be absent.
+func (r *Registry) CompareAndSwap(ctx context.Context, name string, version uint64, value string) (bool, error) {
+	key := r.prefix + name
+	cmp := clientv3.Compare(clientv3.ModRevision(key), "=", int64(version))
+	if version == 0 {
+		cmp = clientv3.Compare(clientv3.CreateRevision(key), "=", 0)
+	}
+	resp, err := r.cli.Txn(ctx).If(cmp).Then(clientv3.OpPut(key, value)).Commit()
+	if err != nil {
+		return false, r.wrapError(err)
+	}
+	return resp.Succeeded, nil
+}
+
+var _ cloudregistry.ValueStore = (*Registry)(nil)
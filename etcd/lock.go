@@ -0,0 +1,85 @@
+package etcd
+
+import (
+	"context"
+	"errors"
+
+	"go.etcd.io/etcd/client/v3/concurrency"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+// lockSessionTTL is the lease TTL in seconds of the lock session,
+// the lock is released by etcd if the client stops the lease keep-alive.
+const lockSessionTTL = 15
+
+// NewMutex creates the distributed mutex based on the etcd concurrency.Mutex,
+// every acquisition has its own session which is closed on Unlock.
+func (r *Registry) NewMutex(ctx context.Context, name string) (cloudregistry.Mutex, error) {
+	lost := make(chan struct{})
+	close(lost)
+	return &mutex{registry: r, key: r.prefix + "locks/" + name, lost: lost}, nil
+}
+
+type mutex struct {
+	registry *Registry
+	key      string
+	session  *concurrency.Session
+	mutex    *concurrency.Mutex
+	lost     <-chan struct{}
+}
+
+// Lock acquires the lock, waiting until it is released or the context is done.
+func (m *mutex) Lock(ctx context.Context) error {
+	return m.acquire(ctx, false)
+}
+
+// TryLock acquires the lock if it is free, otherwise returns ErrLocked.
+func (m *mutex) TryLock(ctx context.Context) error {
+	return m.acquire(ctx, true)
+}
+
+func (m *mutex) acquire(ctx context.Context, try bool) error {
+	if m.mutex != nil {
+		return cloudregistry.ErrLocked
+	}
+	session, err := concurrency.NewSession(m.registry.cli, concurrency.WithTTL(lockSessionTTL))
+	if err != nil {
+		return m.registry.wrapError(err)
+	}
+	mx := concurrency.NewMutex(session, m.key)
+	if try {
+		err = mx.TryLock(ctx)
+	} else {
+		err = mx.Lock(ctx)
+	}
+	if err != nil {
+		_ = session.Close()
+		if errors.Is(err, concurrency.ErrLocked) {
+			return cloudregistry.ErrLocked
+		}
+		return m.registry.wrapError(err)
+	}
+	m.session, m.mutex, m.lost = session, mx, session.Done()
+	return nil
+}
+
+// Unlock releases the lock and closes the session.
+func (m *mutex) Unlock(ctx context.Context) error {
+	if m.mutex == nil {
+		return cloudregistry.ErrNotLocked
+	}
+	err := m.mutex.Unlock(ctx)
+	if cerr := m.session.Close(); err == nil {
+		err = cerr
+	}
+	m.session, m.mutex = nil, nil
+	return m.registry.wrapError(err)
+}
+
+// Lost returns the channel closed when the session of the lock is closed or expired.
+func (m *mutex) Lost() <-chan struct{} {
+	return m.lost
+}
+
+var _ cloudregistry.Locker = (*Registry)(nil)
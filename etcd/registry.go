@@ -4,31 +4,29 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"strings"
 	"sync"
 	"time"
 
 	errorsw "github.com/pkg/errors"
+	"go.etcd.io/etcd/api/v3/mvccpb"
 	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
 	clientv3 "go.etcd.io/etcd/client/v3"
 
 	"github.com/demdxx/cloudregistry"
 )
 
-type valueWatcherWrapper struct {
-	value   cloudregistry.ValueSetter
-	watcher clientv3.WatchChan
-}
-
 // Registry is the etcd registry implementation.
 type Registry struct {
-	watcherWg   sync.WaitGroup
-	watcherOnce sync.Once
-	done        chan struct{}
-
-	cli      *clientv3.Client
-	prefix   string
-	watchers chan *valueWatcherWrapper
-	parent   *Registry
+	watcherWg sync.WaitGroup
+	closeOnce sync.Once
+	done      chan struct{}
+
+	cli    *clientv3.Client
+	prefix string
+	codec  cloudregistry.Codec
+	leases *leaseCache
+	parent *Registry
 }
 
 // Connect connects to the cloud registry.
@@ -50,9 +48,10 @@ func Connect(ctx context.Context, options ...Option) (*Registry, error) {
 // NewRegistry creates a new etcd registry.
 func NewRegistry(cli *clientv3.Client) *Registry {
 	return &Registry{
-		cli:      cli,
-		done:     make(chan struct{}, 1),
-		watchers: make(chan *valueWatcherWrapper, 100),
+		cli:    cli,
+		done:   make(chan struct{}),
+		codec:  cloudregistry.DefaultCodec,
+		leases: newLeaseCache(),
 	}
 }
 
@@ -62,17 +61,22 @@ func (r *Registry) Register(ctx context.Context, service *cloudregistry.Service)
 	leaseTTL := int64(service.Check.TTL.Seconds())
 	leaseResp, err := r.cli.Grant(ctx, leaseTTL)
 	if err != nil {
-		return err
+		return r.wrapError(err)
 	}
 
 	// Prepare the service information
 	serviceInfo := &cloudregistry.ServiceInfo{
 		Name:       service.Name,
+		Namespace:  service.Namespace,
+		Partition:  service.Partition,
 		InstanceID: service.InstanceID,
 		Hostname:   service.Hostname,
 		Port:       service.Port,
 		Public:     service.Public,
 		Private:    service.Private,
+		Tags:       service.Tags,
+		Meta:       service.Meta,
+		Health:     cloudregistry.Health{Status: cloudregistry.HealthPassing, LastCheck: time.Now()},
 		LastUpdate: time.Now(),
 	}
 
@@ -83,16 +87,16 @@ func (r *Registry) Register(ctx context.Context, service *cloudregistry.Service)
 	}
 
 	// Put the service data into etcd under the key with the lease
-	_, err = r.cli.Put(ctx, service.ID().String(),
+	_, err = r.cli.Put(ctx, serviceKey(service.ID()),
 		string(data), clientv3.WithLease(leaseResp.ID))
 	if err != nil {
-		return err
+		return r.wrapError(err)
 	}
 
 	// Keep the lease alive in a background goroutine
 	ch, err := r.cli.KeepAlive(ctx, leaseResp.ID)
 	if err != nil {
-		return err
+		return r.wrapError(err)
 	}
 
 	go func() {
@@ -116,8 +120,8 @@ func (r *Registry) Register(ctx context.Context, service *cloudregistry.Service)
 
 // Deregister deregisters a service from the cloud registry.
 func (r *Registry) Deregister(ctx context.Context, id *cloudregistry.ServiceID) error {
-	_, err := r.cli.Delete(ctx, id.String())
-	return err
+	_, err := r.cli.Delete(ctx, serviceKey(id))
+	return r.wrapError(err)
 }
 
 // Discover discovers a service in the cloud registry.
@@ -125,46 +129,55 @@ func (r *Registry) Discover(ctx context.Context, prefix *cloudregistry.ServicePr
 	// Get all keys under the service name
 	resp, err := r.cli.Get(ctx, prefix.String(), clientv3.WithPrefix())
 	if err != nil {
-		return nil, err
+		return nil, r.wrapError(err)
 	}
 
-	services := make([]*cloudregistry.ServiceInfo, 0, len(resp.Kvs))
-
-	for _, kv := range resp.Kvs {
-		service := new(cloudregistry.ServiceInfo)
-		err := json.Unmarshal(kv.Value, service)
-		if err != nil {
-			continue // Skip invalid entries
-		}
-		services = append(services, service)
-	}
+	services := decodeServices(resp.Kvs)
 
 	if len(services) == 0 {
 		return nil, cloudregistry.ErrNotFound
 	}
 
+	r.applyLeaseHealth(ctx, resp.Kvs, services)
 	return services, nil
 }
 
+// ListServices returns the services with the registered instances in the namespace.
+func (r *Registry) ListServices(ctx context.Context, namespace string) ([]*cloudregistry.ServicePrefix, error) {
+	prefix := "services/"
+	if namespace != "" {
+		prefix += namespace + "/"
+	}
+	resp, err := r.cli.Get(ctx, prefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, r.wrapError(err)
+	}
+	return cloudregistry.ServicePrefixes(decodeServices(resp.Kvs), namespace), nil
+}
+
 // HealthCheck checks the health of a service in the cloud registry.
 func (r *Registry) HealthCheck(ctx context.Context, id *cloudregistry.ServiceID, TTL time.Duration) error {
 	// Retrieve the lease ID associated with the service key
-	resp, err := r.cli.Get(ctx, id.String(), clientv3.WithKeysOnly())
+	resp, err := r.cli.Get(ctx, serviceKey(id), clientv3.WithKeysOnly())
 	if err != nil {
-		return err
+		return r.wrapError(err)
 	}
 
 	if len(resp.Kvs) == 0 {
 		return cloudregistry.ErrNotFound
 	}
 
-	leaseID := clientv3.LeaseID(resp.Kvs[0].Lease)
+	return r.keepAliveOnce(ctx, clientv3.LeaseID(resp.Kvs[0].Lease))
+}
+
+// keepAliveOnce refreshes the lease of the service key, ErrNotReady is returned if the lease is gone.
+func (r *Registry) keepAliveOnce(ctx context.Context, leaseID clientv3.LeaseID) error {
 	if leaseID == 0 {
 		return cloudregistry.ErrNotReady
 	}
 
 	// Keep the lease alive once
-	_, err = r.cli.KeepAliveOnce(ctx, leaseID)
+	_, err := r.cli.KeepAliveOnce(ctx, leaseID)
 	switch err.(type) {
 	case clientv3.ErrKeepAliveHalted:
 		return errorsw.Wrap(cloudregistry.ErrNotReady, err.Error())
@@ -173,28 +186,46 @@ func (r *Registry) HealthCheck(ctx context.Context, id *cloudregistry.ServiceID,
 			return cloudregistry.ErrNotReady
 		}
 	}
-	return err
+	return r.wrapError(err)
 }
 
 // Values returns a ValueClient to interact with the cloud registry.
 func (r *Registry) Values(ctx context.Context, prefix ...string) cloudregistry.ValueClient {
 	if len(prefix) > 0 {
 		return &Registry{
-			done:     r.done,
-			cli:      r.cli,
-			prefix:   r.prefix + prefix[0],
-			watchers: r.watchers,
-			parent:   r,
+			done:   r.done,
+			cli:    r.cli,
+			prefix: r.prefix + prefix[0],
+			codec:  r.codec,
+			leases: r.leases,
+			parent: r,
 		}
 	}
 	return r
 }
 
+// Codec returns the codec decoding the subscription values.
+func (r *Registry) Codec() cloudregistry.Codec {
+	return r.codec
+}
+
+// WithCodec returns a ValueClient with the same prefix using the codec.
+func (r *Registry) WithCodec(codec cloudregistry.Codec) cloudregistry.ValueClient {
+	return &Registry{
+		done:   r.done,
+		cli:    r.cli,
+		prefix: r.prefix,
+		codec:  codec,
+		leases: r.leases,
+		parent: r,
+	}
+}
+
 // Value returns a value from the cloud registry.
 func (r *Registry) Value(ctx context.Context, name string) (string, error) {
 	resp, err := r.cli.Get(ctx, r.prefix+name)
 	if err != nil {
-		return "", err
+		return "", r.wrapError(err)
 	}
 	if len(resp.Kvs) == 0 {
 		return "", cloudregistry.ErrNotFound
@@ -205,79 +236,107 @@ func (r *Registry) Value(ctx context.Context, name string) (string, error) {
 // SetValue sets a value in the cloud registry.
 func (r *Registry) SetValue(ctx context.Context, name, value string) error {
 	_, err := r.cli.Put(ctx, r.prefix+name, value)
-	return err
+	return r.wrapError(err)
 }
 
 // SubscribeValue subscribes to a value in the cloud registry.
 func (r *Registry) SubscribeValue(ctx context.Context, name string, val cloudregistry.ValueSetter) error {
 	ch := r.cli.Watch(ctx, r.prefix+name)
-	return r.subscriveValue(ch, val)
+	return r.subscriveValue(ch, r.valueSetter(val))
 }
 
 // SubscribeValueWithPrefix subscribes to a value in the cloud registry.
 func (r *Registry) SubscribeValueWithPrefix(ctx context.Context, prefix string, val cloudregistry.ValueSetter) error {
 	ch := r.cli.Watch(ctx, r.prefix+prefix, clientv3.WithPrefix())
-	return r.subscriveValue(ch, val)
+	return r.subscriveValue(ch, r.valueSetter(val))
 }
 
-func (r *Registry) subscriveValue(watcher clientv3.WatchChan, val cloudregistry.ValueSetter) error {
+// valueSetter decodes the watched values, the keys are trimmed to be relative to the client prefix.
+func (r *Registry) valueSetter(val cloudregistry.ValueSetter) func(key string, data []byte) error {
+	setValue := cloudregistry.DecodeValueSetter(r.codec, val)
+	return func(key string, data []byte) error {
+		return setValue(strings.TrimPrefix(key, r.prefix), data)
+	}
+}
+
+func (r *Registry) subscriveValue(watcher clientv3.WatchChan, val func(key string, data []byte) error) error {
 	if r.parent != nil {
 		return r.parent.subscriveValue(watcher, val)
 	}
-	r.watchers <- &valueWatcherWrapper{value: val, watcher: watcher}
-	r.watcherOnce.Do(func() {
-		r.watcherWg.Add(1)
-		go r.valueWatcher(r.cli.Ctx())
-	})
+	// Every subscription has its own goroutine, so a quiet key never delays the others
+	r.watcherWg.Add(1)
+	go r.valueWatcher(watcher, val)
 	return nil
 }
 
-func (r *Registry) valueWatcher(ctx context.Context) {
+func (r *Registry) valueWatcher(watcher clientv3.WatchChan, val func(key string, data []byte) error) {
 	defer r.watcherWg.Done()
 	for {
 		select {
-		case <-ctx.Done():
-			return
 		case <-r.done:
 			return
-		case wr, ok := <-r.watchers:
+		case wresp, ok := <-watcher:
 			if !ok {
 				return
 			}
-			if wresp, ok := <-wr.watcher; ok {
-				for _, ev := range wresp.Events {
-					var value any
-					if err := json.Unmarshal(ev.Kv.Value, &value); err != nil {
-						value = string(ev.Kv.Value)
-					}
-					if err := wr.value.SetValue(string(ev.Kv.Key), value); err != nil {
-						continue
-					}
+			for _, ev := range wresp.Events {
+				data := ev.Kv.Value
+				switch {
+				case ev.Type == mvccpb.DELETE:
+					data = nil // The deleted key is delivered as the nil value
+				case data == nil:
+					data = []byte{}
 				}
+				// The values which can't be decoded are skipped
+				_ = val(string(ev.Kv.Key), data)
 			}
-			select {
-			case <-ctx.Done():
-				return
-			case <-r.done:
-				return
-			default:
-				r.watchers <- wr
-			}
 		}
 	}
 }
 
+func decodeServices(kvs []*mvccpb.KeyValue) []*cloudregistry.ServiceInfo {
+	services := make([]*cloudregistry.ServiceInfo, 0, len(kvs))
+	for _, kv := range kvs {
+		service := new(cloudregistry.ServiceInfo)
+		err := json.Unmarshal(kv.Value, service)
+		if err != nil {
+			continue // Skip invalid entries
+		}
+		// The instances registered before the health status was stored are alive while their key exists
+		if service.Health.Status == cloudregistry.HealthUnknown {
+			service.Health.Status = cloudregistry.HealthPassing
+		}
+		services = append(services, service)
+	}
+	return services
+}
+
+// serviceKey returns the key of the service instance, the service ID string is shared by all instances.
+func serviceKey(id *cloudregistry.ServiceID) string {
+	return id.String() + id.InstanceID
+}
+
 // Close closes the cloud registry connection.
 func (r *Registry) Close() (err error) {
-	// Signal the watcher to stop
-	r.done <- struct{}{}
-
-	// Close the watchers channel to signal watchers to stop
-	close(r.watchers)
+	if r.parent != nil {
+		return r.parent.Close()
+	}
+	r.closeOnce.Do(func() {
+		// Signal the watchers and keep-alive routines to stop
+		close(r.done)
 
-	// Wait for all watchers to finish
-	r.watcherWg.Wait()
+		// Wait for all watchers to finish
+		r.watcherWg.Wait()
 
-	// Close the etcd client
-	return r.cli.Close()
+		// Close the etcd client
+		err = r.cli.Close()
+	})
+	return err
 }
+
+var (
+	_ cloudregistry.Registry       = (*Registry)(nil)
+	_ cloudregistry.CodecClient    = (*Registry)(nil)
+	_ cloudregistry.HealthReporter = (*Registry)(nil)
+	_ cloudregistry.ServiceLister  = (*Registry)(nil)
+)
@@ -0,0 +1,162 @@
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+const (
+	watchRetryMinDelay = 100 * time.Millisecond
+	watchRetryMaxDelay = 10 * time.Second
+)
+
+// WatchServices watches the service instances under the prefix using etcd prefix watch.
+// The first event is a snapshot of the current instances, next events reflect the changes.
+// The failed watch is restarted with backoff, the channel is closed only when the context
+// is done or the registry is closed.
+func (r *Registry) WatchServices(ctx context.Context, prefix *cloudregistry.ServicePrefix) (<-chan cloudregistry.ServiceEvent, error) {
+	key := prefix.String()
+	resp, err := r.cli.Get(ctx, key, clientv3.WithPrefix())
+	if err != nil {
+		return nil, r.wrapError(err)
+	}
+
+	events := make(chan cloudregistry.ServiceEvent, 1)
+	go func() {
+		defer close(events)
+
+		services := decodeServices(resp.Kvs)
+		if !sendServiceEvent(ctx, events, cloudregistry.ServiceEvent{
+			Type: cloudregistry.ServiceSnapshot, Services: services,
+		}) {
+			return
+		}
+
+		known := make(map[string]*cloudregistry.ServiceInfo, len(services))
+		for _, svc := range services {
+			known[svc.InstanceID] = svc
+		}
+
+		rev := resp.Header.Revision + 1
+		delay := watchRetryMinDelay
+		for {
+			compacted := false
+			wctx, cancel := context.WithCancel(ctx)
+			wch := r.cli.Watch(wctx, key, clientv3.WithPrefix(), clientv3.WithPrevKV(), clientv3.WithRev(rev))
+			for wresp := range wch {
+				if wresp.CompactRevision != 0 {
+					// The history is compacted, resync the state from the current snapshot
+					compacted = true
+					break
+				}
+				if wresp.Err() != nil {
+					break
+				}
+				delay = watchRetryMinDelay
+				rev = wresp.Header.Revision + 1
+				for _, ev := range wresp.Events {
+					event, ok := serviceEventFromWatch(ev, known)
+					if ok && !sendServiceEvent(ctx, events, event) {
+						cancel()
+						return
+					}
+				}
+			}
+			cancel()
+			if r.watchDone(ctx) {
+				return
+			}
+			// The watch failed or was closed by the server, wait before restarting it
+			if !compacted && !r.waitRetry(ctx, &delay) {
+				return
+			}
+
+			// Resync after the watch was interrupted, the changes in between are reported as the difference
+			resp, err := r.cli.Get(ctx, key, clientv3.WithPrefix())
+			for err != nil {
+				if !r.waitRetry(ctx, &delay) {
+					return
+				}
+				resp, err = r.cli.Get(ctx, key, clientv3.WithPrefix())
+			}
+			prev := make([]*cloudregistry.ServiceInfo, 0, len(known))
+			for _, svc := range known {
+				prev = append(prev, svc)
+			}
+			next := decodeServices(resp.Kvs)
+			clear(known)
+			for _, svc := range next {
+				known[svc.InstanceID] = svc
+			}
+			for _, event := range cloudregistry.DiffServices(prev, next) {
+				if !sendServiceEvent(ctx, events, event) {
+					return
+				}
+			}
+			rev = resp.Header.Revision + 1
+		}
+	}()
+	return events, nil
+}
+
+func serviceEventFromWatch(ev *clientv3.Event, known map[string]*cloudregistry.ServiceInfo) (cloudregistry.ServiceEvent, bool) {
+	if ev.Type == clientv3.EventTypeDelete {
+		if ev.PrevKv == nil {
+			return cloudregistry.ServiceEvent{}, false
+		}
+		service := new(cloudregistry.ServiceInfo)
+		if err := json.Unmarshal(ev.PrevKv.Value, service); err != nil {
+			return cloudregistry.ServiceEvent{}, false
+		}
+		delete(known, service.InstanceID)
+		return cloudregistry.ServiceEvent{Type: cloudregistry.ServiceRemoved, Service: service}, true
+	}
+
+	service := new(cloudregistry.ServiceInfo)
+	if err := json.Unmarshal(ev.Kv.Value, service); err != nil {
+		return cloudregistry.ServiceEvent{}, false
+	}
+	_, exists := known[service.InstanceID]
+	known[service.InstanceID] = service
+	if exists {
+		return cloudregistry.ServiceEvent{Type: cloudregistry.ServiceUpdated, Service: service}, true
+	}
+	return cloudregistry.ServiceEvent{Type: cloudregistry.ServiceAdded, Service: service}, true
+}
+
+// watchDone reports whether the watch context is done or the client is closed.
+func (r *Registry) watchDone(ctx context.Context) bool {
+	return ctx.Err() != nil || r.cli.Ctx().Err() != nil
+}
+
+// waitRetry waits for the retry delay and doubles it up to the maximum,
+// false is returned if the context is done or the client is closed.
+func (r *Registry) waitRetry(ctx context.Context, delay *time.Duration) bool {
+	timer := time.NewTimer(*delay)
+	defer timer.Stop()
+	*delay = min(*delay*2, watchRetryMaxDelay)
+	select {
+	case <-ctx.Done():
+		return false
+	case <-r.cli.Ctx().Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func sendServiceEvent(ctx context.Context, events chan<- cloudregistry.ServiceEvent, event cloudregistry.ServiceEvent) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case events <- event:
+		return true
+	}
+}
+
+var _ cloudregistry.ServiceWatcher = (*Registry)(nil)
@@ -2,19 +2,17 @@ package main
 
 import (
 	"context"
-	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
-	"strings"
 	"time"
 
 	"github.com/demdxx/cloudregistry"
-	"github.com/demdxx/cloudregistry/consul"
-	"github.com/demdxx/cloudregistry/etcd"
-	"github.com/demdxx/cloudregistry/zookeeper"
+	_ "github.com/demdxx/cloudregistry/consul"
+	_ "github.com/demdxx/cloudregistry/etcd"
+	_ "github.com/demdxx/cloudregistry/zookeeper"
 )
 
 func main() {
@@ -35,7 +33,7 @@ func main() {
 	defer cancel()
 
 	// Connect to the registry
-	registry, err := connectRegistry(ctx, *registryConnect)
+	registry, err := cloudregistry.Open(ctx, *registryConnect)
 	if err != nil {
 		log.Fatal("Connect registry", err)
 		return
@@ -97,18 +95,3 @@ func main() {
 		}
 	}
 }
-
-// Connect to the registry
-func connectRegistry(ctx context.Context, conn string) (cloudregistry.Registry, error) {
-	switch {
-	case strings.HasPrefix(conn, "etcd://"):
-		return etcd.Connect(ctx, etcd.WithURI(conn))
-	case strings.HasPrefix(conn, "consul://"):
-		return consul.Connect(ctx, consul.WithURI(conn))
-	case strings.HasPrefix(conn, "zookeeper://"), strings.HasPrefix(conn, "zk://"):
-		// Use the new WithURI option for ZooKeeper
-		return zookeeper.Connect(ctx, zookeeper.WithURI(conn))
-	default:
-		return nil, errors.New("unsupported registry connection string")
-	}
-}
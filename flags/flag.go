@@ -0,0 +1,149 @@
+package flags
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/demdxx/gocast/v2"
+)
+
+// DefaultSubject is the attribute hashed for the percentage rollouts if the flag does not set one.
+const DefaultSubject = "id"
+
+// rolloutBuckets is the number of the hash buckets, so the rollout has the 0.01% precision.
+const rolloutBuckets = 10000
+
+// Attributes describe the subject of the evaluation, like the user ID, country or plan.
+type Attributes map[string]string
+
+// Flag is the feature flag document stored as the registry value.
+//
+// The flag is evaluated in the order: the disabled flag is off, the subjects matching Deny are off,
+// the subjects matching Allow are on, then the Rollout percentage of the subjects is on.
+//
+//	{"enabled": true, "rollout": 25, "allow": {"plan": ["beta"]}, "deny": {"country": ["NL"]}}
+type Flag struct {
+	// Enabled is the main switch of the flag.
+	Enabled bool `json:"enabled"`
+	// Rollout is the percentage of the subjects with the flag on, nil means all of them.
+	Rollout *float64 `json:"rollout,omitempty"`
+	// Subject is the attribute hashed to select the rollout subjects, DefaultSubject if empty.
+	// The subjects without the attribute are not in the partial rollout.
+	Subject string `json:"subject,omitempty"`
+	// Allow lists the attribute values which always have the flag on.
+	Allow map[string][]string `json:"allow,omitempty"`
+	// Deny lists the attribute values which always have the flag off.
+	Deny map[string][]string `json:"deny,omitempty"`
+}
+
+// Evaluate reports whether the flag is on for the subject.
+// The rollout is deterministic, the same subject gets the same result for the flag name
+// in every process, and the subjects of a smaller rollout stay in the larger one.
+func (f *Flag) Evaluate(name string, attrs Attributes) bool {
+	if f == nil || !f.Enabled {
+		return false
+	}
+	if matchAttributes(f.Deny, attrs) {
+		return false
+	}
+	if matchAttributes(f.Allow, attrs) {
+		return true
+	}
+	if f.Rollout == nil || *f.Rollout >= 100 {
+		return true
+	}
+	if *f.Rollout <= 0 {
+		return false
+	}
+	subject := f.Subject
+	if subject == "" {
+		subject = DefaultSubject
+	}
+	value, ok := attrs[subject]
+	if !ok {
+		return false
+	}
+	return float64(bucket(name, value)) < *f.Rollout*rolloutBuckets/100
+}
+
+// Parse converts the registry value to the flag.
+// Besides the flag documents it accepts the plain toggles, like true, "on" or "off",
+// and the numbers which are the rollout percentage of the enabled flag.
+// The numeric strings are the percentages too, so "1" is the 1% rollout, not the enabled flag.
+func Parse(value any) (*Flag, error) {
+	switch val := value.(type) {
+	case *Flag:
+		return val, nil
+	case Flag:
+		return &val, nil
+	case bool:
+		return &Flag{Enabled: val}, nil
+	case float64:
+		return &Flag{Enabled: true, Rollout: &val}, nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32:
+		// The YAML and TOML codecs decode the integers
+		rollout, err := gocast.TryNumber[float64](val)
+		if err != nil {
+			return nil, err
+		}
+		return &Flag{Enabled: true, Rollout: &rollout}, nil
+	case string:
+		return parseString(val)
+	case []byte:
+		return parseString(string(val))
+	case map[string]any:
+		data, err := json.Marshal(val)
+		if err != nil {
+			return nil, err
+		}
+		return parseDocument(data)
+	}
+	return nil, fmt.Errorf("unsupported flag value %T", value)
+}
+
+func parseString(s string) (*Flag, error) {
+	s = strings.TrimSpace(s)
+	switch strings.ToLower(s) {
+	case "on", "yes", "true":
+		return &Flag{Enabled: true}, nil
+	case "off", "no", "false", "":
+		return &Flag{Enabled: false}, nil
+	}
+	if strings.HasPrefix(s, "{") {
+		return parseDocument([]byte(s))
+	}
+	if rollout, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64); err == nil {
+		return &Flag{Enabled: true, Rollout: &rollout}, nil
+	}
+	return nil, fmt.Errorf("invalid flag value %q", s)
+}
+
+func parseDocument(data []byte) (*Flag, error) {
+	flag := &Flag{}
+	if err := json.Unmarshal(data, flag); err != nil {
+		return nil, fmt.Errorf("invalid flag document: %w", err)
+	}
+	return flag, nil
+}
+
+func matchAttributes(rules map[string][]string, attrs Attributes) bool {
+	for name, values := range rules {
+		if value, ok := attrs[name]; ok && slices.Contains(values, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// bucket returns the stable bucket of the subject for the flag.
+func bucket(name, subject string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(subject))
+	return h.Sum64() % rolloutBuckets
+}
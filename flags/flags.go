@@ -0,0 +1,158 @@
+// Package flags implements the feature flags stored in the cloud registry values.
+//
+// Every value under the prefix is a flag named by its key relative to the prefix, so the flags
+// are live-updated from the etcd, Consul, ZooKeeper or memory subscriptions and removed with their keys.
+// The values are the Flag documents, the plain toggles like "on" and "off" or the rollout percentages.
+//
+// Example:
+//
+//	features, err := flags.New(ctx, registry.Values(ctx, "app/"), "flags/")
+//	if err != nil {
+//		return err
+//	}
+//	if features.Enabled(ctx, "new-checkout", flags.Attributes{"id": userID, "plan": plan}) {
+//		// ...
+//	}
+package flags
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+// Option is a configuration option of the Client.
+type Option func(c *Client)
+
+// WithErrorHandler sets the handler of the values which are not valid flags,
+// the previous version of such flag is kept.
+func WithErrorHandler(handler func(name string, err error)) Option {
+	return func(c *Client) {
+		c.errHandler = handler
+	}
+}
+
+// Client evaluates the feature flags of the prefix.
+type Client struct {
+	mx     sync.RWMutex
+	prefix string
+	flags  map[string]*Flag
+	// updated is the set of the flags delivered by the subscription while the current values are listed,
+	// they are newer than the listed ones. It is nil after New returns.
+	updated    map[string]bool
+	errHandler func(name string, err error)
+}
+
+// New loads the flags under the prefix of the value client and subscribes to their changes,
+// the subscription is active until the context is done. The flags are listed, so the client
+// must implement cloudregistry.ValueStore, otherwise ErrValueStoreNotSupported is returned.
+func New(ctx context.Context, values cloudregistry.ValueClient, prefix string, options ...Option) (*Client, error) {
+	c := &Client{prefix: prefix, flags: map[string]*Flag{}, updated: map[string]bool{}}
+	for _, option := range options {
+		option(c)
+	}
+
+	// The subscriptions of some backends deliver only the changes, so the current values are listed
+	// after subscribing, the changes delivered in the meantime are not overwritten by the listing
+	if err := values.SubscribeValueWithPrefix(ctx, prefix, c); err != nil {
+		return nil, err
+	}
+	current, err := cloudregistry.ListValues(ctx, values, prefix)
+	if err != nil {
+		return nil, err
+	}
+	codec := cloudregistry.CodecOf(values)
+	for _, kv := range current {
+		value, err := codec.Decode([]byte(kv.Value))
+		if err != nil {
+			c.reportError(c.name(kv.Key), err)
+			continue
+		}
+		_ = c.setValue(kv.Key, value, true)
+	}
+
+	c.mx.Lock()
+	c.updated = nil
+	c.mx.Unlock()
+	return c, nil
+}
+
+// SetValue updates the flag named by the key relative to the prefix, the nil value removes the flag.
+// It makes the Client the ValueSetter of the value subscriptions.
+func (c *Client) SetValue(key string, value any) error {
+	return c.setValue(key, value, false)
+}
+
+// setValue updates the flag, the listed values are skipped if the subscription delivered a newer one.
+func (c *Client) setValue(key string, value any, listed bool) error {
+	name := c.name(key)
+	var flag *Flag
+	if value != nil {
+		var err error
+		if flag, err = Parse(value); err != nil {
+			c.reportError(name, err)
+			return err
+		}
+	}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	switch {
+	case listed && c.updated[name]:
+		return nil
+	case !listed && c.updated != nil:
+		c.updated[name] = true
+	}
+	if flag == nil {
+		delete(c.flags, name)
+	} else {
+		c.flags[name] = flag
+	}
+	return nil
+}
+
+// name returns the flag name of the key.
+func (c *Client) name(key string) string {
+	return strings.TrimPrefix(key, c.prefix)
+}
+
+// Flag returns the flag by name.
+func (c *Client) Flag(name string) (*Flag, bool) {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+	flag, ok := c.flags[name]
+	return flag, ok
+}
+
+// Enabled reports whether the flag is on for the subject, the unknown flags are off.
+func (c *Client) Enabled(ctx context.Context, name string, attrs Attributes) bool {
+	flag, _ := c.Flag(name)
+	return flag.Evaluate(name, attrs)
+}
+
+func (c *Client) reportError(name string, err error) {
+	if c.errHandler != nil {
+		c.errHandler(name, err)
+	}
+}
+
+var defaultClient atomic.Pointer[Client]
+
+// SetDefault makes the client used by the package-level Enabled.
+func SetDefault(c *Client) {
+	defaultClient.Store(c)
+}
+
+// Enabled reports whether the flag of the default client is on for the subject,
+// all flags are off until SetDefault is called.
+func Enabled(ctx context.Context, name string, attrs Attributes) bool {
+	if c := defaultClient.Load(); c != nil {
+		return c.Enabled(ctx, name, attrs)
+	}
+	return false
+}
+
+var _ cloudregistry.ValueSetter = (*Client)(nil)
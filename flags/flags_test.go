@@ -0,0 +1,219 @@
+package flags
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/demdxx/cloudregistry"
+	"github.com/demdxx/cloudregistry/memory"
+)
+
+func rollout(percent float64) *float64 { return &percent }
+
+func TestFlag_Evaluate(t *testing.T) {
+	tests := []struct {
+		name  string
+		flag  *Flag
+		attrs Attributes
+		want  bool
+	}{
+		{"nil", nil, Attributes{"id": "1"}, false},
+		{"disabled", &Flag{}, Attributes{"id": "1"}, false},
+		{"enabled", &Flag{Enabled: true}, nil, true},
+		{"deny", &Flag{Enabled: true, Deny: map[string][]string{"country": {"NL"}}}, Attributes{"country": "NL"}, false},
+		{"deny over allow", &Flag{
+			Enabled: true,
+			Allow:   map[string][]string{"plan": {"beta"}},
+			Deny:    map[string][]string{"country": {"NL"}},
+		}, Attributes{"plan": "beta", "country": "NL"}, false},
+		{"allow over rollout", &Flag{
+			Enabled: true, Rollout: rollout(0),
+			Allow: map[string][]string{"plan": {"beta"}},
+		}, Attributes{"plan": "beta"}, true},
+		{"zero rollout", &Flag{Enabled: true, Rollout: rollout(0)}, Attributes{"id": "1"}, false},
+		{"full rollout", &Flag{Enabled: true, Rollout: rollout(100)}, nil, true},
+		{"rollout without subject", &Flag{Enabled: true, Rollout: rollout(99.99)}, Attributes{"user": "1"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.flag.Evaluate("feature", tt.attrs); got != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlag_Rollout(t *testing.T) {
+	const subjects = 10000
+	quarter := &Flag{Enabled: true, Rollout: rollout(25)}
+	half := &Flag{Enabled: true, Rollout: rollout(50), Subject: "user"}
+	enabled := 0
+	for i := range subjects {
+		id := strconv.Itoa(i)
+		inQuarter := quarter.Evaluate("feature", Attributes{"id": id})
+		if inQuarter != quarter.Evaluate("feature", Attributes{"id": id}) {
+			t.Fatalf("Evaluate() is not deterministic for subject %s", id)
+		}
+		if inQuarter && !half.Evaluate("feature", Attributes{"user": id}) {
+			t.Fatalf("subject %s of the 25%% rollout is not in the 50%% rollout", id)
+		}
+		if inQuarter {
+			enabled++
+		}
+	}
+	if enabled < subjects*23/100 || enabled > subjects*27/100 {
+		t.Errorf("25%% rollout enabled %d of %d subjects", enabled, subjects)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		value   any
+		enabled bool
+		rollout float64
+		err     bool
+	}{
+		{value: true, enabled: true, rollout: -1},
+		{value: "on", enabled: true, rollout: -1},
+		{value: "off", rollout: -1},
+		{value: "false", rollout: -1},
+		{value: "TRUE", enabled: true, rollout: -1},
+		{value: float64(30), enabled: true, rollout: 30},
+		{value: float64(1), enabled: true, rollout: 1},
+		{value: "1", enabled: true, rollout: 1},
+		{value: "0", enabled: true, rollout: 0},
+		{value: "12.5%", enabled: true, rollout: 12.5},
+		{value: []byte(`{"enabled":true,"rollout":5}`), enabled: true, rollout: 5},
+		{value: map[string]any{"enabled": true}, enabled: true, rollout: -1},
+		{value: "maybe", err: true},
+		{value: `{"enabled":"yes"}`, err: true},
+		{value: 10, enabled: true, rollout: 10},
+		{value: int64(25), enabled: true, rollout: 25},
+		{value: uint8(5), enabled: true, rollout: 5},
+		{value: []string{"on"}, err: true},
+	}
+	for _, tt := range tests {
+		flag, err := Parse(tt.value)
+		if tt.err {
+			if err == nil {
+				t.Errorf("Parse(%#v) should fail", tt.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%#v) error = %v", tt.value, err)
+			continue
+		}
+		if flag.Enabled != tt.enabled {
+			t.Errorf("Parse(%#v).Enabled = %v, want %v", tt.value, flag.Enabled, tt.enabled)
+		}
+		if tt.rollout < 0 && flag.Rollout != nil || tt.rollout >= 0 && (flag.Rollout == nil || *flag.Rollout != tt.rollout) {
+			t.Errorf("Parse(%#v).Rollout = %v, want %v", tt.value, flag.Rollout, tt.rollout)
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	registry := memory.NewRegistry()
+	values := registry.Values(ctx, "app/")
+	_ = values.SetValue(ctx, "flags/checkout", `{"enabled":true,"allow":{"plan":["beta"]},"rollout":0}`)
+
+	var invalid []string
+	client, err := New(ctx, values, "flags/", WithErrorHandler(func(name string, err error) {
+		invalid = append(invalid, name)
+	}))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if !client.Enabled(ctx, "checkout", Attributes{"plan": "beta"}) {
+		t.Error("checkout should be enabled for the beta plan")
+	}
+	if client.Enabled(ctx, "checkout", Attributes{"plan": "free"}) {
+		t.Error("checkout should be disabled for the free plan")
+	}
+	if client.Enabled(ctx, "search", nil) {
+		t.Error("unknown flag should be disabled")
+	}
+
+	_ = values.SetValue(ctx, "flags/search", "on")
+	if !client.Enabled(ctx, "search", nil) {
+		t.Error("search should be enabled after the update")
+	}
+	_ = values.SetValue(ctx, "flags/search", "broken")
+	if !client.Enabled(ctx, "search", nil) {
+		t.Error("invalid value should keep the previous flag")
+	}
+	if len(invalid) != 1 || invalid[0] != "search" {
+		t.Errorf("error handler got %v, want [search]", invalid)
+	}
+	_ = values.SetValue(ctx, "flags/search", "off")
+	if client.Enabled(ctx, "search", nil) {
+		t.Error("search should be disabled after the update")
+	}
+
+	_ = values.SetValue(ctx, "flags/team/search", "on")
+	if !client.Enabled(ctx, "team/search", nil) || client.Enabled(ctx, "search", nil) {
+		t.Error("nested flag should be named by the key relative to the prefix")
+	}
+	if err := cloudregistry.DeleteValue(ctx, values, "flags/search"); err != nil {
+		t.Fatalf("DeleteValue() error = %v", err)
+	}
+	if _, ok := client.Flag("search"); ok {
+		t.Error("deleted key should remove the flag")
+	}
+}
+
+func TestClient_Listed(t *testing.T) {
+	client := &Client{prefix: "flags/", flags: map[string]*Flag{}, updated: map[string]bool{}}
+	_ = client.SetValue("flags/search", "on")
+	_ = client.setValue("flags/search", "off", true)
+	_ = client.setValue("flags/checkout", "on", true)
+	if !client.Enabled(context.Background(), "search", nil) {
+		t.Error("the listed value should not overwrite the subscription update")
+	}
+	if !client.Enabled(context.Background(), "checkout", nil) {
+		t.Error("the listed value should be set")
+	}
+}
+
+func TestDefault(t *testing.T) {
+	ctx := context.Background()
+	if Enabled(ctx, "checkout", nil) {
+		t.Error("flags should be disabled without the default client")
+	}
+	client := &Client{flags: map[string]*Flag{"checkout": {Enabled: true}}}
+	SetDefault(client)
+	defer SetDefault(nil)
+	if !Enabled(ctx, "checkout", nil) {
+		t.Error("Enabled() should use the default client")
+	}
+}
+
+func TestNew_Closed(t *testing.T) {
+	ctx := context.Background()
+	registry := memory.NewRegistry()
+	_ = registry.Close()
+	if _, err := New(ctx, registry, "flags/"); err == nil || errors.Is(err, context.Canceled) {
+		t.Errorf("New() on the closed registry error = %v, want the registry error", err)
+	}
+}
+
+func TestNew_NotValueStore(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	values := valueClient{memory.NewRegistry()}
+	if _, err := New(ctx, values, "flags/"); !errors.Is(err, cloudregistry.ErrValueStoreNotSupported) {
+		t.Errorf("New() without ValueStore error = %v, want %v", err, cloudregistry.ErrValueStoreNotSupported)
+	}
+}
+
+// valueClient hides the ValueStore of the wrapped client.
+type valueClient struct {
+	cloudregistry.ValueClient
+}
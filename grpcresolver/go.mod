@@ -0,0 +1,22 @@
+module github.com/demdxx/cloudregistry/grpcresolver
+
+go 1.23.0
+
+toolchain go1.24.4
+
+replace github.com/demdxx/cloudregistry => ../
+
+require (
+	github.com/demdxx/cloudregistry v0.0.0-00010101000000-000000000000
+	google.golang.org/grpc v1.68.1
+)
+
+require (
+	github.com/demdxx/gocast/v2 v2.10.1 // indirect
+	golang.org/x/exp v0.0.0-20250620022241-b7579e27df2b // indirect
+	golang.org/x/net v0.29.0 // indirect
+	golang.org/x/sys v0.25.0 // indirect
+	golang.org/x/text v0.18.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240903143218-8af14fe29dc1 // indirect
+	google.golang.org/protobuf v1.34.2 // indirect
+)
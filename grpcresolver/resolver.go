@@ -0,0 +1,310 @@
+// Package grpcresolver implements the gRPC name resolver backed by the cloud registry.
+//
+// The target format is cloudregistry:///[namespace/]name[?partition=name&port=grpc],
+// where the optional port parameter selects the named port from the instance Ports.
+//
+// Example:
+//
+//	conn, err := grpc.NewClient("cloudregistry:///production/billing?port=grpc",
+//		grpc.WithResolvers(grpcresolver.NewBuilder(registry)),
+//		grpc.WithTransportCredentials(insecure.NewCredentials()),
+//	)
+package grpcresolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/resolver"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+// Scheme is the default target scheme of the resolver.
+const Scheme = "cloudregistry"
+
+const (
+	defaultRefreshInterval = 10 * time.Second
+	defaultTTL             = 30 * time.Second
+)
+
+// Option is a configuration option for the resolver Builder.
+type Option func(b *Builder)
+
+// WithScheme sets the target scheme handled by the builder.
+func WithScheme(scheme string) Option {
+	return func(b *Builder) {
+		b.scheme = scheme
+	}
+}
+
+// WithPortName sets the default named port taken from the instance Ports,
+// the port target parameter overrides it.
+func WithPortName(name string) Option {
+	return func(b *Builder) {
+		b.portName = name
+	}
+}
+
+// WithRefreshInterval sets the interval of the instance list refresh
+// used if the registry does not support service watching or the watch ends.
+func WithRefreshInterval(interval time.Duration) Option {
+	return func(b *Builder) {
+		b.refreshInterval = interval
+	}
+}
+
+// WithTTL sets the TTL passed to the registry Discover method.
+func WithTTL(ttl time.Duration) Option {
+	return func(b *Builder) {
+		b.ttl = ttl
+	}
+}
+
+// Builder builds the gRPC resolvers for the cloud registry targets.
+type Builder struct {
+	registry        cloudregistry.Registry
+	scheme          string
+	portName        string
+	refreshInterval time.Duration
+	ttl             time.Duration
+}
+
+// NewBuilder creates a new resolver builder for the registry.
+func NewBuilder(registry cloudregistry.Registry, options ...Option) *Builder {
+	b := &Builder{
+		registry:        registry,
+		scheme:          Scheme,
+		refreshInterval: defaultRefreshInterval,
+		ttl:             defaultTTL,
+	}
+	for _, option := range options {
+		option(b)
+	}
+	return b
+}
+
+// Register creates a new resolver builder and registers it globally in gRPC.
+// It must be called only during initialization.
+func Register(registry cloudregistry.Registry, options ...Option) *Builder {
+	b := NewBuilder(registry, options...)
+	resolver.Register(b)
+	return b
+}
+
+// Scheme returns the target scheme handled by the builder.
+func (b *Builder) Scheme() string {
+	return b.scheme
+}
+
+// Build creates a new resolver for the target.
+func (b *Builder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	prefix, err := parseTarget(target)
+	if err != nil {
+		return nil, err
+	}
+	portName := b.portName
+	if port := target.URL.Query().Get("port"); port != "" {
+		portName = port
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &registryResolver{
+		builder:  b,
+		prefix:   prefix,
+		portName: portName,
+		cc:       cc,
+		cancel:   cancel,
+		refresh:  make(chan struct{}, 1),
+	}
+
+	events, err := cloudregistry.WatchServices(ctx, b.registry, prefix)
+	switch {
+	case err == nil:
+		r.wg.Add(1)
+		go r.watch(ctx, events)
+	case errors.Is(err, cloudregistry.ErrWatchNotSupported):
+		r.wg.Add(1)
+		go r.poll(ctx)
+	default:
+		cancel()
+		return nil, err
+	}
+	return r, nil
+}
+
+// registryResolver watches the service instances and updates the gRPC client connection.
+type registryResolver struct {
+	builder  *Builder
+	prefix   *cloudregistry.ServicePrefix
+	portName string
+	cc       resolver.ClientConn
+
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	refresh chan struct{}
+}
+
+// ResolveNow triggers the instance list refresh, in the watch mode the instances are resynced with Discover.
+func (r *registryResolver) ResolveNow(resolver.ResolveNowOptions) {
+	select {
+	case r.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// Close stops the resolver.
+func (r *registryResolver) Close() {
+	r.cancel()
+	r.wg.Wait()
+}
+
+// watch applies the watch events until the resolver is closed.
+// If the watch ends earlier, the instances are polled until it is subscribed again.
+func (r *registryResolver) watch(ctx context.Context, events <-chan cloudregistry.ServiceEvent) {
+	defer r.wg.Done()
+	instances := map[string]*cloudregistry.ServiceInfo{}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.refresh:
+			r.resync(ctx, instances)
+		case event, ok := <-events:
+			if !ok {
+				if events = r.resubscribe(ctx, instances); events == nil {
+					return
+				}
+				continue
+			}
+			switch event.Type {
+			case cloudregistry.ServiceSnapshot:
+				clear(instances)
+				for _, svc := range event.Services {
+					instances[svc.InstanceID] = svc
+				}
+			case cloudregistry.ServiceAdded, cloudregistry.ServiceUpdated:
+				instances[event.Service.InstanceID] = event.Service
+			case cloudregistry.ServiceRemoved:
+				delete(instances, event.Service.InstanceID)
+			}
+			list := make([]*cloudregistry.ServiceInfo, 0, len(instances))
+			for _, svc := range instances {
+				list = append(list, svc)
+			}
+			r.update(list)
+		}
+	}
+}
+
+// resubscribe polls the instances every refresh interval until the watch is subscribed again,
+// nil is returned when the context is done. The new watch starts with the snapshot.
+func (r *registryResolver) resubscribe(ctx context.Context, instances map[string]*cloudregistry.ServiceInfo) <-chan cloudregistry.ServiceEvent {
+	for {
+		r.resync(ctx, instances)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(r.builder.refreshInterval):
+		case <-r.refresh:
+		}
+		if events, err := cloudregistry.WatchServices(ctx, r.builder.registry, r.prefix); err == nil {
+			return events
+		}
+	}
+}
+
+// resync replaces the watched instances with the discovered ones.
+func (r *registryResolver) resync(ctx context.Context, instances map[string]*cloudregistry.ServiceInfo) {
+	services, err := r.builder.registry.Discover(ctx, r.prefix, r.builder.ttl)
+	switch {
+	case err == nil || errors.Is(err, cloudregistry.ErrNotFound):
+		clear(instances)
+		for _, svc := range services {
+			instances[svc.InstanceID] = svc
+		}
+		r.update(services)
+	case ctx.Err() == nil:
+		r.cc.ReportError(err)
+	}
+}
+
+func (r *registryResolver) poll(ctx context.Context) {
+	defer r.wg.Done()
+	ticker := time.NewTicker(r.builder.refreshInterval)
+	defer ticker.Stop()
+	for {
+		services, err := r.builder.registry.Discover(ctx, r.prefix, r.builder.ttl)
+		switch {
+		case err == nil || errors.Is(err, cloudregistry.ErrNotFound):
+			r.update(services)
+		case ctx.Err() == nil:
+			r.cc.ReportError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.refresh:
+		}
+	}
+}
+
+func (r *registryResolver) update(services []*cloudregistry.ServiceInfo) {
+	addrs := make([]resolver.Address, 0, len(services))
+	for _, svc := range services {
+		if addr := serviceAddress(svc, r.portName); addr != "" {
+			addrs = append(addrs, resolver.Address{Addr: addr, ServerName: svc.Name})
+		}
+	}
+	if len(addrs) == 0 {
+		r.cc.ReportError(fmt.Errorf("%w: %s", cloudregistry.ErrNotFound, r.prefix.String()))
+		return
+	}
+	_ = r.cc.UpdateState(resolver.State{Addresses: addrs})
+}
+
+// serviceAddress returns the address of the instance using the named port
+// from the Private or Public hosts, or the instance Hostname and Port.
+func serviceAddress(svc *cloudregistry.ServiceInfo, portName string) string {
+	if portName != "" {
+		for _, hosts := range [][]cloudregistry.Host{svc.Private, svc.Public} {
+			for _, host := range hosts {
+				if port, ok := host.Ports[portName]; ok && port != "" {
+					hostname := host.Hostname
+					if hostname == "" {
+						hostname = svc.Hostname
+					}
+					return net.JoinHostPort(hostname, port)
+				}
+			}
+		}
+	}
+	if svc.Hostname == "" || svc.Port <= 0 {
+		return ""
+	}
+	return net.JoinHostPort(svc.Hostname, strconv.Itoa(svc.Port))
+}
+
+// parseTarget converts the target path [namespace/]name into the service prefix.
+func parseTarget(target resolver.Target) (*cloudregistry.ServicePrefix, error) {
+	parts := strings.Split(strings.Trim(target.Endpoint(), "/"), "/")
+	prefix := &cloudregistry.ServicePrefix{Partition: target.URL.Query().Get("partition")}
+	switch {
+	case len(parts) == 1 && parts[0] != "":
+		prefix.Name = parts[0]
+	case len(parts) == 2 && parts[0] != "" && parts[1] != "":
+		prefix.Namespace, prefix.Name = parts[0], parts[1]
+	default:
+		return nil, fmt.Errorf("invalid cloudregistry target %q, expected [namespace/]name", target.URL.String())
+	}
+	return prefix, nil
+}
+
+var _ resolver.Builder = (*Builder)(nil)
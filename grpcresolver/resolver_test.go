@@ -0,0 +1,244 @@
+package grpcresolver
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/resolver"
+
+	"github.com/demdxx/cloudregistry"
+	"github.com/demdxx/cloudregistry/dummy"
+	"github.com/demdxx/cloudregistry/memory"
+	"github.com/demdxx/cloudregistry/registrytest"
+)
+
+// testRegistry is an in-process registry without watch support.
+type testRegistry struct {
+	cloudregistry.Registry
+
+	mx       sync.Mutex
+	services map[string][]*cloudregistry.ServiceInfo
+}
+
+func newTestRegistry() *testRegistry {
+	return &testRegistry{
+		Registry: &dummy.Registry{},
+		services: map[string][]*cloudregistry.ServiceInfo{},
+	}
+}
+
+func (r *testRegistry) Register(ctx context.Context, service *cloudregistry.Service) error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	key := service.Prefix().String()
+	r.services[key] = append(r.services[key], &cloudregistry.ServiceInfo{
+		Name:       service.Name,
+		Namespace:  service.Namespace,
+		InstanceID: service.InstanceID,
+		Hostname:   service.Hostname,
+		Port:       service.Port,
+		Public:     service.Public,
+		Private:    service.Private,
+	})
+	return nil
+}
+
+func (r *testRegistry) Discover(ctx context.Context, prefix *cloudregistry.ServicePrefix, TTL time.Duration) ([]*cloudregistry.ServiceInfo, error) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if services := r.services[prefix.String()]; len(services) > 0 {
+		return services, nil
+	}
+	return nil, cloudregistry.ErrNotFound
+}
+
+// stateConn records the last resolver state.
+type stateConn struct {
+	resolver.ClientConn
+
+	mx    sync.Mutex
+	state resolver.State
+}
+
+func (c *stateConn) UpdateState(state resolver.State) error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.state = state
+	return nil
+}
+
+func (c *stateConn) ReportError(err error) {}
+
+func (c *stateConn) addresses() int {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return len(c.state.Addresses)
+}
+
+func startServer(t *testing.T) (host string, port int) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(server.Stop)
+	addr := lis.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port
+}
+
+func TestResolver_Dial(t *testing.T) {
+	host, port := startServer(t)
+	registry := newTestRegistry()
+	_ = registry.Register(context.Background(), &cloudregistry.Service{
+		Name:       "health",
+		Namespace:  "test",
+		InstanceID: "health-1",
+		Hostname:   "invalid.host",
+		Port:       1,
+		Private: []cloudregistry.Host{
+			{Hostname: host, Ports: cloudregistry.Ports{"grpc": strconv.Itoa(port)}},
+		},
+	})
+
+	conn, err := grpc.NewClient("cloudregistry:///test/health?port=grpc",
+		grpc.WithResolvers(NewBuilder(registry)),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("grpc.NewClient() error = %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{}, grpc.WaitForReady(true))
+	if err != nil {
+		t.Fatalf("Health.Check() error = %v", err)
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("Health.Check() status = %v, want SERVING", resp.Status)
+	}
+}
+
+func TestResolver_Watch(t *testing.T) {
+	host, port := startServer(t)
+	registry := memory.NewRegistry()
+
+	conn, err := grpc.NewClient("cloudregistry:///health",
+		grpc.WithResolvers(NewBuilder(registry)),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("grpc.NewClient() error = %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	_ = registry.Register(context.Background(), &cloudregistry.Service{
+		Name: "health", InstanceID: "health-1", Hostname: host, Port: port,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{}, grpc.WaitForReady(true)); err != nil {
+		t.Fatalf("Health.Check() error = %v", err)
+	}
+}
+
+func TestResolver_WatchClosed(t *testing.T) {
+	registry := memory.NewRegistry()
+	_ = registry.Register(context.Background(), &cloudregistry.Service{
+		Name: "api", InstanceID: "api-1", Hostname: "10.0.0.1", Port: 80,
+	})
+	closer := registrytest.NewWatchCloser(registry, 1)
+
+	conn := &stateConn{}
+	u, _ := url.Parse("cloudregistry:///api")
+	r, err := NewBuilder(closer, WithRefreshInterval(10*time.Millisecond)).
+		Build(resolver.Target{URL: *u}, conn, resolver.BuildOptions{})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	defer r.Close()
+
+	waitFor(t, func() bool { return conn.addresses() == 1 && closer.Watches() >= 2 })
+
+	// The watch is open again and delivers the changes
+	_ = registry.Register(context.Background(), &cloudregistry.Service{
+		Name: "api", InstanceID: "api-2", Hostname: "10.0.0.2", Port: 80,
+	})
+	waitFor(t, func() bool { return conn.addresses() == 2 })
+}
+
+func TestParseTarget(t *testing.T) {
+	tests := []struct {
+		target  string
+		want    cloudregistry.ServicePrefix
+		wantErr bool
+	}{
+		{target: "cloudregistry:///billing", want: cloudregistry.ServicePrefix{Name: "billing"}},
+		{target: "cloudregistry:///prod/billing", want: cloudregistry.ServicePrefix{Namespace: "prod", Name: "billing"}},
+		{target: "cloudregistry:///prod/billing?partition=eu", want: cloudregistry.ServicePrefix{Namespace: "prod", Name: "billing", Partition: "eu"}},
+		{target: "cloudregistry:///", wantErr: true},
+		{target: "cloudregistry:///a/b/c", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			u, _ := url.Parse(tt.target)
+			got, err := parseTarget(resolver.Target{URL: *u})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTarget() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && *got != tt.want {
+				t.Errorf("parseTarget() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServiceAddress(t *testing.T) {
+	svc := &cloudregistry.ServiceInfo{
+		Hostname: "10.0.0.1",
+		Port:     8080,
+		Public:   []cloudregistry.Host{{Hostname: "example.com", Ports: cloudregistry.Ports{"grpc": "443"}}},
+		Private:  []cloudregistry.Host{{Ports: cloudregistry.Ports{"admin": "9000"}}},
+	}
+	tests := []struct {
+		portName string
+		want     string
+	}{
+		{portName: "", want: "10.0.0.1:8080"},
+		{portName: "grpc", want: "example.com:443"},
+		{portName: "admin", want: "10.0.0.1:9000"},
+		{portName: "unknown", want: "10.0.0.1:8080"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.portName, func(t *testing.T) {
+			if got := serviceAddress(svc, tt.portName); got != tt.want {
+				t.Errorf("serviceAddress() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition is not met in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
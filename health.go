@@ -0,0 +1,90 @@
+package cloudregistry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrHealthReporterNotSupported is returned when the registry can't store the reported health status.
+var ErrHealthReporterNotSupported = errors.New("health status reporting is not supported")
+
+// HealthStatus is the health status of the service instance.
+type HealthStatus int
+
+const (
+	// HealthUnknown is the status of the instances the registry knows nothing about.
+	HealthUnknown HealthStatus = iota
+	// HealthPassing is the status of the healthy instances.
+	HealthPassing
+	// HealthWarning is the status of the degraded instances which still serve the requests.
+	HealthWarning
+	// HealthCritical is the status of the failed instances.
+	HealthCritical
+)
+
+var healthStatusNames = [...]string{
+	HealthUnknown:  "unknown",
+	HealthPassing:  "passing",
+	HealthWarning:  "warning",
+	HealthCritical: "critical",
+}
+
+// ParseHealthStatus parses the status name, the names are the same as the Consul check statuses.
+func ParseHealthStatus(name string) (HealthStatus, error) {
+	for status, statusName := range healthStatusNames {
+		if statusName == name {
+			return HealthStatus(status), nil
+		}
+	}
+	return HealthUnknown, fmt.Errorf("unknown health status %q", name)
+}
+
+// String returns the name of the status.
+func (s HealthStatus) String() string {
+	if s < 0 || int(s) >= len(healthStatusNames) {
+		return healthStatusNames[HealthUnknown]
+	}
+	return healthStatusNames[s]
+}
+
+// MarshalText encodes the status as its name.
+func (s HealthStatus) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText decodes the status from its name.
+func (s *HealthStatus) UnmarshalText(text []byte) (err error) {
+	*s, err = ParseHealthStatus(string(text))
+	return err
+}
+
+// Health is the health state of the service instance.
+type Health struct {
+	Status HealthStatus `json:"status"`
+	// Output is the human readable description of the status, like the check output.
+	Output string `json:"output,omitempty"`
+	// LastCheck is the time of the last status update, zero if the registry does not know it.
+	LastCheck time.Time `json:"last_check"`
+}
+
+// HealthReporter is an optional interface implemented by registries storing the health status
+// reported by the service itself. ReportHealth refreshes the instance TTL like HealthCheck,
+// so a degraded service can report HealthWarning instead of the heartbeat.
+type HealthReporter interface {
+	ReportHealth(ctx context.Context, id *ServiceID, status HealthStatus, output string) error
+}
+
+// ReportHealth reports the health status of the instance if the registry implements HealthReporter.
+// Other registries only know whether the instance is alive, so the passing and warning statuses
+// are reported with HealthCheck and ErrHealthReporterNotSupported is returned for the others.
+func ReportHealth(ctx context.Context, registry Registry, id *ServiceID, status HealthStatus, output string) error {
+	if reporter, ok := registry.(HealthReporter); ok {
+		return reporter.ReportHealth(ctx, id, status, output)
+	}
+	if status == HealthPassing || status == HealthWarning {
+		return registry.HealthCheck(ctx, id, 0)
+	}
+	return ErrHealthReporterNotSupported
+}
@@ -0,0 +1,66 @@
+package cloudregistry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHealthStatus_Text(t *testing.T) {
+	for _, status := range []HealthStatus{HealthUnknown, HealthPassing, HealthWarning, HealthCritical} {
+		parsed, err := ParseHealthStatus(status.String())
+		if err != nil || parsed != status {
+			t.Errorf("ParseHealthStatus(%s) = %v, %v", status, parsed, err)
+		}
+	}
+	if _, err := ParseHealthStatus("maintenance"); err == nil {
+		t.Error("ParseHealthStatus(maintenance) error = nil")
+	}
+	if s := HealthStatus(42).String(); s != "unknown" {
+		t.Errorf("String() of invalid status = %s", s)
+	}
+
+	info := ServiceInfo{InstanceID: "a", Health: Health{Status: HealthWarning, Output: "slow"}}
+	data, err := json.Marshal(&info)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var decoded ServiceInfo
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal(%s) error = %v", data, err)
+	}
+	if decoded.Health != info.Health {
+		t.Errorf("Unmarshal(%s) health = %+v, want %+v", data, decoded.Health, info.Health)
+	}
+}
+
+func TestReportHealth_HealthCheckFallback(t *testing.T) {
+	registry := &lifecycleRegistry{}
+	id := &ServiceID{Name: "api", InstanceID: "1"}
+
+	for _, status := range []HealthStatus{HealthPassing, HealthWarning} {
+		if err := ReportHealth(context.Background(), registry, id, status, ""); err != nil {
+			t.Errorf("ReportHealth(%s) error = %v", status, err)
+		}
+	}
+	if registry.healthChecks != 2 {
+		t.Errorf("healthChecks = %d, want 2", registry.healthChecks)
+	}
+	if err := ReportHealth(context.Background(), registry, id, HealthCritical, ""); !errors.Is(err, ErrHealthReporterNotSupported) {
+		t.Errorf("ReportHealth(critical) error = %v, want %v", err, ErrHealthReporterNotSupported)
+	}
+}
+
+func TestDiffServices_Health(t *testing.T) {
+	prev := []*ServiceInfo{{InstanceID: "a", Health: Health{Status: HealthPassing, LastCheck: time.Unix(1, 0)}}}
+	checked := []*ServiceInfo{{InstanceID: "a", Health: Health{Status: HealthPassing, LastCheck: time.Unix(2, 0)}}}
+	if events := DiffServices(prev, checked); len(events) != 0 {
+		t.Errorf("DiffServices() of the new check time = %v, want none", events)
+	}
+	degraded := []*ServiceInfo{{InstanceID: "a", Health: Health{Status: HealthWarning}}}
+	if events := DiffServices(prev, degraded); len(events) != 1 || events[0].Type != ServiceUpdated {
+		t.Errorf("DiffServices() of the new status = %v, want updated", events)
+	}
+}
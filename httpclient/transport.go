@@ -0,0 +1,263 @@
+// Package httpclient provides the http.RoundTripper resolving service names through the cloud registry.
+//
+// Requests to the hosts like http://billing.svc/path or http://billing.production.svc/path
+// are sent to one of the discovered instances of the service "billing" (in namespace "production").
+//
+// Example:
+//
+//	client := &http.Client{Transport: httpclient.NewTransport(registry)}
+//	resp, err := client.Get("http://billing.svc/invoices")
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+const (
+	defaultDomain      = ".svc"
+	defaultTTL         = 30 * time.Second
+	defaultMaxAttempts = 3
+)
+
+// ResolveFunc maps the request host to the service prefix,
+// returns false if the host must not be resolved through the registry.
+type ResolveFunc func(host string) (*cloudregistry.ServicePrefix, bool)
+
+// Option is a configuration option for the Transport.
+type Option func(t *Transport)
+
+// WithBase sets the underlying transport, http.DefaultTransport is used by default.
+func WithBase(base http.RoundTripper) Option {
+	return func(t *Transport) {
+		t.base = base
+	}
+}
+
+// WithDomain sets the domain suffix of the service hosts, ".svc" by default.
+func WithDomain(domain string) Option {
+	return func(t *Transport) {
+		t.resolve = DomainResolver(domain)
+	}
+}
+
+// WithResolver sets the custom host to service prefix mapping.
+func WithResolver(resolve ResolveFunc) Option {
+	return func(t *Transport) {
+		t.resolve = resolve
+	}
+}
+
+// WithTTL sets the TTL passed to Discover and the lifetime of the cached discovery results.
+func WithTTL(ttl time.Duration) Option {
+	return func(t *Transport) {
+		t.ttl = ttl
+	}
+}
+
+// WithPrivate makes the transport use the Private hosts of the instances instead of the Public ones.
+func WithPrivate() Option {
+	return func(t *Transport) {
+		t.private = true
+	}
+}
+
+// WithMaxAttempts sets the maximal number of instances tried on connection failures.
+func WithMaxAttempts(attempts int) Option {
+	return func(t *Transport) {
+		t.maxAttempts = attempts
+	}
+}
+
+type cacheItem struct {
+	services []*cloudregistry.ServiceInfo
+	expires  time.Time
+}
+
+// Transport is the http.RoundTripper sending requests to the instances discovered in the registry.
+type Transport struct {
+	registry    cloudregistry.Registry
+	base        http.RoundTripper
+	resolve     ResolveFunc
+	ttl         time.Duration
+	private     bool
+	maxAttempts int
+
+	mx    sync.Mutex
+	cache map[string]*cacheItem
+}
+
+// NewTransport creates a new registry-aware transport.
+func NewTransport(registry cloudregistry.Registry, options ...Option) *Transport {
+	t := &Transport{
+		registry:    registry,
+		base:        http.DefaultTransport,
+		resolve:     DomainResolver(defaultDomain),
+		ttl:         defaultTTL,
+		maxAttempts: defaultMaxAttempts,
+		cache:       map[string]*cacheItem{},
+	}
+	for _, option := range options {
+		option(t)
+	}
+	return t
+}
+
+// DomainResolver returns the resolver of the hosts in format <name>[.<namespace>]<domain>.
+func DomainResolver(domain string) ResolveFunc {
+	return func(host string) (*cloudregistry.ServicePrefix, bool) {
+		name, ok := strings.CutSuffix(host, domain)
+		if !ok || name == "" {
+			return nil, false
+		}
+		if name, namespace, ok := strings.Cut(name, "."); ok {
+			return &cloudregistry.ServicePrefix{Name: name, Namespace: namespace}, true
+		}
+		return &cloudregistry.ServicePrefix{Name: name}, true
+	}
+}
+
+// RoundTrip sends the request to one of the service instances.
+// If the connection to the instance fails the request is retried on another one,
+// as long as the request body can be replayed.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	prefix, ok := t.resolve(req.URL.Hostname())
+	if !ok {
+		return t.base.RoundTrip(req)
+	}
+
+	services, err := t.discover(req, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		lastErr error
+		tried   = map[string]bool{}
+	)
+	for attempt := 0; attempt < t.maxAttempts; attempt++ {
+		addr := t.pickAddress(services, req.URL.Scheme, tried)
+		if addr == "" {
+			break
+		}
+		tried[addr] = true
+
+		outReq, err := rewriteRequest(req, addr, attempt > 0)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := t.base.RoundTrip(outReq)
+		if err == nil || !isConnectError(err) || !canRetry(req) {
+			return resp, err
+		}
+		lastErr = err
+	}
+
+	// All tried instances are unreachable, drop the cache to rediscover on the next request
+	t.invalidate(prefix)
+	if lastErr == nil {
+		lastErr = fmt.Errorf("%w: no %s address for %s", cloudregistry.ErrNotFound, req.URL.Scheme, prefix.String())
+	}
+	return nil, lastErr
+}
+
+func (t *Transport) discover(req *http.Request, prefix *cloudregistry.ServicePrefix) ([]*cloudregistry.ServiceInfo, error) {
+	key := prefix.String()
+	now := time.Now()
+
+	t.mx.Lock()
+	item := t.cache[key]
+	t.mx.Unlock()
+	if item != nil && now.Before(item.expires) {
+		return item.services, nil
+	}
+
+	services, err := t.registry.Discover(req.Context(), prefix, t.ttl)
+	if err != nil {
+		return nil, err
+	}
+	if len(services) == 0 {
+		return nil, fmt.Errorf("%w: %s", cloudregistry.ErrNotFound, key)
+	}
+
+	t.mx.Lock()
+	t.cache[key] = &cacheItem{services: services, expires: now.Add(t.ttl)}
+	t.mx.Unlock()
+	return services, nil
+}
+
+func (t *Transport) invalidate(prefix *cloudregistry.ServicePrefix) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	delete(t.cache, prefix.String())
+}
+
+// pickAddress returns a random address of the service instances for the scheme excluding the tried ones.
+func (t *Transport) pickAddress(services []*cloudregistry.ServiceInfo, scheme string, tried map[string]bool) string {
+	addrs := make([]string, 0, len(services))
+	for _, svc := range services {
+		if addr := t.serviceAddress(svc, scheme); addr != "" && !tried[addr] {
+			addrs = append(addrs, addr)
+		}
+	}
+	if len(addrs) == 0 {
+		return ""
+	}
+	return addrs[rand.IntN(len(addrs))]
+}
+
+// serviceAddress returns the address of the instance for the scheme port
+// of the Public (or Private) hosts, or the instance Hostname and Port for plain HTTP.
+func (t *Transport) serviceAddress(svc *cloudregistry.ServiceInfo, scheme string) string {
+	hosts := svc.Public
+	if t.private {
+		hosts = svc.Private
+	}
+	for _, host := range hosts {
+		if port, ok := host.Ports[scheme]; ok && port != "" {
+			hostname := host.Hostname
+			if hostname == "" {
+				hostname = svc.Hostname
+			}
+			return net.JoinHostPort(hostname, port)
+		}
+	}
+	if scheme == "http" && svc.Hostname != "" && svc.Port > 0 {
+		return net.JoinHostPort(svc.Hostname, fmt.Sprint(svc.Port))
+	}
+	return ""
+}
+
+func rewriteRequest(req *http.Request, addr string, replayBody bool) (*http.Request, error) {
+	outReq := req.Clone(req.Context())
+	outReq.URL.Host = addr
+	outReq.Host = ""
+	if replayBody && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		outReq.Body = body
+	}
+	return outReq, nil
+}
+
+// isConnectError reports whether the request failed before it was sent to the server.
+func isConnectError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+func canRetry(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+var _ http.RoundTripper = (*Transport)(nil)
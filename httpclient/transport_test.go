@@ -0,0 +1,230 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/demdxx/cloudregistry"
+	"github.com/demdxx/cloudregistry/dummy"
+)
+
+type testRegistry struct {
+	dummy.Registry
+	services  []*cloudregistry.ServiceInfo
+	discovers atomic.Int32
+	lastTTL   time.Duration
+}
+
+func (r *testRegistry) Discover(ctx context.Context, prefix *cloudregistry.ServicePrefix, TTL time.Duration) ([]*cloudregistry.ServiceInfo, error) {
+	r.discovers.Add(1)
+	r.lastTTL = TTL
+	var services []*cloudregistry.ServiceInfo
+	for _, svc := range r.services {
+		if svc.Name == prefix.Name && svc.Namespace == prefix.Namespace {
+			services = append(services, svc)
+		}
+	}
+	if len(services) == 0 {
+		return nil, cloudregistry.ErrNotFound
+	}
+	return services, nil
+}
+
+func serverInstance(t *testing.T, id string, handler http.HandlerFunc) *cloudregistry.ServiceInfo {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	u, _ := url.Parse(server.URL)
+	return &cloudregistry.ServiceInfo{
+		Name:       "billing",
+		InstanceID: id,
+		Public:     []cloudregistry.Host{{Hostname: u.Hostname(), Ports: cloudregistry.Ports{"http": u.Port()}}},
+	}
+}
+
+func deadInstance(t *testing.T, id string) *cloudregistry.ServiceInfo {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := lis.Addr().(*net.TCPAddr)
+	_ = lis.Close()
+	return &cloudregistry.ServiceInfo{
+		Name:       "billing",
+		InstanceID: id,
+		Hostname:   addr.IP.String(),
+		Port:       addr.Port,
+	}
+}
+
+func TestTransport_RoundTrip(t *testing.T) {
+	registry := &testRegistry{services: []*cloudregistry.ServiceInfo{
+		serverInstance(t, "billing-1", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, "path="+r.URL.Path)
+		}),
+	}}
+	client := &http.Client{Transport: NewTransport(registry, WithTTL(time.Minute))}
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get("http://billing.svc/invoices")
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if string(body) != "path=/invoices" {
+			t.Errorf("Get() body = %q, want %q", body, "path=/invoices")
+		}
+	}
+	if got := registry.discovers.Load(); got != 1 {
+		t.Errorf("Discover() called %d times, want 1 (cached)", got)
+	}
+	if registry.lastTTL != time.Minute {
+		t.Errorf("Discover() TTL = %v, want %v", registry.lastTTL, time.Minute)
+	}
+}
+
+func TestTransport_CacheExpiration(t *testing.T) {
+	registry := &testRegistry{services: []*cloudregistry.ServiceInfo{
+		serverInstance(t, "billing-1", func(w http.ResponseWriter, r *http.Request) {}),
+	}}
+	client := &http.Client{Transport: NewTransport(registry, WithTTL(time.Millisecond))}
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get("http://billing.svc/")
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		_ = resp.Body.Close()
+		time.Sleep(2 * time.Millisecond)
+	}
+	if got := registry.discovers.Load(); got != 2 {
+		t.Errorf("Discover() called %d times, want 2", got)
+	}
+}
+
+func TestTransport_RetryOnConnectionFailure(t *testing.T) {
+	var hits atomic.Int32
+	registry := &testRegistry{services: []*cloudregistry.ServiceInfo{
+		deadInstance(t, "billing-1"),
+		deadInstance(t, "billing-2"),
+		serverInstance(t, "billing-3", func(w http.ResponseWriter, r *http.Request) {
+			hits.Add(1)
+			body, _ := io.ReadAll(r.Body)
+			_, _ = w.Write(body)
+		}),
+	}}
+	client := &http.Client{Transport: NewTransport(registry)}
+
+	for i := 0; i < 5; i++ {
+		resp, err := client.Post("http://billing.svc/pay", "text/plain", strings.NewReader("payload"))
+		if err != nil {
+			t.Fatalf("Post() error = %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if string(body) != "payload" {
+			t.Errorf("Post() body = %q, want replayed payload", body)
+		}
+	}
+	if hits.Load() != 5 {
+		t.Errorf("live instance got %d requests, want 5", hits.Load())
+	}
+}
+
+func TestTransport_AllInstancesDown(t *testing.T) {
+	registry := &testRegistry{services: []*cloudregistry.ServiceInfo{deadInstance(t, "billing-1")}}
+	client := &http.Client{Transport: NewTransport(registry)}
+
+	if _, err := client.Get("http://billing.svc/"); err == nil {
+		t.Fatal("Get() expected error")
+	}
+	if _, err := client.Get("http://billing.svc/"); err == nil {
+		t.Fatal("Get() expected error")
+	}
+	if got := registry.discovers.Load(); got != 2 {
+		t.Errorf("Discover() called %d times, want 2 (cache invalidated after failure)", got)
+	}
+}
+
+func TestTransport_Passthrough(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	registry := &testRegistry{}
+	client := &http.Client{Transport: NewTransport(registry)}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	_ = resp.Body.Close()
+	if registry.discovers.Load() != 0 {
+		t.Error("Discover() should not be called for regular hosts")
+	}
+
+	if _, err := client.Get("http://unknown.svc/"); err == nil {
+		t.Error("Get() for unknown service expected error")
+	}
+}
+
+func TestDomainResolver(t *testing.T) {
+	resolve := DomainResolver(".svc")
+	tests := []struct {
+		host string
+		want *cloudregistry.ServicePrefix
+	}{
+		{host: "billing.svc", want: &cloudregistry.ServicePrefix{Name: "billing"}},
+		{host: "billing.prod.svc", want: &cloudregistry.ServicePrefix{Name: "billing", Namespace: "prod"}},
+		{host: ".svc", want: nil},
+		{host: "example.com", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			got, ok := resolve(tt.host)
+			if ok != (tt.want != nil) {
+				t.Fatalf("DomainResolver()(%s) ok = %v", tt.host, ok)
+			}
+			if ok && *got != *tt.want {
+				t.Errorf("DomainResolver()(%s) = %+v, want %+v", tt.host, *got, *tt.want)
+			}
+		})
+	}
+}
+
+func TestTransport_ServiceAddress(t *testing.T) {
+	svc := &cloudregistry.ServiceInfo{
+		Hostname: "10.0.0.1",
+		Port:     8080,
+		Public:   []cloudregistry.Host{{Hostname: "example.com", Ports: cloudregistry.Ports{"https": "443"}}},
+		Private:  []cloudregistry.Host{{Ports: cloudregistry.Ports{"http": "9000"}}},
+	}
+	tests := []struct {
+		name    string
+		private bool
+		scheme  string
+		want    string
+	}{
+		{name: "public https", scheme: "https", want: "example.com:443"},
+		{name: "public http fallback", scheme: "http", want: "10.0.0.1:8080"},
+		{name: "private http", private: true, scheme: "http", want: "10.0.0.1:9000"},
+		{name: "private https missing", private: true, scheme: "https", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := NewTransport(nil)
+			tr.private = tt.private
+			if got := tr.serviceAddress(svc, tt.scheme); got != tt.want {
+				t.Errorf("serviceAddress() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
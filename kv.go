@@ -0,0 +1,95 @@
+package cloudregistry
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrValueStoreNotSupported is returned when the value client does not support the ValueStore operations.
+var ErrValueStoreNotSupported = errors.New("value store operations are not supported")
+
+// KeyValue is the registry value with its version.
+type KeyValue struct {
+	// Key is relative to the prefix of the value client, so it can be passed to Value.
+	Key   string
+	Value string
+	// Version changes on every modification of the key, zero means the key does not exist.
+	// It is the etcd modification revision, the Consul ModifyIndex or the ZooKeeper node version plus one.
+	Version uint64
+}
+
+// ValueStore is an optional interface of the value clients supporting the deletion,
+// listing and optimistic concurrency of the values.
+type ValueStore interface {
+	// DeleteValue deletes the value, deleting a missing value is not an error.
+	DeleteValue(ctx context.Context, name string) error
+	// DeletePrefix deletes all values with the prefix.
+	// Hierarchical backends like ZooKeeper treat the prefix as a path and delete the whole subtree.
+	DeletePrefix(ctx context.Context, prefix string) error
+	// ListValues returns the values with the prefix sorted by key.
+	ListValues(ctx context.Context, prefix string) ([]*KeyValue, error)
+	// CompareAndSwap sets the value only if its current version equals the given one,
+	// the zero version creates the value only if it does not exist.
+	// It returns false if the value was changed concurrently.
+	CompareAndSwap(ctx context.Context, name string, version uint64, value string) (bool, error)
+}
+
+// DeleteValue deletes the value if the client implements ValueStore.
+func DeleteValue(ctx context.Context, client ValueClient, name string) error {
+	store, err := valueStoreOf(client)
+	if err != nil {
+		return err
+	}
+	return store.DeleteValue(ctx, name)
+}
+
+// DeletePrefix deletes all values with the prefix if the client implements ValueStore.
+func DeletePrefix(ctx context.Context, client ValueClient, prefix string) error {
+	store, err := valueStoreOf(client)
+	if err != nil {
+		return err
+	}
+	return store.DeletePrefix(ctx, prefix)
+}
+
+// ListValues returns the values with the prefix if the client implements ValueStore.
+func ListValues(ctx context.Context, client ValueClient, prefix string) ([]*KeyValue, error) {
+	store, err := valueStoreOf(client)
+	if err != nil {
+		return nil, err
+	}
+	return store.ListValues(ctx, prefix)
+}
+
+// VersionedValue returns the value with its version, ErrNotFound if the value does not exist.
+func VersionedValue(ctx context.Context, client ValueClient, name string) (*KeyValue, error) {
+	values, err := ListValues(ctx, client, name)
+	if err != nil {
+		return nil, err
+	}
+	for _, kv := range values {
+		if kv.Key == name {
+			return kv, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// CompareAndSwap sets the value if its version matches and the client implements ValueStore.
+func CompareAndSwap(ctx context.Context, client ValueClient, name string, version uint64, value string) (bool, error) {
+	store, err := valueStoreOf(client)
+	if err != nil {
+		return false, err
+	}
+	return store.CompareAndSwap(ctx, name, version, value)
+}
+
+func valueStoreOf(client ValueClient) (ValueStore, error) {
+	if wrapped, ok := client.(*codecValueClient); ok {
+		client = wrapped.ValueClient
+	}
+	if store, ok := client.(ValueStore); ok {
+		return store, nil
+	}
+	return nil, ErrValueStoreNotSupported
+}
@@ -0,0 +1,25 @@
+package cloudregistry
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestValueStore_NotSupported(t *testing.T) {
+	ctx := context.Background()
+	values := newMapValues(map[string]string{})
+
+	if err := DeleteValue(ctx, values, "key"); !errors.Is(err, ErrValueStoreNotSupported) {
+		t.Errorf("DeleteValue() error = %v, want %v", err, ErrValueStoreNotSupported)
+	}
+	if err := DeletePrefix(ctx, values, "key"); !errors.Is(err, ErrValueStoreNotSupported) {
+		t.Errorf("DeletePrefix() error = %v, want %v", err, ErrValueStoreNotSupported)
+	}
+	if _, err := ListValues(ctx, WithCodec(values, JSONCodec), ""); !errors.Is(err, ErrValueStoreNotSupported) {
+		t.Errorf("ListValues() error = %v, want %v", err, ErrValueStoreNotSupported)
+	}
+	if _, err := CompareAndSwap(ctx, values, "key", 0, "value"); !errors.Is(err, ErrValueStoreNotSupported) {
+		t.Errorf("CompareAndSwap() error = %v, want %v", err, ErrValueStoreNotSupported)
+	}
+}
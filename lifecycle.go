@@ -0,0 +1,268 @@
+package cloudregistry
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHeartbeatInterval = 10 * time.Second
+	defaultRetryInterval     = time.Second
+	deregisterTimeout        = 10 * time.Second
+)
+
+// ErrLifecycleStarted is returned when the lifecycle is started twice.
+var ErrLifecycleStarted = errors.New("lifecycle is already started")
+
+// LifecycleState is the registration state of the service managed by Lifecycle.
+type LifecycleState int
+
+const (
+	// LifecycleIdle is the state before Start.
+	LifecycleIdle LifecycleState = iota
+	// LifecycleRegistering is the state until the first successful registration.
+	LifecycleRegistering
+	// LifecycleRegistered is the state while the service is registered and heartbeats succeed.
+	LifecycleRegistered
+	// LifecycleLost is the state after the registry lost the service, until it is registered again.
+	LifecycleLost
+	// LifecycleStopped is the state after the service is deregistered.
+	LifecycleStopped
+)
+
+// String returns the name of the state.
+func (s LifecycleState) String() string {
+	switch s {
+	case LifecycleIdle:
+		return "idle"
+	case LifecycleRegistering:
+		return "registering"
+	case LifecycleRegistered:
+		return "registered"
+	case LifecycleLost:
+		return "lost"
+	case LifecycleStopped:
+		return "stopped"
+	}
+	return "unknown"
+}
+
+// LifecycleHandler is called on the state changes of the lifecycle,
+// err is the reason of the change if any.
+type LifecycleHandler func(state LifecycleState, err error)
+
+// LifecycleOption is a configuration option of the lifecycle.
+type LifecycleOption func(l *Lifecycle)
+
+// WithHeartbeatInterval sets the interval of the health checks,
+// one third of Check.TTL by default or 10 seconds if TTL is not set.
+func WithHeartbeatInterval(interval time.Duration) LifecycleOption {
+	return func(l *Lifecycle) {
+		l.heartbeatInterval = interval
+	}
+}
+
+// WithRetryInterval sets the interval between the failed registration attempts, 1 second by default.
+func WithRetryInterval(interval time.Duration) LifecycleOption {
+	return func(l *Lifecycle) {
+		l.retryInterval = interval
+	}
+}
+
+// WithLifecycleHandler adds the handler of the state changes.
+func WithLifecycleHandler(handler LifecycleHandler) LifecycleOption {
+	return func(l *Lifecycle) {
+		l.handlers = append(l.handlers, handler)
+	}
+}
+
+// Lifecycle keeps the service registered in the registry.
+// It registers the service, sends heartbeats with HealthCheck, registers the service
+// again when the registry reports it as missing (lost lease, expired session) and
+// deregisters the service on Stop or when the Start context is done.
+type Lifecycle struct {
+	registry Registry
+	service  *Service
+
+	heartbeatInterval time.Duration
+	retryInterval     time.Duration
+	handlers          []LifecycleHandler
+
+	mx      sync.Mutex
+	state   LifecycleState
+	started bool
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	stopCtx  context.Context
+	done     chan struct{}
+	stopErr  error
+}
+
+// NewLifecycle creates the lifecycle of the service registration.
+func NewLifecycle(registry Registry, service *Service, options ...LifecycleOption) *Lifecycle {
+	l := &Lifecycle{
+		registry:      registry,
+		service:       service,
+		retryInterval: defaultRetryInterval,
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	for _, option := range options {
+		option(l)
+	}
+	if l.heartbeatInterval <= 0 {
+		l.heartbeatInterval = service.Check.TTL / 3
+		if l.heartbeatInterval <= 0 {
+			l.heartbeatInterval = defaultHeartbeatInterval
+		}
+	}
+	return l
+}
+
+// Start starts the registration in the background.
+// The service is deregistered when the context is done.
+func (l *Lifecycle) Start(ctx context.Context) error {
+	l.mx.Lock()
+	if l.started {
+		l.mx.Unlock()
+		return ErrLifecycleStarted
+	}
+	l.started = true
+	l.mx.Unlock()
+
+	l.setState(LifecycleRegistering, nil)
+	go l.run(ctx)
+	return nil
+}
+
+// Stop stops the heartbeats and deregisters the service.
+// It waits until the service is deregistered or the context is done.
+func (l *Lifecycle) Stop(ctx context.Context) error {
+	l.mx.Lock()
+	started := l.started
+	l.mx.Unlock()
+	if !started {
+		return nil
+	}
+
+	l.stopOnce.Do(func() {
+		l.stopCtx = ctx
+		close(l.stop)
+	})
+	select {
+	case <-l.done:
+		return l.stopErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Done returns the channel closed after the lifecycle is stopped.
+func (l *Lifecycle) Done() <-chan struct{} {
+	return l.done
+}
+
+// State returns the current state of the registration.
+func (l *Lifecycle) State() LifecycleState {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	return l.state
+}
+
+// Registered reports whether the service is registered, it suits the readiness probes.
+func (l *Lifecycle) Registered() bool {
+	return l.State() == LifecycleRegistered
+}
+
+func (l *Lifecycle) run(ctx context.Context) {
+	defer close(l.done)
+
+	var (
+		registered bool
+		regCancel  context.CancelFunc = func() {}
+	)
+	// register registers the service with its own context,
+	// so the background routines of the previous registration are stopped
+	register := func() error {
+		regCancel()
+		var regCtx context.Context
+		regCtx, regCancel = context.WithCancel(ctx)
+		return l.registry.Register(regCtx, l.service)
+	}
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-l.stop:
+			break loop
+		case <-timer.C:
+		}
+
+		state := l.State()
+		if state == LifecycleRegistered {
+			err := l.registry.HealthCheck(ctx, l.service.ID(), l.service.Check.TTL)
+			switch {
+			case err == nil:
+				timer.Reset(l.heartbeatInterval)
+				continue
+			case errors.Is(err, ErrNotFound) || errors.Is(err, ErrNotReady):
+				state = LifecycleLost
+				l.setState(state, err)
+			default:
+				// The registry is unavailable, the membership is unknown until the next heartbeat
+				timer.Reset(l.retryInterval)
+				continue
+			}
+		}
+
+		if err := register(); err != nil {
+			l.setState(state, err)
+			timer.Reset(l.retryInterval)
+			continue
+		}
+		registered = true
+		l.setState(LifecycleRegistered, nil)
+		timer.Reset(l.heartbeatInterval)
+	}
+
+	regCancel()
+	if registered {
+		l.stopErr = l.deregister(ctx)
+	}
+	l.setState(LifecycleStopped, l.stopErr)
+}
+
+func (l *Lifecycle) deregister(ctx context.Context) error {
+	select {
+	case <-l.stop:
+		return l.registry.Deregister(l.stopCtx, l.service.ID())
+	default:
+	}
+	// The Start context is done, the service is still deregistered
+	dctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), deregisterTimeout)
+	defer cancel()
+	return l.registry.Deregister(dctx, l.service.ID())
+}
+
+// setState changes the state and calls the handlers,
+// the handlers are called for the repeated state only with the error.
+func (l *Lifecycle) setState(state LifecycleState, err error) {
+	l.mx.Lock()
+	changed := l.state != state
+	l.state = state
+	l.mx.Unlock()
+	if !changed && err == nil {
+		return
+	}
+	for _, handler := range l.handlers {
+		handler(state, err)
+	}
+}
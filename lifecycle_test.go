@@ -0,0 +1,190 @@
+package cloudregistry
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lifecycleRegistry is the registry recording the lifecycle calls.
+type lifecycleRegistry struct {
+	Registry
+
+	mx           sync.Mutex
+	registers    int
+	deregisters  int
+	registerErr  error
+	healthErr    error
+	healthChecks int
+}
+
+func (r *lifecycleRegistry) Register(ctx context.Context, service *Service) error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.registers++
+	return r.registerErr
+}
+
+func (r *lifecycleRegistry) Deregister(ctx context.Context, id *ServiceID) error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.deregisters++
+	return nil
+}
+
+func (r *lifecycleRegistry) HealthCheck(ctx context.Context, id *ServiceID, TTL time.Duration) error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.healthChecks++
+	err := r.healthErr
+	r.healthErr = nil
+	return err
+}
+
+func (r *lifecycleRegistry) set(registerErr, healthErr error) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.registerErr, r.healthErr = registerErr, healthErr
+}
+
+func (r *lifecycleRegistry) counts() (registers, healthChecks, deregisters int) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return r.registers, r.healthChecks, r.deregisters
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLifecycleState_String(t *testing.T) {
+	tests := []struct {
+		state LifecycleState
+		want  string
+	}{
+		{state: LifecycleIdle, want: "idle"},
+		{state: LifecycleRegistering, want: "registering"},
+		{state: LifecycleRegistered, want: "registered"},
+		{state: LifecycleLost, want: "lost"},
+		{state: LifecycleStopped, want: "stopped"},
+		{state: LifecycleState(100), want: "unknown"},
+	}
+	for _, tt := range tests {
+		if got := tt.state.String(); got != tt.want {
+			t.Errorf("LifecycleState(%d).String() = %s, want %s", tt.state, got, tt.want)
+		}
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	registry := &lifecycleRegistry{}
+	service := &Service{Name: "test", InstanceID: "test-1", Check: Check{TTL: 30 * time.Millisecond}}
+
+	var (
+		mx     sync.Mutex
+		states []LifecycleState
+	)
+	lifecycle := NewLifecycle(registry, service,
+		WithRetryInterval(time.Millisecond),
+		WithLifecycleHandler(func(state LifecycleState, err error) {
+			mx.Lock()
+			defer mx.Unlock()
+			states = append(states, state)
+		}))
+	if lifecycle.heartbeatInterval != 10*time.Millisecond {
+		t.Errorf("heartbeat interval = %v, want TTL/3", lifecycle.heartbeatInterval)
+	}
+
+	// The first registration fails and is retried
+	registry.set(errors.New("unavailable"), nil)
+	if err := lifecycle.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if err := lifecycle.Start(context.Background()); !errors.Is(err, ErrLifecycleStarted) {
+		t.Errorf("second Start() error = %v, want %v", err, ErrLifecycleStarted)
+	}
+	waitFor(t, "registration retry", func() bool { registers, _, _ := registry.counts(); return registers >= 2 })
+	if lifecycle.Registered() {
+		t.Error("Registered() = true before the successful registration")
+	}
+
+	registry.set(nil, nil)
+	waitFor(t, "registration", lifecycle.Registered)
+	waitFor(t, "heartbeats", func() bool { _, checks, _ := registry.counts(); return checks >= 2 })
+
+	// The lost registration is restored
+	registers, _, _ := registry.counts()
+	registry.set(nil, ErrNotFound)
+	waitFor(t, "re-registration", func() bool { r, _, _ := registry.counts(); return r > registers })
+	waitFor(t, "registration", lifecycle.Registered)
+
+	if err := lifecycle.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if _, _, deregisters := registry.counts(); deregisters != 1 {
+		t.Errorf("Deregister() called %d times, want 1", deregisters)
+	}
+	if state := lifecycle.State(); state != LifecycleStopped {
+		t.Errorf("State() after Stop() = %s, want %s", state, LifecycleStopped)
+	}
+
+	mx.Lock()
+	defer mx.Unlock()
+	want := []LifecycleState{LifecycleRegistering, LifecycleRegistered, LifecycleLost, LifecycleRegistered, LifecycleStopped}
+	var got []LifecycleState
+	for _, state := range states {
+		if len(got) == 0 || got[len(got)-1] != state {
+			got = append(got, state)
+		}
+	}
+	if len(got) != len(want) {
+		t.Fatalf("state changes = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("state change %d = %s, want %s", i, got[i], want[i])
+		}
+	}
+}
+
+func TestLifecycle_ContextCancel(t *testing.T) {
+	registry := &lifecycleRegistry{}
+	lifecycle := NewLifecycle(registry, &Service{Name: "test", InstanceID: "test-1"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_ = lifecycle.Start(ctx)
+	waitFor(t, "registration", lifecycle.Registered)
+	cancel()
+
+	select {
+	case <-lifecycle.Done():
+	case <-time.After(time.Second):
+		t.Fatal("lifecycle is not stopped after the context cancellation")
+	}
+	if _, _, deregisters := registry.counts(); deregisters != 1 {
+		t.Errorf("Deregister() called %d times, want 1", deregisters)
+	}
+	if err := lifecycle.Stop(context.Background()); err != nil {
+		t.Errorf("Stop() after cancellation error = %v", err)
+	}
+}
+
+func TestLifecycle_StopBeforeStart(t *testing.T) {
+	registry := &lifecycleRegistry{}
+	lifecycle := NewLifecycle(registry, &Service{Name: "test", InstanceID: "test-1"})
+	if err := lifecycle.Stop(context.Background()); err != nil {
+		t.Errorf("Stop() error = %v", err)
+	}
+	if registers, _, deregisters := registry.counts(); registers != 0 || deregisters != 0 {
+		t.Errorf("registry calls = %d/%d, want none", registers, deregisters)
+	}
+}
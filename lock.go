@@ -0,0 +1,43 @@
+package cloudregistry
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// ErrLockerNotSupported is returned when the registry does not support distributed locks.
+	ErrLockerNotSupported = errors.New("distributed locks are not supported")
+	// ErrLocked is returned by TryLock when the lock is held by another owner.
+	ErrLocked = errors.New("lock is held by another owner")
+	// ErrNotLocked is returned by Unlock when the mutex is not held.
+	ErrNotLocked = errors.New("lock is not held")
+)
+
+// Mutex is a distributed mutual exclusion lock.
+// A Mutex is not reentrant and must not be shared by goroutines acquiring it concurrently.
+type Mutex interface {
+	// Lock acquires the lock, waiting until it is released by the current owner or the context is done.
+	Lock(ctx context.Context) error
+	// TryLock acquires the lock only if it is free, otherwise returns ErrLocked.
+	TryLock(ctx context.Context) error
+	// Unlock releases the lock.
+	Unlock(ctx context.Context) error
+	// Lost returns the channel closed when the acquired lock is released or lost,
+	// for example when the backend session expires. The channel is renewed by every acquisition.
+	Lost() <-chan struct{}
+}
+
+// Locker is an optional interface implemented by registries providing distributed locks.
+// Locks with the same name are mutually exclusive across all clients of the registry.
+type Locker interface {
+	NewMutex(ctx context.Context, name string) (Mutex, error)
+}
+
+// NewMutex creates a distributed mutex if the registry implements Locker.
+func NewMutex(ctx context.Context, registry Registry, name string) (Mutex, error) {
+	if locker, ok := registry.(Locker); ok {
+		return locker.NewMutex(ctx, name)
+	}
+	return nil, ErrLockerNotSupported
+}
@@ -0,0 +1,14 @@
+package cloudregistry
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestNewMutex_NotSupported(t *testing.T) {
+	_, err := NewMutex(context.Background(), &lifecycleRegistry{}, "lock")
+	if !errors.Is(err, ErrLockerNotSupported) {
+		t.Errorf("NewMutex() error = %v, want %v", err, ErrLockerNotSupported)
+	}
+}
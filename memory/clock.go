@@ -0,0 +1,52 @@
+package memory
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock provides the current time to the registry.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc is an adapter to allow the use of ordinary functions as Clock.
+type ClockFunc func() time.Time
+
+// Now returns the current time.
+func (f ClockFunc) Now() time.Time { return f() }
+
+// SystemClock is the Clock returning the system time.
+var SystemClock Clock = ClockFunc(time.Now)
+
+// ManualClock is the Clock which time changes only by Advance or Set calls.
+type ManualClock struct {
+	mx  sync.RWMutex
+	now time.Time
+}
+
+// NewManualClock creates a new manual clock with the given time.
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+// Now returns the current time of the clock.
+func (c *ManualClock) Now() time.Time {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+	return c.now
+}
+
+// Advance moves the clock forward by the duration.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Set sets the current time of the clock.
+func (c *ManualClock) Set(now time.Time) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.now = now
+}
@@ -0,0 +1,16 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+func init() {
+	cloudregistry.RegisterDriver("memory", open)
+}
+
+// open creates a new empty registry for every call, the URI has no parameters.
+func open(ctx context.Context, uri string) (cloudregistry.Registry, error) {
+	return NewRegistry(), nil
+}
@@ -0,0 +1,159 @@
+package memory
+
+import (
+	"context"
+	"slices"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+// electionState is the queue of the candidates, the first one is the leader.
+type electionState struct {
+	candidates []*candidate
+	// changed is closed and replaced on every change of the candidates
+	changed chan struct{}
+}
+
+type candidate struct {
+	value string
+}
+
+func (e *electionState) notifyLocked() {
+	close(e.changed)
+	e.changed = make(chan struct{})
+}
+
+func (e *electionState) leaderLocked() (string, bool) {
+	if len(e.candidates) == 0 {
+		return "", false
+	}
+	return e.candidates[0].value, true
+}
+
+// NewElection creates the leader election candidate, the candidates are elected in the campaign order.
+func (r *Registry) NewElection(ctx context.Context, prefix *cloudregistry.ServicePrefix) (cloudregistry.Election, error) {
+	s := r.store
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.closed {
+		return nil, cloudregistry.ErrClosed
+	}
+	key := cloudregistry.ElectionKey(prefix)
+	state := s.elections[key]
+	if state == nil {
+		state = &electionState{changed: make(chan struct{})}
+		s.elections[key] = state
+	}
+	return &election{store: s, state: state}, nil
+}
+
+type election struct {
+	store     *store
+	state     *electionState
+	candidate *candidate
+}
+
+// Campaign waits until the candidate is the first in the queue, the elected candidate updates the leader value.
+func (e *election) Campaign(ctx context.Context, value string) error {
+	s := e.store
+	s.mx.Lock()
+	if s.closed {
+		s.mx.Unlock()
+		return cloudregistry.ErrClosed
+	}
+	if e.candidate != nil {
+		e.candidate.value = value
+		e.state.notifyLocked()
+		s.mx.Unlock()
+		return nil
+	}
+	c := &candidate{value: value}
+	e.state.candidates = append(e.state.candidates, c)
+	e.state.notifyLocked()
+	for e.state.candidates[0] != c {
+		changed := e.state.changed
+		s.mx.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			e.remove(c)
+			return ctx.Err()
+		case <-s.done:
+			return cloudregistry.ErrClosed
+		}
+		s.mx.Lock()
+	}
+	e.candidate = c
+	s.mx.Unlock()
+	return nil
+}
+
+// Resign removes the candidate from the queue.
+func (e *election) Resign(ctx context.Context) error {
+	if e.candidate == nil {
+		return cloudregistry.ErrNotLeader
+	}
+	e.remove(e.candidate)
+	e.candidate = nil
+	return nil
+}
+
+func (e *election) remove(c *candidate) {
+	e.store.mx.Lock()
+	defer e.store.mx.Unlock()
+	e.state.candidates = slices.DeleteFunc(e.state.candidates, func(it *candidate) bool { return it == c })
+	e.state.notifyLocked()
+}
+
+// Leader returns the value of the first candidate.
+func (e *election) Leader(ctx context.Context) (string, error) {
+	s := e.store
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	if s.closed {
+		return "", cloudregistry.ErrClosed
+	}
+	if value, ok := e.state.leaderLocked(); ok {
+		return value, nil
+	}
+	return "", cloudregistry.ErrNotFound
+}
+
+// Observe sends the leader value on every change of the leader until the context is done or the registry is closed.
+func (e *election) Observe(ctx context.Context) <-chan string {
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		var (
+			leader string
+			first  = true
+		)
+		for {
+			e.store.mx.RLock()
+			value, _ := e.state.leaderLocked()
+			changed := e.state.changed
+			e.store.mx.RUnlock()
+
+			if first || value != leader {
+				leader, first = value, false
+				select {
+				case ch <- value:
+				case <-ctx.Done():
+					return
+				case <-e.store.done:
+					return
+				}
+			}
+			select {
+			case <-changed:
+			case <-ctx.Done():
+				return
+			case <-e.store.done:
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+var _ cloudregistry.Elector = (*Registry)(nil)
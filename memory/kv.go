@@ -0,0 +1,117 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+// DeleteValue deletes the value from the registry, the subscribers receive the nil value.
+func (r *Registry) DeleteValue(ctx context.Context, name string) error {
+	s := r.store
+	key := r.prefix + name
+
+	s.mx.Lock()
+	if s.closed {
+		s.mx.Unlock()
+		return cloudregistry.ErrClosed
+	}
+	subs := s.deleteValueLocked(key)
+	s.mx.Unlock()
+
+	notify(subs)
+	return nil
+}
+
+// DeletePrefix deletes all values with the prefix from the registry, the subscribers receive the nil values.
+func (r *Registry) DeletePrefix(ctx context.Context, prefix string) error {
+	s := r.store
+	s.mx.Lock()
+	if s.closed {
+		s.mx.Unlock()
+		return cloudregistry.ErrClosed
+	}
+	var keys []string
+	for key := range s.values {
+		if strings.HasPrefix(key, r.prefix+prefix) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	var subs []*subscription
+	for _, key := range keys {
+		for _, sub := range s.deleteValueLocked(key) {
+			if !slices.Contains(subs, sub) {
+				subs = append(subs, sub)
+			}
+		}
+	}
+	s.mx.Unlock()
+
+	notify(subs)
+	return nil
+}
+
+// deleteValueLocked deletes the value, queues the nil value to the subscriptions and returns them,
+// nil if the value is missing.
+func (s *store) deleteValueLocked(key string) []*subscription {
+	if _, ok := s.values[key]; !ok {
+		return nil
+	}
+	delete(s.values, key)
+	delete(s.versions, key)
+	subs := s.matchSubscriptionsLocked(key)
+	for _, sub := range subs {
+		sub.enqueue(key, nil)
+	}
+	return subs
+}
+
+// ListValues returns the values with the prefix, the version is the registry revision of the last change.
+func (r *Registry) ListValues(ctx context.Context, prefix string) ([]*cloudregistry.KeyValue, error) {
+	s := r.store
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	if s.closed {
+		return nil, cloudregistry.ErrClosed
+	}
+	var values []*cloudregistry.KeyValue
+	for key, value := range s.values {
+		if strings.HasPrefix(key, r.prefix+prefix) {
+			values = append(values, &cloudregistry.KeyValue{
+				Key:     strings.TrimPrefix(key, r.prefix),
+				Value:   value,
+				Version: s.versions[key],
+			})
+		}
+	}
+	slices.SortFunc(values, func(a, b *cloudregistry.KeyValue) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	return values, nil
+}
+
+// CompareAndSwap sets the value if its version matches, the zero version requires the value to be absent.
+func (r *Registry) CompareAndSwap(ctx context.Context, name string, version uint64, value string) (bool, error) {
+	s := r.store
+	key := r.prefix + name
+
+	s.mx.Lock()
+	if s.closed {
+		s.mx.Unlock()
+		return false, cloudregistry.ErrClosed
+	}
+	if s.versions[key] != version {
+		s.mx.Unlock()
+		return false, nil
+	}
+	subs := s.setValueLocked(key, value)
+	s.mx.Unlock()
+
+	notify(subs)
+	return true, nil
+}
+
+var _ cloudregistry.ValueStore = (*Registry)(nil)
@@ -0,0 +1,99 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+// NewMutex creates the mutex shared by the registry and its value clients,
+// the lock is lost when the registry is closed.
+func (r *Registry) NewMutex(ctx context.Context, name string) (cloudregistry.Mutex, error) {
+	s := r.store
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.closed {
+		return nil, cloudregistry.ErrClosed
+	}
+	key := r.prefix + "locks/" + name
+	sem := s.locks[key]
+	if sem == nil {
+		sem = make(chan struct{}, 1)
+		s.locks[key] = sem
+	}
+	lost := make(chan struct{})
+	close(lost)
+	return &mutex{store: s, sem: sem, lost: lost}, nil
+}
+
+type mutex struct {
+	store   *store
+	sem     chan struct{}
+	release chan struct{}
+	lost    chan struct{}
+}
+
+// Lock acquires the lock, waiting until it is released or the context is done.
+func (m *mutex) Lock(ctx context.Context) error {
+	if m.release != nil {
+		return cloudregistry.ErrLocked
+	}
+	select {
+	case m.sem <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-m.store.done:
+		return cloudregistry.ErrClosed
+	}
+	m.held()
+	return nil
+}
+
+// TryLock acquires the lock if it is free, otherwise returns ErrLocked.
+func (m *mutex) TryLock(ctx context.Context) error {
+	if m.release != nil {
+		return cloudregistry.ErrLocked
+	}
+	select {
+	case <-m.store.done:
+		return cloudregistry.ErrClosed
+	default:
+	}
+	select {
+	case m.sem <- struct{}{}:
+	default:
+		return cloudregistry.ErrLocked
+	}
+	m.held()
+	return nil
+}
+
+func (m *mutex) held() {
+	release, lost := make(chan struct{}), make(chan struct{})
+	m.release, m.lost = release, lost
+	go func() {
+		select {
+		case <-release:
+		case <-m.store.done:
+		}
+		close(lost)
+	}()
+}
+
+// Unlock releases the lock.
+func (m *mutex) Unlock(ctx context.Context) error {
+	if m.release == nil {
+		return cloudregistry.ErrNotLocked
+	}
+	close(m.release)
+	m.release = nil
+	<-m.sem
+	return nil
+}
+
+// Lost returns the channel closed when the lock is released or the registry is closed.
+func (m *mutex) Lost() <-chan struct{} {
+	return m.lost
+}
+
+var _ cloudregistry.Locker = (*Registry)(nil)
@@ -0,0 +1,465 @@
+// Package memory implements the in-memory cloud registry.
+//
+// The registry keeps services and values in the process memory and is intended
+// for tests of the code working with the cloud registry. Instances registered with
+// Check.TTL expire if they are not refreshed by HealthCheck, the expiration is
+// evaluated on every registry access using the injectable Clock.
+package memory
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+// Option is a configuration option for the memory registry.
+type Option func(s *store)
+
+// WithClock sets the clock used for the TTL expiration and LastUpdate times.
+func WithClock(clock Clock) Option {
+	return func(s *store) {
+		s.clock = clock
+	}
+}
+
+type instance struct {
+	info *cloudregistry.ServiceInfo
+	ttl  time.Duration
+	seen time.Time
+}
+
+func (i *instance) expired(now time.Time) bool {
+	return i.ttl > 0 && now.Sub(i.seen) > i.ttl
+}
+
+type subscription struct {
+	ctx      context.Context
+	prefix   string // the client prefix trimmed from the delivered keys
+	key      string
+	isPrefix bool
+	value    cloudregistry.ValueSetter
+	codec    cloudregistry.Codec
+
+	// The updates are queued under the store lock in the revision order and delivered out of it
+	qmx        sync.Mutex
+	queue      []valueUpdate
+	delivering bool
+}
+
+// valueUpdate is the queued value change, the nil data is the deleted key.
+type valueUpdate struct {
+	key  string
+	data []byte
+}
+
+// enqueue queues the update, it is called under the store lock to keep the revision order.
+func (s *subscription) enqueue(key string, data []byte) {
+	s.qmx.Lock()
+	defer s.qmx.Unlock()
+	s.queue = append(s.queue, valueUpdate{key: key, data: data})
+}
+
+// deliver delivers the queued updates in order. Only one caller delivers at a time,
+// the updates queued meanwhile, including the ones of the setter itself, are delivered by it.
+func (s *subscription) deliver() {
+	s.qmx.Lock()
+	if s.delivering {
+		s.qmx.Unlock()
+		return
+	}
+	s.delivering = true
+	for len(s.queue) > 0 {
+		update := s.queue[0]
+		s.queue = s.queue[1:]
+		s.qmx.Unlock()
+		s.setValue(update.key, update.data)
+		s.qmx.Lock()
+	}
+	s.delivering = false
+	s.qmx.Unlock()
+}
+
+// setValue decodes the value with the subscription codec, the values which can't be decoded are skipped.
+// The key is delivered relative to the client prefix, the nil data is the deleted key.
+func (s *subscription) setValue(key string, data []byte) {
+	_ = cloudregistry.DecodeValueSetter(s.codec, s.value)(strings.TrimPrefix(key, s.prefix), data)
+}
+
+func (s *subscription) match(key string) bool {
+	if s.isPrefix {
+		return strings.HasPrefix(key, s.key)
+	}
+	return s.key == key
+}
+
+// store is the state shared by the registry and its nested value clients.
+type store struct {
+	mx        sync.RWMutex
+	clock     Clock
+	closed    bool
+	done      chan struct{}
+	services  map[string]*instance
+	values    map[string]string
+	versions  map[string]uint64
+	revision  uint64
+	subs      []*subscription
+	watchers  []*serviceWatcher
+	locks     map[string]chan struct{}
+	elections map[string]*electionState
+}
+
+// Registry is the in-memory registry implementation.
+type Registry struct {
+	store  *store
+	prefix string
+	codec  cloudregistry.Codec
+}
+
+// NewRegistry creates a new empty in-memory registry.
+func NewRegistry(options ...Option) *Registry {
+	s := &store{
+		clock:     SystemClock,
+		done:      make(chan struct{}),
+		services:  map[string]*instance{},
+		values:    map[string]string{},
+		versions:  map[string]uint64{},
+		locks:     map[string]chan struct{}{},
+		elections: map[string]*electionState{},
+	}
+	for _, option := range options {
+		option(s)
+	}
+	return &Registry{store: s, codec: cloudregistry.DefaultCodec}
+}
+
+// Register registers a service in the registry, the existing instance with the same ID is replaced.
+func (r *Registry) Register(ctx context.Context, service *cloudregistry.Service) error {
+	s := r.store
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.closed {
+		return cloudregistry.ErrClosed
+	}
+	now := s.clock.Now()
+	s.expireLocked(now)
+
+	key := serviceKey(service.ID())
+	_, exists := s.services[key]
+	inst := &instance{
+		info: &cloudregistry.ServiceInfo{
+			Name:       service.Name,
+			Namespace:  service.Namespace,
+			Partition:  service.Partition,
+			InstanceID: service.InstanceID,
+			Hostname:   service.Hostname,
+			Port:       service.Port,
+			Public:     cloneHosts(service.Public),
+			Private:    cloneHosts(service.Private),
+			Tags:       slices.Clone(service.Tags),
+			Meta:       maps.Clone(service.Meta),
+			Health:     cloudregistry.Health{Status: cloudregistry.HealthPassing, LastCheck: now},
+			LastUpdate: now,
+		},
+		ttl:  service.Check.TTL,
+		seen: now,
+	}
+	s.services[key] = inst
+
+	eventType := cloudregistry.ServiceAdded
+	if exists {
+		eventType = cloudregistry.ServiceUpdated
+	}
+	s.notifyWatchersLocked(eventType, inst.info)
+	return nil
+}
+
+// Deregister removes the service instance from the registry.
+func (r *Registry) Deregister(ctx context.Context, id *cloudregistry.ServiceID) error {
+	s := r.store
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.closed {
+		return cloudregistry.ErrClosed
+	}
+	s.expireLocked(s.clock.Now())
+
+	key := serviceKey(id)
+	if inst, ok := s.services[key]; ok {
+		delete(s.services, key)
+		s.notifyWatchersLocked(cloudregistry.ServiceRemoved, inst.info)
+	}
+	return nil
+}
+
+// Discover returns the alive instances matching the prefix.
+// Empty Namespace and Partition of the prefix match any value,
+// instances not updated during the TTL are skipped if TTL is positive.
+func (r *Registry) Discover(ctx context.Context, prefix *cloudregistry.ServicePrefix, TTL time.Duration) ([]*cloudregistry.ServiceInfo, error) {
+	s := r.store
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.closed {
+		return nil, cloudregistry.ErrClosed
+	}
+	now := s.clock.Now()
+	s.expireLocked(now)
+
+	var services []*cloudregistry.ServiceInfo
+	for _, inst := range s.services {
+		if !matchPrefix(prefix, inst.info) {
+			continue
+		}
+		if TTL > 0 && now.Sub(inst.info.LastUpdate) > TTL {
+			continue
+		}
+		services = append(services, cloneServiceInfo(inst.info))
+	}
+	if len(services) == 0 {
+		return nil, cloudregistry.ErrNotFound
+	}
+	slices.SortFunc(services, func(a, b *cloudregistry.ServiceInfo) int {
+		return strings.Compare(a.InstanceID, b.InstanceID)
+	})
+	return services, nil
+}
+
+// ListServices returns the services with the live instances in the namespace.
+func (r *Registry) ListServices(ctx context.Context, namespace string) ([]*cloudregistry.ServicePrefix, error) {
+	s := r.store
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.closed {
+		return nil, cloudregistry.ErrClosed
+	}
+	s.expireLocked(s.clock.Now())
+
+	services := make([]*cloudregistry.ServiceInfo, 0, len(s.services))
+	for _, inst := range s.services {
+		services = append(services, inst.info)
+	}
+	return cloudregistry.ServicePrefixes(services, namespace), nil
+}
+
+// HealthCheck refreshes the instance TTL, returns ErrNotFound if the instance is missing or expired.
+// The reported health status is kept.
+func (r *Registry) HealthCheck(ctx context.Context, id *cloudregistry.ServiceID, TTL time.Duration) error {
+	return r.refresh(id, nil)
+}
+
+// ReportHealth sets the health status of the instance and refreshes its TTL,
+// the watchers receive the ServiceUpdated event if the status or output changes.
+func (r *Registry) ReportHealth(ctx context.Context, id *cloudregistry.ServiceID, status cloudregistry.HealthStatus, output string) error {
+	return r.refresh(id, &cloudregistry.Health{Status: status, Output: output})
+}
+
+func (r *Registry) refresh(id *cloudregistry.ServiceID, health *cloudregistry.Health) error {
+	s := r.store
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.closed {
+		return cloudregistry.ErrClosed
+	}
+	now := s.clock.Now()
+	s.expireLocked(now)
+
+	inst, ok := s.services[serviceKey(id)]
+	if !ok {
+		return cloudregistry.ErrNotFound
+	}
+	inst.seen = now
+	inst.info.LastUpdate = now
+	inst.info.Health.LastCheck = now
+	if health != nil && (health.Status != inst.info.Health.Status || health.Output != inst.info.Health.Output) {
+		inst.info.Health.Status, inst.info.Health.Output = health.Status, health.Output
+		s.notifyWatchersLocked(cloudregistry.ServiceUpdated, inst.info)
+	}
+	return nil
+}
+
+// Values returns a ValueClient with the keys prefixed by the given prefix.
+func (r *Registry) Values(ctx context.Context, prefix ...string) cloudregistry.ValueClient {
+	if len(prefix) == 0 {
+		return r
+	}
+	return &Registry{store: r.store, prefix: r.prefix + strings.Join(prefix, ""), codec: r.codec}
+}
+
+// Codec returns the codec decoding the subscription values.
+func (r *Registry) Codec() cloudregistry.Codec {
+	return r.codec
+}
+
+// WithCodec returns a ValueClient with the same prefix using the codec.
+func (r *Registry) WithCodec(codec cloudregistry.Codec) cloudregistry.ValueClient {
+	return &Registry{store: r.store, prefix: r.prefix, codec: codec}
+}
+
+// Value returns a value from the registry.
+func (r *Registry) Value(ctx context.Context, name string) (string, error) {
+	s := r.store
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	if s.closed {
+		return "", cloudregistry.ErrClosed
+	}
+	value, ok := s.values[r.prefix+name]
+	if !ok {
+		return "", cloudregistry.ErrNotFound
+	}
+	return value, nil
+}
+
+// SetValue sets a value in the registry and notifies the subscribers.
+func (r *Registry) SetValue(ctx context.Context, name, value string) error {
+	s := r.store
+	key := r.prefix + name
+
+	s.mx.Lock()
+	if s.closed {
+		s.mx.Unlock()
+		return cloudregistry.ErrClosed
+	}
+	subs := s.setValueLocked(key, value)
+	s.mx.Unlock()
+
+	notify(subs)
+	return nil
+}
+
+// setValueLocked sets the value with the next revision, queues it to the subscriptions and returns them.
+func (s *store) setValueLocked(key, value string) []*subscription {
+	s.revision++
+	s.values[key] = value
+	s.versions[key] = s.revision
+	subs := s.matchSubscriptionsLocked(key)
+	for _, sub := range subs {
+		sub.enqueue(key, []byte(value))
+	}
+	return subs
+}
+
+// notify delivers the queued updates out of the lock, so the subscribers can use the registry.
+func notify(subs []*subscription) {
+	for _, sub := range subs {
+		sub.deliver()
+	}
+}
+
+// SubscribeValue subscribes to a value in the registry.
+// The current value is delivered immediately if it exists.
+func (r *Registry) SubscribeValue(ctx context.Context, name string, val cloudregistry.ValueSetter) error {
+	return r.subscribe(ctx, r.prefix+name, false, val)
+}
+
+// SubscribeValueWithPrefix subscribes to the values with the prefix in the registry.
+// The current values are delivered immediately.
+func (r *Registry) SubscribeValueWithPrefix(ctx context.Context, prefix string, val cloudregistry.ValueSetter) error {
+	return r.subscribe(ctx, r.prefix+prefix, true, val)
+}
+
+func (r *Registry) subscribe(ctx context.Context, key string, isPrefix bool, val cloudregistry.ValueSetter) error {
+	s := r.store
+	sub := &subscription{ctx: ctx, prefix: r.prefix, key: key, isPrefix: isPrefix, value: val, codec: r.codec}
+
+	s.mx.Lock()
+	if s.closed {
+		s.mx.Unlock()
+		return cloudregistry.ErrClosed
+	}
+	s.subs = append(s.subs, sub)
+	var keys []string
+	for k := range s.values {
+		if sub.match(k) {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		sub.enqueue(k, []byte(s.values[k]))
+	}
+	s.mx.Unlock()
+
+	sub.deliver()
+	return nil
+}
+
+// Close closes the registry, all subscriptions and watchers are stopped.
+func (r *Registry) Close() error {
+	s := r.store
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if !s.closed {
+		s.closed = true
+		s.subs = nil
+		s.watchers = nil
+		close(s.done)
+	}
+	return nil
+}
+
+// matchSubscriptionsLocked returns the active subscriptions for the key and drops the finished ones.
+func (s *store) matchSubscriptionsLocked(key string) []*subscription {
+	var matched []*subscription
+	s.subs = slices.DeleteFunc(s.subs, func(sub *subscription) bool {
+		return sub.ctx.Err() != nil
+	})
+	for _, sub := range s.subs {
+		if sub.match(key) {
+			matched = append(matched, sub)
+		}
+	}
+	return matched
+}
+
+// expireLocked removes the instances with the expired TTL.
+func (s *store) expireLocked(now time.Time) {
+	for key, inst := range s.services {
+		if inst.expired(now) {
+			delete(s.services, key)
+			s.notifyWatchersLocked(cloudregistry.ServiceRemoved, inst.info)
+		}
+	}
+}
+
+func serviceKey(id *cloudregistry.ServiceID) string {
+	return id.String() + id.InstanceID
+}
+
+func matchPrefix(prefix *cloudregistry.ServicePrefix, info *cloudregistry.ServiceInfo) bool {
+	return prefix.Name == info.Name &&
+		(prefix.Namespace == "" || prefix.Namespace == info.Namespace) &&
+		(prefix.Partition == "" || prefix.Partition == info.Partition)
+}
+
+func cloneHosts(hosts []cloudregistry.Host) []cloudregistry.Host {
+	if hosts == nil {
+		return nil
+	}
+	cloned := make([]cloudregistry.Host, len(hosts))
+	for i, host := range hosts {
+		cloned[i] = host
+		cloned[i].Ports = maps.Clone(host.Ports)
+	}
+	return cloned
+}
+
+func cloneServiceInfo(info *cloudregistry.ServiceInfo) *cloudregistry.ServiceInfo {
+	cloned := *info
+	cloned.Public = cloneHosts(info.Public)
+	cloned.Private = cloneHosts(info.Private)
+	cloned.Tags = slices.Clone(info.Tags)
+	cloned.Meta = maps.Clone(info.Meta)
+	return &cloned
+}
+
+var (
+	_ cloudregistry.Registry       = (*Registry)(nil)
+	_ cloudregistry.CodecClient    = (*Registry)(nil)
+	_ cloudregistry.HealthReporter = (*Registry)(nil)
+	_ cloudregistry.ServiceLister  = (*Registry)(nil)
+)
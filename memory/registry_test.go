@@ -0,0 +1,388 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/demdxx/cloudregistry"
+	"github.com/demdxx/cloudregistry/registrytest"
+)
+
+func testService(id string, ttl time.Duration) *cloudregistry.Service {
+	return &cloudregistry.Service{
+		Name:       "test-service",
+		Namespace:  "test",
+		InstanceID: id,
+		Hostname:   "localhost",
+		Port:       8080,
+		Tags:       []string{"v1"},
+		Meta:       map[string]string{"zone": "a"},
+		Check:      cloudregistry.Check{ID: id, TTL: ttl},
+	}
+}
+
+type recorder struct {
+	mx     sync.Mutex
+	values map[string]any
+	calls  int
+}
+
+func newRecorder() *recorder {
+	return &recorder{values: map[string]any{}}
+}
+
+func (r *recorder) SetValue(key string, value any) error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.values[key] = value
+	r.calls++
+	return nil
+}
+
+func (r *recorder) get(key string) (any, int) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return r.values[key], r.calls
+}
+
+func TestRegistry_RegisterDiscover(t *testing.T) {
+	ctx := context.Background()
+	registry := NewRegistry()
+
+	if _, err := registry.Discover(ctx, &cloudregistry.ServicePrefix{Name: "test-service"}, 0); !errors.Is(err, cloudregistry.ErrNotFound) {
+		t.Errorf("Discover() on empty registry error = %v, want %v", err, cloudregistry.ErrNotFound)
+	}
+
+	_ = registry.Register(ctx, testService("instance-2", 0))
+	_ = registry.Register(ctx, testService("instance-1", 0))
+	other := testService("other", 0)
+	other.Name = "other-service"
+	_ = registry.Register(ctx, other)
+
+	tests := []struct {
+		name   string
+		prefix cloudregistry.ServicePrefix
+		want   []string
+	}{
+		{name: "by name", prefix: cloudregistry.ServicePrefix{Name: "test-service"}, want: []string{"instance-1", "instance-2"}},
+		{name: "by namespace", prefix: cloudregistry.ServicePrefix{Name: "test-service", Namespace: "test"}, want: []string{"instance-1", "instance-2"}},
+		{name: "other namespace", prefix: cloudregistry.ServicePrefix{Name: "test-service", Namespace: "prod"}},
+		{name: "other partition", prefix: cloudregistry.ServicePrefix{Name: "test-service", Partition: "eu"}},
+		{name: "other service", prefix: cloudregistry.ServicePrefix{Name: "other-service"}, want: []string{"other"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			services, err := registry.Discover(ctx, &tt.prefix, 0)
+			if len(tt.want) == 0 {
+				if !errors.Is(err, cloudregistry.ErrNotFound) {
+					t.Errorf("Discover() error = %v, want %v", err, cloudregistry.ErrNotFound)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Discover() error = %v", err)
+			}
+			if len(services) != len(tt.want) {
+				t.Fatalf("Discover() returned %d services, want %d", len(services), len(tt.want))
+			}
+			for i, svc := range services {
+				if svc.InstanceID != tt.want[i] {
+					t.Errorf("Discover()[%d] = %s, want %s", i, svc.InstanceID, tt.want[i])
+				}
+			}
+		})
+	}
+
+	services, _ := registry.Discover(ctx, &cloudregistry.ServicePrefix{Name: "test-service"}, 0)
+	if svc := services[0]; len(svc.Tags) != 1 || svc.Meta["zone"] != "a" || svc.Namespace != "test" {
+		t.Errorf("Discover() lost service fields: %+v", svc)
+	}
+	services[0].Meta["zone"] = "changed"
+	services, _ = registry.Discover(ctx, &cloudregistry.ServicePrefix{Name: "test-service"}, 0)
+	if services[0].Meta["zone"] != "a" {
+		t.Error("Discover() result modification must not change the registry")
+	}
+
+	if err := registry.Deregister(ctx, testService("instance-1", 0).ID()); err != nil {
+		t.Fatalf("Deregister() error = %v", err)
+	}
+	services, _ = registry.Discover(ctx, &cloudregistry.ServicePrefix{Name: "test-service"}, 0)
+	if len(services) != 1 || services[0].InstanceID != "instance-2" {
+		t.Errorf("Discover() after Deregister() = %v", services)
+	}
+}
+
+func TestRegistry_TTL(t *testing.T) {
+	ctx := context.Background()
+	clock := NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	registry := NewRegistry(WithClock(clock))
+	prefix := &cloudregistry.ServicePrefix{Name: "test-service"}
+
+	_ = registry.Register(ctx, testService("ttl", 10*time.Second))
+	_ = registry.Register(ctx, testService("forever", 0))
+
+	clock.Advance(8 * time.Second)
+	if err := registry.HealthCheck(ctx, testService("ttl", 0).ID(), 10*time.Second); err != nil {
+		t.Fatalf("HealthCheck() error = %v", err)
+	}
+
+	clock.Advance(8 * time.Second)
+	services, _ := registry.Discover(ctx, prefix, 0)
+	if len(services) != 2 {
+		t.Fatalf("Discover() after heartbeat returned %d services, want 2", len(services))
+	}
+	if want := clock.Now().Add(-8 * time.Second); !services[1].LastUpdate.Equal(want) {
+		t.Errorf("LastUpdate = %v, want %v", services[1].LastUpdate, want)
+	}
+
+	// The Discover TTL argument skips instances not updated in time
+	services, _ = registry.Discover(ctx, prefix, 5*time.Second)
+	if len(services) != 0 {
+		t.Errorf("Discover() with TTL returned %v, want none", services)
+	}
+
+	clock.Advance(3 * time.Second)
+	services, _ = registry.Discover(ctx, prefix, 0)
+	if len(services) != 1 || services[0].InstanceID != "forever" {
+		t.Errorf("Discover() after expiration = %v, want only 'forever'", services)
+	}
+	if err := registry.HealthCheck(ctx, testService("ttl", 0).ID(), 0); !errors.Is(err, cloudregistry.ErrNotFound) {
+		t.Errorf("HealthCheck() of expired instance error = %v, want %v", err, cloudregistry.ErrNotFound)
+	}
+}
+
+func TestRegistry_Values(t *testing.T) {
+	ctx := context.Background()
+	registry := NewRegistry()
+
+	if _, err := registry.Value(ctx, "missing"); !errors.Is(err, cloudregistry.ErrNotFound) {
+		t.Errorf("Value() error = %v, want %v", err, cloudregistry.ErrNotFound)
+	}
+
+	nested := registry.Values(ctx, "app/").Values(ctx, "db/")
+	if err := nested.SetValue(ctx, "host", "localhost"); err != nil {
+		t.Fatalf("SetValue() error = %v", err)
+	}
+	if value, _ := registry.Value(ctx, "app/db/host"); value != "localhost" {
+		t.Errorf("Value() of nested key = %q, want %q", value, "localhost")
+	}
+	if value, _ := registry.Values(ctx, "app/").Value(ctx, "db/host"); value != "localhost" {
+		t.Errorf("Value() from nested client = %q, want %q", value, "localhost")
+	}
+	if registry.Values(ctx) != registry {
+		t.Error("Values() without prefix should return the same client")
+	}
+}
+
+func TestRegistry_Subscribe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	registry := NewRegistry()
+	_ = registry.SetValue(ctx, "app/existing", `{"a":1}`)
+
+	single := newRecorder()
+	prefix := newRecorder()
+	if err := registry.SubscribeValue(ctx, "app/key", single); err != nil {
+		t.Fatalf("SubscribeValue() error = %v", err)
+	}
+	if err := registry.Values(ctx, "app/").SubscribeValueWithPrefix(ctx, "", prefix); err != nil {
+		t.Fatalf("SubscribeValueWithPrefix() error = %v", err)
+	}
+	if value, _ := prefix.get("existing"); value == nil {
+		t.Error("SubscribeValueWithPrefix() should deliver the existing values relative to the client prefix")
+	}
+
+	_ = registry.SetValue(ctx, "app/key", "10")
+	_ = registry.SetValue(ctx, "app/other", "text")
+	_ = registry.SetValue(ctx, "unrelated", "x")
+
+	if value, calls := single.get("app/key"); value != float64(10) || calls != 1 {
+		t.Errorf("single subscriber got %v (%d calls), want decoded 10 once", value, calls)
+	}
+	if value, calls := prefix.get("other"); value != "text" || calls != 3 {
+		t.Errorf("prefix subscriber got %v (%d calls), want 'text' and 3 calls", value, calls)
+	}
+
+	_ = registry.DeletePrefix(ctx, "app/")
+	_ = registry.DeleteValue(ctx, "app/missing")
+	if value, calls := prefix.get("other"); value != nil || calls != 6 {
+		t.Errorf("prefix subscriber got %v (%d calls), want the deleted keys as nil", value, calls)
+	}
+	if value, calls := single.get("app/key"); value != nil || calls != 2 {
+		t.Errorf("single subscriber got %v (%d calls), want the deleted key as nil", value, calls)
+	}
+
+	cancel()
+	_ = registry.SetValue(context.Background(), "app/key", "20")
+	if _, calls := single.get("app/key"); calls != 2 {
+		t.Error("subscriber should not be notified after the context is canceled")
+	}
+}
+
+func TestRegistry_SubscribeOrder(t *testing.T) {
+	ctx := context.Background()
+	registry := NewRegistry()
+	values := cloudregistry.WithCodec(registry, cloudregistry.StringCodec)
+
+	var (
+		mx       sync.Mutex
+		received []string
+		entered  = make(chan struct{})
+		release  = make(chan struct{})
+	)
+	_ = values.SubscribeValue(ctx, "key", cloudregistry.ValueSetterFunc(func(key string, value any) error {
+		if value == "1" {
+			close(entered)
+			<-release
+		}
+		mx.Lock()
+		defer mx.Unlock()
+		received = append(received, value.(string))
+		return nil
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = registry.SetValue(ctx, "key", "1")
+	}()
+	<-entered
+	// The newer value is delivered after the older one which is still being delivered
+	_ = registry.SetValue(ctx, "key", "2")
+	close(release)
+	<-done
+
+	mx.Lock()
+	defer mx.Unlock()
+	if len(received) != 2 || received[0] != "1" || received[1] != "2" {
+		t.Errorf("received values = %q, want [1 2] in the revision order", received)
+	}
+}
+
+func TestRegistry_Codec(t *testing.T) {
+	ctx := context.Background()
+	registry := NewRegistry()
+	_ = registry.SetValue(ctx, "app/port", "8080")
+
+	auto := newRecorder()
+	str := newRecorder()
+	_ = registry.SubscribeValue(ctx, "app/port", auto)
+	values := cloudregistry.WithCodec(registry.Values(ctx, "app/"), cloudregistry.StringCodec)
+	_ = values.SubscribeValue(ctx, "port", str)
+
+	if value, _ := auto.get("app/port"); value != float64(8080) {
+		t.Errorf("default codec value = %#v, want float64(8080)", value)
+	}
+	if value, _ := str.get("port"); value != "8080" {
+		t.Errorf("string codec value = %#v, want \"8080\"", value)
+	}
+	if cloudregistry.CodecOf(values.Values(ctx, "nested/")) != cloudregistry.StringCodec {
+		t.Error("nested client should inherit the codec")
+	}
+}
+
+func TestRegistry_Close(t *testing.T) {
+	ctx := context.Background()
+	registry := NewRegistry()
+	if err := registry.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := registry.Close(); err != nil {
+		t.Fatalf("second Close() error = %v", err)
+	}
+	if err := registry.Register(ctx, testService("x", 0)); err == nil {
+		t.Error("Register() after Close() expected error")
+	}
+	if err := registry.SetValue(ctx, "key", "value"); err == nil {
+		t.Error("SetValue() after Close() expected error")
+	}
+}
+
+func TestRegistry_LockLostOnClose(t *testing.T) {
+	ctx := context.Background()
+	registry := NewRegistry()
+	mx, err := registry.NewMutex(ctx, "lock")
+	if err != nil {
+		t.Fatalf("NewMutex() error = %v", err)
+	}
+	if err := mx.Lock(ctx); err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+	_ = registry.Close()
+	select {
+	case <-mx.Lost():
+	case <-time.After(time.Second):
+		t.Fatal("Lost() is not closed after Close()")
+	}
+	if err := mx.Unlock(ctx); err != nil {
+		t.Errorf("Unlock() error = %v", err)
+	}
+}
+
+func TestRegistry_WatchServices(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clock := NewManualClock(time.Now())
+	registry := NewRegistry(WithClock(clock))
+	prefix := &cloudregistry.ServicePrefix{Name: "test-service"}
+
+	_ = registry.Register(ctx, testService("instance-1", 0))
+	events, err := cloudregistry.WatchServices(ctx, registry, prefix)
+	if err != nil {
+		t.Fatalf("WatchServices() error = %v", err)
+	}
+
+	expect := func(tp cloudregistry.ServiceEventType, id string) {
+		t.Helper()
+		select {
+		case ev := <-events:
+			gotID := ""
+			if ev.Service != nil {
+				gotID = ev.Service.InstanceID
+			} else if len(ev.Services) > 0 {
+				gotID = ev.Services[0].InstanceID
+			}
+			if ev.Type != tp || gotID != id {
+				t.Errorf("event = %s %s, want %s %s", ev.Type, gotID, tp, id)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event %s %s was not received", tp, id)
+		}
+	}
+
+	expect(cloudregistry.ServiceSnapshot, "instance-1")
+	_ = registry.Register(ctx, testService("instance-2", time.Second))
+	expect(cloudregistry.ServiceAdded, "instance-2")
+	_ = registry.Register(ctx, testService("instance-1", 0))
+	expect(cloudregistry.ServiceUpdated, "instance-1")
+	_ = registry.Deregister(ctx, testService("instance-1", 0).ID())
+	expect(cloudregistry.ServiceRemoved, "instance-1")
+
+	clock.Advance(2 * time.Second)
+	_, _ = registry.Discover(ctx, prefix, 0)
+	expect(cloudregistry.ServiceRemoved, "instance-2")
+
+	_ = registry.Close()
+	if _, ok := <-events; ok {
+		t.Error("events channel should be closed after Close()")
+	}
+}
+
+func TestOpen(t *testing.T) {
+	registry, err := cloudregistry.Open(context.Background(), "memory://")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if _, ok := registry.(*Registry); !ok {
+		t.Errorf("Open() = %T, want *Registry", registry)
+	}
+}
+
+func TestRegistry_Conformance(t *testing.T) {
+	registrytest.Run(t, func(t *testing.T) (cloudregistry.Registry, func(time.Duration)) {
+		clock := NewManualClock(time.Now())
+		return NewRegistry(WithClock(clock)), clock.Advance
+	}, registrytest.WithTimeout(time.Second))
+}
@@ -0,0 +1,112 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+// serviceWatcher queues the service events for one WatchServices call,
+// so the registry never blocks on slow consumers.
+type serviceWatcher struct {
+	ctx    context.Context
+	prefix cloudregistry.ServicePrefix
+
+	mx     sync.Mutex
+	queue  []cloudregistry.ServiceEvent
+	notify chan struct{}
+}
+
+func (w *serviceWatcher) push(event cloudregistry.ServiceEvent) {
+	w.mx.Lock()
+	w.queue = append(w.queue, event)
+	w.mx.Unlock()
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (w *serviceWatcher) pop() []cloudregistry.ServiceEvent {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	queue := w.queue
+	w.queue = nil
+	return queue
+}
+
+// WatchServices watches the service instances matching the prefix.
+// The first event is a snapshot of the current instances, next events reflect the changes.
+// Expired instances are reported as removed on the next registry access.
+func (r *Registry) WatchServices(ctx context.Context, prefix *cloudregistry.ServicePrefix) (<-chan cloudregistry.ServiceEvent, error) {
+	s := r.store
+	watcher := &serviceWatcher{
+		ctx:    ctx,
+		prefix: *prefix,
+		notify: make(chan struct{}, 1),
+	}
+
+	s.mx.Lock()
+	if s.closed {
+		s.mx.Unlock()
+		return nil, cloudregistry.ErrClosed
+	}
+	s.expireLocked(s.clock.Now())
+	var services []*cloudregistry.ServiceInfo
+	for _, inst := range s.services {
+		if matchPrefix(prefix, inst.info) {
+			services = append(services, cloneServiceInfo(inst.info))
+		}
+	}
+	slices.SortFunc(services, func(a, b *cloudregistry.ServiceInfo) int {
+		return strings.Compare(a.InstanceID, b.InstanceID)
+	})
+	watcher.push(cloudregistry.ServiceEvent{Type: cloudregistry.ServiceSnapshot, Services: services})
+	s.watchers = append(s.watchers, watcher)
+	s.mx.Unlock()
+
+	events := make(chan cloudregistry.ServiceEvent)
+	go func() {
+		defer close(events)
+		defer r.removeWatcher(watcher)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.done:
+				return
+			case <-watcher.notify:
+			}
+			for _, event := range watcher.pop() {
+				select {
+				case <-ctx.Done():
+					return
+				case <-s.done:
+					return
+				case events <- event:
+				}
+			}
+		}
+	}()
+	return events, nil
+}
+
+func (r *Registry) removeWatcher(watcher *serviceWatcher) {
+	s := r.store
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.watchers = slices.DeleteFunc(s.watchers, func(w *serviceWatcher) bool { return w == watcher })
+}
+
+func (s *store) notifyWatchersLocked(eventType cloudregistry.ServiceEventType, info *cloudregistry.ServiceInfo) {
+	for _, watcher := range s.watchers {
+		if matchPrefix(&watcher.prefix, info) {
+			watcher.push(cloudregistry.ServiceEvent{Type: eventType, Service: cloneServiceInfo(info)})
+		}
+	}
+}
+
+var _ cloudregistry.ServiceWatcher = (*Registry)(nil)
@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"expvar"
+	"sync"
+	"time"
+)
+
+// expvarBuckets are the upper bounds of the latency buckets published by Expvar.
+var expvarBuckets = []time.Duration{
+	time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 500 * time.Millisecond, time.Second, 5 * time.Second,
+}
+
+// Expvar is the Recorder publishing the metrics with the expvar package.
+// The published map contains the "calls", "errors" and "latency_ns" maps keyed by the operation,
+// the sum of the latencies divided by the calls gives the average latency.
+// The "latency_buckets" map keeps the latency distribution of each operation, it counts the calls
+// by the smallest bucket bound not below the latency, like "5ms" or "1s", the slower calls are "inf".
+type Expvar struct {
+	mx                 sync.Mutex
+	calls              *expvar.Map
+	errors             *expvar.Map
+	latency            *expvar.Map
+	buckets            *expvar.Map
+	subscriptions      *expvar.Int
+	notifications      *expvar.Int
+	notificationErrors *expvar.Int
+}
+
+// NewExpvar creates the expvar recorder published with the name,
+// it panics if the name is already published like expvar.NewMap.
+func NewExpvar(name string) *Expvar {
+	e := &Expvar{
+		calls:              new(expvar.Map).Init(),
+		errors:             new(expvar.Map).Init(),
+		latency:            new(expvar.Map).Init(),
+		buckets:            new(expvar.Map).Init(),
+		subscriptions:      new(expvar.Int),
+		notifications:      new(expvar.Int),
+		notificationErrors: new(expvar.Int),
+	}
+	root := expvar.NewMap(name)
+	root.Set("calls", e.calls)
+	root.Set("errors", e.errors)
+	root.Set("latency_ns", e.latency)
+	root.Set("latency_buckets", e.buckets)
+	root.Set("subscriptions", e.subscriptions)
+	root.Set("notifications", e.notifications)
+	root.Set("notification_errors", e.notificationErrors)
+	return e
+}
+
+// ObserveOperation counts the operation call, its error and latency.
+func (e *Expvar) ObserveOperation(operation string, duration time.Duration, err error) {
+	e.calls.Add(operation, 1)
+	e.latency.Add(operation, int64(duration))
+	e.operationBuckets(operation).Add(latencyBucket(duration), 1)
+	if err != nil {
+		e.errors.Add(operation, 1)
+	}
+}
+
+// operationBuckets returns the latency buckets of the operation, they are created on the first call.
+func (e *Expvar) operationBuckets(operation string) *expvar.Map {
+	if buckets, ok := e.buckets.Get(operation).(*expvar.Map); ok {
+		return buckets
+	}
+	e.mx.Lock()
+	defer e.mx.Unlock()
+	if buckets, ok := e.buckets.Get(operation).(*expvar.Map); ok {
+		return buckets
+	}
+	buckets := new(expvar.Map).Init()
+	e.buckets.Set(operation, buckets)
+	return buckets
+}
+
+// latencyBucket returns the key of the bucket counting the latency.
+func latencyBucket(duration time.Duration) string {
+	for _, bound := range expvarBuckets {
+		if duration <= bound {
+			return bound.String()
+		}
+	}
+	return "inf"
+}
+
+// AddSubscriptions changes the number of the active subscriptions.
+func (e *Expvar) AddSubscriptions(delta int) {
+	e.subscriptions.Add(int64(delta))
+}
+
+// ObserveNotification counts the delivered notification and the setter error.
+func (e *Expvar) ObserveNotification(err error) {
+	e.notifications.Add(1)
+	if err != nil {
+		e.notificationErrors.Add(1)
+	}
+}
+
+var _ Recorder = (*Expvar)(nil)
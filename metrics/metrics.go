@@ -0,0 +1,196 @@
+// Package metrics implements the registry decorator recording the operation metrics.
+//
+// The metrics are passed to the Recorder, the package provides the expvar recorder
+// and the Prometheus one is in the github.com/demdxx/cloudregistry/metrics/prometheus module.
+//
+// Example:
+//
+//	registry = metrics.NewRegistry(registry, metrics.NewExpvar("cloudregistry"))
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+// The operation names passed to the Recorder.
+const (
+	OpRegister     = "register"
+	OpDeregister   = "deregister"
+	OpDiscover     = "discover"
+	OpHealthCheck  = "health_check"
+	OpReportHealth = "report_health"
+	OpValue        = "value"
+	OpSetValue     = "set_value"
+	OpSubscribe    = "subscribe"
+	OpDeleteValue  = "delete_value"
+	OpDeletePrefix = "delete_prefix"
+	OpListValues   = "list_values"
+	OpCompareSwap  = "compare_and_swap"
+)
+
+// Recorder is the metrics backend.
+type Recorder interface {
+	// ObserveOperation records the latency and the result of the registry operation.
+	ObserveOperation(operation string, duration time.Duration, err error)
+	// AddSubscriptions changes the number of the active value subscriptions.
+	AddSubscriptions(delta int)
+	// ObserveNotification records the value notification delivered to the ValueSetter
+	// with the error returned by the setter.
+	ObserveNotification(err error)
+}
+
+// Registry is the decorator of the registry recording the metrics.
+// The ValueStore operations are measured like the other calls, the service watches,
+// locks and elections are passed to the wrapped registry without the metrics.
+type Registry struct {
+	valueClient
+	cloudregistry.Passthrough
+	registry cloudregistry.Registry
+}
+
+// NewRegistry wraps the registry with the metrics recording.
+func NewRegistry(registry cloudregistry.Registry, recorder Recorder) *Registry {
+	return &Registry{
+		valueClient: valueClient{client: registry, recorder: recorder},
+		Passthrough: cloudregistry.NewPassthrough(registry),
+		registry:    registry,
+	}
+}
+
+// Register registers the service in the wrapped registry.
+func (r *Registry) Register(ctx context.Context, service *cloudregistry.Service) (err error) {
+	defer r.observe(OpRegister, time.Now(), &err)
+	return r.registry.Register(ctx, service)
+}
+
+// Deregister deregisters the service in the wrapped registry.
+func (r *Registry) Deregister(ctx context.Context, id *cloudregistry.ServiceID) (err error) {
+	defer r.observe(OpDeregister, time.Now(), &err)
+	return r.registry.Deregister(ctx, id)
+}
+
+// Discover discovers the services in the wrapped registry.
+func (r *Registry) Discover(ctx context.Context, prefix *cloudregistry.ServicePrefix, TTL time.Duration) ([]*cloudregistry.ServiceInfo, error) {
+	return r.DiscoverWithQuery(ctx, prefix, TTL, nil)
+}
+
+// DiscoverWithQuery discovers the services matching the query in the wrapped registry.
+func (r *Registry) DiscoverWithQuery(ctx context.Context, prefix *cloudregistry.ServicePrefix, TTL time.Duration, query *cloudregistry.DiscoverQuery) (_ []*cloudregistry.ServiceInfo, err error) {
+	defer r.observe(OpDiscover, time.Now(), &err)
+	return cloudregistry.DiscoverWithQuery(ctx, r.registry, prefix, TTL, query)
+}
+
+// HealthCheck checks the service in the wrapped registry.
+func (r *Registry) HealthCheck(ctx context.Context, id *cloudregistry.ServiceID, TTL time.Duration) (err error) {
+	defer r.observe(OpHealthCheck, time.Now(), &err)
+	return r.registry.HealthCheck(ctx, id, TTL)
+}
+
+// ReportHealth reports the health status to the wrapped registry.
+func (r *Registry) ReportHealth(ctx context.Context, id *cloudregistry.ServiceID, status cloudregistry.HealthStatus, output string) (err error) {
+	defer r.observe(OpReportHealth, time.Now(), &err)
+	return cloudregistry.ReportHealth(ctx, r.registry, id, status, output)
+}
+
+type valueClient struct {
+	client   cloudregistry.ValueClient
+	recorder Recorder
+}
+
+// observe records the operation started at the start time, it is deferred with the named error result.
+func (c *valueClient) observe(operation string, start time.Time, err *error) {
+	c.recorder.ObserveOperation(operation, time.Since(start), *err)
+}
+
+// Values returns the ValueClient with the prefix recording the metrics.
+func (c *valueClient) Values(ctx context.Context, prefix ...string) cloudregistry.ValueClient {
+	return &valueClient{client: c.client.Values(ctx, prefix...), recorder: c.recorder}
+}
+
+// Codec returns the codec of the wrapped client.
+func (c *valueClient) Codec() cloudregistry.Codec {
+	return cloudregistry.CodecOf(c.client)
+}
+
+// WithCodec returns the ValueClient with the same prefix recording the metrics and using the codec.
+func (c *valueClient) WithCodec(codec cloudregistry.Codec) cloudregistry.ValueClient {
+	return &valueClient{client: cloudregistry.WithCodec(c.client, codec), recorder: c.recorder}
+}
+
+// Value returns the value from the wrapped client.
+func (c *valueClient) Value(ctx context.Context, name string) (_ string, err error) {
+	defer c.observe(OpValue, time.Now(), &err)
+	return c.client.Value(ctx, name)
+}
+
+// SetValue sets the value in the wrapped client.
+func (c *valueClient) SetValue(ctx context.Context, name, value string) (err error) {
+	defer c.observe(OpSetValue, time.Now(), &err)
+	return c.client.SetValue(ctx, name, value)
+}
+
+// SubscribeValue subscribes to the value, the subscription is active until the context is done.
+func (c *valueClient) SubscribeValue(ctx context.Context, name string, val cloudregistry.ValueSetter) error {
+	return c.subscribe(ctx, val, func(val cloudregistry.ValueSetter) error {
+		return c.client.SubscribeValue(ctx, name, val)
+	})
+}
+
+// SubscribeValueWithPrefix subscribes to the values with the prefix, the subscription is active until the context is done.
+func (c *valueClient) SubscribeValueWithPrefix(ctx context.Context, prefix string, val cloudregistry.ValueSetter) error {
+	return c.subscribe(ctx, val, func(val cloudregistry.ValueSetter) error {
+		return c.client.SubscribeValueWithPrefix(ctx, prefix, val)
+	})
+}
+
+func (c *valueClient) subscribe(ctx context.Context, val cloudregistry.ValueSetter, subscribe func(cloudregistry.ValueSetter) error) (err error) {
+	defer c.observe(OpSubscribe, time.Now(), &err)
+	err = subscribe(cloudregistry.ValueSetterFunc(func(key string, value any) error {
+		err := val.SetValue(key, value)
+		c.recorder.ObserveNotification(err)
+		return err
+	}))
+	if err == nil {
+		c.recorder.AddSubscriptions(1)
+		context.AfterFunc(ctx, func() { c.recorder.AddSubscriptions(-1) })
+	}
+	return err
+}
+
+// DeleteValue deletes the value if the wrapped client implements ValueStore.
+func (c *valueClient) DeleteValue(ctx context.Context, name string) (err error) {
+	defer c.observe(OpDeleteValue, time.Now(), &err)
+	return cloudregistry.DeleteValue(ctx, c.client, name)
+}
+
+// DeletePrefix deletes the values with the prefix if the wrapped client implements ValueStore.
+func (c *valueClient) DeletePrefix(ctx context.Context, prefix string) (err error) {
+	defer c.observe(OpDeletePrefix, time.Now(), &err)
+	return cloudregistry.DeletePrefix(ctx, c.client, prefix)
+}
+
+// ListValues lists the values with the prefix if the wrapped client implements ValueStore.
+func (c *valueClient) ListValues(ctx context.Context, prefix string) (_ []*cloudregistry.KeyValue, err error) {
+	defer c.observe(OpListValues, time.Now(), &err)
+	return cloudregistry.ListValues(ctx, c.client, prefix)
+}
+
+// CompareAndSwap sets the value if its version matches and the wrapped client implements ValueStore.
+func (c *valueClient) CompareAndSwap(ctx context.Context, name string, version uint64, value string) (_ bool, err error) {
+	defer c.observe(OpCompareSwap, time.Now(), &err)
+	return cloudregistry.CompareAndSwap(ctx, c.client, name, version, value)
+}
+
+var (
+	_ cloudregistry.Registry        = (*Registry)(nil)
+	_ cloudregistry.ServiceWatcher  = (*Registry)(nil)
+	_ cloudregistry.QueryDiscoverer = (*Registry)(nil)
+	_ cloudregistry.HealthReporter  = (*Registry)(nil)
+	_ cloudregistry.Locker          = (*Registry)(nil)
+	_ cloudregistry.Elector         = (*Registry)(nil)
+	_ cloudregistry.ValueStore      = (*valueClient)(nil)
+	_ cloudregistry.CodecClient     = (*valueClient)(nil)
+)
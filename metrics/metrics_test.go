@@ -0,0 +1,193 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/demdxx/cloudregistry"
+	"github.com/demdxx/cloudregistry/memory"
+	"github.com/demdxx/cloudregistry/registrytest"
+)
+
+type testRecorder struct {
+	mx                 sync.Mutex
+	calls              map[string]int
+	errors             map[string]int
+	subscriptions      int
+	notifications      int
+	notificationErrors int
+}
+
+func newTestRecorder() *testRecorder {
+	return &testRecorder{calls: map[string]int{}, errors: map[string]int{}}
+}
+
+func (r *testRecorder) ObserveOperation(operation string, duration time.Duration, err error) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.calls[operation]++
+	if err != nil {
+		r.errors[operation]++
+	}
+}
+
+func (r *testRecorder) AddSubscriptions(delta int) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.subscriptions += delta
+}
+
+func (r *testRecorder) ObserveNotification(err error) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.notifications++
+	if err != nil {
+		r.notificationErrors++
+	}
+}
+
+func (r *testRecorder) snapshot() testRecorder {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return testRecorder{
+		subscriptions:      r.subscriptions,
+		notifications:      r.notifications,
+		notificationErrors: r.notificationErrors,
+	}
+}
+
+func TestRegistry_Operations(t *testing.T) {
+	ctx := context.Background()
+	recorder := newTestRecorder()
+	registry := NewRegistry(memory.NewRegistry(), recorder)
+	defer registry.Close()
+
+	service := &cloudregistry.Service{Name: "test-service", InstanceID: "1", Hostname: "localhost"}
+	_ = registry.Register(ctx, service)
+	_, _ = registry.Discover(ctx, &cloudregistry.ServicePrefix{Name: "test-service"}, 0)
+	_ = registry.HealthCheck(ctx, service.ID(), 0)
+	_ = registry.Values(ctx, "app/").SetValue(ctx, "key", "value")
+	_, _ = registry.Value(ctx, "app/key")
+	_, _ = registry.Value(ctx, "app/missing")
+
+	tests := []struct {
+		operation  string
+		calls      int
+		errorCalls int
+	}{
+		{operation: OpRegister, calls: 1},
+		{operation: OpDiscover, calls: 1},
+		{operation: OpHealthCheck, calls: 1},
+		{operation: OpSetValue, calls: 1},
+		{operation: OpValue, calls: 2, errorCalls: 1},
+	}
+	for _, tt := range tests {
+		if got := recorder.calls[tt.operation]; got != tt.calls {
+			t.Errorf("%s calls = %d, want %d", tt.operation, got, tt.calls)
+		}
+		if got := recorder.errors[tt.operation]; got != tt.errorCalls {
+			t.Errorf("%s errors = %d, want %d", tt.operation, got, tt.errorCalls)
+		}
+	}
+}
+
+func TestRegistry_Subscriptions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	recorder := newTestRecorder()
+	registry := NewRegistry(memory.NewRegistry(), recorder)
+	defer registry.Close()
+
+	failing := cloudregistry.ValueSetterFunc(func(string, any) error { return errors.New("setter error") })
+	if err := registry.SubscribeValue(ctx, "key", failing); err != nil {
+		t.Fatalf("SubscribeValue() error = %v", err)
+	}
+	if got := recorder.snapshot().subscriptions; got != 1 {
+		t.Errorf("subscriptions = %d, want 1", got)
+	}
+	_ = registry.SetValue(context.Background(), "key", "value")
+	if got := recorder.snapshot(); got.notifications != 1 || got.notificationErrors != 1 {
+		t.Errorf("notifications = %d, errors = %d, want 1, 1", got.notifications, got.notificationErrors)
+	}
+
+	cancel()
+	deadline := time.Now().Add(time.Second)
+	for recorder.snapshot().subscriptions != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("subscriptions are not decremented after the context is done")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRegistry_Codec(t *testing.T) {
+	ctx := context.Background()
+	recorder := newTestRecorder()
+	registry := NewRegistry(memory.NewRegistry(), recorder)
+	if codec := cloudregistry.CodecOf(registry); codec != cloudregistry.DefaultCodec {
+		t.Errorf("CodecOf() = %v, want the codec of the wrapped registry", codec)
+	}
+
+	values := cloudregistry.WithCodec(registry.Values(ctx, "app/"), cloudregistry.StringCodec)
+	if codec := cloudregistry.CodecOf(values); codec != cloudregistry.StringCodec {
+		t.Errorf("CodecOf() = %v, want %v", codec, cloudregistry.StringCodec)
+	}
+	var received any
+	_ = values.SubscribeValue(ctx, "port", cloudregistry.ValueSetterFunc(func(key string, value any) error {
+		received = value
+		return nil
+	}))
+	_ = values.SetValue(ctx, "port", "8080")
+	if received != "8080" {
+		t.Errorf("received value = %#v, want string 8080", received)
+	}
+	recorder.mx.Lock()
+	defer recorder.mx.Unlock()
+	if recorder.calls[OpSetValue] != 1 || recorder.calls[OpValue] != 0 {
+		t.Errorf("calls = %v, want the recorded SetValue without the Value reads", recorder.calls)
+	}
+}
+
+func TestExpvar(t *testing.T) {
+	recorder := NewExpvar("cloudregistry_test")
+	recorder.ObserveOperation(OpValue, time.Millisecond, nil)
+	recorder.ObserveOperation(OpValue, time.Millisecond, cloudregistry.ErrNotFound)
+	recorder.ObserveOperation(OpDiscover, 20*time.Millisecond, nil)
+	recorder.ObserveOperation(OpDiscover, time.Minute, nil)
+	recorder.AddSubscriptions(2)
+	recorder.ObserveNotification(errors.New("setter error"))
+
+	root := expvar.Get("cloudregistry_test").(*expvar.Map)
+	if got := root.Get("calls").(*expvar.Map).Get(OpValue).String(); got != "2" {
+		t.Errorf("calls = %s, want 2", got)
+	}
+	if got := root.Get("errors").(*expvar.Map).Get(OpValue).String(); got != "1" {
+		t.Errorf("errors = %s, want 1", got)
+	}
+	if got := root.Get("latency_ns").(*expvar.Map).Get(OpValue).String(); got != "2000000" {
+		t.Errorf("latency_ns = %s, want 2000000", got)
+	}
+	buckets := root.Get("latency_buckets").(*expvar.Map)
+	if got := buckets.Get(OpValue).String(); got != `{"1ms": 2}` {
+		t.Errorf("latency_buckets of %s = %s, want 2 calls in 1ms", OpValue, got)
+	}
+	if got := buckets.Get(OpDiscover).String(); got != `{"50ms": 1, "inf": 1}` {
+		t.Errorf("latency_buckets of %s = %s, want 1 call in 50ms and inf", OpDiscover, got)
+	}
+	if got := root.Get("subscriptions").String(); got != "2" {
+		t.Errorf("subscriptions = %s, want 2", got)
+	}
+	if got := root.Get("notification_errors").String(); got != "1" {
+		t.Errorf("notification_errors = %s, want 1", got)
+	}
+}
+
+func TestRegistry_Conformance(t *testing.T) {
+	registrytest.Run(t, func(t *testing.T) (cloudregistry.Registry, func(time.Duration)) {
+		clock := memory.NewManualClock(time.Now())
+		return NewRegistry(memory.NewRegistry(memory.WithClock(clock)), newTestRecorder()), clock.Advance
+	}, registrytest.WithTimeout(time.Second))
+}
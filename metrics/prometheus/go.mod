@@ -0,0 +1,24 @@
+module github.com/demdxx/cloudregistry/metrics/prometheus
+
+go 1.23.0
+
+toolchain go1.24.4
+
+replace github.com/demdxx/cloudregistry => ../../
+
+require (
+	github.com/demdxx/cloudregistry v0.0.0-00010101000000-000000000000
+	github.com/prometheus/client_golang v1.19.0
+)
+
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/davecgh/go-spew v1.1.2-0.20180830191138-d8f796af33cc // indirect
+	github.com/demdxx/gocast/v2 v2.10.1 // indirect
+	github.com/prometheus/client_model v0.5.0 // indirect
+	github.com/prometheus/common v0.48.0 // indirect
+	github.com/prometheus/procfs v0.12.0 // indirect
+	golang.org/x/sys v0.16.0 // indirect
+	google.golang.org/protobuf v1.32.0 // indirect
+)
@@ -0,0 +1,134 @@
+// Package prometheus implements the metrics.Recorder with the Prometheus client.
+//
+// Example:
+//
+//	recorder := prometheus.NewRecorder()
+//	promclient.MustRegister(recorder)
+//	registry = metrics.NewRegistry(registry, recorder)
+package prometheus
+
+import (
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+
+	"github.com/demdxx/cloudregistry/metrics"
+)
+
+// Option is a configuration option for the Recorder.
+type Option func(conf *config)
+
+type config struct {
+	namespace   string
+	constLabels prom.Labels
+	buckets     []float64
+}
+
+// WithNamespace sets the metrics namespace, "cloudregistry" is used by default.
+func WithNamespace(namespace string) Option {
+	return func(conf *config) {
+		conf.namespace = namespace
+	}
+}
+
+// WithConstLabels sets the labels added to all metrics.
+func WithConstLabels(labels prom.Labels) Option {
+	return func(conf *config) {
+		conf.constLabels = labels
+	}
+}
+
+// WithBuckets sets the buckets of the operation latency histogram in seconds.
+func WithBuckets(buckets []float64) Option {
+	return func(conf *config) {
+		conf.buckets = buckets
+	}
+}
+
+// Recorder records the registry metrics, it is the prometheus.Collector
+// which must be registered in the Prometheus registry.
+type Recorder struct {
+	operations    *prom.CounterVec
+	latency       *prom.HistogramVec
+	subscriptions prom.Gauge
+	notifications *prom.CounterVec
+}
+
+// NewRecorder creates the Prometheus recorder.
+func NewRecorder(options ...Option) *Recorder {
+	conf := &config{namespace: "cloudregistry", buckets: prom.DefBuckets}
+	for _, option := range options {
+		option(conf)
+	}
+	return &Recorder{
+		operations: prom.NewCounterVec(prom.CounterOpts{
+			Namespace:   conf.namespace,
+			Name:        "operations_total",
+			Help:        "The number of the registry operations by the operation and the result.",
+			ConstLabels: conf.constLabels,
+		}, []string{"operation", "result"}),
+		latency: prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace:   conf.namespace,
+			Name:        "operation_duration_seconds",
+			Help:        "The latency of the registry operations.",
+			ConstLabels: conf.constLabels,
+			Buckets:     conf.buckets,
+		}, []string{"operation"}),
+		subscriptions: prom.NewGauge(prom.GaugeOpts{
+			Namespace:   conf.namespace,
+			Name:        "active_subscriptions",
+			Help:        "The number of the active value subscriptions.",
+			ConstLabels: conf.constLabels,
+		}),
+		notifications: prom.NewCounterVec(prom.CounterOpts{
+			Namespace:   conf.namespace,
+			Name:        "notifications_total",
+			Help:        "The number of the value notifications delivered to the setters by the result.",
+			ConstLabels: conf.constLabels,
+		}, []string{"result"}),
+	}
+}
+
+// ObserveOperation counts the operation by the result and observes its latency.
+func (r *Recorder) ObserveOperation(operation string, duration time.Duration, err error) {
+	r.operations.WithLabelValues(operation, result(err)).Inc()
+	r.latency.WithLabelValues(operation).Observe(duration.Seconds())
+}
+
+// AddSubscriptions changes the number of the active subscriptions.
+func (r *Recorder) AddSubscriptions(delta int) {
+	r.subscriptions.Add(float64(delta))
+}
+
+// ObserveNotification counts the delivered notification by the result.
+func (r *Recorder) ObserveNotification(err error) {
+	r.notifications.WithLabelValues(result(err)).Inc()
+}
+
+// Describe sends the descriptors of the metrics.
+func (r *Recorder) Describe(ch chan<- *prom.Desc) {
+	r.operations.Describe(ch)
+	r.latency.Describe(ch)
+	r.subscriptions.Describe(ch)
+	r.notifications.Describe(ch)
+}
+
+// Collect sends the current values of the metrics.
+func (r *Recorder) Collect(ch chan<- prom.Metric) {
+	r.operations.Collect(ch)
+	r.latency.Collect(ch)
+	r.subscriptions.Collect(ch)
+	r.notifications.Collect(ch)
+}
+
+func result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
+
+var (
+	_ metrics.Recorder = (*Recorder)(nil)
+	_ prom.Collector   = (*Recorder)(nil)
+)
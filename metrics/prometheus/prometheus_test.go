@@ -0,0 +1,48 @@
+package prometheus
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/demdxx/cloudregistry/metrics"
+)
+
+func TestRecorder(t *testing.T) {
+	recorder := NewRecorder(WithNamespace("test"))
+	registry := prom.NewPedanticRegistry()
+	if err := registry.Register(recorder); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	recorder.ObserveOperation(metrics.OpValue, time.Millisecond, nil)
+	recorder.ObserveOperation(metrics.OpValue, time.Millisecond, errors.New("failed"))
+	recorder.AddSubscriptions(2)
+	recorder.AddSubscriptions(-1)
+	recorder.ObserveNotification(nil)
+
+	expected := `
+# HELP test_operations_total The number of the registry operations by the operation and the result.
+# TYPE test_operations_total counter
+test_operations_total{operation="value",result="error"} 1
+test_operations_total{operation="value",result="success"} 1
+# HELP test_active_subscriptions The number of the active value subscriptions.
+# TYPE test_active_subscriptions gauge
+test_active_subscriptions 1
+# HELP test_notifications_total The number of the value notifications delivered to the setters by the result.
+# TYPE test_notifications_total counter
+test_notifications_total{result="success"} 1
+`
+	err := testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"test_operations_total", "test_active_subscriptions", "test_notifications_total")
+	if err != nil {
+		t.Error(err)
+	}
+	if count := testutil.CollectAndCount(recorder, "test_operation_duration_seconds"); count != 1 {
+		t.Errorf("latency series = %d, want 1", count)
+	}
+}
@@ -0,0 +1,433 @@
+// Package multi implements the composite registry over several cloud registries,
+// it is intended for the migrations between the registry backends.
+//
+// The first registry is the primary one. The writes are sent to all registries,
+// the services are discovered from all of them and the values are read from the primary
+// with the fallback to the secondary registries.
+//
+// The registry implements ServiceWatcher and ServiceLister over the registries which support them,
+// the ValueStore and CodecClient operations of the values follow the same write and read policies.
+// Locker and Elector are not implemented: a lock held in one backend does not exclude
+// the holders in another one, so the locks and elections have to use a single backend directly.
+//
+// Example:
+//
+//	registry, err := multi.New([]cloudregistry.Registry{consulRegistry, zkRegistry},
+//		multi.WithWritePolicy(multi.WriteAny),
+//		multi.WithErrorHandler(func(err error) { log.Println(err) }))
+//	if err != nil {
+//		return err
+//	}
+//	defer registry.Close()
+package multi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+// ErrNoRegistries is returned when the composite registry is created without registries.
+var ErrNoRegistries = errors.New("no registries")
+
+// WritePolicy defines the result of the operations sent to all registries:
+// Register, Deregister, HealthCheck, ReportHealth and SetValue.
+type WritePolicy int
+
+const (
+	// WriteAll fails if any registry fails, the error joins the errors of all failed registries.
+	WriteAll WritePolicy = iota
+	// WriteAny succeeds if at least one registry succeeds, the partial failures are passed to the error handler.
+	WriteAny
+)
+
+// DiscoverPolicy defines how the services are discovered.
+type DiscoverPolicy int
+
+const (
+	// DiscoverMerge merges the instances of all registries de-duplicated by InstanceID,
+	// the instances of the preceding registries win. The failures are ignored if any registry succeeds.
+	DiscoverMerge DiscoverPolicy = iota
+	// DiscoverFailover returns the instances of the first registry which succeeds.
+	DiscoverFailover
+)
+
+// ReadPolicy defines how the values are read and subscribed.
+type ReadPolicy int
+
+const (
+	// ReadFailover reads from the primary registry and falls back to the secondary ones
+	// in order if the value is not found or the registry fails.
+	ReadFailover ReadPolicy = iota
+	// ReadPrimary reads only from the primary registry.
+	ReadPrimary
+)
+
+// Option is a configuration option for the composite registry.
+type Option func(c *config)
+
+// WithWritePolicy sets the write policy, WriteAll is used by default.
+func WithWritePolicy(policy WritePolicy) Option {
+	return func(c *config) {
+		c.write = policy
+	}
+}
+
+// WithDiscoverPolicy sets the discover policy, DiscoverMerge is used by default.
+func WithDiscoverPolicy(policy DiscoverPolicy) Option {
+	return func(c *config) {
+		c.discover = policy
+	}
+}
+
+// WithReadPolicy sets the read policy, ReadFailover is used by default.
+func WithReadPolicy(policy ReadPolicy) Option {
+	return func(c *config) {
+		c.read = policy
+	}
+}
+
+// WithErrorHandler sets the handler of the partial failures which are not returned to the caller.
+func WithErrorHandler(handler func(err error)) Option {
+	return func(c *config) {
+		c.errorHandler = handler
+	}
+}
+
+type config struct {
+	write        WritePolicy
+	discover     DiscoverPolicy
+	read         ReadPolicy
+	errorHandler func(err error)
+}
+
+// writeResult joins the errors of the registries according to the write policy.
+func (c *config) writeResult(errs []error) error {
+	err := errors.Join(errs...)
+	if err != nil && c.write == WriteAny && slices.Contains(errs, nil) {
+		c.handleError(err)
+		return nil
+	}
+	return err
+}
+
+func (c *config) handleError(err error) {
+	if c.errorHandler != nil {
+		c.errorHandler(err)
+	}
+}
+
+// Registry is the composite registry sending the operations to several registries.
+type Registry struct {
+	valueClient
+	registries []cloudregistry.Registry
+}
+
+// New creates the composite registry, the first registry is the primary one.
+func New(registries []cloudregistry.Registry, options ...Option) (*Registry, error) {
+	if len(registries) == 0 {
+		return nil, ErrNoRegistries
+	}
+	conf := &config{}
+	for _, option := range options {
+		option(conf)
+	}
+	clients := make([]cloudregistry.ValueClient, 0, len(registries))
+	for _, registry := range registries {
+		clients = append(clients, registry)
+	}
+	return &Registry{
+		valueClient: valueClient{conf: conf, clients: clients},
+		registries:  slices.Clone(registries),
+	}, nil
+}
+
+// Register registers the service in all registries.
+func (r *Registry) Register(ctx context.Context, service *cloudregistry.Service) error {
+	return r.conf.writeResult(fanOut(len(r.registries), func(i int) error {
+		return r.registries[i].Register(ctx, service)
+	}))
+}
+
+// Deregister deregisters the service in all registries.
+func (r *Registry) Deregister(ctx context.Context, id *cloudregistry.ServiceID) error {
+	return r.conf.writeResult(fanOut(len(r.registries), func(i int) error {
+		return r.registries[i].Deregister(ctx, id)
+	}))
+}
+
+// Discover discovers the services according to the discover policy.
+func (r *Registry) Discover(ctx context.Context, prefix *cloudregistry.ServicePrefix, TTL time.Duration) ([]*cloudregistry.ServiceInfo, error) {
+	return r.DiscoverWithQuery(ctx, prefix, TTL, nil)
+}
+
+// DiscoverWithQuery discovers the services matching the query according to the discover policy,
+// the merged result is truncated to the query limit. The registries without the service do not fail
+// the merge, ErrNotFound is returned if none of them has it.
+func (r *Registry) DiscoverWithQuery(ctx context.Context, prefix *cloudregistry.ServicePrefix, TTL time.Duration, query *cloudregistry.DiscoverQuery) ([]*cloudregistry.ServiceInfo, error) {
+	if r.conf.discover == DiscoverFailover {
+		return failover(len(r.registries), func(i int) ([]*cloudregistry.ServiceInfo, error) {
+			return cloudregistry.DiscoverWithQuery(ctx, r.registries[i], prefix, TTL, query)
+		})
+	}
+
+	results := make([][]*cloudregistry.ServiceInfo, len(r.registries))
+	errs := fanOut(len(r.registries), func(i int) (err error) {
+		results[i], err = cloudregistry.DiscoverWithQuery(ctx, r.registries[i], prefix, TTL, query)
+		if errors.Is(err, cloudregistry.ErrNotFound) {
+			return nil
+		}
+		return err
+	})
+	if err := errors.Join(errs...); err != nil {
+		if !slices.Contains(errs, nil) {
+			return nil, err
+		}
+		r.conf.handleError(err)
+	}
+
+	services := mergeServices(results)
+	if len(services) == 0 {
+		return nil, cloudregistry.ErrNotFound
+	}
+	if query != nil && query.Limit > 0 && len(services) > query.Limit {
+		services = services[:query.Limit]
+	}
+	return services, nil
+}
+
+// ListServices lists the services according to the discover policy, the merged list contains
+// the services of all registries implementing ServiceLister.
+func (r *Registry) ListServices(ctx context.Context, namespace string) ([]*cloudregistry.ServicePrefix, error) {
+	if r.conf.discover == DiscoverFailover {
+		return failover(len(r.registries), func(i int) ([]*cloudregistry.ServicePrefix, error) {
+			return cloudregistry.ListServices(ctx, r.registries[i], namespace)
+		})
+	}
+
+	results := make([][]*cloudregistry.ServicePrefix, len(r.registries))
+	errs := fanOut(len(r.registries), func(i int) (err error) {
+		results[i], err = cloudregistry.ListServices(ctx, r.registries[i], namespace)
+		return err
+	})
+	var (
+		services []*cloudregistry.ServiceInfo
+		failed   []error
+		listed   bool
+	)
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			listed = true
+			for _, prefix := range results[i] {
+				services = append(services, &cloudregistry.ServiceInfo{Name: prefix.Name, Namespace: prefix.Namespace})
+			}
+		case !errors.Is(err, cloudregistry.ErrServiceListNotSupported):
+			failed = append(failed, err)
+		}
+	}
+	if err := errors.Join(failed...); err != nil {
+		if !listed {
+			return nil, err
+		}
+		r.conf.handleError(err)
+	}
+	if !listed {
+		return nil, cloudregistry.ErrServiceListNotSupported
+	}
+	return cloudregistry.ServicePrefixes(services, namespace), nil
+}
+
+// mergeServices merges the instances de-duplicated by InstanceID, the preceding registries win.
+func mergeServices(states [][]*cloudregistry.ServiceInfo) []*cloudregistry.ServiceInfo {
+	var (
+		services []*cloudregistry.ServiceInfo
+		seen     = map[string]bool{}
+	)
+	for _, state := range states {
+		for _, service := range state {
+			if !seen[service.InstanceID] {
+				seen[service.InstanceID] = true
+				services = append(services, service)
+			}
+		}
+	}
+	return services
+}
+
+// HealthCheck checks the service in all registries.
+func (r *Registry) HealthCheck(ctx context.Context, id *cloudregistry.ServiceID, TTL time.Duration) error {
+	return r.conf.writeResult(fanOut(len(r.registries), func(i int) error {
+		return r.registries[i].HealthCheck(ctx, id, TTL)
+	}))
+}
+
+// ReportHealth reports the health status to all registries.
+func (r *Registry) ReportHealth(ctx context.Context, id *cloudregistry.ServiceID, status cloudregistry.HealthStatus, output string) error {
+	return r.conf.writeResult(fanOut(len(r.registries), func(i int) error {
+		return cloudregistry.ReportHealth(ctx, r.registries[i], id, status, output)
+	}))
+}
+
+// Close closes all registries.
+func (r *Registry) Close() error {
+	return errors.Join(fanOut(len(r.registries), func(i int) error {
+		return r.registries[i].Close()
+	})...)
+}
+
+type valueClient struct {
+	conf    *config
+	clients []cloudregistry.ValueClient
+}
+
+// Values returns the composite ValueClient with the prefix.
+func (c *valueClient) Values(ctx context.Context, prefix ...string) cloudregistry.ValueClient {
+	clients := make([]cloudregistry.ValueClient, 0, len(c.clients))
+	for _, client := range c.clients {
+		clients = append(clients, client.Values(ctx, prefix...))
+	}
+	return &valueClient{conf: c.conf, clients: clients}
+}
+
+// Value returns the value according to the read policy.
+func (c *valueClient) Value(ctx context.Context, name string) (string, error) {
+	return failover(c.readCount(), func(i int) (string, error) {
+		return c.clients[i].Value(ctx, name)
+	})
+}
+
+// SetValue sets the value in all registries.
+func (c *valueClient) SetValue(ctx context.Context, name, value string) error {
+	return c.conf.writeResult(fanOut(len(c.clients), func(i int) error {
+		return c.clients[i].SetValue(ctx, name, value)
+	}))
+}
+
+// SubscribeValue subscribes to the value in the first registry which accepts the subscription.
+func (c *valueClient) SubscribeValue(ctx context.Context, name string, val cloudregistry.ValueSetter) error {
+	_, err := failover(c.readCount(), func(i int) (struct{}, error) {
+		return struct{}{}, c.clients[i].SubscribeValue(ctx, name, val)
+	})
+	return err
+}
+
+// SubscribeValueWithPrefix subscribes to the values with the prefix in the first registry which accepts the subscription.
+func (c *valueClient) SubscribeValueWithPrefix(ctx context.Context, prefix string, val cloudregistry.ValueSetter) error {
+	_, err := failover(c.readCount(), func(i int) (struct{}, error) {
+		return struct{}{}, c.clients[i].SubscribeValueWithPrefix(ctx, prefix, val)
+	})
+	return err
+}
+
+// DeleteValue deletes the value in all registries.
+func (c *valueClient) DeleteValue(ctx context.Context, name string) error {
+	return c.conf.writeResult(fanOut(len(c.clients), func(i int) error {
+		return cloudregistry.DeleteValue(ctx, c.clients[i], name)
+	}))
+}
+
+// DeletePrefix deletes the values with the prefix in all registries.
+func (c *valueClient) DeletePrefix(ctx context.Context, prefix string) error {
+	return c.conf.writeResult(fanOut(len(c.clients), func(i int) error {
+		return cloudregistry.DeletePrefix(ctx, c.clients[i], prefix)
+	}))
+}
+
+// ListValues lists the values of the first registry which succeeds according to the read policy.
+func (c *valueClient) ListValues(ctx context.Context, prefix string) ([]*cloudregistry.KeyValue, error) {
+	return failover(c.readCount(), func(i int) ([]*cloudregistry.KeyValue, error) {
+		return cloudregistry.ListValues(ctx, c.clients[i], prefix)
+	})
+}
+
+// CompareAndSwap swaps the value in the primary registry, which owns the versions,
+// the swapped value is then set in the secondary registries according to the write policy.
+func (c *valueClient) CompareAndSwap(ctx context.Context, name string, version uint64, value string) (bool, error) {
+	swapped, err := cloudregistry.CompareAndSwap(ctx, c.clients[0], name, version, value)
+	if err != nil || !swapped {
+		return swapped, err
+	}
+	errs := fanOut(len(c.clients), func(i int) error {
+		if i == 0 {
+			return nil
+		}
+		return c.clients[i].SetValue(ctx, name, value)
+	})
+	return true, c.conf.writeResult(errs)
+}
+
+// Codec returns the codec of the primary registry.
+func (c *valueClient) Codec() cloudregistry.Codec {
+	return cloudregistry.CodecOf(c.clients[0])
+}
+
+// WithCodec returns the composite ValueClient using the codec for all registries.
+func (c *valueClient) WithCodec(codec cloudregistry.Codec) cloudregistry.ValueClient {
+	clients := make([]cloudregistry.ValueClient, 0, len(c.clients))
+	for _, client := range c.clients {
+		clients = append(clients, cloudregistry.WithCodec(client, codec))
+	}
+	return &valueClient{conf: c.conf, clients: clients}
+}
+
+// readCount returns the number of the registries used for reading.
+func (c *valueClient) readCount() int {
+	if c.conf.read == ReadPrimary {
+		return 1
+	}
+	return len(c.clients)
+}
+
+// fanOut runs the operation for every registry concurrently and returns the errors by registry index.
+func fanOut(n int, op func(i int) error) []error {
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, n)
+	)
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := op(i); err != nil {
+				errs[i] = registryError(i, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+// failover runs the operation for the registries in order until the first success.
+func failover[T any](n int, op func(i int) (T, error)) (T, error) {
+	var errs []error
+	for i := range n {
+		result, err := op(i)
+		if err == nil {
+			return result, nil
+		}
+		errs = append(errs, registryError(i, err))
+	}
+	var zero T
+	return zero, errors.Join(errs...)
+}
+
+func registryError(i int, err error) error {
+	return fmt.Errorf("registry #%d: %w", i, err)
+}
+
+var (
+	_ cloudregistry.Registry        = (*Registry)(nil)
+	_ cloudregistry.QueryDiscoverer = (*Registry)(nil)
+	_ cloudregistry.HealthReporter  = (*Registry)(nil)
+	_ cloudregistry.ServiceWatcher  = (*Registry)(nil)
+	_ cloudregistry.ServiceLister   = (*Registry)(nil)
+	_ cloudregistry.ValueClient     = (*valueClient)(nil)
+	_ cloudregistry.ValueStore      = (*valueClient)(nil)
+	_ cloudregistry.CodecClient     = (*valueClient)(nil)
+)
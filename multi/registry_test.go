@@ -0,0 +1,267 @@
+package multi
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/demdxx/cloudregistry"
+	"github.com/demdxx/cloudregistry/memory"
+	"github.com/demdxx/cloudregistry/registrytest"
+)
+
+func testService(id string) *cloudregistry.Service {
+	return &cloudregistry.Service{Name: "test-service", InstanceID: id, Hostname: "localhost", Port: 8080}
+}
+
+func instanceIDs(services []*cloudregistry.ServiceInfo) []string {
+	var ids []string
+	for _, service := range services {
+		ids = append(ids, service.InstanceID)
+	}
+	return ids
+}
+
+func TestNew_NoRegistries(t *testing.T) {
+	if _, err := New(nil); !errors.Is(err, ErrNoRegistries) {
+		t.Errorf("New() error = %v, want %v", err, ErrNoRegistries)
+	}
+}
+
+func TestRegistry_RegisterDiscover(t *testing.T) {
+	ctx := context.Background()
+	primary, secondary := memory.NewRegistry(), memory.NewRegistry()
+	registry, err := New([]cloudregistry.Registry{primary, secondary})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := registry.Register(ctx, testService("both")); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	_ = secondary.Register(ctx, testService("secondary"))
+
+	prefix := &cloudregistry.ServicePrefix{Name: "test-service"}
+	for _, r := range []cloudregistry.Registry{primary, secondary} {
+		if services, _ := r.Discover(ctx, prefix, 0); !slices.Contains(instanceIDs(services), "both") {
+			t.Errorf("the service is not registered in every registry: %v", instanceIDs(services))
+		}
+	}
+
+	services, err := registry.Discover(ctx, prefix, 0)
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	if ids := instanceIDs(services); !slices.Equal(ids, []string{"both", "secondary"}) {
+		t.Errorf("Discover() = %v, want merged instances", ids)
+	}
+
+	failover, _ := New([]cloudregistry.Registry{primary, secondary}, WithDiscoverPolicy(DiscoverFailover))
+	if services, _ := failover.Discover(ctx, prefix, 0); !slices.Equal(instanceIDs(services), []string{"both"}) {
+		t.Errorf("Discover() with failover = %v, want primary instances", instanceIDs(services))
+	}
+
+	if err := registry.Deregister(ctx, testService("both").ID()); err != nil {
+		t.Fatalf("Deregister() error = %v", err)
+	}
+	if services, _ := registry.Discover(ctx, prefix, 0); !slices.Equal(instanceIDs(services), []string{"secondary"}) {
+		t.Errorf("Discover() after Deregister() = %v", instanceIDs(services))
+	}
+}
+
+func TestRegistry_DiscoverNotFound(t *testing.T) {
+	ctx := context.Background()
+	primary, secondary := memory.NewRegistry(), memory.NewRegistry()
+	var handled []error
+	registry, _ := New([]cloudregistry.Registry{primary, secondary},
+		WithErrorHandler(func(err error) { handled = append(handled, err) }))
+	prefix := &cloudregistry.ServicePrefix{Name: "test-service"}
+
+	if services, err := registry.Discover(ctx, prefix, 0); !errors.Is(err, cloudregistry.ErrNotFound) {
+		t.Errorf("Discover() of missing service = %v, %v, want %v", instanceIDs(services), err, cloudregistry.ErrNotFound)
+	}
+	_ = secondary.Register(ctx, testService("secondary"))
+	if services, err := registry.Discover(ctx, prefix, 0); err != nil || !slices.Equal(instanceIDs(services), []string{"secondary"}) {
+		t.Errorf("Discover() = %v, %v, want secondary instances", instanceIDs(services), err)
+	}
+	if len(handled) != 0 {
+		t.Errorf("ErrNotFound should not be handled as a failure: %v", handled)
+	}
+}
+
+func TestRegistry_WritePolicy(t *testing.T) {
+	ctx := context.Background()
+	closed := memory.NewRegistry()
+	_ = closed.Close()
+
+	tests := []struct {
+		name    string
+		policy  WritePolicy
+		wantErr bool
+	}{
+		{name: "all", policy: WriteAll, wantErr: true},
+		{name: "any", policy: WriteAny, wantErr: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var handled []error
+			registry, _ := New([]cloudregistry.Registry{memory.NewRegistry(), closed},
+				WithWritePolicy(tt.policy),
+				WithErrorHandler(func(err error) { handled = append(handled, err) }))
+
+			err := registry.SetValue(ctx, "key", "value")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SetValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && len(handled) != 1 {
+				t.Errorf("error handler calls = %d, want 1", len(handled))
+			}
+			if got, err := registry.Value(ctx, "key"); err != nil || got != "value" {
+				t.Errorf("Value() = %q, %v, want %q", got, err, "value")
+			}
+		})
+	}
+
+	registry, _ := New([]cloudregistry.Registry{closed, closed}, WithWritePolicy(WriteAny))
+	if err := registry.Register(ctx, testService("x")); err == nil {
+		t.Error("Register() with all registries failed expected error")
+	}
+}
+
+func TestRegistry_ReadPolicy(t *testing.T) {
+	ctx := context.Background()
+	primary, secondary := memory.NewRegistry(), memory.NewRegistry()
+	_ = secondary.SetValue(ctx, "app/key", "secondary")
+
+	registry, _ := New([]cloudregistry.Registry{primary, secondary})
+	values := registry.Values(ctx, "app/")
+	if got, err := values.Value(ctx, "key"); err != nil || got != "secondary" {
+		t.Errorf("Value() = %q, %v, want fallback value", got, err)
+	}
+	_ = primary.SetValue(ctx, "app/key", "primary")
+	if got, _ := values.Value(ctx, "key"); got != "primary" {
+		t.Errorf("Value() = %q, want primary value", got)
+	}
+
+	registry, _ = New([]cloudregistry.Registry{primary, secondary}, WithReadPolicy(ReadPrimary))
+	if _, err := registry.Value(ctx, "app/missing"); !errors.Is(err, cloudregistry.ErrNotFound) {
+		t.Errorf("Value() error = %v, want %v", err, cloudregistry.ErrNotFound)
+	}
+	_ = secondary.SetValue(ctx, "app/other", "secondary")
+	if _, err := registry.Value(ctx, "app/other"); !errors.Is(err, cloudregistry.ErrNotFound) {
+		t.Errorf("Value() with primary read policy error = %v, want %v", err, cloudregistry.ErrNotFound)
+	}
+}
+
+func TestRegistry_WatchServices(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	primary, secondary := memory.NewRegistry(), memory.NewRegistry()
+	_ = primary.Register(ctx, testService("1"))
+	_ = secondary.Register(ctx, testService("2"))
+	// The primary instance hides the secondary one until it is deregistered.
+	shadow := testService("1")
+	shadow.Port = 9090
+	_ = secondary.Register(ctx, shadow)
+
+	registry, _ := New([]cloudregistry.Registry{primary, secondary})
+	events, err := registry.WatchServices(ctx, &cloudregistry.ServicePrefix{Name: "test-service"})
+	if err != nil {
+		t.Fatalf("WatchServices() error = %v", err)
+	}
+	next := func() cloudregistry.ServiceEvent {
+		t.Helper()
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(time.Second):
+			t.Fatal("no service event")
+		}
+		return cloudregistry.ServiceEvent{}
+	}
+
+	if event := next(); event.Type != cloudregistry.ServiceSnapshot || !slices.Equal(instanceIDs(event.Services), []string{"1", "2"}) {
+		t.Fatalf("first event = %v %v, want the merged snapshot", event.Type, instanceIDs(event.Services))
+	}
+	_ = secondary.Register(ctx, testService("3"))
+	if event := next(); event.Type != cloudregistry.ServiceAdded || event.Service.InstanceID != "3" {
+		t.Errorf("event = %v %v, want added 3", event.Type, event.Service)
+	}
+
+	_ = primary.Deregister(ctx, &cloudregistry.ServiceID{Name: "test-service", InstanceID: "1"})
+	if event := next(); event.Type != cloudregistry.ServiceUpdated || event.Service.Port != 9090 {
+		t.Errorf("event = %v %v, want updated 1 from the secondary registry", event.Type, event.Service)
+	}
+
+	cancel()
+	for range events {
+	}
+
+	registry, _ = New([]cloudregistry.Registry{primary, secondary}, WithDiscoverPolicy(DiscoverFailover))
+	_ = primary.Close()
+	if _, err := registry.WatchServices(context.Background(), &cloudregistry.ServicePrefix{Name: "test-service"}); err != nil {
+		t.Errorf("WatchServices() with failover error = %v", err)
+	}
+}
+
+func TestRegistry_ValueStore(t *testing.T) {
+	ctx := context.Background()
+	primary, secondary := memory.NewRegistry(), memory.NewRegistry()
+	registry, _ := New([]cloudregistry.Registry{primary, secondary})
+	values := registry.Values(ctx, "app/")
+
+	if swapped, err := cloudregistry.CompareAndSwap(ctx, values, "key", 0, "v1"); err != nil || !swapped {
+		t.Fatalf("CompareAndSwap() = %t, %v, want swapped", swapped, err)
+	}
+	if got, _ := secondary.Value(ctx, "app/key"); got != "v1" {
+		t.Errorf("secondary value = %q, want the swapped value", got)
+	}
+	if swapped, _ := cloudregistry.CompareAndSwap(ctx, values, "key", 0, "v2"); swapped {
+		t.Error("CompareAndSwap() with the stale version swapped the value")
+	}
+
+	kvs, err := cloudregistry.ListValues(ctx, values, "")
+	if err != nil || len(kvs) != 1 || kvs[0].Key != "key" || kvs[0].Value != "v1" {
+		t.Errorf("ListValues() = %v, %v", kvs, err)
+	}
+	if err := cloudregistry.DeleteValue(ctx, values, "key"); err != nil {
+		t.Fatalf("DeleteValue() error = %v", err)
+	}
+	for i, client := range []cloudregistry.Registry{primary, secondary} {
+		if _, err := client.Value(ctx, "app/key"); !errors.Is(err, cloudregistry.ErrNotFound) {
+			t.Errorf("registry #%d Value() error = %v, want %v", i, err, cloudregistry.ErrNotFound)
+		}
+	}
+}
+
+func TestRegistry_Codec(t *testing.T) {
+	registry, _ := New([]cloudregistry.Registry{memory.NewRegistry(), memory.NewRegistry()})
+	if codec := cloudregistry.CodecOf(registry); codec != cloudregistry.DefaultCodec {
+		t.Errorf("CodecOf() = %v, want the codec of the primary registry", codec)
+	}
+	values := cloudregistry.WithCodec(registry, cloudregistry.StringCodec)
+	client, ok := values.(*valueClient)
+	if !ok || len(client.clients) != 2 {
+		t.Fatalf("WithCodec() = %T, want the composite client", values)
+	}
+	for i, client := range client.clients {
+		if codec := cloudregistry.CodecOf(client); codec != cloudregistry.StringCodec {
+			t.Errorf("registry #%d CodecOf() = %v, want %v", i, codec, cloudregistry.StringCodec)
+		}
+	}
+}
+
+func TestRegistry_Conformance(t *testing.T) {
+	registrytest.Run(t, func(t *testing.T) (cloudregistry.Registry, func(time.Duration)) {
+		clock := memory.NewManualClock(time.Now())
+		registry, err := New([]cloudregistry.Registry{
+			memory.NewRegistry(memory.WithClock(clock)),
+			memory.NewRegistry(memory.WithClock(clock)),
+		})
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		return registry, clock.Advance
+	}, registrytest.WithTimeout(time.Second))
+}
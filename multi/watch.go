@@ -0,0 +1,126 @@
+package multi
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+// WatchServices watches the services of the registries implementing ServiceWatcher according
+// to the discover policy. DiscoverFailover watches the first registry which accepts the watch,
+// DiscoverMerge merges the instances of all watched registries like DiscoverWithQuery does.
+// The merged snapshot is sent when every watched registry sent its one, and the channel
+// is closed when any of the watches ends.
+func (r *Registry) WatchServices(ctx context.Context, prefix *cloudregistry.ServicePrefix) (<-chan cloudregistry.ServiceEvent, error) {
+	if r.conf.discover == DiscoverFailover {
+		return failover(len(r.registries), func(i int) (<-chan cloudregistry.ServiceEvent, error) {
+			return cloudregistry.WatchServices(ctx, r.registries[i], prefix)
+		})
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	var (
+		sources []<-chan cloudregistry.ServiceEvent
+		errs    []error
+	)
+	for i, registry := range r.registries {
+		events, err := cloudregistry.WatchServices(ctx, registry, prefix)
+		if err != nil {
+			errs = append(errs, registryError(i, err))
+			continue
+		}
+		sources = append(sources, events)
+	}
+	if len(sources) == 0 {
+		cancel()
+		return nil, errors.Join(errs...)
+	}
+	errs = slices.DeleteFunc(errs, func(err error) bool {
+		return errors.Is(err, cloudregistry.ErrWatchNotSupported)
+	})
+	if err := errors.Join(errs...); err != nil {
+		r.conf.handleError(err)
+	}
+
+	type sourceEvent struct {
+		index int
+		event cloudregistry.ServiceEvent
+		ok    bool
+	}
+	var (
+		input  = make(chan sourceEvent)
+		events = make(chan cloudregistry.ServiceEvent)
+	)
+	for i, source := range sources {
+		go func() {
+			for {
+				event, ok := <-source
+				select {
+				case <-ctx.Done():
+					return
+				case input <- sourceEvent{index: i, event: event, ok: ok}:
+				}
+				if !ok {
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		defer close(events)
+		defer cancel()
+		var (
+			states = make([][]*cloudregistry.ServiceInfo, len(sources))
+			synced = make([]bool, len(sources))
+			merged []*cloudregistry.ServiceInfo
+			ready  bool
+		)
+		for {
+			var in sourceEvent
+			select {
+			case <-ctx.Done():
+				return
+			case in = <-input:
+			}
+			if !in.ok {
+				return
+			}
+			states[in.index] = applyServiceEvent(states[in.index], in.event)
+			synced[in.index] = true
+
+			var out []cloudregistry.ServiceEvent
+			switch next := mergeServices(states); {
+			case ready:
+				out = cloudregistry.DiffServices(merged, next)
+				merged = next
+			case !slices.Contains(synced, false):
+				out = []cloudregistry.ServiceEvent{{Type: cloudregistry.ServiceSnapshot, Services: next}}
+				merged, ready = next, true
+			}
+			for _, event := range out {
+				select {
+				case <-ctx.Done():
+					return
+				case events <- event:
+				}
+			}
+		}
+	}()
+	return events, nil
+}
+
+// applyServiceEvent returns the instances of one registry after the event.
+func applyServiceEvent(services []*cloudregistry.ServiceInfo, event cloudregistry.ServiceEvent) []*cloudregistry.ServiceInfo {
+	if event.Type == cloudregistry.ServiceSnapshot {
+		return slices.Clone(event.Services)
+	}
+	services = slices.DeleteFunc(services, func(service *cloudregistry.ServiceInfo) bool {
+		return service.InstanceID == event.Service.InstanceID
+	})
+	if event.Type != cloudregistry.ServiceRemoved {
+		services = append(services, event.Service)
+	}
+	return services
+}
@@ -0,0 +1,47 @@
+package cloudregistry
+
+import "context"
+
+// Passthrough passes the optional ServiceWatcher, ServiceLister, Locker and Elector interfaces and Close
+// to the wrapped registry, the calls return the "not supported" errors if it does not implement them.
+// The registry decorators embed it for the capabilities they do not decorate.
+type Passthrough struct {
+	registry Registry
+}
+
+// NewPassthrough returns the Passthrough of the registry.
+func NewPassthrough(registry Registry) Passthrough {
+	return Passthrough{registry: registry}
+}
+
+// WatchServices watches the services if the wrapped registry implements ServiceWatcher.
+func (p Passthrough) WatchServices(ctx context.Context, prefix *ServicePrefix) (<-chan ServiceEvent, error) {
+	return WatchServices(ctx, p.registry, prefix)
+}
+
+// ListServices lists the services if the wrapped registry implements ServiceLister.
+func (p Passthrough) ListServices(ctx context.Context, namespace string) ([]*ServicePrefix, error) {
+	return ListServices(ctx, p.registry, namespace)
+}
+
+// NewMutex creates the mutex if the wrapped registry implements Locker.
+func (p Passthrough) NewMutex(ctx context.Context, name string) (Mutex, error) {
+	return NewMutex(ctx, p.registry, name)
+}
+
+// NewElection creates the election candidate if the wrapped registry implements Elector.
+func (p Passthrough) NewElection(ctx context.Context, prefix *ServicePrefix) (Election, error) {
+	return NewElection(ctx, p.registry, prefix)
+}
+
+// Close closes the wrapped registry.
+func (p Passthrough) Close() error {
+	return p.registry.Close()
+}
+
+var (
+	_ ServiceWatcher = Passthrough{}
+	_ ServiceLister  = Passthrough{}
+	_ Locker         = Passthrough{}
+	_ Elector        = Passthrough{}
+)
@@ -0,0 +1,185 @@
+package cloudregistry
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DiscoverQuery restricts the discovered service instances.
+// The nil query matches all instances.
+type DiscoverQuery struct {
+	// Tags are required on every matched instance.
+	Tags []string
+	// Meta values must be equal to the instance metadata values.
+	Meta map[string]string
+	// MetaKeys must exist in the instance metadata with any value.
+	MetaKeys []string
+	// PassingOnly skips the instances with the warning and critical health status.
+	PassingOnly bool
+	// Limit is the maximal number of returned instances, zero means no limit.
+	Limit int
+}
+
+// DiscoverOption configures the DiscoverQuery.
+type DiscoverOption func(query *DiscoverQuery)
+
+// WithTags requires the instances to have all the tags.
+func WithTags(tags ...string) DiscoverOption {
+	return func(query *DiscoverQuery) {
+		query.Tags = append(query.Tags, tags...)
+	}
+}
+
+// WithMeta requires the instance metadata value of the key to be equal to the value.
+func WithMeta(key, value string) DiscoverOption {
+	return func(query *DiscoverQuery) {
+		if query.Meta == nil {
+			query.Meta = map[string]string{}
+		}
+		query.Meta[key] = value
+	}
+}
+
+// WithMetaKeys requires the instance metadata to contain the keys.
+func WithMetaKeys(keys ...string) DiscoverOption {
+	return func(query *DiscoverQuery) {
+		query.MetaKeys = append(query.MetaKeys, keys...)
+	}
+}
+
+// WithPassingOnly skips the instances with the warning and critical health status.
+func WithPassingOnly() DiscoverOption {
+	return func(query *DiscoverQuery) {
+		query.PassingOnly = true
+	}
+}
+
+// WithLimit limits the number of returned instances.
+func WithLimit(limit int) DiscoverOption {
+	return func(query *DiscoverQuery) {
+		query.Limit = limit
+	}
+}
+
+// NewDiscoverQuery creates the query from the options.
+func NewDiscoverQuery(options ...DiscoverOption) *DiscoverQuery {
+	query := &DiscoverQuery{}
+	for _, option := range options {
+		option(query)
+	}
+	return query
+}
+
+// Match reports whether the instance has the required tags, metadata and health status.
+// The unknown health status passes as the registry does not know the instance is failing.
+func (query *DiscoverQuery) Match(svc *ServiceInfo) bool {
+	if query == nil {
+		return true
+	}
+	if query.PassingOnly && svc.Health.Status != HealthPassing && svc.Health.Status != HealthUnknown {
+		return false
+	}
+	for _, tag := range query.Tags {
+		if !slices.Contains(svc.Tags, tag) {
+			return false
+		}
+	}
+	for key, value := range query.Meta {
+		if v, ok := svc.Meta[key]; !ok || v != value {
+			return false
+		}
+	}
+	for _, key := range query.MetaKeys {
+		if _, ok := svc.Meta[key]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter returns the matched instances truncated to the limit, the order is preserved.
+func (query *DiscoverQuery) Filter(services []*ServiceInfo) []*ServiceInfo {
+	if query == nil {
+		return services
+	}
+	filtered := make([]*ServiceInfo, 0, len(services))
+	for _, svc := range services {
+		if query.Limit > 0 && len(filtered) >= query.Limit {
+			break
+		}
+		if query.Match(svc) {
+			filtered = append(filtered, svc)
+		}
+	}
+	return filtered
+}
+
+// String returns the canonical representation of the query, the equal queries have the same string.
+func (query *DiscoverQuery) String() string {
+	if query == nil {
+		return ""
+	}
+	var parts []string
+	for _, tag := range slices.Sorted(slices.Values(query.Tags)) {
+		parts = append(parts, "tag="+strconv.Quote(tag))
+	}
+	for _, key := range slices.Sorted(maps.Keys(query.Meta)) {
+		parts = append(parts, "meta."+strconv.Quote(key)+"="+strconv.Quote(query.Meta[key]))
+	}
+	for _, key := range slices.Sorted(slices.Values(query.MetaKeys)) {
+		parts = append(parts, "meta."+strconv.Quote(key))
+	}
+	if query.PassingOnly {
+		parts = append(parts, "passing")
+	}
+	if query.Limit > 0 {
+		parts = append(parts, "limit="+strconv.Itoa(query.Limit))
+	}
+	return strings.Join(parts, "&")
+}
+
+// QueryDiscoverer is an optional interface implemented by registries which can filter
+// the instances on the server side. DiscoverWithQuery returns ErrNotFound if no instance matches.
+type QueryDiscoverer interface {
+	DiscoverWithQuery(ctx context.Context, prefix *ServicePrefix, TTL time.Duration, query *DiscoverQuery) ([]*ServiceInfo, error)
+}
+
+// Discover discovers the service instances matching the options.
+func Discover(ctx context.Context, registry Registry, prefix *ServicePrefix, TTL time.Duration, options ...DiscoverOption) ([]*ServiceInfo, error) {
+	var query *DiscoverQuery
+	if len(options) > 0 {
+		query = NewDiscoverQuery(options...)
+	}
+	return DiscoverWithQuery(ctx, registry, prefix, TTL, query)
+}
+
+// DiscoverWithQuery discovers the service instances matching the query.
+// The query is passed to the registry implementing QueryDiscoverer,
+// otherwise the instances are filtered on the client side. The nil query calls Discover.
+func DiscoverWithQuery(ctx context.Context, registry Registry, prefix *ServicePrefix, TTL time.Duration, query *DiscoverQuery) ([]*ServiceInfo, error) {
+	if query == nil {
+		return registry.Discover(ctx, prefix, TTL)
+	}
+	if discoverer, ok := registry.(QueryDiscoverer); ok {
+		return discoverer.DiscoverWithQuery(ctx, prefix, TTL, query)
+	}
+	services, err := registry.Discover(ctx, prefix, TTL)
+	if err != nil {
+		return nil, err
+	}
+	return FilterServices(services, query)
+}
+
+// FilterServices applies the query to the discovered instances, ErrNotFound is returned if no instance matches.
+// It is used by the registries which can't filter the instances on the server side.
+func FilterServices(services []*ServiceInfo, query *DiscoverQuery) ([]*ServiceInfo, error) {
+	services = query.Filter(services)
+	if len(services) == 0 {
+		return nil, ErrNotFound
+	}
+	return services, nil
+}
@@ -0,0 +1,96 @@
+package cloudregistry
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// staticRegistry is the registry discovering the fixed instances without the server-side filtering.
+type staticRegistry struct {
+	Registry
+	services []*ServiceInfo
+}
+
+func (r *staticRegistry) Discover(ctx context.Context, prefix *ServicePrefix, TTL time.Duration) ([]*ServiceInfo, error) {
+	if len(r.services) == 0 {
+		return nil, ErrNotFound
+	}
+	return r.services, nil
+}
+
+func TestDiscoverQuery_Filter(t *testing.T) {
+	services := []*ServiceInfo{
+		{InstanceID: "a", Tags: []string{"v1", "blue"}, Meta: map[string]string{"zone": "eu", "canary": ""}},
+		{InstanceID: "b", Tags: []string{"v1"}, Meta: map[string]string{"zone": "us"}, Health: Health{Status: HealthPassing}},
+		{InstanceID: "c", Tags: []string{"v2", "blue"}, Meta: map[string]string{"zone": "eu"}},
+		{InstanceID: "d", Tags: []string{"v1", "blue"}, Meta: map[string]string{"zone": "eu"}, Health: Health{Status: HealthWarning}},
+	}
+	tests := []struct {
+		name  string
+		query *DiscoverQuery
+		want  []string
+	}{
+		{name: "nil", query: nil, want: []string{"a", "b", "c", "d"}},
+		{name: "empty", query: NewDiscoverQuery(), want: []string{"a", "b", "c", "d"}},
+		{name: "tags", query: NewDiscoverQuery(WithTags("v1", "blue")), want: []string{"a", "d"}},
+		{name: "meta", query: NewDiscoverQuery(WithMeta("zone", "eu")), want: []string{"a", "c", "d"}},
+		{name: "meta keys", query: NewDiscoverQuery(WithMetaKeys("canary")), want: []string{"a"}},
+		{name: "missing meta", query: NewDiscoverQuery(WithMeta("rack", "")), want: []string{}},
+		{name: "limit", query: NewDiscoverQuery(WithTags("blue"), WithLimit(2)), want: []string{"a", "c"}},
+		{name: "passing only", query: NewDiscoverQuery(WithTags("blue"), WithPassingOnly()), want: []string{"a", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids := []string{}
+			for _, svc := range tt.query.Filter(services) {
+				ids = append(ids, svc.InstanceID)
+			}
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("Filter() = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiscoverQuery_String(t *testing.T) {
+	first := NewDiscoverQuery(WithTags("b", "a"), WithMeta("x", "1"), WithMeta("y", "2"), WithPassingOnly(), WithLimit(5))
+	second := NewDiscoverQuery(WithLimit(5), WithMeta("y", "2"), WithPassingOnly(), WithTags("a", "b"), WithMeta("x", "1"))
+	if first.String() != second.String() {
+		t.Errorf("String() = %s and %s, want equal", first, second)
+	}
+	if want := `tag="a"&tag="b"&meta."x"="1"&meta."y"="2"&passing&limit=5`; first.String() != want {
+		t.Errorf("String() = %s, want %s", first, want)
+	}
+	if s := (*DiscoverQuery)(nil).String(); s != "" {
+		t.Errorf("String() of nil = %q", s)
+	}
+}
+
+func TestDiscover_ClientSideFilter(t *testing.T) {
+	registry := &staticRegistry{services: []*ServiceInfo{
+		{InstanceID: "a", Tags: []string{"v1"}},
+		{InstanceID: "b", Tags: []string{"v2"}},
+	}}
+	prefix := &ServicePrefix{Name: "api"}
+
+	services, err := Discover(context.Background(), registry, prefix, 0, WithTags("v2"))
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	if len(services) != 1 || services[0].InstanceID != "b" {
+		t.Errorf("Discover() = %v, want [b]", services)
+	}
+
+	_, err = Discover(context.Background(), registry, prefix, 0, WithTags("v3"))
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Discover() error = %v, want %v", err, ErrNotFound)
+	}
+
+	services, err = Discover(context.Background(), registry, prefix, 0)
+	if err != nil || len(services) != 2 {
+		t.Errorf("Discover() = %v, %v, want all instances", services, err)
+	}
+}
@@ -30,6 +30,7 @@ type ValueClient interface {
 	// SetValue sets a value in the cloud registry.
 	SetValue(ctx context.Context, name, value string) error
 	// SubscribeValue subscribes to a value in the cloud registry.
+	// The keys passed to the ValueSetter are relative to the prefix of the value client, like the listed keys.
 	SubscribeValue(ctx context.Context, name string, val ValueSetter) error
 	// SubscribeValueWithPrefix subscribes to a value in the cloud registry.
 	SubscribeValueWithPrefix(ctx context.Context, prefix string, val ValueSetter) error
@@ -0,0 +1,769 @@
+// Package registrytest provides the conformance test suite for cloudregistry.Registry implementations.
+//
+// Every backend, including third-party ones, can prove it follows the Registry and ValueClient
+// contract by running the suite against a real server or a local stand-in:
+//
+//	func TestConformance(t *testing.T) {
+//		registrytest.Run(t, func(t *testing.T) (cloudregistry.Registry, func(time.Duration)) {
+//			clock := memory.NewManualClock(time.Now())
+//			return memory.NewRegistry(memory.WithClock(clock)), clock.Advance
+//		})
+//	}
+package registrytest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+// Factory creates a new registry for each test of the suite.
+// The returned advance function moves the registry time forward,
+// if it is nil the suite sleeps for the duration instead.
+type Factory func(t *testing.T) (registry cloudregistry.Registry, advance func(time.Duration))
+
+// Option is a configuration option of the suite.
+type Option func(s *suite)
+
+// WithTTL sets the Check.TTL used by the expiration tests, 2 seconds by default.
+func WithTTL(ttl time.Duration) Option {
+	return func(s *suite) {
+		s.ttl = ttl
+	}
+}
+
+// WithTimeout sets how long the suite waits for the asynchronous notifications, 15 seconds by default.
+func WithTimeout(timeout time.Duration) Option {
+	return func(s *suite) {
+		s.timeout = timeout
+	}
+}
+
+// WithSkip skips the tests with the given names, like "TTLExpiry".
+func WithSkip(names ...string) Option {
+	return func(s *suite) {
+		s.skip = append(s.skip, names...)
+	}
+}
+
+type suite struct {
+	factory Factory
+	ttl     time.Duration
+	timeout time.Duration
+	skip    []string
+	suffix  string
+}
+
+// Run runs the conformance suite against the registries created by the factory.
+func Run(t *testing.T, factory Factory, options ...Option) {
+	s := &suite{
+		factory: factory,
+		ttl:     2 * time.Second,
+		timeout: 15 * time.Second,
+		// The suffix separates the data of the concurrent or repeated runs on the shared servers
+		suffix: fmt.Sprintf("%x", rand.Uint32()),
+	}
+	for _, option := range options {
+		option(s)
+	}
+
+	tests := []struct {
+		name string
+		test func(t *testing.T, registry cloudregistry.Registry, advance func(time.Duration))
+	}{
+		{name: "Register", test: s.testRegister},
+		{name: "Deregister", test: s.testDeregister},
+		{name: "DiscoverFilters", test: s.testDiscoverFilters},
+		{name: "DiscoverQuery", test: s.testDiscoverQuery},
+		{name: "TTLExpiry", test: s.testTTLExpiry},
+		{name: "HealthCheck", test: s.testHealthCheck},
+		{name: "ReportHealth", test: s.testReportHealth},
+		{name: "ListServices", test: s.testListServices},
+		{name: "Values", test: s.testValues},
+		{name: "SubscribeValue", test: s.testSubscribeValue},
+		{name: "SubscribeValueWithPrefix", test: s.testSubscribeValueWithPrefix},
+		{name: "SubscribeDelete", test: s.testSubscribeDelete},
+		{name: "NestedValues", test: s.testNestedValues},
+		{name: "ValueStore", test: s.testValueStore},
+		{name: "Lock", test: s.testLock},
+		{name: "Election", test: s.testElection},
+		{name: "Close", test: s.testClose},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if slices.Contains(s.skip, tt.name) {
+				t.Skip("skipped by the suite options")
+			}
+			registry, advance := s.factory(t)
+			if advance == nil {
+				advance = time.Sleep
+			}
+			if tt.name != "Close" {
+				t.Cleanup(func() { _ = registry.Close() })
+			}
+			tt.test(t, registry, advance)
+		})
+	}
+}
+
+func (s *suite) service(name, instanceID string) *cloudregistry.Service {
+	return &cloudregistry.Service{
+		Name:       "registrytest-" + name + "-" + s.suffix,
+		InstanceID: instanceID + "-" + s.suffix,
+		Hostname:   "127.0.0.1",
+		Port:       8080,
+		Tags:       []string{"registrytest", "v1"},
+		Meta:       map[string]string{"suite": "registrytest"},
+		Check:      cloudregistry.Check{ID: "check-" + instanceID + "-" + s.suffix, TTL: s.ttl * 10},
+	}
+}
+
+func (s *suite) values(ctx context.Context, registry cloudregistry.Registry) cloudregistry.ValueClient {
+	return registry.Values(ctx, "registrytest-"+s.suffix+"/")
+}
+
+func (s *suite) register(t *testing.T, registry cloudregistry.Registry, services ...*cloudregistry.Service) {
+	t.Helper()
+	for _, service := range services {
+		if err := registry.Register(context.Background(), service); err != nil {
+			t.Fatalf("Register(%s) error = %v", service.InstanceID, err)
+		}
+		t.Cleanup(func() { _ = registry.Deregister(context.Background(), service.ID()) })
+	}
+}
+
+// discover returns the discovered instance IDs, ErrNotFound is treated as an empty result.
+func discover(t *testing.T, registry cloudregistry.Registry, prefix *cloudregistry.ServicePrefix, ttl time.Duration) []string {
+	t.Helper()
+	services, err := registry.Discover(context.Background(), prefix, ttl)
+	if err != nil && !errors.Is(err, cloudregistry.ErrNotFound) {
+		t.Fatalf("Discover(%s) error = %v", prefix.Name, err)
+	}
+	ids := make([]string, 0, len(services))
+	for _, svc := range services {
+		ids = append(ids, svc.InstanceID)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// eventually waits for the condition using the real time.
+func (s *suite) eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(s.timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func (s *suite) testRegister(t *testing.T, registry cloudregistry.Registry, _ func(time.Duration)) {
+	service := s.service("register", "instance-1")
+	s.register(t, registry, service)
+
+	services, err := registry.Discover(context.Background(), service.Prefix(), 0)
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	if len(services) != 1 {
+		t.Fatalf("Discover() returned %d instances, want 1", len(services))
+	}
+	svc := services[0]
+	if svc.Name != service.Name || svc.InstanceID != service.InstanceID {
+		t.Errorf("Discover() = %s/%s, want %s/%s", svc.Name, svc.InstanceID, service.Name, service.InstanceID)
+	}
+	if svc.Hostname != service.Hostname || svc.Port != service.Port {
+		t.Errorf("Discover() address = %s:%d, want %s:%d", svc.Hostname, svc.Port, service.Hostname, service.Port)
+	}
+	if !slices.Equal(svc.Tags, service.Tags) {
+		t.Errorf("Discover() tags = %v, want %v", svc.Tags, service.Tags)
+	}
+	if svc.Meta["suite"] != service.Meta["suite"] {
+		t.Errorf("Discover() meta = %v, want %v", svc.Meta, service.Meta)
+	}
+}
+
+func (s *suite) testDeregister(t *testing.T, registry cloudregistry.Registry, _ func(time.Duration)) {
+	first := s.service("deregister", "instance-1")
+	second := s.service("deregister", "instance-2")
+	s.register(t, registry, first, second)
+
+	if err := registry.Deregister(context.Background(), first.ID()); err != nil {
+		t.Fatalf("Deregister() error = %v", err)
+	}
+	s.eventually(t, "deregistered instance to disappear", func() bool {
+		return slices.Equal(discover(t, registry, first.Prefix(), 0), []string{second.InstanceID})
+	})
+}
+
+func (s *suite) testDiscoverFilters(t *testing.T, registry cloudregistry.Registry, _ func(time.Duration)) {
+	first := s.service("filter-a", "instance-1")
+	second := s.service("filter-a", "instance-2")
+	other := s.service("filter-b", "instance-3")
+	s.register(t, registry, first, second, other)
+
+	if got, want := discover(t, registry, first.Prefix(), 0), []string{first.InstanceID, second.InstanceID}; !slices.Equal(got, want) {
+		t.Errorf("Discover(filter-a) = %v, want %v", got, want)
+	}
+	if got, want := discover(t, registry, other.Prefix(), 0), []string{other.InstanceID}; !slices.Equal(got, want) {
+		t.Errorf("Discover(filter-b) = %v, want %v", got, want)
+	}
+
+	_, err := registry.Discover(context.Background(), &cloudregistry.ServicePrefix{Name: "registrytest-missing-" + s.suffix}, 0)
+	if !errors.Is(err, cloudregistry.ErrNotFound) {
+		t.Errorf("Discover(missing) error = %v, want %v", err, cloudregistry.ErrNotFound)
+	}
+}
+
+func (s *suite) testDiscoverQuery(t *testing.T, registry cloudregistry.Registry, _ func(time.Duration)) {
+	first := s.service("query", "instance-1")
+	second := s.service("query", "instance-2")
+	second.Tags = append(second.Tags, "canary")
+	second.Meta = map[string]string{"suite": "registrytest", "zone": "eu-1"}
+	third := s.service("query", "instance-3")
+	third.Meta = map[string]string{"suite": "other", "zone": "us-1"}
+	s.register(t, registry, first, second, third)
+
+	query := func(options ...cloudregistry.DiscoverOption) []string {
+		services, err := cloudregistry.Discover(context.Background(), registry, first.Prefix(), 0, options...)
+		if err != nil && !errors.Is(err, cloudregistry.ErrNotFound) {
+			t.Fatalf("Discover(%s) error = %v", cloudregistry.NewDiscoverQuery(options...), err)
+		}
+		ids := make([]string, 0, len(services))
+		for _, svc := range services {
+			ids = append(ids, svc.InstanceID)
+		}
+		slices.Sort(ids)
+		return ids
+	}
+
+	if got, want := query(cloudregistry.WithTags("v1", "canary")), []string{second.InstanceID}; !slices.Equal(got, want) {
+		t.Errorf("Discover(tags) = %v, want %v", got, want)
+	}
+	if got, want := query(cloudregistry.WithMeta("suite", "registrytest")), []string{first.InstanceID, second.InstanceID}; !slices.Equal(got, want) {
+		t.Errorf("Discover(meta) = %v, want %v", got, want)
+	}
+	if got, want := query(cloudregistry.WithMetaKeys("zone")), []string{second.InstanceID, third.InstanceID}; !slices.Equal(got, want) {
+		t.Errorf("Discover(meta keys) = %v, want %v", got, want)
+	}
+	if got := query(cloudregistry.WithTags("missing")); len(got) != 0 {
+		t.Errorf("Discover(missing tag) = %v, want none", got)
+	}
+	if got := query(cloudregistry.WithLimit(2)); len(got) != 2 {
+		t.Errorf("Discover(limit) = %v, want 2 instances", got)
+	}
+
+	for _, service := range []*cloudregistry.Service{first, second, third} {
+		if err := registry.HealthCheck(context.Background(), service.ID(), s.ttl); err != nil {
+			t.Fatalf("HealthCheck(%s) error = %v", service.InstanceID, err)
+		}
+	}
+	s.eventually(t, "healthy instances to pass", func() bool {
+		return len(query(cloudregistry.WithPassingOnly())) == 3
+	})
+}
+
+func (s *suite) testTTLExpiry(t *testing.T, registry cloudregistry.Registry, advance func(time.Duration)) {
+	alive := s.service("ttl", "alive")
+	alive.Check.TTL = s.ttl
+	expiring := s.service("ttl", "expiring")
+	expiring.Check.TTL = s.ttl
+
+	// The registration context is canceled to stop any background heartbeats of the backend
+	ctx, cancel := context.WithCancel(context.Background())
+	for _, service := range []*cloudregistry.Service{alive, expiring} {
+		if err := registry.Register(ctx, service); err != nil {
+			cancel()
+			t.Fatalf("Register(%s) error = %v", service.InstanceID, err)
+		}
+		t.Cleanup(func() { _ = registry.Deregister(context.Background(), service.ID()) })
+	}
+	cancel()
+
+	for i := 0; i < 6; i++ {
+		advance(s.ttl / 2)
+		if err := registry.HealthCheck(context.Background(), alive.ID(), s.ttl); err != nil {
+			t.Fatalf("HealthCheck() error = %v", err)
+		}
+	}
+
+	s.eventually(t, "expired instance to disappear", func() bool {
+		return slices.Equal(discover(t, registry, alive.Prefix(), s.ttl), []string{alive.InstanceID})
+	})
+}
+
+func (s *suite) testHealthCheck(t *testing.T, registry cloudregistry.Registry, _ func(time.Duration)) {
+	service := s.service("health", "instance-1")
+	s.register(t, registry, service)
+
+	if err := registry.HealthCheck(context.Background(), service.ID(), service.Check.TTL); err != nil {
+		t.Errorf("HealthCheck() of registered instance error = %v", err)
+	}
+
+	missing := s.service("health", "missing").ID()
+	err := registry.HealthCheck(context.Background(), missing, service.Check.TTL)
+	if !errors.Is(err, cloudregistry.ErrNotFound) && !errors.Is(err, cloudregistry.ErrNotReady) {
+		t.Errorf("HealthCheck() of missing instance error = %v, want %v or %v",
+			err, cloudregistry.ErrNotFound, cloudregistry.ErrNotReady)
+	}
+}
+
+func (s *suite) testReportHealth(t *testing.T, registry cloudregistry.Registry, _ func(time.Duration)) {
+	service := s.service("report-health", "instance-1")
+	s.register(t, registry, service)
+
+	health := func() cloudregistry.Health {
+		services, err := registry.Discover(context.Background(), service.Prefix(), 0)
+		if err != nil || len(services) != 1 {
+			t.Fatalf("Discover() = %v, %v, want 1 instance", services, err)
+		}
+		return services[0].Health
+	}
+
+	err := cloudregistry.ReportHealth(context.Background(), registry, service.ID(), cloudregistry.HealthWarning, "degraded")
+	if errors.Is(err, cloudregistry.ErrHealthReporterNotSupported) {
+		t.Skip("the registry does not store the health status")
+	}
+	if err != nil {
+		t.Fatalf("ReportHealth(warning) error = %v", err)
+	}
+	if _, ok := registry.(cloudregistry.HealthReporter); !ok {
+		return
+	}
+	s.eventually(t, "warning status", func() bool {
+		h := health()
+		return h.Status == cloudregistry.HealthWarning && h.Output == "degraded"
+	})
+
+	if err := registry.HealthCheck(context.Background(), service.ID(), s.ttl); err != nil {
+		t.Fatalf("HealthCheck() error = %v", err)
+	}
+	if h := health(); h.Status != cloudregistry.HealthWarning {
+		t.Errorf("Health after HealthCheck = %s, want the reported %s", h.Status, cloudregistry.HealthWarning)
+	}
+	services, err := cloudregistry.Discover(context.Background(), registry, service.Prefix(), 0, cloudregistry.WithPassingOnly())
+	if !errors.Is(err, cloudregistry.ErrNotFound) {
+		t.Errorf("Discover(passing) = %v, %v, want %v", services, err, cloudregistry.ErrNotFound)
+	}
+
+	err = cloudregistry.ReportHealth(context.Background(), registry, service.ID(), cloudregistry.HealthPassing, "")
+	if err != nil {
+		t.Fatalf("ReportHealth(passing) error = %v", err)
+	}
+	s.eventually(t, "passing status", func() bool {
+		return health().Status == cloudregistry.HealthPassing
+	})
+
+	missing := s.service("report-health", "missing").ID()
+	if err := cloudregistry.ReportHealth(context.Background(), registry, missing, cloudregistry.HealthPassing, ""); !errors.Is(err, cloudregistry.ErrNotFound) {
+		t.Errorf("ReportHealth(missing) error = %v, want %v", err, cloudregistry.ErrNotFound)
+	}
+}
+
+// listServices returns the names of the listed services of the suite run.
+func (s *suite) listServices(t *testing.T, registry cloudregistry.Registry, namespace string) []string {
+	t.Helper()
+	prefixes, err := cloudregistry.ListServices(context.Background(), registry, namespace)
+	if err != nil {
+		t.Fatalf("ListServices(%q) error = %v", namespace, err)
+	}
+	var names []string
+	for _, prefix := range prefixes {
+		if strings.HasSuffix(prefix.Name, "-"+s.suffix) {
+			names = append(names, prefix.Name)
+		}
+	}
+	return names
+}
+
+func (s *suite) testListServices(t *testing.T, registry cloudregistry.Registry, _ func(time.Duration)) {
+	if _, err := cloudregistry.ListServices(context.Background(), registry, ""); errors.Is(err, cloudregistry.ErrServiceListNotSupported) {
+		t.Skip("the registry does not implement ServiceLister")
+	}
+	first, second := s.service("list-a", "instance-1"), s.service("list-b", "instance-1")
+	s.register(t, registry, first, second, s.service("list-a", "instance-2"))
+
+	want := []string{first.Name, second.Name}
+	s.eventually(t, "the listed services", func() bool {
+		return slices.Equal(s.listServices(t, registry, ""), want)
+	})
+	if names := s.listServices(t, registry, "registrytest-"+s.suffix); len(names) != 0 {
+		t.Errorf("ListServices() of the other namespace = %v, want empty", names)
+	}
+
+	if err := registry.Deregister(context.Background(), second.ID()); err != nil {
+		t.Fatalf("Deregister() error = %v", err)
+	}
+	s.eventually(t, "the service without instances to be unlisted", func() bool {
+		return slices.Equal(s.listServices(t, registry, ""), want[:1])
+	})
+}
+
+func (s *suite) testValues(t *testing.T, registry cloudregistry.Registry, _ func(time.Duration)) {
+	ctx := context.Background()
+	values := s.values(ctx, registry)
+
+	if _, err := values.Value(ctx, "missing"); !errors.Is(err, cloudregistry.ErrNotFound) {
+		t.Errorf("Value(missing) error = %v, want %v", err, cloudregistry.ErrNotFound)
+	}
+	for _, value := range []string{"value-1", "value-2"} {
+		if err := values.SetValue(ctx, "key", value); err != nil {
+			t.Fatalf("SetValue() error = %v", err)
+		}
+		if got, err := values.Value(ctx, "key"); err != nil || got != value {
+			t.Errorf("Value() = %q, %v, want %q", got, err, value)
+		}
+	}
+}
+
+// collector is the ValueSetter collecting the last value per key.
+type collector struct {
+	mx     sync.Mutex
+	values map[string]any
+}
+
+func (c *collector) SetValue(key string, value any) error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.values[key] = value
+	return nil
+}
+
+// has reports whether the value was received for the key, the keys are relative to the client prefix.
+func (c *collector) has(key string, value any) bool {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	val, ok := c.values[key]
+	return ok && val == value
+}
+
+func (s *suite) testSubscribeValue(t *testing.T, registry cloudregistry.Registry, _ func(time.Duration)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	values := s.values(ctx, registry)
+	received := &collector{values: map[string]any{}}
+
+	if err := values.SubscribeValue(ctx, "watched", received); err != nil {
+		t.Fatalf("SubscribeValue() error = %v", err)
+	}
+	_ = values.SetValue(ctx, "unwatched", "other")
+	if err := values.SetValue(ctx, "watched", "value-1"); err != nil {
+		t.Fatalf("SetValue() error = %v", err)
+	}
+	s.eventually(t, "value-1 notification", func() bool { return received.has("watched", "value-1") })
+
+	if err := values.SetValue(ctx, "watched", "value-2"); err != nil {
+		t.Fatalf("SetValue() error = %v", err)
+	}
+	s.eventually(t, "value-2 notification", func() bool { return received.has("watched", "value-2") })
+
+	if received.has("unwatched", "other") {
+		t.Error("SubscribeValue() delivered the value of another key")
+	}
+}
+
+func (s *suite) testSubscribeValueWithPrefix(t *testing.T, registry cloudregistry.Registry, _ func(time.Duration)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	values := s.values(ctx, registry)
+	received := &collector{values: map[string]any{}}
+
+	// Create the prefix before the subscription, some backends require it to exist
+	_ = values.SetValue(ctx, "prefix/initial", "initial")
+	if err := values.SubscribeValueWithPrefix(ctx, "prefix", received); err != nil {
+		t.Fatalf("SubscribeValueWithPrefix() error = %v", err)
+	}
+	_ = values.SetValue(ctx, "outside", "outside")
+	if err := values.SetValue(ctx, "prefix/a", "value-a"); err != nil {
+		t.Fatalf("SetValue() error = %v", err)
+	}
+	if err := values.SetValue(ctx, "prefix/nested/b", "value-b"); err != nil {
+		t.Fatalf("SetValue() error = %v", err)
+	}
+	s.eventually(t, "direct child notification", func() bool { return received.has("prefix/a", "value-a") })
+	s.eventually(t, "nested child notification", func() bool { return received.has("prefix/nested/b", "value-b") })
+
+	if received.has("outside", "outside") {
+		t.Error("SubscribeValueWithPrefix() delivered the value outside of the prefix")
+	}
+}
+
+func (s *suite) testSubscribeDelete(t *testing.T, registry cloudregistry.Registry, _ func(time.Duration)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	values := s.values(ctx, registry)
+	if _, ok := values.(cloudregistry.ValueStore); !ok {
+		t.Skip("the value client does not implement ValueStore")
+	}
+	single := &collector{values: map[string]any{}}
+	prefix := &collector{values: map[string]any{}}
+
+	_ = values.SetValue(ctx, "deleted/initial", "initial")
+	if err := values.SubscribeValue(ctx, "deleted/key", single); err != nil {
+		t.Fatalf("SubscribeValue() error = %v", err)
+	}
+	if err := values.SubscribeValueWithPrefix(ctx, "deleted", prefix); err != nil {
+		t.Fatalf("SubscribeValueWithPrefix() error = %v", err)
+	}
+	if err := values.SetValue(ctx, "deleted/key", "value"); err != nil {
+		t.Fatalf("SetValue() error = %v", err)
+	}
+	s.eventually(t, "value notification", func() bool {
+		return single.has("deleted/key", "value") && prefix.has("deleted/key", "value")
+	})
+
+	// The deleted keys are delivered as the nil values
+	if err := cloudregistry.DeleteValue(ctx, values, "deleted/key"); err != nil {
+		t.Fatalf("DeleteValue() error = %v", err)
+	}
+	s.eventually(t, "deletion notification", func() bool {
+		return single.has("deleted/key", nil) && prefix.has("deleted/key", nil)
+	})
+}
+
+func (s *suite) testNestedValues(t *testing.T, registry cloudregistry.Registry, _ func(time.Duration)) {
+	ctx := context.Background()
+	values := s.values(ctx, registry)
+	nested := values.Values(ctx, "a/").Values(ctx, "b/")
+
+	if err := nested.SetValue(ctx, "key", "nested"); err != nil {
+		t.Fatalf("SetValue() error = %v", err)
+	}
+	if got, err := values.Value(ctx, "a/b/key"); err != nil || got != "nested" {
+		t.Errorf("Value(a/b/key) = %q, %v, want %q", got, err, "nested")
+	}
+	if got, err := values.Values(ctx, "a/").Value(ctx, "b/key"); err != nil || got != "nested" {
+		t.Errorf("Values(a/).Value(b/key) = %q, %v, want %q", got, err, "nested")
+	}
+	if got, err := nested.Value(ctx, "key"); err != nil || got != "nested" {
+		t.Errorf("nested Value(key) = %q, %v, want %q", got, err, "nested")
+	}
+}
+
+func (s *suite) testValueStore(t *testing.T, registry cloudregistry.Registry, _ func(time.Duration)) {
+	ctx := context.Background()
+	values := s.values(ctx, registry)
+	if _, ok := values.(cloudregistry.ValueStore); !ok {
+		t.Skip("the value client does not implement ValueStore")
+	}
+
+	if ok, err := cloudregistry.CompareAndSwap(ctx, values, "store/key", 0, "value-1"); err != nil || !ok {
+		t.Fatalf("CompareAndSwap() create = %v, %v, want true", ok, err)
+	}
+	if ok, err := cloudregistry.CompareAndSwap(ctx, values, "store/key", 0, "other"); err != nil || ok {
+		t.Errorf("CompareAndSwap() create existing = %v, %v, want false", ok, err)
+	}
+	kv, err := cloudregistry.VersionedValue(ctx, values, "store/key")
+	if err != nil || kv.Value != "value-1" || kv.Version == 0 {
+		t.Fatalf("VersionedValue() = %+v, %v", kv, err)
+	}
+	if ok, err := cloudregistry.CompareAndSwap(ctx, values, "store/key", kv.Version, "value-2"); err != nil || !ok {
+		t.Errorf("CompareAndSwap() = %v, %v, want true", ok, err)
+	}
+	if ok, err := cloudregistry.CompareAndSwap(ctx, values, "store/key", kv.Version, "value-3"); err != nil || ok {
+		t.Errorf("CompareAndSwap() with stale version = %v, %v, want false", ok, err)
+	}
+	if got, _ := values.Value(ctx, "store/key"); got != "value-2" {
+		t.Errorf("Value() = %q, want %q", got, "value-2")
+	}
+
+	if err := values.SetValue(ctx, "store/nested/a", "a"); err != nil {
+		t.Fatalf("SetValue() error = %v", err)
+	}
+	list, err := cloudregistry.ListValues(ctx, values, "store/")
+	if err != nil {
+		t.Fatalf("ListValues() error = %v", err)
+	}
+	var keys []string
+	for _, kv := range list {
+		keys = append(keys, kv.Key)
+	}
+	if want := []string{"store/key", "store/nested/a"}; !slices.Equal(keys, want) {
+		t.Errorf("ListValues() keys = %v, want %v", keys, want)
+	}
+
+	if err := cloudregistry.DeleteValue(ctx, values, "store/key"); err != nil {
+		t.Fatalf("DeleteValue() error = %v", err)
+	}
+	if _, err := values.Value(ctx, "store/key"); !errors.Is(err, cloudregistry.ErrNotFound) {
+		t.Errorf("Value() after DeleteValue() error = %v, want %v", err, cloudregistry.ErrNotFound)
+	}
+	if err := cloudregistry.DeleteValue(ctx, values, "store/missing"); err != nil {
+		t.Errorf("DeleteValue() of missing value error = %v", err)
+	}
+	if err := cloudregistry.DeletePrefix(ctx, values, "store/"); err != nil {
+		t.Fatalf("DeletePrefix() error = %v", err)
+	}
+	if list, err := cloudregistry.ListValues(ctx, values, "store/"); err != nil || len(list) != 0 {
+		t.Errorf("ListValues() after DeletePrefix() = %v, %v, want empty", list, err)
+	}
+}
+
+func (s *suite) testLock(t *testing.T, registry cloudregistry.Registry, _ func(time.Duration)) {
+	ctx := context.Background()
+	if _, ok := registry.(cloudregistry.Locker); !ok {
+		t.Skip("the registry does not implement Locker")
+	}
+	name := "registrytest-" + s.suffix
+	first, err := cloudregistry.NewMutex(ctx, registry, name)
+	if err != nil {
+		t.Fatalf("NewMutex() error = %v", err)
+	}
+	second, err := cloudregistry.NewMutex(ctx, registry, name)
+	if err != nil {
+		t.Fatalf("NewMutex() error = %v", err)
+	}
+
+	if err := first.Lock(ctx); err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+	if err := second.TryLock(ctx); !errors.Is(err, cloudregistry.ErrLocked) {
+		t.Errorf("TryLock() of held lock error = %v, want %v", err, cloudregistry.ErrLocked)
+	}
+
+	acquired := make(chan error, 1)
+	go func() { acquired <- second.Lock(ctx) }()
+	select {
+	case err := <-acquired:
+		t.Fatalf("Lock() of held lock returned %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	lost := first.Lost()
+	if err := first.Unlock(ctx); err != nil {
+		t.Fatalf("Unlock() error = %v", err)
+	}
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Fatalf("Lock() after Unlock() error = %v", err)
+		}
+	case <-time.After(s.timeout):
+		t.Fatal("Lock() is not acquired after Unlock()")
+	}
+	select {
+	case <-lost:
+	case <-time.After(s.timeout):
+		t.Error("Lost() is not closed after Unlock()")
+	}
+
+	if err := second.Unlock(ctx); err != nil {
+		t.Errorf("Unlock() error = %v", err)
+	}
+	if err := second.Unlock(ctx); !errors.Is(err, cloudregistry.ErrNotLocked) {
+		t.Errorf("Unlock() of released lock error = %v, want %v", err, cloudregistry.ErrNotLocked)
+	}
+	if err := first.TryLock(ctx); err != nil {
+		t.Errorf("TryLock() of free lock error = %v", err)
+	}
+	_ = first.Unlock(ctx)
+}
+
+func (s *suite) testElection(t *testing.T, registry cloudregistry.Registry, _ func(time.Duration)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, ok := registry.(cloudregistry.Elector); !ok {
+		t.Skip("the registry does not implement Elector")
+	}
+	prefix := &cloudregistry.ServicePrefix{Name: "registrytest-election-" + s.suffix}
+	first, err := cloudregistry.NewElection(ctx, registry, prefix)
+	if err != nil {
+		t.Fatalf("NewElection() error = %v", err)
+	}
+	second, err := cloudregistry.NewElection(ctx, registry, prefix)
+	if err != nil {
+		t.Fatalf("NewElection() error = %v", err)
+	}
+	observed := first.Observe(ctx)
+	waitLeader := func(want string) {
+		t.Helper()
+		timeout := time.After(s.timeout)
+		for {
+			select {
+			case leader, ok := <-observed:
+				if !ok {
+					t.Fatal("Observe() channel is closed")
+				}
+				if leader == want {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("Observe() leader %q is not received", want)
+			}
+		}
+	}
+
+	if _, err := first.Leader(ctx); !errors.Is(err, cloudregistry.ErrNotFound) {
+		t.Errorf("Leader() without candidates error = %v, want %v", err, cloudregistry.ErrNotFound)
+	}
+	if err := first.Campaign(ctx, "first"); err != nil {
+		t.Fatalf("Campaign() error = %v", err)
+	}
+	waitLeader("first")
+	if leader, err := second.Leader(ctx); err != nil || leader != "first" {
+		t.Errorf("Leader() = %q, %v, want %q", leader, err, "first")
+	}
+
+	elected := make(chan error, 1)
+	go func() { elected <- second.Campaign(ctx, "second") }()
+	select {
+	case err := <-elected:
+		t.Fatalf("Campaign() with the elected leader returned %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if err := first.Resign(ctx); err != nil {
+		t.Fatalf("Resign() error = %v", err)
+	}
+	select {
+	case err := <-elected:
+		if err != nil {
+			t.Fatalf("Campaign() after Resign() error = %v", err)
+		}
+	case <-time.After(s.timeout):
+		t.Fatal("the candidate is not elected after Resign()")
+	}
+	waitLeader("second")
+
+	if err := second.Resign(ctx); err != nil {
+		t.Errorf("Resign() error = %v", err)
+	}
+	waitLeader("")
+	if err := second.Resign(ctx); !errors.Is(err, cloudregistry.ErrNotLeader) {
+		t.Errorf("Resign() without campaign error = %v, want %v", err, cloudregistry.ErrNotLeader)
+	}
+}
+
+func (s *suite) testClose(t *testing.T, registry cloudregistry.Registry, _ func(time.Duration)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	values := s.values(ctx, registry)
+	noop := cloudregistry.ValueSetterFunc(func(string, any) error { return nil })
+	if err := values.SubscribeValue(ctx, "close", noop); err != nil {
+		t.Fatalf("SubscribeValue() error = %v", err)
+	}
+	if err := values.SubscribeValueWithPrefix(ctx, "close/", noop); err != nil {
+		t.Fatalf("SubscribeValueWithPrefix() error = %v", err)
+	}
+
+	closed := make(chan error, 1)
+	go func() { closed <- registry.Close() }()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Errorf("Close() error = %v", err)
+		}
+	case <-time.After(s.timeout):
+		t.Fatal("Close() hangs with active subscriptions")
+	}
+}
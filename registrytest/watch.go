@@ -0,0 +1,54 @@
+package registrytest
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+// WatchCloser wraps the registry and ends its first service watches right after the snapshot,
+// it tests the watch consumers which have to resubscribe when the watch is closed.
+type WatchCloser struct {
+	cloudregistry.Registry
+	closes  int
+	watches atomic.Int32
+}
+
+// NewWatchCloser returns the registry wrapper which closes the first n service watches.
+func NewWatchCloser(registry cloudregistry.Registry, n int) *WatchCloser {
+	return &WatchCloser{Registry: registry, closes: n}
+}
+
+// Watches returns the number of the WatchServices calls.
+func (w *WatchCloser) Watches() int {
+	return int(w.watches.Load())
+}
+
+// WatchServices watches the services of the wrapped registry, the first watches end after the snapshot.
+func (w *WatchCloser) WatchServices(ctx context.Context, prefix *cloudregistry.ServicePrefix) (<-chan cloudregistry.ServiceEvent, error) {
+	if int(w.watches.Add(1)) > w.closes {
+		return cloudregistry.WatchServices(ctx, w.Registry, prefix)
+	}
+	wctx, cancel := context.WithCancel(ctx)
+	source, err := cloudregistry.WatchServices(wctx, w.Registry, prefix)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	events := make(chan cloudregistry.ServiceEvent, 1)
+	go func() {
+		defer close(events)
+		defer cancel()
+		select {
+		case <-ctx.Done():
+		case event, ok := <-source:
+			if ok {
+				events <- event
+			}
+		}
+	}()
+	return events, nil
+}
+
+var _ cloudregistry.ServiceWatcher = (*WatchCloser)(nil)
@@ -0,0 +1,97 @@
+package resilience
+
+import (
+	"sync"
+	"time"
+)
+
+// State is the state of the circuit breaker.
+type State int
+
+const (
+	// StateClosed passes the calls to the registry.
+	StateClosed State = iota
+	// StateOpen rejects the calls with ErrCircuitOpen.
+	StateOpen
+	// StateHalfOpen passes one trial call, its result closes or opens the circuit again.
+	StateHalfOpen
+)
+
+// String returns the name of the state.
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// breaker opens the circuit after the threshold of the consecutive failed calls,
+// the zero threshold disables it.
+type breaker struct {
+	mx          sync.Mutex
+	threshold   int
+	openTimeout time.Duration
+	now         func() time.Time
+
+	state    State
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+func (b *breaker) current() State {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.openTimeout {
+		return StateHalfOpen
+	}
+	return b.state
+}
+
+// allow reports whether the call can be made, in the half-open state only one trial call is allowed.
+func (b *breaker) allow() bool {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	switch b.state {
+	case StateOpen:
+		if b.now().Sub(b.openedAt) < b.openTimeout {
+			return false
+		}
+		b.state = StateHalfOpen
+	case StateHalfOpen:
+		if b.trial {
+			return false
+		}
+	default:
+		return true
+	}
+	b.trial = true
+	return true
+}
+
+func (b *breaker) success() {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	b.state, b.failures, b.trial = StateClosed, 0, false
+}
+
+func (b *breaker) failure() {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	b.failures++
+	if b.state == StateHalfOpen || (b.threshold > 0 && b.failures >= b.threshold) {
+		b.state, b.openedAt, b.trial = StateOpen, b.now(), false
+	}
+}
+
+// cancel releases the trial call which was canceled by the caller.
+func (b *breaker) cancel() {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	b.trial = false
+}
@@ -0,0 +1,356 @@
+// Package resilience implements the registry decorator retrying the transient failures
+// and protecting the callers with the circuit breaker.
+//
+// The idempotent operations Discover, HealthCheck, ReportHealth, Value and ListValues are retried
+// with the jittered exponential backoff, every attempt has its own deadline.
+// The other operations are not retried but pass through the circuit breaker.
+// While the circuit is open, Discover returns the last successful result for the prefix.
+//
+// Example:
+//
+//	registry = resilience.NewRegistry(registry,
+//		resilience.WithMaxAttempts(5),
+//		resilience.WithCircuitBreaker(10, time.Minute))
+package resilience
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+// ErrCircuitOpen is returned when the circuit breaker rejects the call.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// Option is a configuration option for the resilient registry.
+type Option func(p *policy)
+
+// WithMaxAttempts sets the number of attempts of the idempotent operations, 3 by default.
+func WithMaxAttempts(attempts int) Option {
+	return func(p *policy) {
+		p.maxAttempts = max(attempts, 1)
+	}
+}
+
+// WithBackoff sets the initial and the maximal delay between the attempts, 100ms and 5s by default.
+// The delay doubles after every attempt, the half of it is randomized.
+func WithBackoff(initial, maximal time.Duration) Option {
+	return func(p *policy) {
+		p.initialBackoff, p.maxBackoff = initial, maximal
+	}
+}
+
+// WithCallTimeout sets the deadline of every attempt, 5s by default, zero disables it.
+func WithCallTimeout(timeout time.Duration) Option {
+	return func(p *policy) {
+		p.callTimeout = timeout
+	}
+}
+
+// WithRetryable sets the classifier of the transient errors which are retried and counted
+// by the circuit breaker, DefaultRetryable is used by default.
+func WithRetryable(retryable func(err error) bool) Option {
+	return func(p *policy) {
+		p.retryable = retryable
+	}
+}
+
+// WithCircuitBreaker sets the number of the consecutive failed calls opening the circuit
+// and the time after which the trial call is allowed, 5 and 30s by default.
+// The zero threshold disables the circuit breaker.
+func WithCircuitBreaker(threshold int, openTimeout time.Duration) Option {
+	return func(p *policy) {
+		p.breaker.threshold, p.breaker.openTimeout = threshold, openTimeout
+	}
+}
+
+// DefaultRetryable treats all errors as transient except the registry answers ErrNotFound and ErrNotReady,
+// the errors which are not fixed by repeating the call, the canceled context and the unsupported operations.
+func DefaultRetryable(err error) bool {
+	switch {
+	case errors.Is(err, cloudregistry.ErrNotFound),
+		errors.Is(err, cloudregistry.ErrNotReady),
+		errors.Is(err, cloudregistry.ErrPermissionDenied),
+		errors.Is(err, cloudregistry.ErrAlreadyExists),
+		errors.Is(err, cloudregistry.ErrConflict),
+		errors.Is(err, cloudregistry.ErrClosed),
+		errors.Is(err, cloudregistry.ErrValueStoreNotSupported),
+		errors.Is(err, cloudregistry.ErrHealthReporterNotSupported),
+		errors.Is(err, cloudregistry.ErrWatchNotSupported),
+		errors.Is(err, cloudregistry.ErrServiceListNotSupported),
+		errors.Is(err, cloudregistry.ErrLockerNotSupported),
+		errors.Is(err, cloudregistry.ErrElectorNotSupported),
+		errors.Is(err, context.Canceled):
+		return false
+	}
+	return true
+}
+
+type policy struct {
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	callTimeout    time.Duration
+	retryable      func(err error) bool
+	breaker        *breaker
+}
+
+// call runs the operation with the retries if the breaker allows it,
+// the result of the call is recorded by the breaker.
+func (p *policy) call(ctx context.Context, retry bool, op func(ctx context.Context) error) error {
+	if !p.breaker.allow() {
+		return ErrCircuitOpen
+	}
+	attempts := 1
+	if retry {
+		attempts = p.maxAttempts
+	}
+	var err error
+	for attempt := range attempts {
+		if attempt > 0 {
+			if werr := p.wait(ctx, attempt); werr != nil {
+				break
+			}
+		}
+		if err = p.attempt(ctx, op); err == nil || !p.retryable(err) || ctx.Err() != nil {
+			break
+		}
+	}
+	switch {
+	case ctx.Err() != nil && err != nil:
+		p.breaker.cancel()
+	case err != nil && p.retryable(err):
+		p.breaker.failure()
+	default:
+		p.breaker.success()
+	}
+	return err
+}
+
+func (p *policy) attempt(ctx context.Context, op func(ctx context.Context) error) error {
+	if p.callTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.callTimeout)
+		defer cancel()
+	}
+	return op(ctx)
+}
+
+// backoff returns the delay before the attempt, it is the exponential backoff with the equal jitter.
+func (p *policy) backoff(attempt int) time.Duration {
+	delay := p.initialBackoff
+	for i := 1; i < attempt && delay < p.maxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, p.maxBackoff)
+	if half := int64(delay / 2); half > 0 {
+		delay = time.Duration(half + rand.Int64N(half+1))
+	}
+	return delay
+}
+
+// wait sleeps the backoff delay before the attempt or until the context is done.
+func (p *policy) wait(ctx context.Context, attempt int) error {
+	timer := time.NewTimer(p.backoff(attempt))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Registry is the resilient decorator of the registry.
+// The ValueStore reads are retried, the writes are only counted by the circuit breaker.
+// The service watches, locks and elections are passed to the wrapped registry as is,
+// the backends keep their sessions and watches alive themselves.
+type Registry struct {
+	valueClient
+	cloudregistry.Passthrough
+	registry cloudregistry.Registry
+
+	mx         sync.RWMutex
+	discovered map[string][]*cloudregistry.ServiceInfo // by the prefix and query
+}
+
+// NewRegistry wraps the registry with the retries and the circuit breaker.
+func NewRegistry(registry cloudregistry.Registry, options ...Option) *Registry {
+	p := &policy{
+		maxAttempts:    3,
+		initialBackoff: 100 * time.Millisecond,
+		maxBackoff:     5 * time.Second,
+		callTimeout:    5 * time.Second,
+		retryable:      DefaultRetryable,
+		breaker:        &breaker{threshold: 5, openTimeout: 30 * time.Second, now: time.Now},
+	}
+	for _, option := range options {
+		option(p)
+	}
+	return &Registry{
+		valueClient: valueClient{client: registry, policy: p},
+		Passthrough: cloudregistry.NewPassthrough(registry),
+		registry:    registry,
+		discovered:  map[string][]*cloudregistry.ServiceInfo{},
+	}
+}
+
+// CircuitState returns the current state of the circuit breaker.
+func (r *Registry) CircuitState() State {
+	return r.policy.breaker.current()
+}
+
+// Register registers the service, the call is not retried.
+func (r *Registry) Register(ctx context.Context, service *cloudregistry.Service) error {
+	return r.policy.call(ctx, false, func(ctx context.Context) error {
+		return r.registry.Register(ctx, service)
+	})
+}
+
+// Deregister deregisters the service, the call is not retried.
+func (r *Registry) Deregister(ctx context.Context, id *cloudregistry.ServiceID) error {
+	return r.policy.call(ctx, false, func(ctx context.Context) error {
+		return r.registry.Deregister(ctx, id)
+	})
+}
+
+// Discover discovers the services with the retries,
+// the last successful result is returned while the circuit is open.
+func (r *Registry) Discover(ctx context.Context, prefix *cloudregistry.ServicePrefix, TTL time.Duration) ([]*cloudregistry.ServiceInfo, error) {
+	return r.DiscoverWithQuery(ctx, prefix, TTL, nil)
+}
+
+// DiscoverWithQuery discovers the services matching the query with the retries,
+// the last successful result of the same query is returned while the circuit is open.
+func (r *Registry) DiscoverWithQuery(ctx context.Context, prefix *cloudregistry.ServicePrefix, TTL time.Duration, query *cloudregistry.DiscoverQuery) ([]*cloudregistry.ServiceInfo, error) {
+	var services []*cloudregistry.ServiceInfo
+	err := r.policy.call(ctx, true, func(ctx context.Context) (err error) {
+		services, err = cloudregistry.DiscoverWithQuery(ctx, r.registry, prefix, TTL, query)
+		return err
+	})
+	key := prefix.String() + "?" + query.String()
+	if err == nil {
+		r.mx.Lock()
+		r.discovered[key] = services
+		r.mx.Unlock()
+		return services, nil
+	}
+	if errors.Is(err, ErrCircuitOpen) {
+		r.mx.RLock()
+		cached, ok := r.discovered[key]
+		r.mx.RUnlock()
+		if ok {
+			return slices.Clone(cached), nil
+		}
+	}
+	return nil, err
+}
+
+// HealthCheck checks the service with the retries.
+func (r *Registry) HealthCheck(ctx context.Context, id *cloudregistry.ServiceID, TTL time.Duration) error {
+	return r.policy.call(ctx, true, func(ctx context.Context) error {
+		return r.registry.HealthCheck(ctx, id, TTL)
+	})
+}
+
+// ReportHealth reports the health status with the retries.
+func (r *Registry) ReportHealth(ctx context.Context, id *cloudregistry.ServiceID, status cloudregistry.HealthStatus, output string) error {
+	return r.policy.call(ctx, true, func(ctx context.Context) error {
+		return cloudregistry.ReportHealth(ctx, r.registry, id, status, output)
+	})
+}
+
+type valueClient struct {
+	client cloudregistry.ValueClient
+	policy *policy
+}
+
+// Values returns the ValueClient with the prefix sharing the retry policy and the circuit breaker.
+func (c *valueClient) Values(ctx context.Context, prefix ...string) cloudregistry.ValueClient {
+	return &valueClient{client: c.client.Values(ctx, prefix...), policy: c.policy}
+}
+
+// Codec returns the codec of the wrapped client.
+func (c *valueClient) Codec() cloudregistry.Codec {
+	return cloudregistry.CodecOf(c.client)
+}
+
+// WithCodec returns the ValueClient with the same prefix using the codec and sharing the retry policy.
+func (c *valueClient) WithCodec(codec cloudregistry.Codec) cloudregistry.ValueClient {
+	return &valueClient{client: cloudregistry.WithCodec(c.client, codec), policy: c.policy}
+}
+
+// Value returns the value with the retries.
+func (c *valueClient) Value(ctx context.Context, name string) (value string, err error) {
+	err = c.policy.call(ctx, true, func(ctx context.Context) (err error) {
+		value, err = c.client.Value(ctx, name)
+		return err
+	})
+	return value, err
+}
+
+// SetValue sets the value, the call is not retried.
+func (c *valueClient) SetValue(ctx context.Context, name, value string) error {
+	return c.policy.call(ctx, false, func(ctx context.Context) error {
+		return c.client.SetValue(ctx, name, value)
+	})
+}
+
+// SubscribeValue subscribes to the value, the backends retry the watches themselves.
+func (c *valueClient) SubscribeValue(ctx context.Context, name string, val cloudregistry.ValueSetter) error {
+	return c.client.SubscribeValue(ctx, name, val)
+}
+
+// SubscribeValueWithPrefix subscribes to the values with the prefix, the backends retry the watches themselves.
+func (c *valueClient) SubscribeValueWithPrefix(ctx context.Context, prefix string, val cloudregistry.ValueSetter) error {
+	return c.client.SubscribeValueWithPrefix(ctx, prefix, val)
+}
+
+// DeleteValue deletes the value if the wrapped client implements ValueStore, the call is not retried.
+func (c *valueClient) DeleteValue(ctx context.Context, name string) error {
+	return c.policy.call(ctx, false, func(ctx context.Context) error {
+		return cloudregistry.DeleteValue(ctx, c.client, name)
+	})
+}
+
+// DeletePrefix deletes the values with the prefix if the wrapped client implements ValueStore, the call is not retried.
+func (c *valueClient) DeletePrefix(ctx context.Context, prefix string) error {
+	return c.policy.call(ctx, false, func(ctx context.Context) error {
+		return cloudregistry.DeletePrefix(ctx, c.client, prefix)
+	})
+}
+
+// ListValues lists the values with the prefix if the wrapped client implements ValueStore, the call is retried.
+func (c *valueClient) ListValues(ctx context.Context, prefix string) (values []*cloudregistry.KeyValue, err error) {
+	err = c.policy.call(ctx, true, func(ctx context.Context) (err error) {
+		values, err = cloudregistry.ListValues(ctx, c.client, prefix)
+		return err
+	})
+	return values, err
+}
+
+// CompareAndSwap sets the value if its version matches and the wrapped client implements ValueStore,
+// the call is not retried.
+func (c *valueClient) CompareAndSwap(ctx context.Context, name string, version uint64, value string) (swapped bool, err error) {
+	err = c.policy.call(ctx, false, func(ctx context.Context) (err error) {
+		swapped, err = cloudregistry.CompareAndSwap(ctx, c.client, name, version, value)
+		return err
+	})
+	return swapped, err
+}
+
+var (
+	_ cloudregistry.Registry        = (*Registry)(nil)
+	_ cloudregistry.ServiceWatcher  = (*Registry)(nil)
+	_ cloudregistry.QueryDiscoverer = (*Registry)(nil)
+	_ cloudregistry.HealthReporter  = (*Registry)(nil)
+	_ cloudregistry.Locker          = (*Registry)(nil)
+	_ cloudregistry.Elector         = (*Registry)(nil)
+	_ cloudregistry.ValueStore      = (*valueClient)(nil)
+	_ cloudregistry.CodecClient     = (*valueClient)(nil)
+)
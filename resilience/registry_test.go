@@ -0,0 +1,240 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/demdxx/cloudregistry"
+	"github.com/demdxx/cloudregistry/memory"
+	"github.com/demdxx/cloudregistry/registrytest"
+)
+
+var errTransient = errors.New("transient error")
+
+// flakyRegistry fails the Discover and Value calls while the failures are left.
+type flakyRegistry struct {
+	*memory.Registry
+	mx       sync.Mutex
+	failures int
+	block    bool
+	calls    int
+}
+
+func newFlakyRegistry(failures int) *flakyRegistry {
+	return &flakyRegistry{Registry: memory.NewRegistry(), failures: failures}
+}
+
+func (r *flakyRegistry) fail(ctx context.Context) error {
+	r.mx.Lock()
+	r.calls++
+	block := r.block
+	fail := r.failures != 0
+	if r.failures > 0 {
+		r.failures--
+	}
+	r.mx.Unlock()
+	if block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	if fail {
+		return errTransient
+	}
+	return nil
+}
+
+func (r *flakyRegistry) setFailures(failures int) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.failures, r.calls = failures, 0
+}
+
+func (r *flakyRegistry) callCount() int {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return r.calls
+}
+
+func (r *flakyRegistry) Discover(ctx context.Context, prefix *cloudregistry.ServicePrefix, TTL time.Duration) ([]*cloudregistry.ServiceInfo, error) {
+	if err := r.fail(ctx); err != nil {
+		return nil, err
+	}
+	return r.Registry.Discover(ctx, prefix, TTL)
+}
+
+func (r *flakyRegistry) Value(ctx context.Context, name string) (string, error) {
+	if err := r.fail(ctx); err != nil {
+		return "", err
+	}
+	return r.Registry.Value(ctx, name)
+}
+
+func TestRegistry_Retry(t *testing.T) {
+	ctx := context.Background()
+	flaky := newFlakyRegistry(2)
+	_ = flaky.SetValue(ctx, "key", "value")
+	registry := NewRegistry(flaky, WithBackoff(time.Millisecond, 5*time.Millisecond))
+
+	if got, err := registry.Value(ctx, "key"); err != nil || got != "value" {
+		t.Fatalf("Value() = %q, %v, want %q", got, err, "value")
+	}
+	if calls := flaky.callCount(); calls != 3 {
+		t.Errorf("calls = %d, want 3", calls)
+	}
+
+	flaky.setFailures(0)
+	if _, err := registry.Value(ctx, "missing"); !errors.Is(err, cloudregistry.ErrNotFound) {
+		t.Errorf("Value() error = %v, want %v", err, cloudregistry.ErrNotFound)
+	}
+	if calls := flaky.callCount(); calls != 1 {
+		t.Errorf("calls of not found value = %d, want 1", calls)
+	}
+
+	flaky.setFailures(-1)
+	if _, err := registry.Value(ctx, "key"); !errors.Is(err, errTransient) {
+		t.Errorf("Value() error = %v, want %v", err, errTransient)
+	}
+	if calls := flaky.callCount(); calls != 3 {
+		t.Errorf("calls = %d, want 3", calls)
+	}
+}
+
+func TestRegistry_CallTimeout(t *testing.T) {
+	flaky := newFlakyRegistry(0)
+	flaky.block = true
+	registry := NewRegistry(flaky, WithCallTimeout(10*time.Millisecond),
+		WithMaxAttempts(2), WithBackoff(time.Millisecond, time.Millisecond))
+
+	if _, err := registry.Value(context.Background(), "key"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Value() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if calls := flaky.callCount(); calls != 2 {
+		t.Errorf("calls = %d, want 2", calls)
+	}
+}
+
+func TestRegistry_CircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	flaky := newFlakyRegistry(0)
+	_ = flaky.Register(ctx, &cloudregistry.Service{Name: "billing", InstanceID: "1"})
+	registry := NewRegistry(flaky, WithMaxAttempts(1), WithCircuitBreaker(2, time.Minute))
+	clock := memory.NewManualClock(time.Now())
+	registry.policy.breaker.now = clock.Now
+	prefix := &cloudregistry.ServicePrefix{Name: "billing"}
+
+	if services, err := registry.Discover(ctx, prefix, 0); err != nil || len(services) != 1 {
+		t.Fatalf("Discover() = %v, %v", services, err)
+	}
+
+	flaky.setFailures(-1)
+	for range 2 {
+		if _, err := registry.Discover(ctx, prefix, 0); !errors.Is(err, errTransient) {
+			t.Fatalf("Discover() error = %v, want %v", err, errTransient)
+		}
+	}
+	if state := registry.CircuitState(); state != StateOpen {
+		t.Fatalf("CircuitState() = %v, want %v", state, StateOpen)
+	}
+	if services, err := registry.Discover(ctx, prefix, 0); err != nil || len(services) != 1 {
+		t.Errorf("Discover() with open circuit = %v, %v, want the cached result", services, err)
+	}
+	if _, err := registry.Value(ctx, "key"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Value() error = %v, want %v", err, ErrCircuitOpen)
+	}
+	if calls := flaky.callCount(); calls != 2 {
+		t.Errorf("calls = %d, want 2", calls)
+	}
+
+	clock.Advance(time.Minute)
+	if state := registry.CircuitState(); state != StateHalfOpen {
+		t.Fatalf("CircuitState() = %v, want %v", state, StateHalfOpen)
+	}
+	if _, err := registry.Discover(ctx, prefix, 0); !errors.Is(err, errTransient) {
+		t.Fatalf("Discover() trial error = %v, want %v", err, errTransient)
+	}
+	if state := registry.CircuitState(); state != StateOpen {
+		t.Fatalf("CircuitState() after failed trial = %v, want %v", state, StateOpen)
+	}
+
+	flaky.setFailures(0)
+	clock.Advance(time.Minute)
+	if services, err := registry.Discover(ctx, prefix, 0); err != nil || len(services) != 1 {
+		t.Fatalf("Discover() trial = %v, %v", services, err)
+	}
+	if state := registry.CircuitState(); state != StateClosed {
+		t.Errorf("CircuitState() after successful trial = %v, want %v", state, StateClosed)
+	}
+}
+
+func TestPolicy_Backoff(t *testing.T) {
+	p := &policy{initialBackoff: 100 * time.Millisecond, maxBackoff: time.Second}
+	tests := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{attempt: 1, min: 50 * time.Millisecond, max: 100 * time.Millisecond},
+		{attempt: 2, min: 100 * time.Millisecond, max: 200 * time.Millisecond},
+		{attempt: 4, min: 400 * time.Millisecond, max: 800 * time.Millisecond},
+		{attempt: 10, min: 500 * time.Millisecond, max: time.Second},
+	}
+	for _, tt := range tests {
+		for range 100 {
+			if delay := p.backoff(tt.attempt); delay < tt.min || delay > tt.max {
+				t.Fatalf("backoff(%d) = %v, want in [%v, %v]", tt.attempt, delay, tt.min, tt.max)
+			}
+		}
+	}
+}
+
+func TestRegistry_Codec(t *testing.T) {
+	registry := NewRegistry(memory.NewRegistry())
+	if codec := cloudregistry.CodecOf(registry); codec != cloudregistry.DefaultCodec {
+		t.Errorf("CodecOf() = %v, want the codec of the wrapped registry", codec)
+	}
+	values := cloudregistry.WithCodec(registry, cloudregistry.StringCodec)
+	if codec := cloudregistry.CodecOf(values); codec != cloudregistry.StringCodec {
+		t.Errorf("CodecOf() = %v, want %v", codec, cloudregistry.StringCodec)
+	}
+	if client, ok := values.(*valueClient); !ok || client.policy != registry.policy {
+		t.Errorf("WithCodec() = %T, want the client sharing the retry policy", values)
+	}
+}
+
+func TestDefaultRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{err: errTransient, want: true},
+		{err: cloudregistry.NewError(cloudregistry.ErrUnavailable, errTransient), want: true},
+		{err: cloudregistry.ErrTimeout, want: true},
+		{err: cloudregistry.ErrNotFound, want: false},
+		{err: cloudregistry.ErrNotReady, want: false},
+		{err: cloudregistry.NewError(cloudregistry.ErrPermissionDenied, errTransient), want: false},
+		{err: cloudregistry.ErrAlreadyExists, want: false},
+		{err: cloudregistry.ErrConflict, want: false},
+		{err: cloudregistry.ErrClosed, want: false},
+		{err: cloudregistry.ErrValueStoreNotSupported, want: false},
+		{err: cloudregistry.ErrHealthReporterNotSupported, want: false},
+		{err: cloudregistry.ErrWatchNotSupported, want: false},
+		{err: cloudregistry.ErrServiceListNotSupported, want: false},
+		{err: cloudregistry.ErrLockerNotSupported, want: false},
+		{err: cloudregistry.ErrElectorNotSupported, want: false},
+		{err: context.Canceled, want: false},
+	}
+	for _, tt := range tests {
+		if got := DefaultRetryable(tt.err); got != tt.want {
+			t.Errorf("DefaultRetryable(%v) = %t, want %t", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRegistry_Conformance(t *testing.T) {
+	registrytest.Run(t, func(t *testing.T) (cloudregistry.Registry, func(time.Duration)) {
+		clock := memory.NewManualClock(time.Now())
+		return NewRegistry(memory.NewRegistry(memory.WithClock(clock))), clock.Advance
+	}, registrytest.WithTimeout(time.Second))
+}
@@ -103,6 +103,7 @@ type ServiceInfo struct {
 	Private    []Host            `json:"private,omitempty"`
 	Tags       []string          `json:"tags,omitempty"`
 	Meta       map[string]string `json:"meta,omitempty"`
+	Health     Health            `json:"health"`
 	RawInfo    any               `json:"raw_info,omitempty"`
 	LastUpdate time.Time         `json:"last_update"`
 }
@@ -0,0 +1,47 @@
+package cloudregistry
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+)
+
+// ErrServiceListNotSupported is returned when the registry can't enumerate the services.
+var ErrServiceListNotSupported = errors.New("service listing is not supported")
+
+// ServiceLister is an optional interface implemented by registries which can enumerate the registered services.
+type ServiceLister interface {
+	// ListServices returns the prefixes of the registered services with the Name and Namespace set,
+	// sorted by namespace and name. The non-empty namespace limits the list to its services.
+	ListServices(ctx context.Context, namespace string) ([]*ServicePrefix, error)
+}
+
+// ListServices returns the registered services if the registry implements ServiceLister.
+func ListServices(ctx context.Context, registry Registry, namespace string) ([]*ServicePrefix, error) {
+	if lister, ok := registry.(ServiceLister); ok {
+		return lister.ListServices(ctx, namespace)
+	}
+	return nil, ErrServiceListNotSupported
+}
+
+// ServicePrefixes returns the distinct services of the instances in the namespace,
+// the empty namespace matches all of them. The result is sorted by namespace and name.
+func ServicePrefixes(services []*ServiceInfo, namespace string) []*ServicePrefix {
+	var (
+		prefixes []*ServicePrefix
+		seen     = map[ServicePrefix]bool{}
+	)
+	for _, svc := range services {
+		prefix := ServicePrefix{Name: svc.Name, Namespace: svc.Namespace}
+		if (namespace != "" && namespace != svc.Namespace) || seen[prefix] {
+			continue
+		}
+		seen[prefix] = true
+		prefixes = append(prefixes, &prefix)
+	}
+	slices.SortFunc(prefixes, func(a, b *ServicePrefix) int {
+		return cmp.Or(cmp.Compare(a.Namespace, b.Namespace), cmp.Compare(a.Name, b.Name))
+	})
+	return prefixes
+}
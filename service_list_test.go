@@ -0,0 +1,41 @@
+package cloudregistry
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestServicePrefixes(t *testing.T) {
+	services := []*ServiceInfo{
+		{Name: "web", InstanceID: "1"},
+		{Name: "api", Namespace: "prod", InstanceID: "2"},
+		{Name: "api", InstanceID: "3"},
+		{Name: "web", InstanceID: "4"},
+		{Name: "api", Namespace: "prod", Partition: "eu", InstanceID: "5"},
+	}
+	tests := []struct {
+		namespace string
+		want      []ServicePrefix
+	}{
+		{want: []ServicePrefix{{Name: "api"}, {Name: "web"}, {Name: "api", Namespace: "prod"}}},
+		{namespace: "prod", want: []ServicePrefix{{Name: "api", Namespace: "prod"}}},
+		{namespace: "dev"},
+	}
+	for _, tt := range tests {
+		var got []ServicePrefix
+		for _, prefix := range ServicePrefixes(services, tt.namespace) {
+			got = append(got, *prefix)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("ServicePrefixes(%q) = %v, want %v", tt.namespace, got, tt.want)
+		}
+	}
+}
+
+func TestListServices_NotSupported(t *testing.T) {
+	if _, err := ListServices(context.Background(), nil, ""); !errors.Is(err, ErrServiceListNotSupported) {
+		t.Errorf("ListServices() error = %v, want %v", err, ErrServiceListNotSupported)
+	}
+}
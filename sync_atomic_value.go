@@ -13,6 +13,7 @@ type syncValue[T any] interface {
 
 // SyncAtomicValue is a thread-safe value holder.
 type SyncAtomicValue[T syncValue[V], V any] struct {
+	ValueHooks[V]
 	val T
 }
 
@@ -27,12 +28,10 @@ func (v *SyncAtomicValue[T, V]) Value() V {
 }
 
 // SetValue sets the value to the given value.
-func (v *SyncAtomicValue[T, V]) SetValue(_ string, val any) error {
-	vl, err := gocast.TryCast[V](val)
-	if err == nil {
-		v.val.Store(vl)
-	}
-	return err
+func (v *SyncAtomicValue[T, V]) SetValue(key string, val any) error {
+	return v.update(key, val, func(val any) (V, error) {
+		return gocast.TryCast[V](val)
+	}, v.Value, v.val.Store)
 }
 
 var _ Valuer[any] = (*SyncAtomicValue[*atomic.Value, any])(nil)
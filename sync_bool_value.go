@@ -0,0 +1,62 @@
+package cloudregistry
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/demdxx/gocast/v2"
+)
+
+// SyncBoolValue is a thread-safe bool value holder.
+// Strings are parsed with strconv.ParseBool, numbers are true if not zero.
+type SyncBoolValue struct {
+	ValueHooks[bool]
+	val atomic.Bool
+}
+
+// NewSyncBoolValue creates a new SyncBoolValue with the given value.
+func NewSyncBoolValue(val bool) *SyncBoolValue {
+	v := &SyncBoolValue{}
+	v.val.Store(val)
+	return v
+}
+
+// Value returns the value.
+func (v *SyncBoolValue) Value() bool {
+	return v.val.Load()
+}
+
+// SetValue sets the value to the given value.
+func (v *SyncBoolValue) SetValue(key string, val any) error {
+	return v.update(key, val, parseBool, v.Value, v.val.Store)
+}
+
+func parseBool(val any) (bool, error) {
+	switch bval := val.(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return bval, nil
+	case string:
+		return parseBoolString(bval)
+	case []byte:
+		return parseBoolString(string(bval))
+	}
+	nval, err := gocast.TryNumber[float64](val)
+	if err != nil {
+		return false, fmt.Errorf("invalid bool value %v: %w", val, err)
+	}
+	return nval != 0, nil
+}
+
+func parseBoolString(s string) (bool, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(s)
+}
+
+var _ Valuer[bool] = (*SyncBoolValue)(nil)
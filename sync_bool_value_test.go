@@ -0,0 +1,36 @@
+package cloudregistry
+
+import "testing"
+
+func TestSyncBoolValue_SetValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		init     bool
+		setValue any
+		want     bool
+		wantErr  bool
+	}{
+		{name: "set bool", setValue: true, want: true},
+		{name: "set string true", setValue: "true", want: true},
+		{name: "set string with spaces", setValue: " 1 ", want: true},
+		{name: "set string false", init: true, setValue: "false", want: false},
+		{name: "set bytes", setValue: []byte("T"), want: true},
+		{name: "set number", setValue: float64(1), want: true},
+		{name: "set zero", init: true, setValue: 0, want: false},
+		{name: "set empty string", init: true, setValue: "", want: false},
+		{name: "set nil", init: true, setValue: nil, want: false},
+		{name: "set invalid string", init: true, setValue: "enabled", want: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewSyncBoolValue(tt.init)
+			err := v.SetValue("", tt.setValue)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SyncBoolValue.SetValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := v.Value(); got != tt.want {
+				t.Errorf("SyncBoolValue.Value() after SetValue = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
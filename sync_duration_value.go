@@ -0,0 +1,69 @@
+package cloudregistry
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/demdxx/gocast/v2"
+)
+
+// SyncDurationValue is a thread-safe time.Duration value holder.
+// Strings are parsed with time.ParseDuration, like "5s" or "1m30s".
+// Numbers, including the numeric strings, are seconds as the registry values like "30" usually are.
+type SyncDurationValue struct {
+	ValueHooks[time.Duration]
+	val atomic.Int64
+}
+
+// NewSyncDurationValue creates a new SyncDurationValue with the given value.
+func NewSyncDurationValue(val time.Duration) *SyncDurationValue {
+	v := &SyncDurationValue{}
+	v.val.Store(int64(val))
+	return v
+}
+
+// Value returns the value.
+func (v *SyncDurationValue) Value() time.Duration {
+	return time.Duration(v.val.Load())
+}
+
+// SetValue sets the value to the given value.
+func (v *SyncDurationValue) SetValue(key string, val any) error {
+	return v.update(key, val, parseDuration, v.Value, func(val time.Duration) {
+		v.val.Store(int64(val))
+	})
+}
+
+func parseDuration(val any) (time.Duration, error) {
+	switch dval := val.(type) {
+	case nil:
+		return 0, nil
+	case time.Duration:
+		return dval, nil
+	case string:
+		return parseDurationString(dval)
+	case []byte:
+		return parseDurationString(string(dval))
+	}
+	seconds, err := gocast.TryNumber[float64](val)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration value %v: %w", val, err)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+func parseDurationString(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	if seconds, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	return time.ParseDuration(s)
+}
+
+var _ Valuer[time.Duration] = (*SyncDurationValue)(nil)
@@ -0,0 +1,37 @@
+package cloudregistry
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSyncDurationValue_SetValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		init     time.Duration
+		setValue any
+		want     time.Duration
+		wantErr  bool
+	}{
+		{name: "set duration", setValue: time.Minute, want: time.Minute},
+		{name: "set string", setValue: "5s", want: 5 * time.Second},
+		{name: "set compound string", setValue: "1m30s", want: 90 * time.Second},
+		{name: "set bytes", setValue: []byte("250ms"), want: 250 * time.Millisecond},
+		{name: "set numeric string", setValue: "30", want: 30 * time.Second},
+		{name: "set number", setValue: float64(1.5), want: 1500 * time.Millisecond},
+		{name: "set nil", init: time.Second, setValue: nil, want: 0},
+		{name: "set invalid string", init: time.Second, setValue: "soon", want: time.Second, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewSyncDurationValue(tt.init)
+			err := v.SetValue("", tt.setValue)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SyncDurationValue.SetValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := v.Value(); got != tt.want {
+				t.Errorf("SyncDurationValue.Value() after SetValue = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package cloudregistry
+
+import (
+	"math"
+	"sync/atomic"
+
+	"github.com/demdxx/gocast/v2"
+)
+
+// SyncFloat64Value is a thread-safe float64 value holder.
+type SyncFloat64Value struct {
+	ValueHooks[float64]
+	bits atomic.Uint64
+}
+
+// NewSyncFloat64Value creates a new SyncFloat64Value with the given value.
+func NewSyncFloat64Value(val float64) *SyncFloat64Value {
+	v := &SyncFloat64Value{}
+	v.bits.Store(math.Float64bits(val))
+	return v
+}
+
+// Value returns the value.
+func (v *SyncFloat64Value) Value() float64 {
+	return math.Float64frombits(v.bits.Load())
+}
+
+// SetValue sets the value to the given value.
+func (v *SyncFloat64Value) SetValue(key string, val any) error {
+	return v.update(key, val, gocast.TryNumber[float64], v.Value, func(val float64) {
+		v.bits.Store(math.Float64bits(val))
+	})
+}
+
+var _ Valuer[float64] = (*SyncFloat64Value)(nil)
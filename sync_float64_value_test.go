@@ -0,0 +1,31 @@
+package cloudregistry
+
+import "testing"
+
+func TestSyncFloat64Value_SetValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		init     float64
+		setValue any
+		want     float64
+		wantErr  bool
+	}{
+		{name: "set float64", setValue: 0.25, want: 0.25},
+		{name: "set int", setValue: 3, want: 3},
+		{name: "set string", setValue: "-1.5", want: -1.5},
+		{name: "set nil", init: 2, setValue: nil, want: 0},
+		{name: "set invalid string", init: 2, setValue: "half", want: 2, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewSyncFloat64Value(tt.init)
+			err := v.SetValue("", tt.setValue)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SyncFloat64Value.SetValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := v.Value(); got != tt.want {
+				t.Errorf("SyncFloat64Value.Value() after SetValue = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
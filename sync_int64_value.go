@@ -8,6 +8,7 @@ import (
 
 // SyncInt64Value is a thread-safe int64 value holder.
 type SyncInt64Value struct {
+	ValueHooks[int64]
 	val int64
 }
 
@@ -22,12 +23,10 @@ func (v *SyncInt64Value) Value() int64 {
 }
 
 // SetValue sets the value to the given value.
-func (v *SyncInt64Value) SetValue(_ string, val any) error {
-	nval, err := gocast.TryNumber[int64](val)
-	if err == nil {
-		atomic.StoreInt64(&v.val, nval)
-	}
-	return err
+func (v *SyncInt64Value) SetValue(key string, val any) error {
+	return v.update(key, val, gocast.TryNumber[int64], v.Value, func(val int64) {
+		atomic.StoreInt64(&v.val, val)
+	})
 }
 
 var _ Valuer[int64] = (*SyncInt64Value)(nil)
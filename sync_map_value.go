@@ -0,0 +1,98 @@
+package cloudregistry
+
+import (
+	"maps"
+	"reflect"
+	"strings"
+	"sync/atomic"
+
+	"github.com/demdxx/gocast/v2"
+)
+
+// SyncMapValue is a thread-safe holder of the values under a prefix, designed for SubscribeValueWithPrefix.
+// Every delivered key is one entry of the map with the prefix trimmed from the key,
+// the nil value removes the entry. Reads are lock-free, the returned map is shared and must not be modified.
+//
+// The subscriptions deliver the keys relative to the prefix of the value client, so the prefix
+// is the one passed to SubscribeValueWithPrefix, not including the prefix of the client.
+//
+// The hooks apply to the whole map: the validator checks the next map, the listeners receive
+// the old and new maps and the default map provides the entries of the deleted and unparsable keys.
+//
+//	limits := cloudregistry.NewSyncMapValue[int]("limits/")
+//	limits.SetDefault(map[string]int{"rps": 100})
+//	err := registry.Values(ctx, "app/").SubscribeValueWithPrefix(ctx, "limits/", limits)
+//	rps, ok := limits.Get("rps")
+type SyncMapValue[T any] struct {
+	ValueHooks[map[string]T]
+	prefix string
+	val    atomic.Pointer[map[string]T]
+}
+
+// NewSyncMapValue creates a new empty SyncMapValue trimming the prefix from the keys.
+func NewSyncMapValue[T any](prefix string) *SyncMapValue[T] {
+	v := &SyncMapValue[T]{prefix: prefix}
+	v.val.Store(&map[string]T{})
+	return v
+}
+
+// Value returns all entries.
+func (v *SyncMapValue[T]) Value() map[string]T {
+	return *v.val.Load()
+}
+
+// Get returns the entry of the sub-key.
+func (v *SyncMapValue[T]) Get(key string) (T, bool) {
+	val, ok := (*v.val.Load())[key]
+	return val, ok
+}
+
+// SetValue sets the entry of the key, the nil value removes it or restores its default.
+// The unparsable value keeps the previous entry unless the key has the default.
+func (v *SyncMapValue[T]) SetValue(key string, val any) error {
+	h := &v.ValueHooks
+	h.mx.Lock()
+	defer h.mx.Unlock()
+
+	if err := checkEncrypted(key, val); err != nil {
+		h.reportError(key, err)
+		return err
+	}
+	name := strings.TrimPrefix(key, v.prefix)
+	def, hasDef := h.def[name]
+
+	old := v.Value()
+	next := maps.Clone(old)
+	var err error
+	if val == nil {
+		if hasDef {
+			next[name] = def
+		} else {
+			delete(next, name)
+		}
+	} else if elem, perr := gocast.TryCast[T](val); perr != nil {
+		h.reportError(key, perr)
+		if !hasDef {
+			return perr
+		}
+		next[name], err = def, perr
+	} else {
+		next[name] = elem
+	}
+	if err == nil && h.validator != nil {
+		if err := h.validator(next); err != nil {
+			h.reportError(key, err)
+			return err
+		}
+	}
+
+	v.val.Store(&next)
+	if len(h.listeners) > 0 && !reflect.DeepEqual(old, next) {
+		for _, listener := range h.listeners {
+			listener(old, next)
+		}
+	}
+	return err
+}
+
+var _ Valuer[map[string]any] = (*SyncMapValue[any])(nil)
@@ -0,0 +1,101 @@
+package cloudregistry
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"testing"
+)
+
+func TestSyncMapValue_SetValue(t *testing.T) {
+	v := NewSyncMapValue[int]("app/limits/")
+	for key, val := range map[string]any{"app/limits/rps": "100", "app/limits/burst": float64(20)} {
+		if err := v.SetValue(key, val); err != nil {
+			t.Fatalf("SyncMapValue.SetValue(%s) error = %v", key, err)
+		}
+	}
+	snapshot := v.Value()
+	if want := map[string]int{"rps": 100, "burst": 20}; !maps.Equal(snapshot, want) {
+		t.Errorf("SyncMapValue.Value() = %v, want %v", snapshot, want)
+	}
+	if rps, ok := v.Get("rps"); !ok || rps != 100 {
+		t.Errorf("SyncMapValue.Get(rps) = %v, %v", rps, ok)
+	}
+
+	if err := v.SetValue("app/limits/rps", "many"); err == nil {
+		t.Error("SyncMapValue.SetValue() of invalid value error = nil")
+	}
+	if err := v.SetValue("app/limits/burst", nil); err != nil {
+		t.Fatalf("SyncMapValue.SetValue(nil) error = %v", err)
+	}
+	if want := map[string]int{"rps": 100}; !maps.Equal(v.Value(), want) {
+		t.Errorf("SyncMapValue.Value() after delete = %v, want %v", v.Value(), want)
+	}
+	if len(snapshot) != 2 {
+		t.Errorf("the previous snapshot was modified: %v", snapshot)
+	}
+}
+
+func TestSyncMapValue_DecodedValues(t *testing.T) {
+	v := NewSyncMapValue[bool]("flags/")
+	setter := DecodeValueSetter(DefaultCodec, v)
+	for _, kv := range [][2]string{{"flags/a", "true"}, {"flags/b", "false"}} {
+		if err := setter(kv[0], []byte(kv[1])); err != nil {
+			t.Fatalf("SetValue(%s) error = %v", kv[0], err)
+		}
+	}
+	if want := map[string]bool{"a": true, "b": false}; !maps.Equal(v.Value(), want) {
+		t.Errorf("SyncMapValue.Value() = %v, want %v", v.Value(), want)
+	}
+}
+
+func TestSyncMapValue_PrefixedClient(t *testing.T) {
+	ctx := context.Background()
+	values := newMapValues(map[string]string{}).Values(ctx, "app/")
+	v := NewSyncMapValue[int]("limits/")
+	if err := values.SubscribeValueWithPrefix(ctx, "limits/", v); err != nil {
+		t.Fatalf("SubscribeValueWithPrefix() error = %v", err)
+	}
+	_ = values.SetValue(ctx, "limits/rps", "100")
+	_ = values.SetValue(ctx, "limits/burst/max", "20")
+	if want := map[string]int{"rps": 100, "burst/max": 20}; !maps.Equal(v.Value(), want) {
+		t.Errorf("SyncMapValue.Value() = %v, want %v", v.Value(), want)
+	}
+}
+
+func TestSyncMapValue_Hooks(t *testing.T) {
+	errTooHigh := errors.New("too high")
+	v := NewSyncMapValue[int]("limits/")
+	v.SetDefault(map[string]int{"rps": 100})
+	v.SetValidator(func(val map[string]int) error {
+		for _, limit := range val {
+			if limit > 1000 {
+				return errTooHigh
+			}
+		}
+		return nil
+	})
+	var changes []map[string]int
+	v.OnChange(func(old, new map[string]int) { changes = append(changes, new) })
+
+	_ = v.SetValue("limits/rps", "10")
+	_ = v.SetValue("limits/burst", "20")
+	if err := v.SetValue("limits/burst", "5000"); !errors.Is(err, errTooHigh) {
+		t.Errorf("SetValue() of invalid value error = %v, want %v", err, errTooHigh)
+	}
+	if err := v.SetValue("limits/burst", "many"); err == nil {
+		t.Error("SetValue() of unparsable value without default error = nil")
+	}
+	if want := map[string]int{"rps": 10, "burst": 20}; !maps.Equal(v.Value(), want) {
+		t.Errorf("SyncMapValue.Value() after rejected updates = %v, want %v", v.Value(), want)
+	}
+
+	_ = v.SetValue("limits/rps", nil)
+	_ = v.SetValue("limits/burst", nil)
+	if want := map[string]int{"rps": 100}; !maps.Equal(v.Value(), want) {
+		t.Errorf("SyncMapValue.Value() after delete = %v, want %v", v.Value(), want)
+	}
+	if len(changes) != 4 {
+		t.Errorf("OnChange calls = %d, want 4", len(changes))
+	}
+}
@@ -0,0 +1,95 @@
+package cloudregistry
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/demdxx/gocast/v2"
+)
+
+// SyncSliceValue is a thread-safe slice value holder.
+// Strings are parsed as JSON arrays if they start with '[', otherwise as comma separated values,
+// the elements are converted to T. The returned slice is shared and must not be modified.
+type SyncSliceValue[T any] struct {
+	ValueHooks[[]T]
+	val atomic.Pointer[[]T]
+}
+
+// NewSyncSliceValue creates a new SyncSliceValue with the given values.
+func NewSyncSliceValue[T any](vals ...T) *SyncSliceValue[T] {
+	v := &SyncSliceValue[T]{}
+	v.val.Store(&vals)
+	return v
+}
+
+// Value returns the value.
+func (v *SyncSliceValue[T]) Value() []T {
+	if val := v.val.Load(); val != nil {
+		return *val
+	}
+	return nil
+}
+
+// SetValue sets the value to the given value.
+func (v *SyncSliceValue[T]) SetValue(key string, val any) error {
+	return v.update(key, val, parseSlice[T], v.Value, func(val []T) {
+		v.val.Store(&val)
+	})
+}
+
+func parseSlice[T any](val any) ([]T, error) {
+	switch sval := val.(type) {
+	case nil:
+		return nil, nil
+	case []T:
+		return append([]T(nil), sval...), nil
+	case []any:
+		return castSlice[T](sval)
+	case string:
+		return parseSliceString[T](sval)
+	case []byte:
+		return parseSliceString[T](string(sval))
+	}
+	// The single value is the slice of one element
+	elem, err := gocast.TryCast[T](val)
+	if err != nil {
+		return nil, err
+	}
+	return []T{elem}, nil
+}
+
+func parseSliceString[T any](s string) ([]T, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return []T{}, nil
+	}
+	if strings.HasPrefix(s, "[") {
+		var vals []any
+		if err := json.Unmarshal([]byte(s), &vals); err != nil {
+			return nil, fmt.Errorf("invalid JSON array: %w", err)
+		}
+		return castSlice[T](vals)
+	}
+	parts := strings.Split(s, ",")
+	vals := make([]any, 0, len(parts))
+	for _, part := range parts {
+		vals = append(vals, strings.TrimSpace(part))
+	}
+	return castSlice[T](vals)
+}
+
+func castSlice[T any](vals []any) ([]T, error) {
+	result := make([]T, 0, len(vals))
+	for i, val := range vals {
+		elem, err := gocast.TryCast[T](val)
+		if err != nil {
+			return nil, fmt.Errorf("invalid element #%d: %w", i, err)
+		}
+		result = append(result, elem)
+	}
+	return result, nil
+}
+
+var _ Valuer[[]string] = (*SyncSliceValue[string])(nil)
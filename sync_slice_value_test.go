@@ -0,0 +1,53 @@
+package cloudregistry
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSyncSliceValue_SetValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		setValue any
+		want     []string
+		wantErr  bool
+	}{
+		{name: "set slice", setValue: []string{"a", "b"}, want: []string{"a", "b"}},
+		{name: "set decoded JSON", setValue: []any{"a", "b"}, want: []string{"a", "b"}},
+		{name: "set JSON string", setValue: `["a", "b,c"]`, want: []string{"a", "b,c"}},
+		{name: "set CSV", setValue: "a, b ,c", want: []string{"a", "b", "c"}},
+		{name: "set bytes", setValue: []byte("a,b"), want: []string{"a", "b"}},
+		{name: "set single value", setValue: "a", want: []string{"a"}},
+		{name: "set empty string", setValue: "", want: []string{}},
+		{name: "set nil", setValue: nil, want: nil},
+		{name: "set invalid JSON", setValue: `["a"`, want: []string{"init"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewSyncSliceValue("init")
+			err := v.SetValue("", tt.setValue)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SyncSliceValue.SetValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := v.Value(); !slices.Equal(got, tt.want) {
+				t.Errorf("SyncSliceValue.Value() after SetValue = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyncSliceValue_Numbers(t *testing.T) {
+	v := NewSyncSliceValue[int]()
+	if err := v.SetValue("", "1, 2, 3"); err != nil {
+		t.Fatalf("SyncSliceValue.SetValue() error = %v", err)
+	}
+	if got := v.Value(); !slices.Equal(got, []int{1, 2, 3}) {
+		t.Errorf("SyncSliceValue.Value() = %v, want [1 2 3]", got)
+	}
+	if err := v.SetValue("", "1,two"); err == nil {
+		t.Error("SyncSliceValue.SetValue() of invalid element error = nil")
+	}
+	if got := v.Value(); !slices.Equal(got, []int{1, 2, 3}) {
+		t.Errorf("SyncSliceValue.Value() after failed SetValue = %v, want [1 2 3]", got)
+	}
+}
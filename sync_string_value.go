@@ -0,0 +1,37 @@
+package cloudregistry
+
+import (
+	"sync/atomic"
+
+	"github.com/demdxx/gocast/v2"
+)
+
+// SyncStringValue is a thread-safe string value holder.
+type SyncStringValue struct {
+	ValueHooks[string]
+	val atomic.Pointer[string]
+}
+
+// NewSyncStringValue creates a new SyncStringValue with the given value.
+func NewSyncStringValue(val string) *SyncStringValue {
+	v := &SyncStringValue{}
+	v.val.Store(&val)
+	return v
+}
+
+// Value returns the value.
+func (v *SyncStringValue) Value() string {
+	if val := v.val.Load(); val != nil {
+		return *val
+	}
+	return ""
+}
+
+// SetValue sets the value to the given value.
+func (v *SyncStringValue) SetValue(key string, val any) error {
+	return v.update(key, val, gocast.TryStr, v.Value, func(val string) {
+		v.val.Store(&val)
+	})
+}
+
+var _ Valuer[string] = (*SyncStringValue)(nil)
@@ -0,0 +1,33 @@
+package cloudregistry
+
+import "testing"
+
+func TestSyncStringValue_SetValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		init     string
+		setValue any
+		want     string
+	}{
+		{name: "set string", setValue: "blue", want: "blue"},
+		{name: "set bytes", setValue: []byte("green"), want: "green"},
+		{name: "set number", setValue: 42, want: "42"},
+		{name: "set nil", init: "red", setValue: nil, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewSyncStringValue(tt.init)
+			if err := v.SetValue("", tt.setValue); err != nil {
+				t.Errorf("SyncStringValue.SetValue() error = %v", err)
+			}
+			if got := v.Value(); got != tt.want {
+				t.Errorf("SyncStringValue.Value() after SetValue = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	var zero SyncStringValue
+	if got := zero.Value(); got != "" {
+		t.Errorf("SyncStringValue.Value() of zero value = %q", got)
+	}
+}
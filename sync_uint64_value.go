@@ -8,6 +8,7 @@ import (
 
 // SyncUInt64Value is a thread-safe uint64 value holder.
 type SyncUInt64Value struct {
+	ValueHooks[uint64]
 	val uint64
 }
 
@@ -22,12 +23,10 @@ func (v *SyncUInt64Value) Value() uint64 {
 }
 
 // SetValue sets the value to the given value.
-func (v *SyncUInt64Value) SetValue(_ string, val any) error {
-	nval, err := gocast.TryNumber[uint64](val)
-	if err == nil {
-		atomic.StoreUint64(&v.val, nval)
-	}
-	return err
+func (v *SyncUInt64Value) SetValue(key string, val any) error {
+	return v.update(key, val, gocast.TryNumber[uint64], v.Value, func(val uint64) {
+		atomic.StoreUint64(&v.val, val)
+	})
 }
 
 var _ Valuer[uint64] = (*SyncUInt64Value)(nil)
@@ -8,6 +8,7 @@ import (
 
 // SyncValue is a thread-safe value holder.
 type SyncValue[T any] struct {
+	ValueHooks[T]
 	mx  sync.RWMutex
 	val T
 }
@@ -24,12 +25,15 @@ func (v *SyncValue[T]) Value() T {
 	return v.val
 }
 
-// SetValue sets the value to the given value.
-func (v *SyncValue[T]) SetValue(_ string, val any) (err error) {
-	v.mx.Lock()
-	defer v.mx.Unlock()
-	v.val, err = gocast.TryCast[T](val)
-	return err
+// SetValue sets the value to the given value, the value is kept if it can't be cast to T.
+func (v *SyncValue[T]) SetValue(key string, val any) error {
+	return v.update(key, val, func(val any) (T, error) {
+		return gocast.TryCast[T](val)
+	}, v.Value, func(val T) {
+		v.mx.Lock()
+		defer v.mx.Unlock()
+		v.val = val
+	})
 }
 
 var _ Valuer[any] = (*SyncValue[any])(nil)
@@ -0,0 +1,24 @@
+module github.com/demdxx/cloudregistry/tracing
+
+go 1.23.0
+
+toolchain go1.24.4
+
+replace github.com/demdxx/cloudregistry => ../
+
+require (
+	github.com/demdxx/cloudregistry v0.0.0-00010101000000-000000000000
+	go.opentelemetry.io/otel v1.34.0
+	go.opentelemetry.io/otel/sdk v1.34.0
+	go.opentelemetry.io/otel/trace v1.34.0
+)
+
+require (
+	github.com/demdxx/gocast/v2 v2.10.1 // indirect
+	github.com/go-logr/logr v1.4.2 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	go.opentelemetry.io/auto/sdk v1.1.0 // indirect
+	go.opentelemetry.io/otel/metric v1.34.0 // indirect
+	golang.org/x/sys v0.29.0 // indirect
+)
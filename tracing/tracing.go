@@ -0,0 +1,349 @@
+// Package tracing implements the OpenTelemetry tracing decorators of the registry and value clients.
+//
+// Every call starts the span with the backend, service, key, result count and error type attributes.
+// The value notifications are delivered in the spans which are children of the subscription span,
+// the setters implementing ContextValueSetter receive the context of the notification span.
+//
+// Example:
+//
+//	registry = tracing.NewRegistry(registry, tracing.WithTracerProvider(provider))
+package tracing
+
+import (
+	"context"
+	"errors"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+const instrumentationName = "github.com/demdxx/cloudregistry/tracing"
+
+// The span attribute keys.
+const (
+	BackendKey          = attribute.Key("cloudregistry.backend")
+	ServiceNameKey      = attribute.Key("cloudregistry.service.name")
+	ServiceNamespaceKey = attribute.Key("cloudregistry.service.namespace")
+	ServicePartitionKey = attribute.Key("cloudregistry.service.partition")
+	InstanceIDKey       = attribute.Key("cloudregistry.service.instance_id")
+	PrefixKey           = attribute.Key("cloudregistry.prefix")
+	KeyKey              = attribute.Key("cloudregistry.key")
+	ResultCountKey      = attribute.Key("cloudregistry.result_count")
+	QueryKey            = attribute.Key("cloudregistry.query")
+	HealthStatusKey     = attribute.Key("cloudregistry.health.status")
+	ErrorTypeKey        = attribute.Key("error.type")
+)
+
+// ContextValueSetter is implemented by the value setters which receive the context
+// of the notification span, it is used instead of the SetValue method.
+type ContextValueSetter interface {
+	SetValueContext(ctx context.Context, key string, value any) error
+}
+
+// Option is a configuration option for the tracing decorators.
+type Option func(conf *config)
+
+// WithTracerProvider sets the tracer provider, the global one is used by default.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(conf *config) {
+		conf.provider = provider
+	}
+}
+
+// WithBackend sets the backend attribute value,
+// the package name of the wrapped registry like "etcd" is used by default.
+func WithBackend(backend string) Option {
+	return func(conf *config) {
+		conf.backend = backend
+	}
+}
+
+type config struct {
+	provider trace.TracerProvider
+	backend  string
+	tracer   trace.Tracer
+}
+
+func newConfig(client any, options []Option) *config {
+	conf := &config{}
+	for _, option := range options {
+		option(conf)
+	}
+	if conf.provider == nil {
+		conf.provider = otel.GetTracerProvider()
+	}
+	if conf.backend == "" {
+		conf.backend = backendName(client)
+	}
+	conf.tracer = conf.provider.Tracer(instrumentationName)
+	return conf
+}
+
+// backendName returns the package name of the client type.
+func backendName(client any) string {
+	tp := reflect.TypeOf(client)
+	for tp != nil && tp.Kind() == reflect.Pointer {
+		tp = tp.Elem()
+	}
+	if tp == nil || tp.PkgPath() == "" {
+		return "unknown"
+	}
+	return path.Base(tp.PkgPath())
+}
+
+func (c *config) start(ctx context.Context, operation string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return c.tracer.Start(ctx, "cloudregistry."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(append(attrs, BackendKey.String(c.backend))...))
+}
+
+// end records the error and ends the span. The ErrNotFound is the regular result,
+// so it is classified but does not mark the span as failed.
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.SetAttributes(ErrorTypeKey.String(ErrorType(err)))
+		if !errors.Is(err, cloudregistry.ErrNotFound) {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}
+	span.End()
+}
+
+// ErrorType classifies the error for the error.type attribute.
+func ErrorType(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, cloudregistry.ErrNotFound):
+		return "not_found"
+	case errors.Is(err, cloudregistry.ErrNotReady):
+		return "not_ready"
+	case errors.Is(err, cloudregistry.ErrAlreadyExists):
+		return "already_exists"
+	case errors.Is(err, cloudregistry.ErrConflict):
+		return "conflict"
+	case errors.Is(err, cloudregistry.ErrUnavailable):
+		return "unavailable"
+	case errors.Is(err, cloudregistry.ErrPermissionDenied):
+		return "permission_denied"
+	case errors.Is(err, cloudregistry.ErrClosed):
+		return "closed"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, cloudregistry.ErrTimeout):
+		return "timeout"
+	}
+	return "error"
+}
+
+func serviceAttributes(name, namespace, partition string) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{ServiceNameKey.String(name)}
+	if namespace != "" {
+		attrs = append(attrs, ServiceNamespaceKey.String(namespace))
+	}
+	if partition != "" {
+		attrs = append(attrs, ServicePartitionKey.String(partition))
+	}
+	return attrs
+}
+
+func serviceIDAttributes(id *cloudregistry.ServiceID) []attribute.KeyValue {
+	return append(serviceAttributes(id.Name, id.Namespace, id.Partition), InstanceIDKey.String(id.InstanceID))
+}
+
+// Registry is the tracing decorator of the registry.
+// The service watches, locks and elections live longer than a call, so they are passed
+// to the wrapped registry without the spans.
+type Registry struct {
+	valueClient
+	cloudregistry.Passthrough
+	registry cloudregistry.Registry
+}
+
+// NewRegistry wraps the registry with the tracing.
+func NewRegistry(registry cloudregistry.Registry, options ...Option) *Registry {
+	return &Registry{
+		valueClient: valueClient{client: registry, conf: newConfig(registry, options)},
+		Passthrough: cloudregistry.NewPassthrough(registry),
+		registry:    registry,
+	}
+}
+
+// Register registers the service in the wrapped registry.
+func (r *Registry) Register(ctx context.Context, service *cloudregistry.Service) (err error) {
+	ctx, span := r.conf.start(ctx, "Register", serviceIDAttributes(service.ID())...)
+	defer func() { end(span, err) }()
+	return r.registry.Register(ctx, service)
+}
+
+// Deregister deregisters the service in the wrapped registry.
+func (r *Registry) Deregister(ctx context.Context, id *cloudregistry.ServiceID) (err error) {
+	ctx, span := r.conf.start(ctx, "Deregister", serviceIDAttributes(id)...)
+	defer func() { end(span, err) }()
+	return r.registry.Deregister(ctx, id)
+}
+
+// Discover discovers the services in the wrapped registry.
+func (r *Registry) Discover(ctx context.Context, prefix *cloudregistry.ServicePrefix, TTL time.Duration) ([]*cloudregistry.ServiceInfo, error) {
+	return r.DiscoverWithQuery(ctx, prefix, TTL, nil)
+}
+
+// DiscoverWithQuery discovers the services matching the query in the wrapped registry,
+// the query is added to the span attributes.
+func (r *Registry) DiscoverWithQuery(ctx context.Context, prefix *cloudregistry.ServicePrefix, TTL time.Duration, query *cloudregistry.DiscoverQuery) (services []*cloudregistry.ServiceInfo, err error) {
+	attrs := serviceAttributes(prefix.Name, prefix.Namespace, prefix.Partition)
+	if query != nil {
+		attrs = append(attrs, QueryKey.String(query.String()))
+	}
+	ctx, span := r.conf.start(ctx, "Discover", attrs...)
+	defer func() {
+		span.SetAttributes(ResultCountKey.Int(len(services)))
+		end(span, err)
+	}()
+	return cloudregistry.DiscoverWithQuery(ctx, r.registry, prefix, TTL, query)
+}
+
+// HealthCheck checks the service in the wrapped registry.
+func (r *Registry) HealthCheck(ctx context.Context, id *cloudregistry.ServiceID, TTL time.Duration) (err error) {
+	ctx, span := r.conf.start(ctx, "HealthCheck", serviceIDAttributes(id)...)
+	defer func() { end(span, err) }()
+	return r.registry.HealthCheck(ctx, id, TTL)
+}
+
+// ReportHealth reports the health status to the wrapped registry, the status is added to the span attributes.
+func (r *Registry) ReportHealth(ctx context.Context, id *cloudregistry.ServiceID, status cloudregistry.HealthStatus, output string) (err error) {
+	attrs := append(serviceIDAttributes(id), HealthStatusKey.String(status.String()))
+	ctx, span := r.conf.start(ctx, "ReportHealth", attrs...)
+	defer func() { end(span, err) }()
+	return cloudregistry.ReportHealth(ctx, r.registry, id, status, output)
+}
+
+// NewValueClient wraps the value client with the tracing.
+func NewValueClient(client cloudregistry.ValueClient, options ...Option) cloudregistry.ValueClient {
+	return &valueClient{client: client, conf: newConfig(client, options)}
+}
+
+type valueClient struct {
+	client cloudregistry.ValueClient
+	conf   *config
+	prefix string
+}
+
+func (c *valueClient) start(ctx context.Context, operation, key string) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{KeyKey.String(key)}
+	if c.prefix != "" {
+		attrs = append(attrs, PrefixKey.String(c.prefix))
+	}
+	return c.conf.start(ctx, operation, attrs...)
+}
+
+// Values returns the ValueClient with the prefix, the prefix is added to the span attributes.
+func (c *valueClient) Values(ctx context.Context, prefix ...string) cloudregistry.ValueClient {
+	return &valueClient{
+		client: c.client.Values(ctx, prefix...),
+		conf:   c.conf,
+		prefix: c.prefix + strings.Join(prefix, ""),
+	}
+}
+
+// Codec returns the codec of the wrapped client.
+func (c *valueClient) Codec() cloudregistry.Codec {
+	return cloudregistry.CodecOf(c.client)
+}
+
+// WithCodec returns the traced ValueClient with the same prefix using the codec.
+func (c *valueClient) WithCodec(codec cloudregistry.Codec) cloudregistry.ValueClient {
+	return &valueClient{client: cloudregistry.WithCodec(c.client, codec), conf: c.conf, prefix: c.prefix}
+}
+
+// Value returns the value from the wrapped client.
+func (c *valueClient) Value(ctx context.Context, name string) (_ string, err error) {
+	ctx, span := c.start(ctx, "Value", name)
+	defer func() { end(span, err) }()
+	return c.client.Value(ctx, name)
+}
+
+// SetValue sets the value in the wrapped client.
+func (c *valueClient) SetValue(ctx context.Context, name, value string) (err error) {
+	ctx, span := c.start(ctx, "SetValue", name)
+	defer func() { end(span, err) }()
+	return c.client.SetValue(ctx, name, value)
+}
+
+// SubscribeValue subscribes to the value, every notification has its own span.
+func (c *valueClient) SubscribeValue(ctx context.Context, name string, val cloudregistry.ValueSetter) (err error) {
+	ctx, span := c.start(ctx, "SubscribeValue", name)
+	defer func() { end(span, err) }()
+	return c.client.SubscribeValue(ctx, name, c.setter(ctx, val))
+}
+
+// SubscribeValueWithPrefix subscribes to the values with the prefix, every notification has its own span.
+func (c *valueClient) SubscribeValueWithPrefix(ctx context.Context, prefix string, val cloudregistry.ValueSetter) (err error) {
+	ctx, span := c.start(ctx, "SubscribeValueWithPrefix", prefix)
+	defer func() { end(span, err) }()
+	return c.client.SubscribeValueWithPrefix(ctx, prefix, c.setter(ctx, val))
+}
+
+// setter wraps the value setter with the notification span,
+// the context carries the subscription span as the parent.
+func (c *valueClient) setter(ctx context.Context, val cloudregistry.ValueSetter) cloudregistry.ValueSetter {
+	return cloudregistry.ValueSetterFunc(func(key string, value any) (err error) {
+		ctx, span := c.start(ctx, "Notify", key)
+		defer func() { end(span, err) }()
+		if setter, ok := val.(ContextValueSetter); ok {
+			return setter.SetValueContext(ctx, key, value)
+		}
+		return val.SetValue(key, value)
+	})
+}
+
+// DeleteValue deletes the value if the wrapped client implements ValueStore.
+func (c *valueClient) DeleteValue(ctx context.Context, name string) (err error) {
+	ctx, span := c.start(ctx, "DeleteValue", name)
+	defer func() { end(span, err) }()
+	return cloudregistry.DeleteValue(ctx, c.client, name)
+}
+
+// DeletePrefix deletes the values with the prefix if the wrapped client implements ValueStore.
+func (c *valueClient) DeletePrefix(ctx context.Context, prefix string) (err error) {
+	ctx, span := c.start(ctx, "DeletePrefix", prefix)
+	defer func() { end(span, err) }()
+	return cloudregistry.DeletePrefix(ctx, c.client, prefix)
+}
+
+// ListValues lists the values with the prefix if the wrapped client implements ValueStore.
+func (c *valueClient) ListValues(ctx context.Context, prefix string) (values []*cloudregistry.KeyValue, err error) {
+	ctx, span := c.start(ctx, "ListValues", prefix)
+	defer func() {
+		span.SetAttributes(ResultCountKey.Int(len(values)))
+		end(span, err)
+	}()
+	return cloudregistry.ListValues(ctx, c.client, prefix)
+}
+
+// CompareAndSwap sets the value if its version matches and the wrapped client implements ValueStore.
+func (c *valueClient) CompareAndSwap(ctx context.Context, name string, version uint64, value string) (_ bool, err error) {
+	ctx, span := c.start(ctx, "CompareAndSwap", name)
+	defer func() { end(span, err) }()
+	return cloudregistry.CompareAndSwap(ctx, c.client, name, version, value)
+}
+
+var (
+	_ cloudregistry.Registry        = (*Registry)(nil)
+	_ cloudregistry.ServiceWatcher  = (*Registry)(nil)
+	_ cloudregistry.QueryDiscoverer = (*Registry)(nil)
+	_ cloudregistry.HealthReporter  = (*Registry)(nil)
+	_ cloudregistry.Locker          = (*Registry)(nil)
+	_ cloudregistry.Elector         = (*Registry)(nil)
+	_ cloudregistry.ValueStore      = (*valueClient)(nil)
+	_ cloudregistry.CodecClient     = (*valueClient)(nil)
+)
@@ -0,0 +1,200 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/demdxx/cloudregistry"
+	"github.com/demdxx/cloudregistry/memory"
+)
+
+func newTestRegistry(t *testing.T) (*Registry, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	registry := NewRegistry(memory.NewRegistry(), WithTracerProvider(provider))
+	t.Cleanup(func() { _ = registry.Close() })
+	return registry, recorder
+}
+
+func spanAttribute(span sdktrace.ReadOnlySpan, key attribute.Key) (attribute.Value, bool) {
+	for _, attr := range span.Attributes() {
+		if attr.Key == key {
+			return attr.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+type contextSetter struct {
+	ctx context.Context
+}
+
+func (s *contextSetter) SetValue(key string, value any) error {
+	return errors.New("SetValueContext must be used")
+}
+
+func (s *contextSetter) SetValueContext(ctx context.Context, key string, value any) error {
+	s.ctx = ctx
+	return nil
+}
+
+func TestRegistry_Spans(t *testing.T) {
+	ctx := context.Background()
+	registry, recorder := newTestRegistry(t)
+
+	service := &cloudregistry.Service{Name: "billing", Namespace: "prod", InstanceID: "1", Hostname: "localhost"}
+	_ = registry.Register(ctx, service)
+	_, _ = registry.Discover(ctx, &cloudregistry.ServicePrefix{Name: "billing", Namespace: "prod"}, 0)
+	_, _ = registry.Values(ctx, "app/").Value(ctx, "missing")
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("spans = %d, want 3", len(spans))
+	}
+	tests := []struct {
+		name  string
+		attrs map[attribute.Key]attribute.Value
+	}{
+		{
+			name: "cloudregistry.Register",
+			attrs: map[attribute.Key]attribute.Value{
+				BackendKey:          attribute.StringValue("memory"),
+				ServiceNameKey:      attribute.StringValue("billing"),
+				ServiceNamespaceKey: attribute.StringValue("prod"),
+				InstanceIDKey:       attribute.StringValue("1"),
+			},
+		},
+		{
+			name: "cloudregistry.Discover",
+			attrs: map[attribute.Key]attribute.Value{
+				ServiceNameKey: attribute.StringValue("billing"),
+				ResultCountKey: attribute.IntValue(1),
+			},
+		},
+		{
+			name: "cloudregistry.Value",
+			attrs: map[attribute.Key]attribute.Value{
+				PrefixKey:    attribute.StringValue("app/"),
+				KeyKey:       attribute.StringValue("missing"),
+				ErrorTypeKey: attribute.StringValue("not_found"),
+			},
+		},
+	}
+	for i, tt := range tests {
+		span := spans[i]
+		if span.Name() != tt.name {
+			t.Errorf("span[%d] name = %q, want %q", i, span.Name(), tt.name)
+			continue
+		}
+		for key, want := range tt.attrs {
+			if got, ok := spanAttribute(span, key); !ok || got != want {
+				t.Errorf("%s attribute %s = %v, want %v", tt.name, key, got.Emit(), want.Emit())
+			}
+		}
+		if span.Status().Code == codes.Error {
+			t.Errorf("%s status is error", tt.name)
+		}
+	}
+}
+
+func TestRegistry_ErrorStatus(t *testing.T) {
+	registry, recorder := newTestRegistry(t)
+	_ = registry.Close()
+
+	if err := registry.SetValue(context.Background(), "key", "value"); err == nil {
+		t.Fatal("SetValue() after Close() expected error")
+	}
+	span := recorder.Ended()[0]
+	if span.Status().Code != codes.Error {
+		t.Errorf("status = %v, want %v", span.Status().Code, codes.Error)
+	}
+	if got, _ := spanAttribute(span, ErrorTypeKey); got.AsString() != "closed" {
+		t.Errorf("error.type = %q, want %q", got.AsString(), "closed")
+	}
+}
+
+func TestRegistry_SubscriptionPropagation(t *testing.T) {
+	ctx := context.Background()
+	registry, recorder := newTestRegistry(t)
+
+	setter := &contextSetter{}
+	if err := registry.SubscribeValue(ctx, "key", setter); err != nil {
+		t.Fatalf("SubscribeValue() error = %v", err)
+	}
+	if err := registry.SetValue(ctx, "key", "value"); err != nil {
+		t.Fatalf("SetValue() error = %v", err)
+	}
+	if setter.ctx == nil {
+		t.Fatal("SetValueContext() is not called")
+	}
+
+	var subscribe, notify sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		switch span.Name() {
+		case "cloudregistry.SubscribeValue":
+			subscribe = span
+		case "cloudregistry.Notify":
+			notify = span
+		}
+	}
+	if subscribe == nil || notify == nil {
+		t.Fatalf("subscription spans are not recorded: %v, %v", subscribe, notify)
+	}
+	if notify.Parent().SpanID() != subscribe.SpanContext().SpanID() {
+		t.Error("the notification span is not a child of the subscription span")
+	}
+	if got := trace.SpanContextFromContext(setter.ctx).SpanID(); got != notify.SpanContext().SpanID() {
+		t.Error("the setter context does not carry the notification span")
+	}
+}
+
+func TestRegistry_Codec(t *testing.T) {
+	ctx := context.Background()
+	registry, recorder := newTestRegistry(t)
+	values := cloudregistry.WithCodec(registry.Values(ctx, "app/"), cloudregistry.StringCodec)
+	if codec := cloudregistry.CodecOf(values); codec != cloudregistry.StringCodec {
+		t.Errorf("CodecOf() = %v, want %v", codec, cloudregistry.StringCodec)
+	}
+	if err := values.SetValue(ctx, "port", "8080"); err != nil {
+		t.Fatalf("SetValue() error = %v", err)
+	}
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("ended spans = %d, want 1", len(spans))
+	}
+	if got, _ := spanAttribute(spans[0], PrefixKey); got.AsString() != "app/" {
+		t.Errorf("prefix = %q, want app/", got.AsString())
+	}
+}
+
+func TestErrorType(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{err: nil, want: ""},
+		{err: cloudregistry.ErrNotFound, want: "not_found"},
+		{err: cloudregistry.ErrNotReady, want: "not_ready"},
+		{err: context.Canceled, want: "canceled"},
+		{err: cloudregistry.ErrAlreadyExists, want: "already_exists"},
+		{err: cloudregistry.ErrConflict, want: "conflict"},
+		{err: cloudregistry.NewError(cloudregistry.ErrUnavailable, errors.New("connection refused")), want: "unavailable"},
+		{err: cloudregistry.ErrPermissionDenied, want: "permission_denied"},
+		{err: cloudregistry.ErrClosed, want: "closed"},
+		{err: context.DeadlineExceeded, want: "timeout"},
+		{err: cloudregistry.ErrTimeout, want: "timeout"},
+		{err: errors.New("failed"), want: "error"},
+	}
+	for _, tt := range tests {
+		if got := ErrorType(tt.err); got != tt.want {
+			t.Errorf("ErrorType(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package cloudregistry
+
+import (
+	"reflect"
+	"sync"
+)
+
+// ValueHooks holds the change listeners, the validator and the default value of a value holder,
+// the zero value has no hooks. The holder updates are serialized and the listeners are called
+// synchronously in the update order, so they must not set the value of the same holder.
+//
+//	timeout := cloudregistry.NewSyncDurationValue(5 * time.Second)
+//	timeout.SetDefault(5 * time.Second)
+//	timeout.SetValidator(func(d time.Duration) error {
+//		if d <= 0 {
+//			return errors.New("timeout must be positive")
+//		}
+//		return nil
+//	})
+//	timeout.OnChange(func(old, new time.Duration) { log.Printf("timeout %s -> %s", old, new) })
+//	timeout.OnError(func(key string, err error) { log.Printf("invalid %s: %v", key, err) })
+type ValueHooks[T any] struct {
+	mx         sync.Mutex
+	listeners  []func(old, new T)
+	validator  func(val T) error
+	errHandler func(key string, err error)
+	def        T
+	hasDef     bool
+}
+
+// OnChange adds the listener called after the value changes.
+func (h *ValueHooks[T]) OnChange(fn func(old, new T)) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	h.listeners = append(h.listeners, fn)
+}
+
+// SetValidator sets the function rejecting the invalid values, the rejected update keeps the previous value.
+func (h *ValueHooks[T]) SetValidator(fn func(val T) error) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	h.validator = fn
+}
+
+// SetDefault sets the value used when the key is deleted, delivered as nil, or can't be parsed.
+// Without the default the unparsable updates keep the previous value.
+func (h *ValueHooks[T]) SetDefault(val T) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	h.def, h.hasDef = val, true
+}
+
+// OnError sets the callback receiving the rejected and unparsable updates.
+func (h *ValueHooks[T]) OnError(fn func(key string, err error)) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	h.errHandler = fn
+}
+
+// update parses and validates the value, stores it and notifies the listeners if it changed.
+// The parse or validation error is returned even if the default value was stored.
+// The encrypted values are reported and keep the previous value.
+func (h *ValueHooks[T]) update(key string, val any, parse func(val any) (T, error), load func() T, store func(val T)) error {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+
+	if err := checkEncrypted(key, val); err != nil {
+		h.reportError(key, err)
+		return err
+	}
+
+	var (
+		nval T
+		err  error
+	)
+	if val == nil && h.hasDef {
+		nval = h.def
+	} else if nval, err = parse(val); err != nil {
+		h.reportError(key, err)
+		if !h.hasDef {
+			return err
+		}
+		nval = h.def
+	} else if h.validator != nil {
+		if err := h.validator(nval); err != nil {
+			h.reportError(key, err)
+			return err
+		}
+	}
+
+	old := load()
+	store(nval)
+	if len(h.listeners) > 0 && !reflect.DeepEqual(old, nval) {
+		for _, listener := range h.listeners {
+			listener(old, nval)
+		}
+	}
+	return err
+}
+
+func (h *ValueHooks[T]) reportError(key string, err error) {
+	if h.errHandler != nil {
+		h.errHandler(key, err)
+	}
+}
@@ -0,0 +1,101 @@
+package cloudregistry
+
+import (
+	"errors"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestValueHooks_OnChange(t *testing.T) {
+	v := NewSyncInt64Value(1)
+	var changes [][2]int64
+	v.OnChange(func(old, new int64) { changes = append(changes, [2]int64{old, new}) })
+
+	for _, val := range []any{"2", 2, "3"} {
+		if err := v.SetValue("limit", val); err != nil {
+			t.Fatalf("SetValue(%v) error = %v", val, err)
+		}
+	}
+	if want := [][2]int64{{1, 2}, {2, 3}}; !slices.Equal(changes, want) {
+		t.Errorf("changes = %v, want %v", changes, want)
+	}
+}
+
+func TestValueHooks_Validator(t *testing.T) {
+	errNegative := errors.New("negative")
+	v := NewSyncDurationValue(time.Second)
+	v.SetValidator(func(d time.Duration) error {
+		if d < 0 {
+			return errNegative
+		}
+		return nil
+	})
+	var rejected []string
+	v.OnError(func(key string, err error) { rejected = append(rejected, key+": "+err.Error()) })
+	changed := false
+	v.OnChange(func(old, new time.Duration) { changed = true })
+
+	if err := v.SetValue("timeout", "-5s"); !errors.Is(err, errNegative) {
+		t.Errorf("SetValue(-5s) error = %v, want %v", err, errNegative)
+	}
+	if got := v.Value(); got != time.Second {
+		t.Errorf("Value() after rejected update = %v, want %v", got, time.Second)
+	}
+	if changed {
+		t.Error("OnChange called for the rejected update")
+	}
+	if want := []string{"timeout: negative"}; !slices.Equal(rejected, want) {
+		t.Errorf("OnError calls = %v, want %v", rejected, want)
+	}
+}
+
+func TestValueHooks_Default(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   any
+		want    int64
+		wantErr bool
+	}{
+		{name: "deleted", value: nil, want: 10},
+		{name: "unparsable", value: "many", want: 10, wantErr: true},
+		{name: "valid", value: "20", want: 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewSyncInt64Value(5)
+			v.SetDefault(10)
+			var reported error
+			v.OnError(func(_ string, err error) { reported = err })
+
+			err := v.SetValue("rps", tt.value)
+			if (err != nil) != tt.wantErr || (reported != nil) != tt.wantErr {
+				t.Errorf("SetValue() error = %v, reported %v, wantErr %v", err, reported, tt.wantErr)
+			}
+			if got := v.Value(); got != tt.want {
+				t.Errorf("Value() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyncValue_KeepsValueOnCastError(t *testing.T) {
+	v := NewSyncValue(42)
+	if err := v.SetValue("", "not a number"); err == nil {
+		t.Fatal("SetValue() error = nil")
+	}
+	if got := v.Value(); got != 42 {
+		t.Errorf("Value() after cast error = %v, want 42", got)
+	}
+
+	av := NewSyncAtomicValue[*atomic.Value, any](&atomic.Value{})
+	var changes []any
+	av.OnChange(func(old, new any) { changes = append(changes, new) })
+	if err := av.SetValue("", []string{"a"}); err != nil {
+		t.Fatalf("SetValue() error = %v", err)
+	}
+	if len(changes) != 1 {
+		t.Errorf("OnChange calls = %v, want 1", changes)
+	}
+}
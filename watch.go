@@ -0,0 +1,116 @@
+package cloudregistry
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+)
+
+// ErrWatchNotSupported is returned when the registry does not support service watching.
+var ErrWatchNotSupported = errors.New("service watch is not supported")
+
+// ServiceEventType is the type of the service watch event.
+type ServiceEventType int
+
+const (
+	// ServiceSnapshot is the first event of the watch with all current instances.
+	ServiceSnapshot ServiceEventType = iota
+	// ServiceAdded is sent when a new service instance appears.
+	ServiceAdded
+	// ServiceUpdated is sent when a service instance information changes.
+	ServiceUpdated
+	// ServiceRemoved is sent when a service instance disappears.
+	ServiceRemoved
+)
+
+// String returns the string representation of the event type.
+func (t ServiceEventType) String() string {
+	switch t {
+	case ServiceSnapshot:
+		return "snapshot"
+	case ServiceAdded:
+		return "added"
+	case ServiceUpdated:
+		return "updated"
+	case ServiceRemoved:
+		return "removed"
+	}
+	return "unknown"
+}
+
+// ServiceEvent represents a change of the service instances set.
+type ServiceEvent struct {
+	Type ServiceEventType
+	// Service is the changed instance, set for Added, Updated and Removed events.
+	Service *ServiceInfo
+	// Services is the list of all instances, set for the Snapshot event.
+	Services []*ServiceInfo
+}
+
+// ServiceWatcher is an optional interface implemented by registries which can stream service changes.
+// The returned channel starts with a ServiceSnapshot event and is closed when the context is done
+// or the registry is closed.
+type ServiceWatcher interface {
+	WatchServices(ctx context.Context, prefix *ServicePrefix) (<-chan ServiceEvent, error)
+}
+
+// WatchServices starts watching services of the registry if it implements ServiceWatcher.
+func WatchServices(ctx context.Context, registry Registry, prefix *ServicePrefix) (<-chan ServiceEvent, error) {
+	if watcher, ok := registry.(ServiceWatcher); ok {
+		return watcher.WatchServices(ctx, prefix)
+	}
+	return nil, ErrWatchNotSupported
+}
+
+// DiffServices compares two lists of service instances by InstanceID and returns
+// the Added, Updated and Removed events required to turn prev into next.
+// Instances are considered updated if any field except LastUpdate, Health.LastCheck and RawInfo differs.
+func DiffServices(prev, next []*ServiceInfo) []ServiceEvent {
+	var (
+		events  []ServiceEvent
+		prevMap = make(map[string]*ServiceInfo, len(prev))
+		nextMap = make(map[string]struct{}, len(next))
+	)
+	for _, svc := range prev {
+		prevMap[svc.InstanceID] = svc
+	}
+	for _, svc := range next {
+		nextMap[svc.InstanceID] = struct{}{}
+		old, ok := prevMap[svc.InstanceID]
+		switch {
+		case !ok:
+			events = append(events, ServiceEvent{Type: ServiceAdded, Service: svc})
+		case !sameServiceInfo(old, svc):
+			events = append(events, ServiceEvent{Type: ServiceUpdated, Service: svc})
+		}
+	}
+	for _, svc := range prev {
+		if _, ok := nextMap[svc.InstanceID]; !ok {
+			events = append(events, ServiceEvent{Type: ServiceRemoved, Service: svc})
+		}
+	}
+	return events
+}
+
+func sameServiceInfo(a, b *ServiceInfo) bool {
+	return a.Name == b.Name &&
+		a.Namespace == b.Namespace &&
+		a.Partition == b.Partition &&
+		a.InstanceID == b.InstanceID &&
+		a.Hostname == b.Hostname &&
+		a.Port == b.Port &&
+		slices.EqualFunc(a.Public, b.Public, sameHost) &&
+		slices.EqualFunc(a.Private, b.Private, sameHost) &&
+		slices.Equal(a.Tags, b.Tags) &&
+		maps.Equal(a.Meta, b.Meta) &&
+		a.Health.Status == b.Health.Status &&
+		a.Health.Output == b.Health.Output
+}
+
+func sameHost(a, b Host) bool {
+	return a.Hostname == b.Hostname &&
+		a.User == b.User &&
+		a.Password == b.Password &&
+		maps.Equal(a.Ports, b.Ports)
+}
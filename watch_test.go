@@ -0,0 +1,87 @@
+package cloudregistry
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestServiceEventType_String(t *testing.T) {
+	tests := []struct {
+		tp   ServiceEventType
+		want string
+	}{
+		{tp: ServiceSnapshot, want: "snapshot"},
+		{tp: ServiceAdded, want: "added"},
+		{tp: ServiceUpdated, want: "updated"},
+		{tp: ServiceRemoved, want: "removed"},
+		{tp: ServiceEventType(100), want: "unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := tt.tp.String(); got != tt.want {
+				t.Errorf("ServiceEventType.String() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffServices(t *testing.T) {
+	svc1 := &ServiceInfo{Name: "svc", InstanceID: "1", Hostname: "host1", Port: 80}
+	svc2 := &ServiceInfo{Name: "svc", InstanceID: "2", Hostname: "host2", Port: 80}
+	svc2Moved := &ServiceInfo{Name: "svc", InstanceID: "2", Hostname: "host3", Port: 80}
+	svc2Tagged := &ServiceInfo{Name: "svc", InstanceID: "2", Hostname: "host2", Port: 80, Tags: []string{"v2"}}
+	svc3 := &ServiceInfo{Name: "svc", InstanceID: "3", Hostname: "host3", Port: 80}
+
+	tests := []struct {
+		name string
+		prev []*ServiceInfo
+		next []*ServiceInfo
+		want map[string]ServiceEventType
+	}{
+		{
+			name: "no changes",
+			prev: []*ServiceInfo{svc1, svc2},
+			next: []*ServiceInfo{svc2, svc1},
+			want: map[string]ServiceEventType{},
+		},
+		{
+			name: "added from empty",
+			prev: nil,
+			next: []*ServiceInfo{svc1, svc2},
+			want: map[string]ServiceEventType{"1": ServiceAdded, "2": ServiceAdded},
+		},
+		{
+			name: "added, updated and removed",
+			prev: []*ServiceInfo{svc1, svc2},
+			next: []*ServiceInfo{svc2Moved, svc3},
+			want: map[string]ServiceEventType{"1": ServiceRemoved, "2": ServiceUpdated, "3": ServiceAdded},
+		},
+		{
+			name: "tags changed",
+			prev: []*ServiceInfo{svc2},
+			next: []*ServiceInfo{svc2Tagged},
+			want: map[string]ServiceEventType{"2": ServiceUpdated},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := DiffServices(tt.prev, tt.next)
+			if len(events) != len(tt.want) {
+				t.Fatalf("DiffServices() returned %d events, want %d: %v", len(events), len(tt.want), events)
+			}
+			for _, ev := range events {
+				if want, ok := tt.want[ev.Service.InstanceID]; !ok || want != ev.Type {
+					t.Errorf("DiffServices() event %s for %s, want %s", ev.Type, ev.Service.InstanceID, want)
+				}
+			}
+		})
+	}
+}
+
+func TestWatchServices_NotSupported(t *testing.T) {
+	_, err := WatchServices(context.Background(), nil, &ServicePrefix{Name: "svc"})
+	if !errors.Is(err, ErrWatchNotSupported) {
+		t.Errorf("WatchServices() error = %v, want %v", err, ErrWatchNotSupported)
+	}
+}
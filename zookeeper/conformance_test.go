@@ -0,0 +1,27 @@
+package zookeeper
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/demdxx/cloudregistry"
+	"github.com/demdxx/cloudregistry/registrytest"
+)
+
+// TestConformance runs the conformance suite against the server from CLOUDREGISTRY_TEST_ZOOKEEPER_URI,
+// for example zookeeper://localhost:2181/services
+func TestConformance(t *testing.T) {
+	uri := os.Getenv("CLOUDREGISTRY_TEST_ZOOKEEPER_URI")
+	if uri == "" {
+		t.Skip("CLOUDREGISTRY_TEST_ZOOKEEPER_URI is not set")
+	}
+	registrytest.Run(t, func(t *testing.T) (cloudregistry.Registry, func(time.Duration)) {
+		registry, err := cloudregistry.Open(context.Background(), uri)
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		return registry, nil
+	})
+}
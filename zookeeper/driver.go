@@ -0,0 +1,20 @@
+package zookeeper
+
+import (
+	"context"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+func init() {
+	cloudregistry.RegisterDriver("zookeeper", open)
+	cloudregistry.RegisterDriver("zk", open)
+}
+
+func open(ctx context.Context, uri string) (cloudregistry.Registry, error) {
+	registry, err := Connect(ctx, WithURI(uri))
+	if err != nil {
+		return nil, err
+	}
+	return registry, nil
+}
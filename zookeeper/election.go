@@ -0,0 +1,159 @@
+package zookeeper
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"time"
+
+	"github.com/go-zookeeper/zk"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+const candidateNodePrefix = "candidate-"
+
+// NewElection creates the leader election candidate based on the ephemeral sequential nodes:
+// the candidate with the lowest node is the leader and the node data is the leader value.
+func (r *Registry) NewElection(ctx context.Context, prefix *cloudregistry.ServicePrefix) (cloudregistry.Election, error) {
+	if r.conn == nil {
+		return nil, fmt.Errorf("ZooKeeper connection is nil")
+	}
+	return &election{conn: r.conn, path: path.Join(r.prefix, cloudregistry.ElectionKey(prefix))}, nil
+}
+
+type election struct {
+	conn *zk.Conn
+	path string
+	node string
+}
+
+// Campaign waits until the candidate node is the lowest one, the elected candidate updates the leader value.
+func (e *election) Campaign(ctx context.Context, value string) error {
+	if e.node != "" {
+		if _, err := e.conn.Set(e.node, []byte(value), -1); err != nil {
+			return fmt.Errorf("failed to update leader value: %w", wrapError(err))
+		}
+		return nil
+	}
+	if err := ensurePath(e.conn, e.path); err != nil {
+		return fmt.Errorf("failed to create election path: %w", wrapError(err))
+	}
+	node, err := e.conn.Create(path.Join(e.path, candidateNodePrefix), []byte(value),
+		zk.FlagEphemeral|zk.FlagSequence, zk.WorldACL(zk.PermAll))
+	if err != nil {
+		return fmt.Errorf("failed to create candidate node: %w", wrapError(err))
+	}
+	if err := waitLowest(ctx, e.conn, e.path, node, false); err != nil {
+		_ = e.conn.Delete(node, -1)
+		return err
+	}
+	e.node = node
+	return nil
+}
+
+// Resign deletes the candidate node.
+func (e *election) Resign(ctx context.Context) error {
+	if e.node == "" {
+		return cloudregistry.ErrNotLeader
+	}
+	err := e.conn.Delete(e.node, -1)
+	e.node = ""
+	if err != nil && err != zk.ErrNoNode {
+		return fmt.Errorf("failed to delete candidate node: %w", wrapError(err))
+	}
+	return nil
+}
+
+// Leader returns the data of the lowest candidate node.
+func (e *election) Leader(ctx context.Context) (string, error) {
+	for {
+		children, _, err := e.conn.Children(e.path)
+		if err == zk.ErrNoNode || (err == nil && len(children) == 0) {
+			return "", cloudregistry.ErrNotFound
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to list candidate nodes: %w", wrapError(err))
+		}
+		data, _, err := e.conn.Get(path.Join(e.path, slices.Min(children)))
+		if err == zk.ErrNoNode {
+			// The leader has resigned, check the next candidate
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to get leader value: %w", wrapError(err))
+		}
+		return string(data), nil
+	}
+}
+
+// Observe watches the candidate nodes and the data of the lowest one.
+func (e *election) Observe(ctx context.Context) <-chan string {
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		var (
+			leader string
+			first  = true
+		)
+		for {
+			value, events, err := e.watchLeader()
+			if err != nil {
+				if err == zk.ErrClosing || err == zk.ErrConnectionClosed {
+					return
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(time.Second): // Prevent busy waiting
+					continue
+				}
+			}
+			if first || value != leader {
+				leader, first = value, false
+				select {
+				case ch <- value:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-events[0]:
+			case <-events[1]:
+			}
+		}
+	}()
+	return ch
+}
+
+// watchLeader returns the leader value with the watches of the candidate list and the leader data.
+func (e *election) watchLeader() (string, [2]<-chan zk.Event, error) {
+	var events [2]<-chan zk.Event
+	if err := ensurePath(e.conn, e.path); err != nil {
+		return "", events, err
+	}
+	for {
+		children, _, childEvents, err := e.conn.ChildrenW(e.path)
+		if err != nil {
+			return "", events, err
+		}
+		events[0] = childEvents
+		if len(children) == 0 {
+			return "", events, nil
+		}
+		data, _, dataEvents, err := e.conn.GetW(path.Join(e.path, slices.Min(children)))
+		if err == zk.ErrNoNode {
+			continue
+		}
+		if err != nil {
+			return "", events, err
+		}
+		events[1] = dataEvents
+		return string(data), events, nil
+	}
+}
+
+var _ cloudregistry.Elector = (*Registry)(nil)
@@ -0,0 +1,39 @@
+package zookeeper
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-zookeeper/zk"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+// errorKinds maps the ZooKeeper errors onto the registry error kinds.
+var errorKinds = []struct{ err, kind error }{
+	{err: zk.ErrNoNode, kind: cloudregistry.ErrNotFound},
+	{err: zk.ErrNodeExists, kind: cloudregistry.ErrAlreadyExists},
+	{err: zk.ErrBadVersion, kind: cloudregistry.ErrConflict},
+	{err: zk.ErrNotEmpty, kind: cloudregistry.ErrConflict},
+	{err: zk.ErrNoAuth, kind: cloudregistry.ErrPermissionDenied},
+	{err: zk.ErrAuthFailed, kind: cloudregistry.ErrPermissionDenied},
+	{err: zk.ErrClosing, kind: cloudregistry.ErrClosed},
+	{err: zk.ErrConnectionClosed, kind: cloudregistry.ErrUnavailable},
+	{err: zk.ErrNoServer, kind: cloudregistry.ErrUnavailable},
+	{err: zk.ErrSessionExpired, kind: cloudregistry.ErrUnavailable},
+	{err: zk.ErrSessionMoved, kind: cloudregistry.ErrUnavailable},
+	{err: context.DeadlineExceeded, kind: cloudregistry.ErrTimeout},
+}
+
+// wrapError maps the ZooKeeper errors onto the registry error kinds, the original error stays wrapped.
+func wrapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	for _, it := range errorKinds {
+		if errors.Is(err, it.err) {
+			return cloudregistry.NewError(it.kind, err)
+		}
+	}
+	return err
+}
@@ -0,0 +1,46 @@
+package zookeeper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-zookeeper/zk"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+func TestWrapError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want error
+	}{
+		{err: zk.ErrNoNode, want: cloudregistry.ErrNotFound},
+		{err: zk.ErrNodeExists, want: cloudregistry.ErrAlreadyExists},
+		{err: zk.ErrBadVersion, want: cloudregistry.ErrConflict},
+		{err: zk.ErrNoAuth, want: cloudregistry.ErrPermissionDenied},
+		{err: zk.ErrClosing, want: cloudregistry.ErrClosed},
+		{err: zk.ErrSessionExpired, want: cloudregistry.ErrUnavailable},
+		{err: fmt.Errorf("get: %w", zk.ErrConnectionClosed), want: cloudregistry.ErrUnavailable},
+		{err: context.DeadlineExceeded, want: cloudregistry.ErrTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			got := wrapError(tt.err)
+			if !errors.Is(got, tt.want) {
+				t.Errorf("wrapError() = %v, want %v", got, tt.want)
+			}
+			if !errors.Is(got, tt.err) {
+				t.Errorf("wrapError() = %v does not wrap %v", got, tt.err)
+			}
+		})
+	}
+
+	if err := wrapError(nil); err != nil {
+		t.Errorf("wrapError(nil) = %v", err)
+	}
+	if err := errors.New("unknown"); wrapError(err) != err {
+		t.Errorf("wrapError() changed the unmapped error")
+	}
+}
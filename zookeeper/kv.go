@@ -0,0 +1,134 @@
+package zookeeper
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/go-zookeeper/zk"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+// DeleteValue deletes the value node, the nodes with children are not deleted.
+func (r *Registry) DeleteValue(ctx context.Context, name string) error {
+	if r.conn == nil {
+		return fmt.Errorf("ZooKeeper connection is nil")
+	}
+	err := r.conn.Delete(path.Join(r.prefix, name), -1)
+	if err != nil && err != zk.ErrNoNode {
+		return fmt.Errorf("failed to delete value: %w", wrapError(err))
+	}
+	return nil
+}
+
+// DeletePrefix deletes the prefix node with the whole subtree.
+func (r *Registry) DeletePrefix(ctx context.Context, prefix string) error {
+	if r.conn == nil {
+		return fmt.Errorf("ZooKeeper connection is nil")
+	}
+	if err := r.deleteTree(path.Join(r.prefix, prefix)); err != nil {
+		return fmt.Errorf("failed to delete prefix: %w", wrapError(err))
+	}
+	return nil
+}
+
+// ListValues returns the values of the prefix node and its subtree,
+// the intermediate nodes without data are skipped. The version is the node version plus one.
+func (r *Registry) ListValues(ctx context.Context, prefix string) ([]*cloudregistry.KeyValue, error) {
+	if r.conn == nil {
+		return nil, fmt.Errorf("ZooKeeper connection is nil")
+	}
+	var values []*cloudregistry.KeyValue
+	if err := r.listTree(path.Join(r.prefix, prefix), &values); err != nil {
+		return nil, fmt.Errorf("failed to list values: %w", wrapError(err))
+	}
+	slices.SortFunc(values, func(a, b *cloudregistry.KeyValue) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	return values, nil
+}
+
+// CompareAndSwap sets the value if the node version matches,
+// the zero version creates the node only if it does not exist.
+func (r *Registry) CompareAndSwap(ctx context.Context, name string, version uint64, value string) (bool, error) {
+	if r.conn == nil {
+		return false, fmt.Errorf("ZooKeeper connection is nil")
+	}
+	fullPath := path.Join(r.prefix, name)
+
+	var err error
+	if version == 0 {
+		if err = ensurePath(r.conn, path.Dir(fullPath)); err != nil {
+			return false, fmt.Errorf("failed to create parent path: %w", wrapError(err))
+		}
+		_, err = r.conn.Create(fullPath, []byte(value), 0, zk.WorldACL(zk.PermAll))
+	} else {
+		_, err = r.conn.Set(fullPath, []byte(value), int32(version-1))
+	}
+
+	switch err {
+	case nil:
+		return true, nil
+	case zk.ErrNodeExists, zk.ErrBadVersion, zk.ErrNoNode:
+		return false, nil
+	}
+	return false, fmt.Errorf("failed to compare and swap value: %w", wrapError(err))
+}
+
+func (r *Registry) deleteTree(nodePath string) error {
+	children, _, err := r.conn.Children(nodePath)
+	if err == zk.ErrNoNode {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if err := r.deleteTree(path.Join(nodePath, child)); err != nil {
+			return err
+		}
+	}
+	if err := r.conn.Delete(nodePath, -1); err != nil && err != zk.ErrNoNode {
+		return err
+	}
+	return nil
+}
+
+func (r *Registry) listTree(nodePath string, values *[]*cloudregistry.KeyValue) error {
+	data, stat, err := r.conn.Get(nodePath)
+	if err == zk.ErrNoNode {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(data) > 0 || stat.NumChildren == 0 {
+		*values = append(*values, &cloudregistry.KeyValue{
+			Key:     strings.TrimPrefix(strings.TrimPrefix(nodePath, r.prefix), "/"),
+			Value:   string(data),
+			Version: uint64(stat.Version) + 1,
+		})
+	}
+	if stat.NumChildren == 0 {
+		return nil
+	}
+
+	children, _, err := r.conn.Children(nodePath)
+	if err == zk.ErrNoNode {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if err := r.listTree(path.Join(nodePath, child), values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var _ cloudregistry.ValueStore = (*Registry)(nil)
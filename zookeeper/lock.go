@@ -0,0 +1,133 @@
+package zookeeper
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+
+	"github.com/go-zookeeper/zk"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+const lockNodePrefix = "lock-"
+
+// NewMutex creates the distributed mutex based on the ZooKeeper lock recipe:
+// every contender creates the ephemeral sequential node under the lock path,
+// the owner of the lowest node holds the lock and others watch their predecessors.
+func (r *Registry) NewMutex(ctx context.Context, name string) (cloudregistry.Mutex, error) {
+	if r.conn == nil {
+		return nil, fmt.Errorf("ZooKeeper connection is nil")
+	}
+	lost := make(chan struct{})
+	close(lost)
+	return &mutex{conn: r.conn, path: path.Join(r.prefix, "locks", name), lost: lost}, nil
+}
+
+type mutex struct {
+	conn *zk.Conn
+	path string
+	node string
+	lost chan struct{}
+}
+
+// Lock acquires the lock, waiting until it is released or the context is done.
+func (m *mutex) Lock(ctx context.Context) error {
+	return m.acquire(ctx, false)
+}
+
+// TryLock acquires the lock if it is free, otherwise returns ErrLocked.
+func (m *mutex) TryLock(ctx context.Context) error {
+	return m.acquire(ctx, true)
+}
+
+func (m *mutex) acquire(ctx context.Context, try bool) error {
+	if m.node != "" {
+		return cloudregistry.ErrLocked
+	}
+	if err := ensurePath(m.conn, m.path); err != nil {
+		return fmt.Errorf("failed to create lock path: %w", wrapError(err))
+	}
+	node, err := m.conn.Create(path.Join(m.path, lockNodePrefix), nil,
+		zk.FlagEphemeral|zk.FlagSequence, zk.WorldACL(zk.PermAll))
+	if err != nil {
+		return fmt.Errorf("failed to create lock node: %w", wrapError(err))
+	}
+	if err := waitLowest(ctx, m.conn, m.path, node, try); err != nil {
+		_ = m.conn.Delete(node, -1)
+		return err
+	}
+	m.node, m.lost = node, make(chan struct{})
+	go m.watchNode(node, m.lost)
+	return nil
+}
+
+// waitLowest blocks until the node is the lowest one in the directory,
+// the try mode returns ErrLocked instead of waiting for the predecessors.
+func waitLowest(ctx context.Context, conn *zk.Conn, dir, node string, try bool) error {
+	for {
+		children, _, err := conn.Children(dir)
+		if err != nil {
+			return fmt.Errorf("failed to list nodes: %w", wrapError(err))
+		}
+		slices.Sort(children)
+		idx := slices.Index(children, path.Base(node))
+		switch {
+		case idx < 0:
+			return fmt.Errorf("node %s is lost", node)
+		case idx == 0:
+			return nil
+		case try:
+			return cloudregistry.ErrLocked
+		}
+		exists, _, events, err := conn.ExistsW(path.Join(dir, children[idx-1]))
+		if err != nil {
+			return fmt.Errorf("failed to watch predecessor node: %w", wrapError(err))
+		}
+		if !exists {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-events:
+		}
+	}
+}
+
+// watchNode closes the lost channel when the lock node is deleted
+// or the watch is dropped because of the session expiration.
+func (m *mutex) watchNode(node string, lost chan struct{}) {
+	defer close(lost)
+	for {
+		exists, _, events, err := m.conn.ExistsW(node)
+		if err != nil || !exists {
+			return
+		}
+		event := <-events
+		if event.Type == zk.EventNodeDeleted || event.Type == zk.EventNotWatching {
+			return
+		}
+	}
+}
+
+// Unlock releases the lock by deleting the lock node.
+func (m *mutex) Unlock(ctx context.Context) error {
+	if m.node == "" {
+		return cloudregistry.ErrNotLocked
+	}
+	err := m.conn.Delete(m.node, -1)
+	m.node = ""
+	if err != nil && err != zk.ErrNoNode {
+		return fmt.Errorf("failed to delete lock node: %w", wrapError(err))
+	}
+	return nil
+}
+
+// Lost returns the channel closed when the lock node is deleted or the session is expired.
+func (m *mutex) Lost() <-chan struct{} {
+	return m.lost
+}
+
+var _ cloudregistry.Locker = (*Registry)(nil)
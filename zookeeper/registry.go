@@ -4,7 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
 	"path"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -29,20 +31,31 @@ type zkConfig struct {
 // valueWatcherWrapper wraps a ValueSetter with watch parameters.
 type valueWatcherWrapper struct {
 	value    cloudregistry.ValueSetter
+	codec    cloudregistry.Codec
+	prefix   string // the client prefix trimmed from the delivered keys
 	path     string
 	isPrefix bool
+	// exists is set when the single watched node exists, its removal is delivered as the nil value
+	exists bool
+
+	// The prefix watch state: ZooKeeper watches are one-shot and not recursive,
+	// so every node under the prefix has its own watches, armed is the set of the active ones.
+	mx      sync.Mutex
+	armed   map[string]bool
+	last    map[string]string
+	changes chan struct{}
 }
 
 // Registry is the ZooKeeper registry implementation.
 type Registry struct {
-	watcherWg   sync.WaitGroup
-	watcherOnce sync.Once
-	done        chan struct{}
-
-	conn     *zk.Conn
-	prefix   string
-	watchers chan *valueWatcherWrapper
-	parent   *Registry
+	watcherWg sync.WaitGroup
+	closeOnce sync.Once
+	done      chan struct{}
+
+	conn   *zk.Conn
+	prefix string
+	codec  cloudregistry.Codec
+	parent *Registry
 }
 
 // Connect connects to the ZooKeeper cloud registry.
@@ -59,13 +72,13 @@ func Connect(ctx context.Context, options ...Option) (*Registry, error) {
 
 	conn, _, err := zk.Connect(conf.hosts, conf.sessionTimeout)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to ZooKeeper: %w", err)
+		return nil, fmt.Errorf("failed to connect to ZooKeeper: %w", wrapError(err))
 	}
 
 	// Ensure base path exists
 	if err := ensurePath(conn, conf.basePath); err != nil {
 		conn.Close()
-		return nil, fmt.Errorf("failed to create base path: %w", err)
+		return nil, fmt.Errorf("failed to create base path: %w", wrapError(err))
 	}
 
 	return NewRegistry(conn, conf.basePath), nil
@@ -77,10 +90,10 @@ func NewRegistry(conn *zk.Conn, basePath string) *Registry {
 		basePath = defaultBasePath
 	}
 	return &Registry{
-		conn:     conn,
-		prefix:   basePath,
-		done:     make(chan struct{}, 1),
-		watchers: make(chan *valueWatcherWrapper, 100),
+		conn:   conn,
+		prefix: basePath,
+		done:   make(chan struct{}),
+		codec:  cloudregistry.DefaultCodec,
 	}
 }
 
@@ -104,6 +117,7 @@ func (r *Registry) Register(ctx context.Context, service *cloudregistry.Service)
 		Private:    service.Private,
 		Tags:       service.Tags,
 		Meta:       service.Meta,
+		Health:     cloudregistry.Health{Status: cloudregistry.HealthPassing, LastCheck: time.Now()},
 		LastUpdate: time.Now(),
 	}
 
@@ -116,13 +130,13 @@ func (r *Registry) Register(ctx context.Context, service *cloudregistry.Service)
 	// Ensure parent path exists
 	parentPath := path.Dir(servicePath)
 	if err := ensurePath(r.conn, parentPath); err != nil {
-		return fmt.Errorf("failed to create parent path: %w", err)
+		return fmt.Errorf("failed to create parent path: %w", wrapError(err))
 	}
 
 	// Create ephemeral sequential node for the service instance
 	actualPath, err := r.conn.Create(servicePath, data, zk.FlagEphemeral, zk.WorldACL(zk.PermAll))
 	if err != nil {
-		return fmt.Errorf("failed to register service: %w", err)
+		return fmt.Errorf("failed to register service: %w", wrapError(err))
 	}
 
 	// Start health check routine if TTL is specified
@@ -138,38 +152,14 @@ func (r *Registry) Deregister(ctx context.Context, id *cloudregistry.ServiceID)
 	if r.conn == nil {
 		return fmt.Errorf("ZooKeeper connection is nil")
 	}
-	servicePath := r.buildServicePath(id)
+	instancePath := r.buildServicePath(id)
 
-	// List all instances of this service
-	children, _, err := r.conn.Children(servicePath)
-	if err != nil {
+	if err := r.conn.Delete(instancePath, -1); err != nil {
 		if err == zk.ErrNoNode {
 			return nil // Already deregistered
 		}
-		return fmt.Errorf("failed to list service instances: %w", err)
-	}
-
-	// Find and delete the instance
-	for _, child := range children {
-		instancePath := path.Join(servicePath, child)
-		data, _, err := r.conn.Get(instancePath)
-		if err != nil {
-			continue
-		}
-
-		var serviceInfo cloudregistry.ServiceInfo
-		if err := json.Unmarshal(data, &serviceInfo); err != nil {
-			continue
-		}
-
-		if serviceInfo.InstanceID == id.InstanceID {
-			if err := r.conn.Delete(instancePath, -1); err != nil {
-				return fmt.Errorf("failed to deregister service: %w", err)
-			}
-			return nil
-		}
+		return fmt.Errorf("failed to deregister service: %w", wrapError(err))
 	}
-
 	return nil
 }
 
@@ -178,36 +168,18 @@ func (r *Registry) Discover(ctx context.Context, prefix *cloudregistry.ServicePr
 	if r.conn == nil {
 		return nil, fmt.Errorf("ZooKeeper connection is nil")
 	}
-	servicePath := r.buildServicePrefixPath(prefix)
+	// ZooKeeper rejects paths with the trailing slash
+	servicePath := strings.TrimSuffix(r.buildServicePrefixPath(prefix), "/")
 
 	children, _, err := r.conn.Children(servicePath)
 	if err != nil {
 		if err == zk.ErrNoNode {
 			return nil, cloudregistry.ErrNotFound
 		}
-		return nil, fmt.Errorf("failed to discover services: %w", err)
+		return nil, fmt.Errorf("failed to discover services: %w", wrapError(err))
 	}
 
-	var services []*cloudregistry.ServiceInfo
-	for _, child := range children {
-		instancePath := path.Join(servicePath, child)
-		data, _, err := r.conn.Get(instancePath)
-		if err != nil {
-			continue
-		}
-
-		var serviceInfo cloudregistry.ServiceInfo
-		if err := json.Unmarshal(data, &serviceInfo); err != nil {
-			continue
-		}
-
-		// Check if service is still alive (not older than TTL)
-		if TTL > 0 && time.Since(serviceInfo.LastUpdate) > TTL {
-			continue
-		}
-
-		services = append(services, &serviceInfo)
-	}
+	services := r.readServices(servicePath, children, TTL)
 
 	if len(services) == 0 {
 		return nil, cloudregistry.ErrNotFound
@@ -216,51 +188,78 @@ func (r *Registry) Discover(ctx context.Context, prefix *cloudregistry.ServicePr
 	return services, nil
 }
 
+// ListServices returns the services with the registered instances in the namespace,
+// the instance nodes are found by walking the services tree.
+func (r *Registry) ListServices(ctx context.Context, namespace string) ([]*cloudregistry.ServicePrefix, error) {
+	if r.conn == nil {
+		return nil, fmt.Errorf("ZooKeeper connection is nil")
+	}
+	services, err := r.walkServices(ctx, path.Join(r.prefix, "services", namespace))
+	if err != nil && err != zk.ErrNoNode {
+		return nil, fmt.Errorf("failed to list services: %w", wrapError(err))
+	}
+	return cloudregistry.ServicePrefixes(services, namespace), nil
+}
+
 // HealthCheck checks the health of a service in the ZooKeeper cloud registry.
 func (r *Registry) HealthCheck(ctx context.Context, id *cloudregistry.ServiceID, TTL time.Duration) error {
 	if r.conn == nil {
 		return fmt.Errorf("ZooKeeper connection is nil")
 	}
-	servicePath := r.buildServicePath(id)
+	if err := r.touchService(r.buildServicePath(id)); err != nil {
+		if err == zk.ErrNoNode {
+			return cloudregistry.ErrNotFound
+		}
+		return fmt.Errorf("failed to update service health: %w", wrapError(err))
+	}
+	return nil
+}
 
-	children, _, err := r.conn.Children(servicePath)
+// ReportHealth stores the health status in the instance node and refreshes its LastUpdate timestamp.
+func (r *Registry) ReportHealth(ctx context.Context, id *cloudregistry.ServiceID, status cloudregistry.HealthStatus, output string) error {
+	if r.conn == nil {
+		return fmt.Errorf("ZooKeeper connection is nil")
+	}
+	err := r.updateService(r.buildServicePath(id), func(serviceInfo *cloudregistry.ServiceInfo) {
+		serviceInfo.Health.Status, serviceInfo.Health.Output = status, output
+	})
 	if err != nil {
 		if err == zk.ErrNoNode {
 			return cloudregistry.ErrNotFound
 		}
-		return fmt.Errorf("failed to check service health: %w", err)
+		return fmt.Errorf("failed to report service health: %w", wrapError(err))
 	}
+	return nil
+}
 
-	// Find the instance and update its timestamp
-	for _, child := range children {
-		instancePath := path.Join(servicePath, child)
-		data, stat, err := r.conn.Get(instancePath)
-		if err != nil {
-			continue
-		}
+// touchService updates the LastUpdate timestamp of the instance node, the health status is kept.
+func (r *Registry) touchService(instancePath string) error {
+	return r.updateService(instancePath, func(*cloudregistry.ServiceInfo) {})
+}
 
-		var serviceInfo cloudregistry.ServiceInfo
-		if err := json.Unmarshal(data, &serviceInfo); err != nil {
-			continue
-		}
+// updateService updates the instance node with the LastUpdate and the last check timestamps.
+// The node is set with its read version, so the concurrent updates are not lost.
+func (r *Registry) updateService(instancePath string, update func(serviceInfo *cloudregistry.ServiceInfo)) error {
+	data, stat, err := r.conn.Get(instancePath)
+	if err != nil {
+		return err
+	}
 
-		if serviceInfo.InstanceID == id.InstanceID {
-			// Update LastUpdate timestamp
-			serviceInfo.LastUpdate = time.Now()
-			newData, err := json.Marshal(serviceInfo)
-			if err != nil {
-				return fmt.Errorf("failed to marshal service info: %w", err)
-			}
+	var serviceInfo cloudregistry.ServiceInfo
+	if err := json.Unmarshal(data, &serviceInfo); err != nil {
+		return fmt.Errorf("failed to unmarshal service info: %w", err)
+	}
 
-			_, err = r.conn.Set(instancePath, newData, stat.Version)
-			if err != nil {
-				return fmt.Errorf("failed to update service health: %w", err)
-			}
-			return nil
-		}
+	update(&serviceInfo)
+	serviceInfo.LastUpdate = time.Now()
+	serviceInfo.Health.LastCheck = serviceInfo.LastUpdate
+	newData, err := json.Marshal(serviceInfo)
+	if err != nil {
+		return fmt.Errorf("failed to marshal service info: %w", err)
 	}
 
-	return cloudregistry.ErrNotFound
+	_, err = r.conn.Set(instancePath, newData, stat.Version)
+	return err
 }
 
 // Values returns a ValueClient to interact with the cloud registry.
@@ -271,11 +270,27 @@ func (r *Registry) Values(ctx context.Context, prefix ...string) cloudregistry.V
 	}
 
 	return &Registry{
-		conn:     r.conn,
-		prefix:   newPrefix,
-		done:     r.done,
-		watchers: r.watchers,
-		parent:   r,
+		conn:   r.conn,
+		prefix: newPrefix,
+		done:   r.done,
+		codec:  r.codec,
+		parent: r,
+	}
+}
+
+// Codec returns the codec decoding the subscription values.
+func (r *Registry) Codec() cloudregistry.Codec {
+	return r.codec
+}
+
+// WithCodec returns a ValueClient with the same prefix using the codec.
+func (r *Registry) WithCodec(codec cloudregistry.Codec) cloudregistry.ValueClient {
+	return &Registry{
+		conn:   r.conn,
+		prefix: r.prefix,
+		done:   r.done,
+		codec:  codec,
+		parent: r,
 	}
 }
 
@@ -290,7 +305,7 @@ func (r *Registry) Value(ctx context.Context, name string) (string, error) {
 		if err == zk.ErrNoNode {
 			return "", cloudregistry.ErrNotFound
 		}
-		return "", fmt.Errorf("failed to get value: %w", err)
+		return "", fmt.Errorf("failed to get value: %w", wrapError(err))
 	}
 	return string(data), nil
 }
@@ -304,7 +319,7 @@ func (r *Registry) SetValue(ctx context.Context, name, value string) error {
 
 	// Ensure parent path exists
 	if err := ensurePath(r.conn, path.Dir(fullPath)); err != nil {
-		return fmt.Errorf("failed to create parent path: %w", err)
+		return fmt.Errorf("failed to create parent path: %w", wrapError(err))
 	}
 
 	// Try to update existing node first
@@ -315,7 +330,7 @@ func (r *Registry) SetValue(ctx context.Context, name, value string) error {
 	}
 
 	if err != nil {
-		return fmt.Errorf("failed to set value: %w", err)
+		return fmt.Errorf("failed to set value: %w", wrapError(err))
 	}
 	return nil
 }
@@ -327,58 +342,51 @@ func (r *Registry) SubscribeValue(ctx context.Context, name string, val cloudreg
 	}
 	fullPath := path.Join(r.prefix, name)
 
-	r.startWatcherOnce()
-
-	wrapper := &valueWatcherWrapper{
+	r.startWatcher(ctx, &valueWatcherWrapper{
 		value:    val,
+		codec:    r.codec,
+		prefix:   r.prefix,
 		path:     fullPath,
 		isPrefix: false,
-	}
-
-	select {
-	case r.watchers <- wrapper:
-		return nil
-	case <-ctx.Done():
-		return ctx.Err()
-	}
+	})
+	return nil
 }
 
 // SubscribeValueWithPrefix subscribes to values with a prefix in the ZooKeeper cloud registry.
+// The values of all nodes under the prefix are watched, including the nested ones.
 func (r *Registry) SubscribeValueWithPrefix(ctx context.Context, prefix string, val cloudregistry.ValueSetter) error {
 	if r.conn == nil {
 		return fmt.Errorf("ZooKeeper connection is nil")
 	}
 	fullPath := path.Join(r.prefix, prefix)
 
-	r.startWatcherOnce()
-
-	wrapper := &valueWatcherWrapper{
+	r.startWatcher(ctx, &valueWatcherWrapper{
 		value:    val,
+		codec:    r.codec,
+		prefix:   r.prefix,
 		path:     fullPath,
 		isPrefix: true,
-	}
-
-	select {
-	case r.watchers <- wrapper:
-		return nil
-	case <-ctx.Done():
-		return ctx.Err()
-	}
+	})
+	return nil
 }
 
 // Close closes the ZooKeeper connection and stops all watchers.
 func (r *Registry) Close() error {
-	// Signal all watchers to stop
-	close(r.done)
-
-	// Wait for all watchers to finish
-	r.watcherWg.Wait()
-
-	// Close the connection
-	if r.conn != nil {
-		r.conn.Close()
+	if r.parent != nil {
+		return r.parent.Close()
 	}
+	r.closeOnce.Do(func() {
+		// Signal all watchers to stop
+		close(r.done)
+
+		// Wait for all watchers to finish
+		r.watcherWg.Wait()
 
+		// Close the connection
+		if r.conn != nil {
+			r.conn.Close()
+		}
+	})
 	return nil
 }
 
@@ -401,102 +409,291 @@ func (r *Registry) buildServicePrefixPath(prefix *cloudregistry.ServicePrefix) s
 	return result
 }
 
-func (r *Registry) startWatcherOnce() {
-	r.watcherOnce.Do(func() {
-		r.watcherWg.Add(1)
-		go r.watcherRoutine()
-	})
-}
+// readServices reads the service information of the instance nodes,
+// instances older than TTL are skipped if TTL is positive.
+func (r *Registry) readServices(servicePath string, children []string, TTL time.Duration) []*cloudregistry.ServiceInfo {
+	var services []*cloudregistry.ServiceInfo
+	for _, child := range children {
+		instancePath := path.Join(servicePath, child)
+		data, _, err := r.conn.Get(instancePath)
+		if err != nil {
+			continue
+		}
 
-func (r *Registry) watcherRoutine() {
-	defer r.watcherWg.Done()
+		var serviceInfo cloudregistry.ServiceInfo
+		if err := json.Unmarshal(data, &serviceInfo); err != nil {
+			continue
+		}
 
-	watchers := make(map[string]*valueWatcherWrapper)
+		// Check if service is still alive (not older than TTL)
+		if TTL > 0 && time.Since(serviceInfo.LastUpdate) > TTL {
+			continue
+		}
+		// The instances registered before the health status was stored are alive while their node exists,
+		// the LastUpdate age tells when they were checked
+		if serviceInfo.Health.Status == cloudregistry.HealthUnknown {
+			serviceInfo.Health.Status = cloudregistry.HealthPassing
+		}
+		if serviceInfo.Health.LastCheck.IsZero() {
+			serviceInfo.Health.LastCheck = serviceInfo.LastUpdate
+		}
 
-	for {
-		select {
-		case <-r.done:
-			return
-		case wrapper := <-r.watchers:
-			if wrapper == nil {
-				continue
-			}
+		services = append(services, &serviceInfo)
+	}
+	return services
+}
 
-			watchers[wrapper.path] = wrapper
-			go r.watchPath(wrapper)
+// walkServices reads the service information of all instance nodes under the path,
+// the nodes removed during the walk are skipped.
+func (r *Registry) walkServices(ctx context.Context, nodePath string) ([]*cloudregistry.ServiceInfo, error) {
+	children, _, err := r.conn.Children(nodePath)
+	if err != nil {
+		return nil, err
+	}
+	services := r.readServices(nodePath, children, 0)
+	for _, child := range children {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		nested, err := r.walkServices(ctx, path.Join(nodePath, child))
+		if err != nil && err != zk.ErrNoNode {
+			return nil, err
 		}
+		services = append(services, nested...)
 	}
+	return services, nil
 }
 
-func (r *Registry) watchPath(wrapper *valueWatcherWrapper) {
+func (r *Registry) startWatcher(ctx context.Context, wrapper *valueWatcherWrapper) {
+	if r.parent != nil {
+		r.parent.startWatcher(ctx, wrapper)
+		return
+	}
+	r.watcherWg.Add(1)
+	go r.watchPath(ctx, wrapper)
+}
+
+func (r *Registry) watchPath(ctx context.Context, wrapper *valueWatcherWrapper) {
+	defer r.watcherWg.Done()
 	for {
 		select {
+		case <-ctx.Done():
+			return
 		case <-r.done:
 			return
 		default:
-			if wrapper.isPrefix {
-				r.watchPrefix(wrapper)
-			} else {
-				r.watchSingle(wrapper)
+		}
+
+		var err error
+		if wrapper.isPrefix {
+			err = r.watchPrefix(ctx, wrapper)
+		} else {
+			err = r.watchSingle(ctx, wrapper)
+		}
+		if err != nil {
+			if err == zk.ErrClosing || err == zk.ErrConnectionClosed {
+				return
+			}
+			select {
+			case <-ctx.Done():
+			case <-r.done:
+			case <-time.After(time.Second): // Prevent busy waiting
 			}
-			time.Sleep(time.Second) // Prevent busy waiting
 		}
 	}
 }
 
-func (r *Registry) watchSingle(wrapper *valueWatcherWrapper) {
+func (r *Registry) watchSingle(ctx context.Context, wrapper *valueWatcherWrapper) error {
 	data, _, events, err := r.conn.GetW(wrapper.path)
-	if err != nil {
-		if err != zk.ErrNoNode {
-			return
+	if err == zk.ErrNoNode {
+		if wrapper.exists {
+			wrapper.exists = false
+			_ = wrapper.setValue(wrapper.path, nil)
 		}
 		// Node doesn't exist, wait for creation
-		_, _, events, err := r.conn.ExistsW(wrapper.path)
-		if err != nil {
-			return
+		var exists bool
+		exists, _, events, err = r.conn.ExistsW(wrapper.path)
+		if err == nil && exists {
+			return nil // Created in the meantime, read it again
 		}
-		select {
-		case <-r.done:
-			return
-		case <-events:
-			// Node was created, try again
-			return
+	} else if err == nil {
+		// Notify about current value, the nil data is reserved for the removed node
+		if data == nil {
+			data = []byte{}
 		}
+		wrapper.exists = true
+		_ = wrapper.setValue(wrapper.path, data)
+	}
+	if err != nil {
+		return err
 	}
 
-	// Notify about current value
-	wrapper.value.SetValue(wrapper.path, string(data))
-
-	// Wait for changes
+	// Wait for changes, they will be picked up in the next iteration
 	select {
+	case <-ctx.Done():
 	case <-r.done:
-		return
 	case <-events:
-		// Value changed, will be picked up in next iteration
 	}
+	return nil
 }
 
-func (r *Registry) watchPrefix(wrapper *valueWatcherWrapper) {
-	children, _, events, err := r.conn.ChildrenW(wrapper.path)
+// watchPrefix walks all nodes under the prefix, notifies about the changed and removed values
+// and waits for any of the node watches to fire.
+func (r *Registry) watchPrefix(ctx context.Context, wrapper *valueWatcherWrapper) error {
+	wrapper.mx.Lock()
+	if wrapper.armed == nil {
+		wrapper.armed = map[string]bool{}
+		wrapper.changes = make(chan struct{}, 1)
+	}
+	wrapper.mx.Unlock()
+
+	values := map[string]string{}
+	if err := r.walkPrefix(ctx, wrapper, wrapper.path, values); err != nil {
+		return err
+	}
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		if last, ok := wrapper.last[key]; !ok || last != values[key] {
+			_ = wrapper.setValue(key, []byte(values[key]))
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(wrapper.last)) {
+		if _, ok := values[key]; !ok {
+			_ = wrapper.setValue(key, nil)
+		}
+	}
+	wrapper.last = values
+
+	select {
+	case <-ctx.Done():
+	case <-r.done:
+	case <-wrapper.changes:
+	}
+	return nil
+}
+
+func (r *Registry) walkPrefix(ctx context.Context, wrapper *valueWatcherWrapper, nodePath string, values map[string]string) error {
+	children, err := r.childrenW(ctx, wrapper, nodePath)
+	if err == zk.ErrNoNode {
+		if nodePath != wrapper.path {
+			return nil // Removed after the parent listing, the parent watch reports it
+		}
+		// The prefix node doesn't exist, wait for creation
+		key := "exists:" + nodePath
+		if !wrapper.arm(key) {
+			return nil
+		}
+		exists, _, events, err := r.conn.ExistsW(nodePath)
+		if err != nil {
+			wrapper.disarm(key)
+			return err
+		}
+		r.waitWatch(ctx, wrapper, key, events)
+		if exists {
+			wrapper.signal() // Created in the meantime, walk it again
+		}
+		return nil
+	}
 	if err != nil {
-		return
+		return err
 	}
 
-	// Notify about all current children
 	for _, child := range children {
-		childPath := path.Join(wrapper.path, child)
-		data, _, err := r.conn.Get(childPath)
-		if err == nil {
-			wrapper.value.SetValue(childPath, string(data))
+		childPath := path.Join(nodePath, child)
+		data, err := r.dataW(ctx, wrapper, childPath)
+		if err == zk.ErrNoNode {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		values[childPath] = string(data)
+		if err := r.walkPrefix(ctx, wrapper, childPath, values); err != nil {
+			return err
 		}
 	}
+	return nil
+}
+
+// childrenW lists the node children and sets the children watch unless it is already set.
+func (r *Registry) childrenW(ctx context.Context, wrapper *valueWatcherWrapper, nodePath string) ([]string, error) {
+	key := "children:" + nodePath
+	if !wrapper.arm(key) {
+		children, _, err := r.conn.Children(nodePath)
+		return children, err
+	}
+	children, _, events, err := r.conn.ChildrenW(nodePath)
+	if err != nil {
+		wrapper.disarm(key)
+		return nil, err
+	}
+	r.waitWatch(ctx, wrapper, key, events)
+	return children, nil
+}
 
-	// Wait for changes
+// dataW reads the node data and sets the data watch unless it is already set.
+func (r *Registry) dataW(ctx context.Context, wrapper *valueWatcherWrapper, nodePath string) ([]byte, error) {
+	key := "data:" + nodePath
+	if !wrapper.arm(key) {
+		data, _, err := r.conn.Get(nodePath)
+		return data, err
+	}
+	data, _, events, err := r.conn.GetW(nodePath)
+	if err != nil {
+		wrapper.disarm(key)
+		return nil, err
+	}
+	r.waitWatch(ctx, wrapper, key, events)
+	return data, nil
+}
+
+// waitWatch waits for the one-shot watch to fire, disarms it and signals the changes.
+func (r *Registry) waitWatch(ctx context.Context, wrapper *valueWatcherWrapper, key string, events <-chan zk.Event) {
+	r.watcherWg.Add(1)
+	go func() {
+		defer r.watcherWg.Done()
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.done:
+			return
+		case <-events:
+		}
+		wrapper.disarm(key)
+		wrapper.signal()
+	}()
+}
+
+// setValue decodes the node data and delivers it to the subscriber with the key relative to the client prefix.
+func (w *valueWatcherWrapper) setValue(key string, data []byte) error {
+	codec := w.codec
+	if codec == nil {
+		codec = cloudregistry.DefaultCodec
+	}
+	key = strings.TrimPrefix(strings.TrimPrefix(key, w.prefix), "/")
+	return cloudregistry.DecodeValueSetter(codec, w.value)(key, data)
+}
+
+// arm marks the watch as active, returns false if it is already active.
+func (w *valueWatcherWrapper) arm(key string) bool {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	if w.armed[key] {
+		return false
+	}
+	w.armed[key] = true
+	return true
+}
+
+func (w *valueWatcherWrapper) disarm(key string) {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	delete(w.armed, key)
+}
+
+func (w *valueWatcherWrapper) signal() {
 	select {
-	case <-r.done:
-		return
-	case <-events:
-		// Children changed, will be picked up in next iteration
+	case w.changes <- struct{}{}:
+	default:
 	}
 }
 
@@ -511,16 +708,9 @@ func (r *Registry) healthCheckRoutine(ctx context.Context, servicePath string, t
 		case <-r.done:
 			return
 		case <-ticker.C:
-			// Update the node to keep it alive
-			_, stat, err := r.conn.Get(servicePath)
-			if err != nil {
-				return // Node was deleted
-			}
-
-			// Touch the node to update its modification time
-			_, err = r.conn.Set(servicePath, nil, stat.Version)
-			if err != nil {
-				return // Failed to update
+			// Touch the node to keep the service information and refresh LastUpdate
+			if err := r.touchService(servicePath); err != nil {
+				return // Node was deleted or failed to update
 			}
 		}
 	}
@@ -556,3 +746,10 @@ func ensurePath(conn *zk.Conn, zkPath string) error {
 
 	return nil
 }
+
+var (
+	_ cloudregistry.Registry       = (*Registry)(nil)
+	_ cloudregistry.CodecClient    = (*Registry)(nil)
+	_ cloudregistry.HealthReporter = (*Registry)(nil)
+	_ cloudregistry.ServiceLister  = (*Registry)(nil)
+)
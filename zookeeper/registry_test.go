@@ -72,6 +72,22 @@ func TestRegistry_Interface(t *testing.T) {
 	var _ cloudregistry.ValueClient = (*Registry)(nil)
 }
 
+func TestRegistry_Driver(t *testing.T) {
+	drivers := cloudregistry.Drivers()
+	for _, scheme := range []string{"zk", "zookeeper"} {
+		found := false
+		for _, name := range drivers {
+			if name == scheme {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("Driver for scheme '%s' is not registered, got %v", scheme, drivers)
+		}
+	}
+}
+
 func TestZkConfig(t *testing.T) {
 	conf := &zkConfig{
 		hosts:          []string{"localhost:2181"},
@@ -198,6 +214,11 @@ func TestRegistry_ErrorCases(t *testing.T) {
 		t.Error("Discover with nil connection should return error")
 	}
 
+	_, err = registry.ListServices(ctx, "")
+	if err == nil {
+		t.Error("ListServices with nil connection should return error")
+	}
+
 	err = registry.HealthCheck(ctx, serviceID, time.Minute)
 	if err == nil {
 		t.Error("HealthCheck with nil connection should return error")
@@ -0,0 +1,131 @@
+package zookeeper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-zookeeper/zk"
+
+	"github.com/demdxx/cloudregistry"
+)
+
+const (
+	watchRetryMinDelay = 100 * time.Millisecond
+	watchRetryMaxDelay = 10 * time.Second
+)
+
+// WatchServices watches the service instances using ChildrenW on the service path.
+// The first event is a snapshot of the current instances, next events reflect the changes.
+// The failed watch is set again with backoff and the instances are resynced, the channel is closed
+// only when the context is done or the registry is closed.
+func (r *Registry) WatchServices(ctx context.Context, prefix *cloudregistry.ServicePrefix) (<-chan cloudregistry.ServiceEvent, error) {
+	if r.conn == nil {
+		return nil, fmt.Errorf("ZooKeeper connection is nil")
+	}
+	servicePath := strings.TrimSuffix(r.buildServicePrefixPath(prefix), "/")
+
+	services, changes, err := r.servicesW(servicePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch services: %w", wrapError(err))
+	}
+
+	events := make(chan cloudregistry.ServiceEvent, 1)
+	go func() {
+		defer close(events)
+
+		if !r.sendServiceEvent(ctx, events, cloudregistry.ServiceEvent{
+			Type: cloudregistry.ServiceSnapshot, Services: services,
+		}) {
+			return
+		}
+
+		delay := watchRetryMinDelay
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-r.done:
+				return
+			case <-changes:
+			}
+
+			next, nextChanges, err := r.servicesW(servicePath)
+			if err != nil {
+				// The client reconnects after the connection loss, only the closed registry stops the watch
+				if errors.Is(err, zk.ErrClosing) {
+					return
+				}
+				if !r.waitRetry(ctx, &delay) {
+					return
+				}
+				// Retry on the next iteration with the closed channel
+				closedCh := make(chan zk.Event)
+				close(closedCh)
+				changes = closedCh
+				continue
+			}
+			delay = watchRetryMinDelay
+
+			for _, event := range cloudregistry.DiffServices(services, next) {
+				if !r.sendServiceEvent(ctx, events, event) {
+					return
+				}
+			}
+			services, changes = next, nextChanges
+		}
+	}()
+	return events, nil
+}
+
+// servicesW returns the service instances and sets a watch on the service path.
+// If the path does not exist yet, the watch waits for its creation.
+func (r *Registry) servicesW(servicePath string) ([]*cloudregistry.ServiceInfo, <-chan zk.Event, error) {
+	children, _, changes, err := r.conn.ChildrenW(servicePath)
+	if errors.Is(err, zk.ErrNoNode) {
+		exists, _, existsChanges, err := r.conn.ExistsW(servicePath)
+		if err != nil {
+			return nil, nil, err
+		}
+		if exists {
+			// The node was created in between, the next call lists the children
+			return r.servicesW(servicePath)
+		}
+		return nil, existsChanges, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return r.readServices(servicePath, children, 0), changes, nil
+}
+
+// waitRetry waits for the retry delay and doubles it up to the maximum,
+// false is returned if the context is done or the registry is closed.
+func (r *Registry) waitRetry(ctx context.Context, delay *time.Duration) bool {
+	timer := time.NewTimer(*delay)
+	defer timer.Stop()
+	*delay = min(*delay*2, watchRetryMaxDelay)
+	select {
+	case <-ctx.Done():
+		return false
+	case <-r.done:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func (r *Registry) sendServiceEvent(ctx context.Context, events chan<- cloudregistry.ServiceEvent, event cloudregistry.ServiceEvent) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-r.done:
+		return false
+	case events <- event:
+		return true
+	}
+}
+
+var _ cloudregistry.ServiceWatcher = (*Registry)(nil)